@@ -7,11 +7,13 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"time"
 
 	"github.com/google/uuid"
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 
 	"wgnetwork/model"
+	"wgnetwork/pkg/wgmngr"
 )
 
 // deviceCreate handler
@@ -66,6 +68,14 @@ func (api *API) deviceCreate(
 		return b, err
 	}
 
+	n, ok := api.network(request.Network)
+	if !ok {
+		err = errors.New("validation error")
+		b := validateError{"network", "unknown network"}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
 	u, err := model.LoadUser(tx, request.UserUUID)
 	if err != nil {
 		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
@@ -86,16 +96,46 @@ func (api *API) deviceCreate(
 		pk = sk.PublicKey()
 	}
 
-	ipNetwork, err := model.AllocateIP(tx, api.cfg.WgInet, pk)
+	var psk wgtypes.Key
+	if len(request.WGPresharedKey) > 0 {
+		// omit error check, we already validate this field
+		psk, _ = wgtypes.ParseKey(request.WGPresharedKey)
+	} else if request.PresharedKey {
+		psk, err = wgtypes.GenerateKey()
+		if err != nil {
+			return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+		}
+	}
+
+	ipNetwork, err := model.AllocateIP(tx, n.WgInet, pk)
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	host, err := api.hostNets()
 	if err != nil {
 		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
 	}
 
+	routes := parseRoutes(request.Routes)
+	err = model.CheckRoutes(
+		tx, ipNetwork.IP, api.wgIPNets(), host, routes)
+	if err != nil {
+		b := validateError{"routes", err.Error()}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		err = errors.New("validation error")
+		return b, err
+	}
+
 	d := model.Device{
-		IPNetwork:  ipNetwork,
-		PubKey:     pk,
-		Label:      request.Label,
-		WANForward: request.WANForward,
+		IPNetwork:    ipNetwork,
+		PubKey:       pk,
+		PresharedKey: psk,
+		Label:        request.Label,
+		WANForward:   request.WANForward,
+		Routes:       routes,
+		Network:      api.networkKey(n),
+		ExpiresAt:    parseExpiresAt(request.ExpiresAt),
 
 		UserUUID: u.UUID}
 
@@ -112,47 +152,85 @@ func (api *API) deviceCreate(
 		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
 	}
 
+	devices, err := model.LoadDevices(tx)
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	allowedIPs := d.AllowedIPs(
+		n.WgIPNet6,
+		devices.Network(d.Network).Routes(d.IPNetwork.IP))
+
+	endpoints, err := api.networkEndpoints(tx, n)
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		err = fmt.Errorf("can't commit tx: %v", err)
 		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
 	}
 
+	api.notify()
+
 	response := DeviceCreateResponse{
 		UserUUID:   u.UUID,
 		UserName:   u.Name,
 		Label:      d.Label,
 		WANForward: d.WANForward,
+		Disabled:   d.Disabled,
+		ExpiresAt:  d.ExpiresAt,
+		Expired:    d.Expired(time.Now()),
+		Network:    n.Name,
 
 		WgDeviceInet:       d.CIDR().String(),
-		WgDevicePort:       api.cfg.WgPort,
+		WgDevicePort:       n.WgPort,
 		WgDevicePubKey:     pk.String(),
-		WgDeviceAllowedIPs: make([]string, len(d.AllowedIPs())),
+		WgDeviceAllowedIPs: make([]string, len(allowedIPs)),
 
-		WgInet:   api.cfg.WgInet.String(),
-		WgIPNet:  api.cfg.WgIPNet.String(),
-		WgIP:     api.cfg.WgInet.IP.String(),
-		WgPort:   api.cfg.WgPort,
-		WgPubKey: api.cfg.WgPubKey.String(),
+		WgInet:   n.WgInet.String(),
+		WgIPNet:  n.WgIPNet.String(),
+		WgIP:     n.WgInet.IP.String(),
+		WgPort:   n.WgPort,
+		WgPubKey: n.WgManager.PublicKey().String(),
 
-		WanIP: api.cfg.WanIP.String(),
+		WanIP:     api.cfg.WanIP().String(),
+		Endpoints: endpoints,
 	}
+	response.WgDeviceInet6, response.WgIPNet6, response.WgIP6 =
+		deviceInet6(d, n)
 	if sk != [wgtypes.KeyLen]byte{} {
 		response.WgDevicePrivKey = sk.String()
 	}
-	for idx, item := range d.AllowedIPs() {
+	if psk != [wgtypes.KeyLen]byte{} {
+		response.WgDevicePresharedKey = psk.String()
+	}
+	for idx, item := range allowedIPs {
 		response.WgDeviceAllowedIPs[idx] = item.String()
 	}
+	response.WgDeviceRoutes = formatRoutes(d.Routes)
 
 	return response.marshal(), nil
 }
 
 // DeviceCreateRequest model.
 type DeviceCreateRequest struct {
-	UserUUID    string `json:"user_uuid"`
-	Label       string `json:"label"`
-	WANForward  bool   `json:"wan_forward"`
-	WGPublicKey string `json:"wg_public_key"`
+	UserUUID    string   `json:"user_uuid"`
+	Label       string   `json:"label"`
+	WANForward  bool     `json:"wan_forward"`
+	Routes      []string `json:"routes"`
+	WGPublicKey string   `json:"wg_public_key"`
+
+	// Network name, default network is used if empty.
+	Network string `json:"network"`
+
+	// PresharedKey requests preshared key generation,
+	// ignored if WGPresharedKey is provided.
+	PresharedKey   bool   `json:"preshared_key"`
+	WGPresharedKey string `json:"wg_preshared_key"`
+
+	// ExpiresAt is an optional expiration time in RFC 3339 format.
+	ExpiresAt string `json:"expires_at"`
 }
 
 func (s *DeviceCreateRequest) validate() (string, error) {
@@ -171,6 +249,14 @@ func (s *DeviceCreateRequest) validate() (string, error) {
 		return "label", err
 	}
 
+	for _, v := range s.Routes {
+		_, _, err := net.ParseCIDR(v)
+		if err != nil {
+			err = errors.New("bad value")
+			return "routes", err
+		}
+	}
+
 	if len(s.WGPublicKey) > 0 {
 		_, err := wgtypes.ParseKey(s.WGPublicKey)
 		if err != nil {
@@ -179,6 +265,22 @@ func (s *DeviceCreateRequest) validate() (string, error) {
 		}
 	}
 
+	if len(s.WGPresharedKey) > 0 {
+		_, err := wgtypes.ParseKey(s.WGPresharedKey)
+		if err != nil {
+			err = errors.New("bad value")
+			return "wg_preshared_key", err
+		}
+	}
+
+	if len(s.ExpiresAt) > 0 {
+		_, err := time.Parse(time.RFC3339, s.ExpiresAt)
+		if err != nil {
+			err = errors.New("bad value")
+			return "expires_at", err
+		}
+	}
+
 	return "", nil
 }
 
@@ -194,20 +296,32 @@ type DeviceCreateResponse struct {
 	UserName   string `json:"user_name"`
 	Label      string `json:"label"`
 	WANForward bool   `json:"wan_forward"`
+	Disabled   bool   `json:"disabled"`
+	Network    string `json:"network"`
+
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Expired   bool       `json:"expired"`
 
 	WgDeviceInet       string   `json:"wg_device_inet"`
+	WgDeviceInet6      string   `json:"wg_device_inet6,omitempty"`
 	WgDevicePort       uint16   `json:"wg_device_port"`
 	WgDevicePubKey     string   `json:"wg_device_pubkey"`
 	WgDevicePrivKey    string   `json:"wg_device_privkey,omitempty"`
 	WgDeviceAllowedIPs []string `json:"wg_device_allowed_ips"`
+	WgDeviceRoutes     []string `json:"wg_device_routes"`
+
+	WgDevicePresharedKey string `json:"wg_device_psk,omitempty"`
 
 	WgInet   string `json:"wg_server_inet"`
 	WgIPNet  string `json:"wg_server_ipnet"`
 	WgIP     string `json:"wg_server_ip"`
+	WgIPNet6 string `json:"wg_server_ipnet6,omitempty"`
+	WgIP6    string `json:"wg_server_ip6,omitempty"`
 	WgPort   uint16 `json:"wg_server_port"`
 	WgPubKey string `json:"wg_server_pubkey"`
 
-	WanIP string `json:"server_wanip"`
+	WanIP     string   `json:"server_wanip"`
+	Endpoints []string `json:"server_endpoints"`
 }
 
 func (s DeviceCreateResponse) marshal() json.RawMessage {
@@ -278,12 +392,49 @@ func (api *API) deviceEdit(
 		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
 	}
 
+	n, err := api.deviceNetwork(d)
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
 	if request.Label != nil {
 		d.Label = *request.Label
 	}
 	if request.WANForward != nil {
 		d.WANForward = *request.WANForward
 	}
+	if request.Server != nil {
+		d.Server = *request.Server
+	}
+	if request.DownloadRate != nil {
+		d.DownloadRate = *request.DownloadRate
+	}
+	if request.UploadRate != nil {
+		d.UploadRate = *request.UploadRate
+	}
+	if request.Disabled != nil {
+		d.Disabled = *request.Disabled
+	}
+	if request.ExpiresAt != nil {
+		d.ExpiresAt = parseExpiresAt(*request.ExpiresAt)
+	}
+	if request.Routes != nil {
+		host, err := api.hostNets()
+		if err != nil {
+			return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+		}
+
+		routes := parseRoutes(*request.Routes)
+		err = model.CheckRoutes(
+			tx, d.IPNetwork.IP, api.wgIPNets(), host, routes)
+		if err != nil {
+			b := validateError{"routes", err.Error()}.marshal()
+			b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+			err = errors.New("validation error")
+			return b, err
+		}
+		d.Routes = routes
+	}
 	if request.WGPublicKey != nil {
 		// omit error check, we already validate this field
 		pk, _ := wgtypes.ParseKey(*request.WGPublicKey)
@@ -296,45 +447,85 @@ func (api *API) deviceEdit(
 		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
 	}
 
+	devices, err := model.LoadDevices(tx)
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	allowedIPs := d.AllowedIPs(
+		n.WgIPNet6,
+		devices.Network(d.Network).Routes(d.IPNetwork.IP))
+
+	endpoints, err := api.networkEndpoints(tx, n)
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		err = fmt.Errorf("can't commit tx: %v", err)
 		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
 	}
 
+	api.notify()
+
 	response := DeviceResponse{
 		UserUUID:           u.UUID,
 		UserName:           u.Name,
 		Label:              d.Label,
 		WANForward:         d.WANForward,
-		WgDeviceAllowedIPs: make([]string, len(d.AllowedIPs())),
+		Server:             d.Server,
+		DownloadRate:       d.DownloadRate,
+		UploadRate:         d.UploadRate,
+		Disabled:           d.Disabled,
+		ExpiresAt:          d.ExpiresAt,
+		Expired:            d.Expired(time.Now()),
+		Network:            n.Name,
+		WgDeviceAllowedIPs: make([]string, len(allowedIPs)),
 
 		WgDeviceInet:   d.CIDR().String(),
-		WgDevicePort:   api.cfg.WgPort,
+		WgDevicePort:   n.WgPort,
 		WgDevicePubKey: d.PubKey.String(),
 
-		WgInet:   api.cfg.WgInet.String(),
-		WgIPNet:  api.cfg.WgIPNet.String(),
-		WgIP:     api.cfg.WgInet.IP.String(),
-		WgPort:   api.cfg.WgPort,
-		WgPubKey: api.cfg.WgPubKey.String(),
+		WgInet:   n.WgInet.String(),
+		WgIPNet:  n.WgIPNet.String(),
+		WgIP:     n.WgInet.IP.String(),
+		WgPort:   n.WgPort,
+		WgPubKey: n.WgManager.PublicKey().String(),
 
-		WanIP: api.cfg.WanIP.String(),
+		WanIP:     api.cfg.WanIP().String(),
+		Endpoints: endpoints,
 	}
+	response.WgDeviceInet6, response.WgIPNet6, response.WgIP6 =
+		deviceInet6(d, n)
 
-	for idx, item := range d.AllowedIPs() {
+	for idx, item := range allowedIPs {
 		response.WgDeviceAllowedIPs[idx] = item.String()
 	}
+	response.WgDeviceRoutes = formatRoutes(d.Routes)
+	if d.PresharedKey != [wgtypes.KeyLen]byte{} {
+		response.WgDevicePresharedKey = d.PresharedKey.String()
+	}
+	response.setPeerStat(api.peerStats()[d.PubKey])
 
 	return response.marshal(), nil
 }
 
 // DeviceEditRequest model.
 type DeviceEditRequest struct {
-	IP          string  `json:"ip"`
-	Label       *string `json:"label"`
-	WANForward  *bool   `json:"wan_forward"`
-	WGPublicKey *string `json:"wg_public_key"`
+	IP          string    `json:"ip"`
+	Label       *string   `json:"label"`
+	WANForward  *bool     `json:"wan_forward"`
+	Server      *bool     `json:"server"`
+	Disabled    *bool     `json:"disabled"`
+	Routes      *[]string `json:"routes"`
+	WGPublicKey *string   `json:"wg_public_key"`
+
+	// ExpiresAt in RFC 3339 format, empty value removes expiration.
+	ExpiresAt *string `json:"expires_at"`
+
+	// DownloadRate and UploadRate in kbit/s, zero value removes the limit.
+	DownloadRate *uint32 `json:"download_rate"`
+	UploadRate   *uint32 `json:"upload_rate"`
 }
 
 func (s *DeviceEditRequest) validate() (string, error) {
@@ -349,6 +540,16 @@ func (s *DeviceEditRequest) validate() (string, error) {
 		return "label", err
 	}
 
+	if s.Routes != nil {
+		for _, v := range *s.Routes {
+			_, _, err := net.ParseCIDR(v)
+			if err != nil {
+				err = errors.New("bad value")
+				return "routes", err
+			}
+		}
+	}
+
 	if s.WGPublicKey != nil {
 		_, err := wgtypes.ParseKey(*s.WGPublicKey)
 		if err != nil {
@@ -357,6 +558,14 @@ func (s *DeviceEditRequest) validate() (string, error) {
 		}
 	}
 
+	if s.ExpiresAt != nil && len(*s.ExpiresAt) > 0 {
+		_, err := time.Parse(time.RFC3339, *s.ExpiresAt)
+		if err != nil {
+			err = errors.New("bad value")
+			return "expires_at", err
+		}
+	}
+
 	return "", nil
 }
 
@@ -447,6 +656,8 @@ func (api *API) deviceRemove(
 		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
 	}
 
+	api.notify()
+
 	return json.RawMessage(`{"msg": "ok"}`), nil
 }
 
@@ -470,6 +681,213 @@ func (s DeviceRemoveRequest) Marshal() json.RawMessage {
 	return json.RawMessage(b)
 }
 
+// deviceRotateKey handler
+func (api *API) deviceRotateKey(
+	ctx context.Context, w http.ResponseWriter, r json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(true) // writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	request := new(DeviceRotateKeyRequest)
+	err = json.Unmarshal(r, &request)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	field, err := request.validate()
+	if err != nil {
+		msg := err.Error()
+		err = errors.New("validation error")
+		b := validateError{field, msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	ip := net.ParseIP(request.IP).To4()
+	d, err := model.LoadDevice(tx, ip)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	u, err := model.LoadUser(tx, d.UserUUID)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	n, err := api.deviceNetwork(d)
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	var (
+		sk wgtypes.Key
+		pk wgtypes.Key
+	)
+	if len(request.WGPublicKey) > 0 {
+		// omit error check, we already validate this field
+		pk, _ = wgtypes.ParseKey(request.WGPublicKey)
+	} else {
+		sk, err = wgtypes.GeneratePrivateKey()
+		if err != nil {
+			return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+		}
+		pk = sk.PublicKey()
+	}
+
+	exists, err := model.PubKeyExists(tx, pk)
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	if exists {
+		err = errors.New("validation error")
+		b := validateError{"wg_public_key", "already exists"}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	// preshared key belongs to the compromised tunnel as well
+	if d.PresharedKey != [wgtypes.KeyLen]byte{} {
+		d.PresharedKey, err = wgtypes.GenerateKey()
+		if err != nil {
+			return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+		}
+	}
+	d.PubKey = pk
+
+	err = d.Store(tx)
+	if err != nil {
+		err = fmt.Errorf("can't store device: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	devices, err := model.LoadDevices(tx)
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	allowedIPs := d.AllowedIPs(
+		n.WgIPNet6,
+		devices.Network(d.Network).Routes(d.IPNetwork.IP))
+
+	endpoints, err := api.networkEndpoints(tx, n)
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("can't commit tx: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	api.notify()
+
+	response := DeviceRotateKeyResponse{
+		UserUUID:   u.UUID,
+		UserName:   u.Name,
+		Label:      d.Label,
+		WANForward: d.WANForward,
+		Disabled:   d.Disabled,
+		ExpiresAt:  d.ExpiresAt,
+		Expired:    d.Expired(time.Now()),
+		Network:    n.Name,
+
+		WgDeviceInet:       d.CIDR().String(),
+		WgDevicePort:       n.WgPort,
+		WgDevicePubKey:     pk.String(),
+		WgDeviceAllowedIPs: make([]string, len(allowedIPs)),
+
+		WgInet:   n.WgInet.String(),
+		WgIPNet:  n.WgIPNet.String(),
+		WgIP:     n.WgInet.IP.String(),
+		WgPort:   n.WgPort,
+		WgPubKey: n.WgManager.PublicKey().String(),
+
+		WanIP:     api.cfg.WanIP().String(),
+		Endpoints: endpoints,
+	}
+	response.WgDeviceInet6, response.WgIPNet6, response.WgIP6 =
+		deviceInet6(d, n)
+	if sk != [wgtypes.KeyLen]byte{} {
+		response.WgDevicePrivKey = sk.String()
+	}
+	if d.PresharedKey != [wgtypes.KeyLen]byte{} {
+		response.WgDevicePresharedKey = d.PresharedKey.String()
+	}
+	for idx, item := range allowedIPs {
+		response.WgDeviceAllowedIPs[idx] = item.String()
+	}
+	response.WgDeviceRoutes = formatRoutes(d.Routes)
+
+	return response.marshal(), nil
+}
+
+// DeviceRotateKeyRequest model.
+type DeviceRotateKeyRequest struct {
+	IP          string `json:"ip"`
+	WGPublicKey string `json:"wg_public_key"`
+}
+
+func (s *DeviceRotateKeyRequest) validate() (string, error) {
+	if net.ParseIP(s.IP).To4() == nil {
+		err := errors.New("required")
+		return "ip", err
+	}
+
+	if len(s.WGPublicKey) > 0 {
+		_, err := wgtypes.ParseKey(s.WGPublicKey)
+		if err != nil {
+			err = errors.New("bad value")
+			return "wg_public_key", err
+		}
+	}
+
+	return "", nil
+}
+
+// Marshall returns the json encoding of DeviceRotateKeyRequest.
+func (s DeviceRotateKeyRequest) Marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
+
+// DeviceRotateKeyResponse model.
+type DeviceRotateKeyResponse DeviceCreateResponse
+
+func (s DeviceRotateKeyResponse) marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
+
 // device handler
 func (api *API) device(
 	ctx context.Context, w http.ResponseWriter, r json.RawMessage,
@@ -533,28 +951,62 @@ func (api *API) device(
 		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
 	}
 
+	n, err := api.deviceNetwork(d)
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	devices, err := model.LoadDevices(tx)
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	allowedIPs := d.AllowedIPs(
+		n.WgIPNet6,
+		devices.Network(d.Network).Routes(d.IPNetwork.IP))
+
+	endpoints, err := api.networkEndpoints(tx, n)
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
 	response := DeviceResponse{
 		UserUUID:   u.UUID,
 		UserName:   u.Name,
 		Label:      d.Label,
 		WANForward: d.WANForward,
+		Server:     d.Server,
+		Disabled:   d.Disabled,
+		ExpiresAt:  d.ExpiresAt,
+		Expired:    d.Expired(time.Now()),
+		Network:    n.Name,
+
+		DownloadRate: d.DownloadRate,
+		UploadRate:   d.UploadRate,
 
 		WgDeviceInet:       d.CIDR().String(),
-		WgDevicePort:       api.cfg.WgPort,
+		WgDevicePort:       n.WgPort,
 		WgDevicePubKey:     d.PubKey.String(),
-		WgDeviceAllowedIPs: make([]string, len(d.AllowedIPs())),
+		WgDeviceAllowedIPs: make([]string, len(allowedIPs)),
 
-		WgInet:   api.cfg.WgInet.String(),
-		WgIPNet:  api.cfg.WgIPNet.String(),
-		WgIP:     api.cfg.WgInet.IP.String(),
-		WgPort:   api.cfg.WgPort,
-		WgPubKey: api.cfg.WgPubKey.String(),
+		WgInet:   n.WgInet.String(),
+		WgIPNet:  n.WgIPNet.String(),
+		WgIP:     n.WgInet.IP.String(),
+		WgPort:   n.WgPort,
+		WgPubKey: n.WgManager.PublicKey().String(),
 
-		WanIP: api.cfg.WanIP.String(),
+		WanIP:     api.cfg.WanIP().String(),
+		Endpoints: endpoints,
 	}
-	for idx, item := range d.AllowedIPs() {
+	response.WgDeviceInet6, response.WgIPNet6, response.WgIP6 =
+		deviceInet6(d, n)
+	for idx, item := range allowedIPs {
 		response.WgDeviceAllowedIPs[idx] = item.String()
 	}
+	response.WgDeviceRoutes = formatRoutes(d.Routes)
+	if d.PresharedKey != [wgtypes.KeyLen]byte{} {
+		response.WgDevicePresharedKey = d.PresharedKey.String()
+	}
+	response.setPeerStat(api.peerStats()[d.PubKey])
 
 	return response.marshal(), nil
 }
@@ -580,25 +1032,66 @@ func (s DeviceRequest) Marshal() json.RawMessage {
 	return json.RawMessage(b)
 }
 
+// DevicePeerStat model, the live state of the device wireguard peer
+type DevicePeerStat struct {
+	WgDeviceEndpoint      string     `json:"wg_device_endpoint"`
+	WgDeviceLastHandshake *time.Time `json:"wg_device_last_handshake"`
+	WgDeviceRxBytes       int64      `json:"wg_device_rx_bytes"`
+	WgDeviceTxBytes       int64      `json:"wg_device_tx_bytes"`
+	WgDeviceOnline        bool       `json:"wg_device_online"`
+}
+
+func (s *DevicePeerStat) setPeerStat(stat wgmngr.PeerStat) {
+	if stat.Endpoint != nil {
+		s.WgDeviceEndpoint = stat.Endpoint.String()
+	}
+	if !stat.LastHandshake.IsZero() {
+		t := stat.LastHandshake.UTC()
+		s.WgDeviceLastHandshake = &t
+	}
+	s.WgDeviceRxBytes = stat.ReceiveBytes
+	s.WgDeviceTxBytes = stat.TransmitBytes
+	s.WgDeviceOnline = stat.Online()
+}
+
 // DeviceResponse model
 type DeviceResponse struct {
 	UserUUID   string `json:"user_uuid"`
 	UserName   string `json:"user_name"`
 	Label      string `json:"label"`
 	WANForward bool   `json:"wan_forward"`
+	Server     bool   `json:"server"`
+	Disabled   bool   `json:"disabled"`
+	Network    string `json:"network"`
+
+	// DownloadRate and UploadRate in kbit/s, zero value is no limit.
+	DownloadRate uint32 `json:"download_rate"`
+	UploadRate   uint32 `json:"upload_rate"`
+
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Expired   bool       `json:"expired"`
 
 	WgDeviceInet       string   `json:"wg_device_inet"`
+	WgDeviceInet6      string   `json:"wg_device_inet6,omitempty"`
 	WgDevicePort       uint16   `json:"wg_device_port"`
 	WgDevicePubKey     string   `json:"wg_device_pubkey"`
 	WgDeviceAllowedIPs []string `json:"wg_device_allowed_ips"`
+	WgDeviceRoutes     []string `json:"wg_device_routes"`
+
+	WgDevicePresharedKey string `json:"wg_device_psk,omitempty"`
+
+	DevicePeerStat
 
 	WgInet   string `json:"wg_server_inet"`
 	WgIPNet  string `json:"wg_server_ipnet"`
 	WgIP     string `json:"wg_server_ip"`
+	WgIPNet6 string `json:"wg_server_ipnet6,omitempty"`
+	WgIP6    string `json:"wg_server_ip6,omitempty"`
 	WgPort   uint16 `json:"wg_server_port"`
 	WgPubKey string `json:"wg_server_pubkey"`
 
-	WanIP string `json:"server_wanip"`
+	WanIP     string   `json:"server_wanip"`
+	Endpoints []string `json:"server_endpoints"`
 }
 
 func (s DeviceResponse) marshal() json.RawMessage {
@@ -648,21 +1141,43 @@ func (api *API) deviceList(
 		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
 	}
 
+	stats := api.peerStats()
+
 	response := make(DeviceListResponse, len(devices))
 	for i, d := range devices {
+		n, err := api.deviceNetwork(d)
+		if err != nil {
+			return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+		}
+
+		allowedIPs := d.AllowedIPs(
+			n.WgIPNet6,
+			devices.Network(d.Network).Routes(d.IPNetwork.IP))
+
 		response[i] = DeviceListItem{
 			IPNetwork:  d.IPNetwork.CIDR().String(),
 			PubKey:     d.PubKey.String(),
 			Label:      d.Label,
 			WANForward: d.WANForward,
-			AllowedIPs: make([]string, len(d.AllowedIPs())),
+			Server:     d.Server,
+			Disabled:   d.Disabled,
+			ExpiresAt:  d.ExpiresAt,
+			Expired:    d.Expired(time.Now()),
+			AllowedIPs: make([]string, len(allowedIPs)),
+			Network:    n.Name,
+
+			DownloadRate: d.DownloadRate,
+			UploadRate:   d.UploadRate,
 
 			UserUUID: d.UserUUID,
 		}
 
-		for j, item := range d.AllowedIPs() {
+		for j, item := range allowedIPs {
 			response[i].AllowedIPs[j] = item.String()
 		}
+		response[i].IPNetwork6, _, _ = deviceInet6(d, n)
+		response[i].Routes = formatRoutes(d.Routes)
+		response[i].setPeerStat(stats[d.PubKey])
 	}
 
 	return response.marshal(), nil
@@ -670,11 +1185,23 @@ func (api *API) deviceList(
 
 // DeviceListItem model.
 type DeviceListItem struct {
-	IPNetwork  string   `json:"ipnetwork"`
-	PubKey     string   `json:"pub_key"`
-	Label      string   `json:"label"`
-	WANForward bool     `json:"wan_forward"`
-	AllowedIPs []string `json:"allowed_ips"`
+	IPNetwork  string     `json:"ipnetwork"`
+	IPNetwork6 string     `json:"ipnetwork6,omitempty"`
+	PubKey     string     `json:"pub_key"`
+	Label      string     `json:"label"`
+	WANForward bool       `json:"wan_forward"`
+	Server     bool       `json:"server"`
+	Disabled   bool       `json:"disabled"`
+	AllowedIPs []string   `json:"allowed_ips"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	Expired    bool       `json:"expired"`
+	Routes     []string   `json:"routes"`
+	Network    string     `json:"network"`
+
+	DownloadRate uint32 `json:"download_rate"`
+	UploadRate   uint32 `json:"upload_rate"`
+
+	DevicePeerStat
 
 	UserUUID string `json:"user_uuid"`
 }
@@ -686,3 +1213,47 @@ func (s DeviceListResponse) marshal() json.RawMessage {
 	b, _ := json.Marshal(s)
 	return json.RawMessage(b)
 }
+
+// parseRoutes from validated request values.
+func parseRoutes(values []string) []*net.IPNet {
+	routes := make([]*net.IPNet, len(values))
+	for i := range values {
+		// omit error check, we already validate this field
+		_, routes[i], _ = net.ParseCIDR(values[i])
+	}
+
+	return routes
+}
+
+func formatRoutes(routes []*net.IPNet) []string {
+	values := make([]string, len(routes))
+	for i := range routes {
+		values[i] = routes[i].String()
+	}
+
+	return values
+}
+
+// deviceInet6 returns ipv6 addresses of the device, the network and
+// the server, empty values if the network has no ipv6 prefix.
+func deviceInet6(d model.Device, n Network) (string, string, string) {
+	if n.WgIPNet6 == nil {
+		return "", "", ""
+	}
+
+	return d.CIDR6(n.WgIPNet6).String(),
+		n.WgIPNet6.String(),
+		n.WgInet6.IP.String()
+}
+
+// parseExpiresAt parses validated RFC 3339 value, nil for empty value.
+func parseExpiresAt(v string) *time.Time {
+	if len(v) == 0 {
+		return nil
+	}
+
+	// omit error check, we already validate this value
+	t, _ := time.Parse(time.RFC3339, v)
+	t = t.UTC()
+	return &t
+}
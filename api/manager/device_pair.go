@@ -0,0 +1,325 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"wgnetwork/model"
+)
+
+// devicePairAdd handler
+func (api *API) devicePairAdd(
+	ctx context.Context, w http.ResponseWriter, r json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(true) // writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	request := new(DevicePairAddRequest)
+	err = json.Unmarshal(r, &request)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	field, err := request.validate()
+	if err != nil {
+		msg := err.Error()
+		err = errors.New("validation error")
+		b := validateError{field, msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	ipA := net.ParseIP(request.A).To4()
+	a, err := model.LoadDevice(tx, ipA)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+	ipB := net.ParseIP(request.B).To4()
+	b, err := model.LoadDevice(tx, ipB)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	// the traffic isn't forwarded between the networks
+	if a.Network != b.Network {
+		msg := "device of another network"
+		err = errors.New("validation error")
+		b := validateError{"b", msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	p := model.NewDevicePair(ipA, ipB)
+	err = p.Validate()
+	if err != nil {
+		msg := err.Error()
+		err = errors.New("validation error")
+		b := validateError{"b", msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	err = p.Store(tx)
+	if err != nil {
+		err = fmt.Errorf("can't store device pair: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("can't commit tx: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	api.notify()
+
+	return json.RawMessage(`{"msg": "ok"}`), nil
+}
+
+// DevicePairAddRequest model.
+type DevicePairAddRequest struct {
+	A string `json:"a"`
+	B string `json:"b"`
+}
+
+func (s *DevicePairAddRequest) validate() (string, error) {
+	if net.ParseIP(s.A).To4() == nil {
+		err := errors.New("required")
+		return "a", err
+	}
+
+	if net.ParseIP(s.B).To4() == nil {
+		err := errors.New("required")
+		return "b", err
+	}
+
+	return "", nil
+}
+
+// Marshall returns the json encoding of DevicePairAddRequest.
+func (s DevicePairAddRequest) Marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
+
+// devicePairRemove handler
+func (api *API) devicePairRemove(
+	ctx context.Context, w http.ResponseWriter, r json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(true) // writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	request := new(DevicePairRemoveRequest)
+	err = json.Unmarshal(r, &request)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	field, err := request.validate()
+	if err != nil {
+		msg := err.Error()
+		err = errors.New("validation error")
+		b := validateError{field, msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	ipA := net.ParseIP(request.A).To4()
+	ipB := net.ParseIP(request.B).To4()
+	if !model.IsDevicePairExists(tx, ipA, ipB) {
+		msg := "not paired"
+		err = errors.New("validation error")
+		b := validateError{"b", msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	err = model.RemoveDevicePair(tx, ipA, ipB)
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("can't commit tx: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	api.notify()
+
+	return json.RawMessage(`{"msg": "ok"}`), nil
+}
+
+// DevicePairRemoveRequest model.
+type DevicePairRemoveRequest struct {
+	A string `json:"a"`
+	B string `json:"b"`
+}
+
+func (s *DevicePairRemoveRequest) validate() (string, error) {
+	if net.ParseIP(s.A).To4() == nil {
+		err := errors.New("required")
+		return "a", err
+	}
+
+	if net.ParseIP(s.B).To4() == nil {
+		err := errors.New("required")
+		return "b", err
+	}
+
+	return "", nil
+}
+
+// Marshall returns the json encoding of DevicePairRemoveRequest.
+func (s DevicePairRemoveRequest) Marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
+
+// devicePairList handler
+func (api *API) devicePairList(
+	ctx context.Context, w http.ResponseWriter, _ json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(false) // non-writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	pairs, err := model.LoadDevicePairs(tx)
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	response := make(DevicePairListResponse, 0, len(pairs))
+	for _, p := range pairs {
+		item := DevicePairListItem{
+			A: p.A.String(),
+			B: p.B.String(),
+		}
+
+		d, err := model.LoadDevice(tx, p.A)
+		if err == nil {
+			item.ALabel = d.Label
+			if n, err := api.deviceNetwork(d); err == nil {
+				item.Network = n.Name
+			}
+		}
+		d, err = model.LoadDevice(tx, p.B)
+		if err == nil {
+			item.BLabel = d.Label
+		}
+
+		response = append(response, item)
+	}
+
+	return response.marshal(), nil
+}
+
+// DevicePairListItem model.
+type DevicePairListItem struct {
+	A       string `json:"a"`
+	ALabel  string `json:"a_label"`
+	B       string `json:"b"`
+	BLabel  string `json:"b_label"`
+	Network string `json:"network"`
+}
+
+// DevicePairListResponse model.
+type DevicePairListResponse []DevicePairListItem
+
+func (s DevicePairListResponse) marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
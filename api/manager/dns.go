@@ -81,6 +81,8 @@ func (api *API) domainCreate(
 		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
 	}
 
+	api.notify()
+
 	response := DomainResponse{d}
 
 	return response.marshal(), nil
@@ -184,6 +186,12 @@ func (api *API) domainRecordSet(
 			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
 		}
 		d.SetA(r)
+	case "aaaa":
+		r, err := request.GetAAAA()
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		d.SetAAAA(r)
 	case "cname":
 		r, err := request.GetCNAME()
 		if err != nil {
@@ -204,6 +212,8 @@ func (api *API) domainRecordSet(
 		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
 	}
 
+	api.notify()
+
 	response := DomainResponse{d}
 
 	return response.marshal(), nil
@@ -255,6 +265,21 @@ func (s DomainRecordSetRequest) GetA() (model.ARecord, error) {
 	return r, nil
 }
 
+// GetAAAA returns AAAARecord of data,
+func (s DomainRecordSetRequest) GetAAAA() (model.AAAARecord, error) {
+	if strings.ToLower(s.Type) != "aaaa" {
+		return model.AAAARecord{}, errors.New("wrong type")
+	}
+
+	r := model.AAAARecord{}
+	err := json.Unmarshal(s.Data, &r)
+	if err != nil {
+		return model.AAAARecord{}, err
+	}
+
+	return r, nil
+}
+
 // GetCNAME returns CNAMERecord of data,
 func (s DomainRecordSetRequest) GetCNAME() (model.CNAMERecord, error) {
 	if strings.ToLower(s.Type) != "cname" {
@@ -334,6 +359,12 @@ func (api *API) domainRecordRemove(
 			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
 		}
 		d.RemoveA(ip)
+	case "aaaa":
+		ip, err := request.GetAAAA()
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		d.RemoveAAAA(ip)
 	case "cname":
 		target, err := request.GetCNAME()
 		if err != nil {
@@ -354,6 +385,8 @@ func (api *API) domainRecordRemove(
 		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
 	}
 
+	api.notify()
+
 	response := DomainResponse{d}
 
 	return response.marshal(), nil
@@ -406,6 +439,21 @@ func (s DomainRecordRemoveRequest) GetA() (net.IP, error) {
 	return ip, nil
 }
 
+// GetAAAA returns AAAARecord ip of data,
+func (s DomainRecordRemoveRequest) GetAAAA() (net.IP, error) {
+	if strings.ToLower(s.Type) != "aaaa" {
+		return nil, errors.New("wrong type")
+	}
+
+	ip := net.IP{}
+	err := json.Unmarshal(s.Data, &ip)
+	if err != nil {
+		return nil, err
+	}
+
+	return ip, nil
+}
+
 // GetCNAME returns CNAMERecord of data,
 func (s DomainRecordRemoveRequest) GetCNAME() (string, error) {
 	if strings.ToLower(s.Type) != "cname" {
@@ -483,6 +531,8 @@ func (api *API) domainRemove(
 		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
 	}
 
+	api.notify()
+
 	return json.RawMessage(`{"msg": "ok"}`), nil
 }
 
@@ -0,0 +1,592 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"wgnetwork/model"
+)
+
+// egressRuleAdd handler
+func (api *API) egressRuleAdd(
+	ctx context.Context, w http.ResponseWriter, r json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(true) // writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	request := new(EgressRuleAddRequest)
+	err = json.Unmarshal(r, &request)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	field, err := request.validate()
+	if err != nil {
+		msg := err.Error()
+		err = errors.New("validation error")
+		b := validateError{field, msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	rules, err := model.LoadEgressRules(tx)
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	rules = rules.Add(request.Rule.rule())
+
+	err = rules.Store(tx)
+	if err != nil {
+		err = fmt.Errorf("can't store egress rules: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("can't commit tx: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	api.notify()
+
+	return json.RawMessage(`{"msg": "ok"}`), nil
+}
+
+// EgressRuleAddRequest model.
+type EgressRuleAddRequest struct {
+	Rule EgressRuleRequest `json:"rule"`
+}
+
+func (s *EgressRuleAddRequest) validate() (string, error) {
+	return s.Rule.validate()
+}
+
+// Marshall returns the json encoding of EgressRuleAddRequest.
+func (s EgressRuleAddRequest) Marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
+
+// egressRuleRemove handler
+func (api *API) egressRuleRemove(
+	ctx context.Context, w http.ResponseWriter, r json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(true) // writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	request := new(EgressRuleRemoveRequest)
+	err = json.Unmarshal(r, &request)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	field, err := request.validate()
+	if err != nil {
+		msg := err.Error()
+		err = errors.New("validation error")
+		b := validateError{field, msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	rules, err := model.LoadEgressRules(tx)
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	rules, err = rules.Remove(request.Index)
+	if err != nil {
+		msg := err.Error()
+		err = errors.New("validation error")
+		b := validateError{"index", msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	err = rules.Store(tx)
+	if err != nil {
+		err = fmt.Errorf("can't store egress rules: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("can't commit tx: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	api.notify()
+
+	return json.RawMessage(`{"msg": "ok"}`), nil
+}
+
+// EgressRuleRemoveRequest model.
+type EgressRuleRemoveRequest struct {
+	Index int `json:"index"`
+}
+
+func (s *EgressRuleRemoveRequest) validate() (string, error) {
+	if s.Index < 0 {
+		err := errors.New("should be non negative")
+		return "index", err
+	}
+
+	return "", nil
+}
+
+// Marshall returns the json encoding of EgressRuleRemoveRequest.
+func (s EgressRuleRemoveRequest) Marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
+
+// egressRules handler
+func (api *API) egressRules(
+	ctx context.Context, w http.ResponseWriter, r json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(false) // non-writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	rules, err := model.LoadEgressRules(tx)
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	response := newEgressRulesResponse(rules)
+
+	return response.marshal(), nil
+}
+
+// deviceEgressAdd handler
+func (api *API) deviceEgressAdd(
+	ctx context.Context, w http.ResponseWriter, r json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(true) // writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	request := new(DeviceEgressAddRequest)
+	err = json.Unmarshal(r, &request)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	field, err := request.validate()
+	if err != nil {
+		msg := err.Error()
+		err = errors.New("validation error")
+		b := validateError{field, msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	ip := net.ParseIP(request.IP).To4()
+	d, err := model.LoadDevice(tx, ip)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	d.Egress = d.Egress.Add(request.Rule.rule())
+
+	err = d.Store(tx)
+	if err != nil {
+		err = fmt.Errorf("can't store device: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("can't commit tx: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	api.notify()
+
+	return json.RawMessage(`{"msg": "ok"}`), nil
+}
+
+// DeviceEgressAddRequest model.
+type DeviceEgressAddRequest struct {
+	IP   string            `json:"ip"`
+	Rule EgressRuleRequest `json:"rule"`
+}
+
+func (s *DeviceEgressAddRequest) validate() (string, error) {
+	if net.ParseIP(s.IP).To4() == nil {
+		err := errors.New("required")
+		return "ip", err
+	}
+
+	return s.Rule.validate()
+}
+
+// Marshall returns the json encoding of DeviceEgressAddRequest.
+func (s DeviceEgressAddRequest) Marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
+
+// deviceEgressRemove handler
+func (api *API) deviceEgressRemove(
+	ctx context.Context, w http.ResponseWriter, r json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(true) // writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	request := new(DeviceEgressRemoveRequest)
+	err = json.Unmarshal(r, &request)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	field, err := request.validate()
+	if err != nil {
+		msg := err.Error()
+		err = errors.New("validation error")
+		b := validateError{field, msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	ip := net.ParseIP(request.IP).To4()
+	d, err := model.LoadDevice(tx, ip)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	d.Egress, err = d.Egress.Remove(request.Index)
+	if err != nil {
+		msg := err.Error()
+		err = errors.New("validation error")
+		b := validateError{"index", msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	err = d.Store(tx)
+	if err != nil {
+		err = fmt.Errorf("can't store device: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("can't commit tx: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	api.notify()
+
+	return json.RawMessage(`{"msg": "ok"}`), nil
+}
+
+// DeviceEgressRemoveRequest model.
+type DeviceEgressRemoveRequest struct {
+	IP    string `json:"ip"`
+	Index int    `json:"index"`
+}
+
+func (s *DeviceEgressRemoveRequest) validate() (string, error) {
+	if net.ParseIP(s.IP).To4() == nil {
+		err := errors.New("required")
+		return "ip", err
+	}
+
+	if s.Index < 0 {
+		err := errors.New("should be non negative")
+		return "index", err
+	}
+
+	return "", nil
+}
+
+// Marshall returns the json encoding of DeviceEgressRemoveRequest.
+func (s DeviceEgressRemoveRequest) Marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
+
+// deviceEgress handler
+func (api *API) deviceEgress(
+	ctx context.Context, w http.ResponseWriter, r json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(false) // non-writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	request := new(DeviceRequest)
+	err = json.Unmarshal(r, &request)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	field, err := request.Validate()
+	if err != nil {
+		msg := err.Error()
+		err = errors.New("validation error")
+		b := validateError{field, msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	ip := net.ParseIP(request.IP).To4()
+	d, err := model.LoadDevice(tx, ip)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	response := newEgressRulesResponse(d.Egress)
+
+	return response.marshal(), nil
+}
+
+// EgressRuleRequest model, matches connections forwarded to wan.
+type EgressRuleRequest struct {
+	Action  string   `json:"action"`
+	IPProto string   `json:"ipproto"`
+	DPorts  []uint16 `json:"dports"`
+	DNets   []string `json:"dnets"`
+}
+
+func (s *EgressRuleRequest) validate() (string, error) {
+	for _, v := range s.DNets {
+		_, err := model.ParseEgressNet(v)
+		if err != nil {
+			return "dnets", err
+		}
+	}
+
+	r := s.rule()
+	err := r.Validate()
+	if err != nil {
+		return "rule", err
+	}
+
+	return "", nil
+}
+
+func (s *EgressRuleRequest) rule() model.EgressRule {
+	r := model.EgressRule{
+		Action:  s.Action,
+		IPProto: s.IPProto,
+		DPorts:  s.DPorts,
+		DNets:   s.DNets,
+	}
+
+	return r
+}
+
+// EgressRuleListItem model.
+type EgressRuleListItem struct {
+	Index   int      `json:"index"`
+	Action  string   `json:"action"`
+	IPProto string   `json:"ipproto"`
+	DPorts  []uint16 `json:"dports"`
+	DNets   []string `json:"dnets"`
+}
+
+// EgressRulesResponse model.
+type EgressRulesResponse []EgressRuleListItem
+
+func newEgressRulesResponse(rules model.EgressRules) EgressRulesResponse {
+	response := make(EgressRulesResponse, 0, len(rules))
+	for idx, r := range rules {
+		response = append(response, EgressRuleListItem{
+			Index:   idx,
+			Action:  r.Action,
+			IPProto: r.IPProto,
+			DPorts:  r.DPorts,
+			DNets:   r.DNets,
+		})
+	}
+
+	return response
+}
+
+func (s EgressRulesResponse) marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
@@ -0,0 +1,458 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"wgnetwork/model"
+)
+
+// firewallPreview handler
+func (api *API) firewallPreview(
+	ctx context.Context, w http.ResponseWriter, _ json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(false) // non-writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	if api.cfg.FirewallPreview == nil {
+		err := errors.New("firewall preview isn't available")
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	ruleset, err := api.cfg.FirewallPreview()
+	if err != nil {
+		err = fmt.Errorf("can't render firewall ruleset: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	response := FirewallPreviewResponse{
+		Ruleset: ruleset,
+	}
+
+	return response.marshal(), nil
+}
+
+// FirewallPreviewResponse model.
+type FirewallPreviewResponse struct {
+	Ruleset string `json:"ruleset"`
+}
+
+func (s FirewallPreviewResponse) marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
+
+// firewallBlacklist handler
+func (api *API) firewallBlacklist(
+	ctx context.Context, w http.ResponseWriter, _ json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(false) // non-writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	if api.cfg.FirewallBlacklist == nil {
+		err := errors.New("firewall blacklist isn't available")
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	ips, err := api.cfg.FirewallBlacklist()
+	if err != nil {
+		err = fmt.Errorf("can't list firewall blacklist: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	response := make(FirewallBlacklistResponse, len(ips))
+	for i, ip := range ips {
+		response[i] = ip.String()
+	}
+
+	return response.marshal(), nil
+}
+
+// FirewallBlacklistResponse model.
+type FirewallBlacklistResponse []string
+
+func (s FirewallBlacklistResponse) marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
+
+// firewallUnban handler
+func (api *API) firewallUnban(
+	ctx context.Context, w http.ResponseWriter, r json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(false) // non-writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	request := new(FirewallUnbanRequest)
+	err = json.Unmarshal(r, &request)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	field, err := request.validate()
+	if err != nil {
+		msg := err.Error()
+		err = errors.New("validation error")
+		b := validateError{field, msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	if api.cfg.FirewallBlacklist == nil || api.cfg.FirewallUnban == nil {
+		err := errors.New("firewall blacklist isn't available")
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	ips, err := api.cfg.FirewallBlacklist()
+	if err != nil {
+		err = fmt.Errorf("can't list firewall blacklist: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	// deletion of the missing element fails the whole batch
+	ip := net.ParseIP(request.IP)
+	found := false
+	for _, v := range ips {
+		if v.Equal(ip) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		err = errors.New("validation error")
+		b := validateError{"ip", "not banned"}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	err = api.cfg.FirewallUnban(ip)
+	if err != nil {
+		err = fmt.Errorf("can't unban %s: %v", ip, err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	return json.RawMessage(`{"msg": "ok"}`), nil
+}
+
+// FirewallUnbanRequest model.
+type FirewallUnbanRequest struct {
+	IP string `json:"ip"`
+}
+
+func (s *FirewallUnbanRequest) validate() (string, error) {
+	if len(s.IP) == 0 {
+		err := errors.New("required")
+		return "ip", err
+	}
+	if net.ParseIP(s.IP) == nil {
+		err := errors.New("bad value")
+		return "ip", err
+	}
+
+	return "", nil
+}
+
+// Marshall returns the json encoding of FirewallUnbanRequest.
+func (s FirewallUnbanRequest) Marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
+
+// firewallStats handler
+func (api *API) firewallStats(
+	ctx context.Context, w http.ResponseWriter, _ json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(false) // non-writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	if api.cfg.FirewallStats == nil {
+		err := errors.New("firewall stats isn't available")
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	counters, err := api.cfg.FirewallStats()
+	if err != nil {
+		err = fmt.Errorf("can't read firewall counters: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	response := make(FirewallStatsResponse, len(counters))
+	for i, v := range counters {
+		response[i] = FirewallStatsItem{
+			Name:    v.Name,
+			Packets: v.Packets,
+			Bytes:   v.Bytes,
+		}
+
+		// the drop counters of the devices are named by the device ip
+		ip := net.ParseIP(strings.TrimPrefix(v.Name, "device_"))
+		if ip == nil {
+			continue
+		}
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			continue
+		}
+		response[i].Device = d.Label
+	}
+
+	return response.marshal(), nil
+}
+
+// FirewallCounter is the named counter of the firewall rules.
+type FirewallCounter struct {
+	Name    string
+	Packets uint64
+	Bytes   uint64
+}
+
+// FirewallStatsResponse model.
+type FirewallStatsResponse []FirewallStatsItem
+
+// FirewallStatsItem model, the device is set for the device drop counter.
+type FirewallStatsItem struct {
+	Name    string `json:"name"`
+	Device  string `json:"device,omitempty"`
+	Packets uint64 `json:"packets"`
+	Bytes   uint64 `json:"bytes"`
+}
+
+func (s FirewallStatsResponse) marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
+
+// firewallConfirm handler
+func (api *API) firewallConfirm(
+	ctx context.Context, w http.ResponseWriter, _ json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(false) // non-writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	if api.cfg.FirewallConfirm == nil {
+		err := errors.New("firewall confirmation is turned off")
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	err = api.cfg.FirewallConfirm()
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	return json.RawMessage(`{"msg": "ok"}`), nil
+}
+
+// firewallPending handler
+func (api *API) firewallPending(
+	ctx context.Context, w http.ResponseWriter, _ json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(false) // non-writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	response := FirewallPendingResponse{
+		Enabled: api.cfg.FirewallConfirm != nil,
+	}
+	if api.cfg.FirewallDeadline != nil {
+		response.Deadline = api.cfg.FirewallDeadline()
+	}
+
+	return response.marshal(), nil
+}
+
+// FirewallPendingResponse model, the deadline is set
+// while the firewall changes are pending confirmation.
+type FirewallPendingResponse struct {
+	Enabled  bool       `json:"enabled"`
+	Deadline *time.Time `json:"deadline,omitempty"`
+}
+
+func (s FirewallPendingResponse) marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
@@ -0,0 +1,866 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"wgnetwork/model"
+)
+
+// groupCreate handler
+func (api *API) groupCreate(
+	ctx context.Context, w http.ResponseWriter, r json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(true) // writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	request := new(GroupCreateRequest)
+	err = json.Unmarshal(r, &request)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	field, err := request.validate()
+	if err != nil {
+		msg := err.Error()
+		err = errors.New("validation error")
+		b := validateError{field, msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	_, err = model.LoadGroup(tx, request.Name)
+	if err == nil {
+		msg := "already exists"
+		err = errors.New("validation error")
+		b := validateError{"name", msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	g := model.NewGroup(request.Name)
+
+	err = g.Store(tx)
+	if err != nil {
+		err = fmt.Errorf("can't store group: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("can't commit tx: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	api.notify()
+
+	return json.RawMessage(`{"msg": "ok"}`), nil
+}
+
+// GroupCreateRequest model.
+type GroupCreateRequest struct {
+	Name string `json:"name"`
+}
+
+func (s *GroupCreateRequest) validate() (string, error) {
+	err := model.ValidateGroupName(s.Name)
+	if err != nil {
+		return "name", err
+	}
+
+	return "", nil
+}
+
+// Marshall returns the json encoding of GroupCreateRequest.
+func (s GroupCreateRequest) Marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
+
+// groupRemove handler
+func (api *API) groupRemove(
+	ctx context.Context, w http.ResponseWriter, r json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(true) // writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	request := new(GroupRemoveRequest)
+	err = json.Unmarshal(r, &request)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	field, err := request.validate()
+	if err != nil {
+		msg := err.Error()
+		err = errors.New("validation error")
+		b := validateError{field, msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	_, err = model.LoadGroup(tx, request.Name)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	// untag devices of the group
+	devices, err := model.LoadDevices(tx)
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	for _, d := range devices.Group(request.Name) {
+		d.RemoveGroup(request.Name)
+		err = d.Store(tx)
+		if err != nil {
+			err = fmt.Errorf("can't store device: %v", err)
+			return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+		}
+	}
+
+	// drop policies targeting the group
+	groups, err := model.LoadGroups(tx)
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	for _, g := range groups {
+		n := len(g.Policies)
+		g.RemovePolicies(request.Name)
+		if len(g.Policies) == n {
+			continue
+		}
+		err = g.Store(tx)
+		if err != nil {
+			err = fmt.Errorf("can't store group: %v", err)
+			return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+		}
+	}
+
+	err = model.RemoveGroup(tx, request.Name)
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("can't commit tx: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	api.notify()
+
+	return json.RawMessage(`{"msg": "ok"}`), nil
+}
+
+// GroupRemoveRequest model.
+type GroupRemoveRequest struct {
+	Name string `json:"name"`
+}
+
+func (s *GroupRemoveRequest) validate() (string, error) {
+	err := model.ValidateGroupName(s.Name)
+	if err != nil {
+		return "name", err
+	}
+
+	return "", nil
+}
+
+// Marshall returns the json encoding of GroupRemoveRequest.
+func (s GroupRemoveRequest) Marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
+
+// groupDeviceAdd handler
+func (api *API) groupDeviceAdd(
+	ctx context.Context, w http.ResponseWriter, r json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(true) // writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	request := new(GroupDeviceRequest)
+	err = json.Unmarshal(r, &request)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	field, err := request.validate()
+	if err != nil {
+		msg := err.Error()
+		err = errors.New("validation error")
+		b := validateError{field, msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	g, err := model.LoadGroup(tx, request.Name)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	ip := net.ParseIP(request.IP).To4()
+	d, err := model.LoadDevice(tx, ip)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	d.AddGroup(g.Name)
+
+	err = d.Store(tx)
+	if err != nil {
+		err = fmt.Errorf("can't store device: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("can't commit tx: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	api.notify()
+
+	return json.RawMessage(`{"msg": "ok"}`), nil
+}
+
+// groupDeviceRemove handler
+func (api *API) groupDeviceRemove(
+	ctx context.Context, w http.ResponseWriter, r json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(true) // writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	request := new(GroupDeviceRequest)
+	err = json.Unmarshal(r, &request)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	field, err := request.validate()
+	if err != nil {
+		msg := err.Error()
+		err = errors.New("validation error")
+		b := validateError{field, msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	g, err := model.LoadGroup(tx, request.Name)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	ip := net.ParseIP(request.IP).To4()
+	d, err := model.LoadDevice(tx, ip)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	d.RemoveGroup(g.Name)
+
+	err = d.Store(tx)
+	if err != nil {
+		err = fmt.Errorf("can't store device: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("can't commit tx: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	api.notify()
+
+	return json.RawMessage(`{"msg": "ok"}`), nil
+}
+
+// GroupDeviceRequest model.
+type GroupDeviceRequest struct {
+	Name string `json:"name"`
+	IP   string `json:"ip"`
+}
+
+func (s *GroupDeviceRequest) validate() (string, error) {
+	err := model.ValidateGroupName(s.Name)
+	if err != nil {
+		return "name", err
+	}
+
+	if net.ParseIP(s.IP).To4() == nil {
+		err := errors.New("required")
+		return "ip", err
+	}
+
+	return "", nil
+}
+
+// Marshall returns the json encoding of GroupDeviceRequest.
+func (s GroupDeviceRequest) Marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
+
+// groupPolicyAdd handler
+func (api *API) groupPolicyAdd(
+	ctx context.Context, w http.ResponseWriter, r json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(true) // writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	request := new(GroupPolicyAddRequest)
+	err = json.Unmarshal(r, &request)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	field, err := request.validate()
+	if err != nil {
+		msg := err.Error()
+		err = errors.New("validation error")
+		b := validateError{field, msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	g, err := model.LoadGroup(tx, request.Name)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	_, err = model.LoadGroup(tx, request.To)
+	if err != nil {
+		msg := "not found"
+		err = errors.New("validation error")
+		b := validateError{"to", msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	g.Policies = append(g.Policies, model.GroupPolicy{
+		To:      request.To,
+		IPProto: request.IPProto,
+		DPorts:  request.DPorts,
+	})
+
+	err = g.Store(tx)
+	if err != nil {
+		err = fmt.Errorf("can't store group: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("can't commit tx: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	api.notify()
+
+	return json.RawMessage(`{"msg": "ok"}`), nil
+}
+
+// GroupPolicyAddRequest model, allows the devices of the group
+// to reach the devices of the target group.
+type GroupPolicyAddRequest struct {
+	Name    string   `json:"name"`
+	To      string   `json:"to"`
+	IPProto string   `json:"ipproto"`
+	DPorts  []uint16 `json:"dports"`
+}
+
+func (s *GroupPolicyAddRequest) validate() (string, error) {
+	err := model.ValidateGroupName(s.Name)
+	if err != nil {
+		return "name", err
+	}
+
+	p := model.GroupPolicy{To: s.To, IPProto: s.IPProto, DPorts: s.DPorts}
+	err = p.Validate()
+	if err != nil {
+		return "policy", err
+	}
+
+	return "", nil
+}
+
+// Marshall returns the json encoding of GroupPolicyAddRequest.
+func (s GroupPolicyAddRequest) Marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
+
+// groupPolicyRemove handler
+func (api *API) groupPolicyRemove(
+	ctx context.Context, w http.ResponseWriter, r json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(true) // writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	request := new(GroupPolicyRemoveRequest)
+	err = json.Unmarshal(r, &request)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	field, err := request.validate()
+	if err != nil {
+		msg := err.Error()
+		err = errors.New("validation error")
+		b := validateError{field, msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	g, err := model.LoadGroup(tx, request.Name)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	g.Policies, err = g.Policies.Remove(request.Index)
+	if err != nil {
+		msg := err.Error()
+		err = errors.New("validation error")
+		b := validateError{"index", msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	err = g.Store(tx)
+	if err != nil {
+		err = fmt.Errorf("can't store group: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("can't commit tx: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	api.notify()
+
+	return json.RawMessage(`{"msg": "ok"}`), nil
+}
+
+// GroupPolicyRemoveRequest model.
+type GroupPolicyRemoveRequest struct {
+	Name  string `json:"name"`
+	Index int    `json:"index"`
+}
+
+func (s *GroupPolicyRemoveRequest) validate() (string, error) {
+	err := model.ValidateGroupName(s.Name)
+	if err != nil {
+		return "name", err
+	}
+
+	if s.Index < 0 {
+		err := errors.New("should be non negative")
+		return "index", err
+	}
+
+	return "", nil
+}
+
+// Marshall returns the json encoding of GroupPolicyRemoveRequest.
+func (s GroupPolicyRemoveRequest) Marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
+
+// group handler
+func (api *API) group(
+	ctx context.Context, w http.ResponseWriter, r json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(false) // non-writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	request := new(GroupRequest)
+	err = json.Unmarshal(r, &request)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	field, err := request.Validate()
+	if err != nil {
+		msg := err.Error()
+		err = errors.New("validation error")
+		b := validateError{field, msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	g, err := model.LoadGroup(tx, request.Name)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	devices, err := model.LoadDevices(tx)
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	devices = devices.Group(g.Name)
+
+	response := GroupResponse{
+		Name:     g.Name,
+		Devices:  make([]GroupDeviceItem, 0, len(devices)),
+		Policies: make([]GroupPolicyItem, 0, len(g.Policies)),
+	}
+	for _, d := range devices {
+		response.Devices = append(response.Devices, GroupDeviceItem{
+			IP:       d.IPNetwork.IP.String(),
+			Label:    d.Label,
+			Network:  d.Network,
+			UserUUID: d.UserUUID,
+		})
+	}
+	for idx, p := range g.Policies {
+		response.Policies = append(response.Policies, GroupPolicyItem{
+			Index:   idx,
+			To:      p.To,
+			IPProto: p.IPProto,
+			DPorts:  p.DPorts,
+		})
+	}
+
+	return response.marshal(), nil
+}
+
+// GroupRequest model.
+type GroupRequest struct {
+	Name string `json:"name"`
+}
+
+// Validate fields.
+func (s *GroupRequest) Validate() (string, error) {
+	err := model.ValidateGroupName(s.Name)
+	if err != nil {
+		return "name", err
+	}
+
+	return "", nil
+}
+
+// Marshall returns the json encoding of GroupRequest.
+func (s GroupRequest) Marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
+
+// GroupResponse model.
+type GroupResponse struct {
+	Name     string            `json:"name"`
+	Devices  []GroupDeviceItem `json:"devices"`
+	Policies []GroupPolicyItem `json:"policies"`
+}
+
+func (s GroupResponse) marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
+
+// GroupDeviceItem model.
+type GroupDeviceItem struct {
+	IP       string `json:"ip"`
+	Label    string `json:"label"`
+	Network  string `json:"network"`
+	UserUUID string `json:"user_uuid"`
+}
+
+// GroupPolicyItem model.
+type GroupPolicyItem struct {
+	Index   int      `json:"index"`
+	To      string   `json:"to"`
+	IPProto string   `json:"ipproto"`
+	DPorts  []uint16 `json:"dports"`
+}
+
+// groupList handler
+func (api *API) groupList(
+	ctx context.Context, w http.ResponseWriter, _ json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(false) // non-writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	groups, err := model.LoadGroups(tx)
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	devices, err := model.LoadDevices(tx)
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	response := make(GroupListResponse, 0, len(groups))
+	for _, g := range groups {
+		response = append(response, GroupListItem{
+			Name:     g.Name,
+			Devices:  len(devices.Group(g.Name)),
+			Policies: len(g.Policies),
+		})
+	}
+
+	return response.marshal(), nil
+}
+
+// GroupListItem model.
+type GroupListItem struct {
+	Name     string `json:"name"`
+	Devices  int    `json:"devices"`
+	Policies int    `json:"policies"`
+}
+
+// GroupListResponse model.
+type GroupListResponse []GroupListItem
+
+func (s GroupListResponse) marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
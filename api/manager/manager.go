@@ -8,25 +8,77 @@ import (
 	"time"
 
 	bolt "go.etcd.io/bbolt"
-	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 
+	"wgnetwork/model"
 	"wgnetwork/pkg/rpcapi"
+	"wgnetwork/pkg/wgmngr"
 )
 
 // Config object.
 type Config struct {
 	AuthRequired bool
 
-	WanIP    net.IP
-	WgInet   *net.IPNet
-	WgIPNet  *net.IPNet
-	WgPort   uint16
-	WgPubKey wgtypes.Key
+	// WanIP returns the current ip address of wan interface,
+	// it may change while running.
+	WanIP func() net.IP
+
+	// HostNets returns the networks of the host interfaces and routes,
+	// the routes of the devices shouldn't overlap them.
+	HostNets func() ([]*net.IPNet, error)
+
+	// HostPorts are the tcp ports of the host served on the wan,
+	// the trust ports and the listeners of the service, they are
+	// never forwarded.
+	HostPorts []uint16
+
+	// Endpoints the devices connect the server at unless the network
+	// has its own ones, the wan ip address is used if empty.
+	Endpoints []model.Endpoint
+
+	// Networks served, the first one is the default network.
+	Networks []Network
 
 	OTPIssuer string
 
 	SessionSecret string
 	SessionTTL    time.Duration
+
+	// Notify is called after a committed change to reconcile the state.
+	Notify func()
+
+	// FirewallPreview renders the firewall ruleset as nft text.
+	FirewallPreview func() (string, error)
+
+	// FirewallBlacklist returns the sources banned by the rate limits.
+	FirewallBlacklist func() ([]net.IP, error)
+
+	// FirewallUnban removes the source from the blacklist.
+	FirewallUnban func(net.IP) error
+
+	// FirewallStats returns the named counters of the firewall rules.
+	FirewallStats func() ([]FirewallCounter, error)
+
+	// FirewallConfirm confirms the pending firewall changes,
+	// nil if the confirmation is turned off.
+	FirewallConfirm func() error
+
+	// FirewallDeadline returns the deadline of the pending firewall
+	// changes, nil if nothing is pending.
+	FirewallDeadline func() *time.Time
+}
+
+// Network object.
+type Network struct {
+	Name    string
+	WgInet  *net.IPNet
+	WgIPNet *net.IPNet
+	WgPort  uint16
+
+	// optional ipv6 prefix of the network
+	WgInet6  *net.IPNet
+	WgIPNet6 *net.IPNet
+
+	WgManager *wgmngr.Manager
 }
 
 // API object.
@@ -57,18 +109,58 @@ func New(
 // RegisterHandlers on provided api handler.
 func (api *API) RegisterHandlers(rpc *rpcapi.API) {
 	rpc.Register("manager/wg/cfg", api.wgCfg)
+	rpc.Register("manager/wg/rotate-key", api.wgRotateKey)
+	rpc.Register("manager/wg/networks", api.wgNetworks)
+	rpc.Register("manager/wg/endpoints/set", api.wgEndpointsSet)
 
 	rpc.Register("manager/user/create", api.userCreate)
 	rpc.Register("manager/user/edit", api.userEdit)
 	rpc.Register("manager/user/remove", api.userRemove)
 	rpc.Register("manager/user", api.user)
 	rpc.Register("manager/users", api.userList)
+	rpc.Register("manager/user/rule/add", api.userRuleAdd)
+	rpc.Register("manager/user/rule/remove", api.userRuleRemove)
+	rpc.Register("manager/user/rules", api.userRules)
 
 	rpc.Register("manager/device/create", api.deviceCreate)
 	rpc.Register("manager/device/edit", api.deviceEdit)
 	rpc.Register("manager/device/remove", api.deviceRemove)
+	rpc.Register("manager/device/rotate-key", api.deviceRotateKey)
 	rpc.Register("manager/device", api.device)
 	rpc.Register("manager/devices", api.deviceList)
+	rpc.Register("manager/device/rule/add", api.deviceRuleAdd)
+	rpc.Register("manager/device/rule/remove", api.deviceRuleRemove)
+	rpc.Register("manager/device/rules", api.deviceRules)
+	rpc.Register("manager/device/egress/add", api.deviceEgressAdd)
+	rpc.Register("manager/device/egress/remove", api.deviceEgressRemove)
+	rpc.Register("manager/device/egress", api.deviceEgress)
+	rpc.Register("manager/device/pair/add", api.devicePairAdd)
+	rpc.Register("manager/device/pair/remove", api.devicePairRemove)
+	rpc.Register("manager/device/pairs", api.devicePairList)
+
+	rpc.Register("manager/group/create", api.groupCreate)
+	rpc.Register("manager/group/remove", api.groupRemove)
+	rpc.Register("manager/group/device/add", api.groupDeviceAdd)
+	rpc.Register("manager/group/device/remove", api.groupDeviceRemove)
+	rpc.Register("manager/group/policy/add", api.groupPolicyAdd)
+	rpc.Register("manager/group/policy/remove", api.groupPolicyRemove)
+	rpc.Register("manager/group", api.group)
+	rpc.Register("manager/groups", api.groupList)
+
+	rpc.Register("manager/port-forward/add", api.portForwardAdd)
+	rpc.Register("manager/port-forward/remove", api.portForwardRemove)
+	rpc.Register("manager/port-forwards", api.portForwardList)
+
+	rpc.Register("manager/egress/rule/add", api.egressRuleAdd)
+	rpc.Register("manager/egress/rule/remove", api.egressRuleRemove)
+	rpc.Register("manager/egress/rules", api.egressRules)
+
+	rpc.Register("manager/firewall/preview", api.firewallPreview)
+	rpc.Register("manager/firewall/blacklist", api.firewallBlacklist)
+	rpc.Register("manager/firewall/unban", api.firewallUnban)
+	rpc.Register("manager/firewall/stats", api.firewallStats)
+	rpc.Register("manager/firewall/confirm", api.firewallConfirm)
+	rpc.Register("manager/firewall/pending", api.firewallPending)
 
 	rpc.Register("manager/trust/ipset/add", api.trustIPSetAdd)
 	rpc.Register("manager/trust/ipset/remove", api.trustIPSetRemove)
@@ -82,6 +174,13 @@ func (api *API) RegisterHandlers(rpc *rpcapi.API) {
 	rpc.Register("manager/dns/domains", api.domainList)
 }
 
+// notify about the state change.
+func (api *API) notify() {
+	if api.cfg.Notify != nil {
+		api.cfg.Notify()
+	}
+}
+
 // rpcError object
 type rpcError struct {
 	Code    int             `json:"code"`
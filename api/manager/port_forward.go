@@ -0,0 +1,343 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"wgnetwork/model"
+)
+
+// portForwardAdd handler
+func (api *API) portForwardAdd(
+	ctx context.Context, w http.ResponseWriter, r json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(true) // writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	request := new(PortForwardAddRequest)
+	err = json.Unmarshal(r, &request)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	field, err := request.validate()
+	if err != nil {
+		msg := err.Error()
+		err = errors.New("validation error")
+		b := validateError{field, msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	// wireguard ports are served on the wan
+	if request.Proto == "udp" {
+		for _, n := range api.cfg.Networks {
+			if n.WgPort == request.Port {
+				msg := "used by wireguard"
+				err = errors.New("validation error")
+				b := validateError{"port", msg}.marshal()
+				b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+				return b, err
+			}
+		}
+	}
+
+	// ssh and the service itself are served on the wan
+	if request.Proto == "tcp" {
+		for _, port := range api.cfg.HostPorts {
+			if port == request.Port {
+				msg := "used by the server"
+				err = errors.New("validation error")
+				b := validateError{"port", msg}.marshal()
+				b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+				return b, err
+			}
+		}
+	}
+
+	_, err = model.LoadPortForward(tx, request.Proto, request.Port)
+	if err == nil {
+		msg := "already forwarded"
+		err = errors.New("validation error")
+		b := validateError{"port", msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	ip := net.ParseIP(request.IP).To4()
+	_, err = model.LoadDevice(tx, ip)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	f := model.NewPortForward(
+		request.Proto, request.Port, ip, request.DevicePort)
+
+	err = f.Store(tx)
+	if err != nil {
+		err = fmt.Errorf("can't store port forward: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("can't commit tx: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	api.notify()
+
+	return json.RawMessage(`{"msg": "ok"}`), nil
+}
+
+// PortForwardAddRequest model.
+type PortForwardAddRequest struct {
+	Proto      string `json:"proto"`
+	Port       uint16 `json:"port"`
+	IP         string `json:"ip"`
+	DevicePort uint16 `json:"device_port"`
+}
+
+func (s *PortForwardAddRequest) validate() (string, error) {
+	if s.Proto != "tcp" && s.Proto != "udp" {
+		err := errors.New("tcp or udp expected")
+		return "proto", err
+	}
+
+	if s.Port == 0 {
+		err := errors.New("required")
+		return "port", err
+	}
+
+	if net.ParseIP(s.IP).To4() == nil {
+		err := errors.New("required")
+		return "ip", err
+	}
+
+	if s.DevicePort == 0 {
+		err := errors.New("required")
+		return "device_port", err
+	}
+
+	return "", nil
+}
+
+// Marshall returns the json encoding of PortForwardAddRequest.
+func (s PortForwardAddRequest) Marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
+
+// portForwardRemove handler
+func (api *API) portForwardRemove(
+	ctx context.Context, w http.ResponseWriter, r json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(true) // writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	request := new(PortForwardRemoveRequest)
+	err = json.Unmarshal(r, &request)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	field, err := request.validate()
+	if err != nil {
+		msg := err.Error()
+		err = errors.New("validation error")
+		b := validateError{field, msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	_, err = model.LoadPortForward(tx, request.Proto, request.Port)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	err = model.RemovePortForward(tx, request.Proto, request.Port)
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("can't commit tx: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	api.notify()
+
+	return json.RawMessage(`{"msg": "ok"}`), nil
+}
+
+// PortForwardRemoveRequest model.
+type PortForwardRemoveRequest struct {
+	Proto string `json:"proto"`
+	Port  uint16 `json:"port"`
+}
+
+func (s *PortForwardRemoveRequest) validate() (string, error) {
+	if s.Proto != "tcp" && s.Proto != "udp" {
+		err := errors.New("tcp or udp expected")
+		return "proto", err
+	}
+
+	if s.Port == 0 {
+		err := errors.New("required")
+		return "port", err
+	}
+
+	return "", nil
+}
+
+// Marshall returns the json encoding of PortForwardRemoveRequest.
+func (s PortForwardRemoveRequest) Marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
+
+// portForwardList handler
+func (api *API) portForwardList(
+	ctx context.Context, w http.ResponseWriter, _ json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(false) // non-writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	forwards, err := model.LoadPortForwards(tx)
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	response := make(PortForwardListResponse, 0, len(forwards))
+	for _, f := range forwards {
+		item := PortForwardListItem{
+			Proto:      f.Proto,
+			Port:       f.Port,
+			IP:         f.IP.String(),
+			DevicePort: f.DevicePort,
+		}
+
+		d, err := model.LoadDevice(tx, f.IP)
+		if err == nil {
+			item.Label = d.Label
+			item.Disabled = d.Disabled
+		}
+
+		response = append(response, item)
+	}
+
+	return response.marshal(), nil
+}
+
+// PortForwardListItem model.
+type PortForwardListItem struct {
+	Proto      string `json:"proto"`
+	Port       uint16 `json:"port"`
+	IP         string `json:"ip"`
+	DevicePort uint16 `json:"device_port"`
+	Label      string `json:"label"`
+	Disabled   bool   `json:"disabled"`
+}
+
+// PortForwardListResponse model.
+type PortForwardListResponse []PortForwardListItem
+
+func (s PortForwardListResponse) marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
@@ -0,0 +1,641 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"wgnetwork/model"
+)
+
+// deviceRuleAdd handler
+func (api *API) deviceRuleAdd(
+	ctx context.Context, w http.ResponseWriter, r json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(true) // writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	request := new(DeviceRuleAddRequest)
+	err = json.Unmarshal(r, &request)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	field, err := request.validate()
+	if err != nil {
+		msg := err.Error()
+		err = errors.New("validation error")
+		b := validateError{field, msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	ip := net.ParseIP(request.IP).To4()
+	d, err := model.LoadDevice(tx, ip)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	d.Rules = d.Rules.Add(request.Rule.nfrule())
+
+	err = d.Store(tx)
+	if err != nil {
+		err = fmt.Errorf("can't store device: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("can't commit tx: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	api.notify()
+
+	return json.RawMessage(`{"msg": "ok"}`), nil
+}
+
+// DeviceRuleAddRequest model.
+type DeviceRuleAddRequest struct {
+	IP   string      `json:"ip"`
+	Rule RuleRequest `json:"rule"`
+}
+
+func (s *DeviceRuleAddRequest) validate() (string, error) {
+	if net.ParseIP(s.IP).To4() == nil {
+		err := errors.New("required")
+		return "ip", err
+	}
+
+	return s.Rule.validate()
+}
+
+// Marshall returns the json encoding of DeviceRuleAddRequest.
+func (s DeviceRuleAddRequest) Marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
+
+// deviceRuleRemove handler
+func (api *API) deviceRuleRemove(
+	ctx context.Context, w http.ResponseWriter, r json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(true) // writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	request := new(DeviceRuleRemoveRequest)
+	err = json.Unmarshal(r, &request)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	field, err := request.validate()
+	if err != nil {
+		msg := err.Error()
+		err = errors.New("validation error")
+		b := validateError{field, msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	ip := net.ParseIP(request.IP).To4()
+	d, err := model.LoadDevice(tx, ip)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	d.Rules, err = d.Rules.Remove(request.Index)
+	if err != nil {
+		msg := err.Error()
+		err = errors.New("validation error")
+		b := validateError{"index", msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	err = d.Store(tx)
+	if err != nil {
+		err = fmt.Errorf("can't store device: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("can't commit tx: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	api.notify()
+
+	return json.RawMessage(`{"msg": "ok"}`), nil
+}
+
+// DeviceRuleRemoveRequest model.
+type DeviceRuleRemoveRequest struct {
+	IP    string `json:"ip"`
+	Index int    `json:"index"`
+}
+
+func (s *DeviceRuleRemoveRequest) validate() (string, error) {
+	if net.ParseIP(s.IP).To4() == nil {
+		err := errors.New("required")
+		return "ip", err
+	}
+
+	if s.Index < 0 {
+		err := errors.New("should be non negative")
+		return "index", err
+	}
+
+	return "", nil
+}
+
+// Marshall returns the json encoding of DeviceRuleRemoveRequest.
+func (s DeviceRuleRemoveRequest) Marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
+
+// deviceRules handler
+func (api *API) deviceRules(
+	ctx context.Context, w http.ResponseWriter, r json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(false) // non-writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	request := new(DeviceRequest)
+	err = json.Unmarshal(r, &request)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	field, err := request.Validate()
+	if err != nil {
+		msg := err.Error()
+		err = errors.New("validation error")
+		b := validateError{field, msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	ip := net.ParseIP(request.IP).To4()
+	d, err := model.LoadDevice(tx, ip)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	response := newRulesResponse(d.Rules)
+
+	return response.marshal(), nil
+}
+
+// userRuleAdd handler
+func (api *API) userRuleAdd(
+	ctx context.Context, w http.ResponseWriter, r json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(true) // writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	request := new(UserRuleAddRequest)
+	err = json.Unmarshal(r, &request)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	field, err := request.validate()
+	if err != nil {
+		msg := err.Error()
+		err = errors.New("validation error")
+		b := validateError{field, msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	u, err := model.LoadUser(tx, request.UUID)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	u.Rules = u.Rules.Add(request.Rule.nfrule())
+
+	err = u.Store(tx)
+	if err != nil {
+		err = fmt.Errorf("can't store user: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("can't commit tx: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	api.notify()
+
+	return json.RawMessage(`{"msg": "ok"}`), nil
+}
+
+// UserRuleAddRequest model.
+type UserRuleAddRequest struct {
+	UUID string      `json:"uuid"`
+	Rule RuleRequest `json:"rule"`
+}
+
+func (s *UserRuleAddRequest) validate() (string, error) {
+	_, err := uuid.Parse(s.UUID)
+	if err != nil {
+		err := errors.New("required")
+		return "uuid", err
+	}
+
+	return s.Rule.validate()
+}
+
+// Marshall returns the json encoding of UserRuleAddRequest.
+func (s UserRuleAddRequest) Marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
+
+// userRuleRemove handler
+func (api *API) userRuleRemove(
+	ctx context.Context, w http.ResponseWriter, r json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(true) // writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	request := new(UserRuleRemoveRequest)
+	err = json.Unmarshal(r, &request)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	field, err := request.validate()
+	if err != nil {
+		msg := err.Error()
+		err = errors.New("validation error")
+		b := validateError{field, msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	u, err := model.LoadUser(tx, request.UUID)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	u.Rules, err = u.Rules.Remove(request.Index)
+	if err != nil {
+		msg := err.Error()
+		err = errors.New("validation error")
+		b := validateError{"index", msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	err = u.Store(tx)
+	if err != nil {
+		err = fmt.Errorf("can't store user: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("can't commit tx: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	api.notify()
+
+	return json.RawMessage(`{"msg": "ok"}`), nil
+}
+
+// UserRuleRemoveRequest model.
+type UserRuleRemoveRequest struct {
+	UUID  string `json:"uuid"`
+	Index int    `json:"index"`
+}
+
+func (s *UserRuleRemoveRequest) validate() (string, error) {
+	_, err := uuid.Parse(s.UUID)
+	if err != nil {
+		err := errors.New("required")
+		return "uuid", err
+	}
+
+	if s.Index < 0 {
+		err := errors.New("should be non negative")
+		return "index", err
+	}
+
+	return "", nil
+}
+
+// Marshall returns the json encoding of UserRuleRemoveRequest.
+func (s UserRuleRemoveRequest) Marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
+
+// userRules handler
+func (api *API) userRules(
+	ctx context.Context, w http.ResponseWriter, r json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(false) // non-writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	request := new(UserRequest)
+	err = json.Unmarshal(r, &request)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	field, err := request.Validate()
+	if err != nil {
+		msg := err.Error()
+		err = errors.New("validation error")
+		b := validateError{field, msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	u, err := model.LoadUser(tx, request.UUID)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	response := newRulesResponse(u.Rules)
+
+	return response.marshal(), nil
+}
+
+// RuleRequest model, allows connections matching the rule.
+type RuleRequest struct {
+	IPProto   string   `json:"ipproto"`
+	Direction string   `json:"direction"`
+	SPorts    []uint16 `json:"sports"`
+	DPorts    []uint16 `json:"dports"`
+	SAddrs    []string `json:"saddrs"`
+	DAddrs    []string `json:"daddrs"`
+}
+
+func (s *RuleRequest) validate() (string, error) {
+	for _, v := range s.SAddrs {
+		if net.ParseIP(v) == nil {
+			err := fmt.Errorf("bad address %q", v)
+			return "saddrs", err
+		}
+	}
+	for _, v := range s.DAddrs {
+		if net.ParseIP(v) == nil {
+			err := fmt.Errorf("bad address %q", v)
+			return "daddrs", err
+		}
+	}
+
+	r := s.nfrule()
+	err := r.Validate()
+	if err != nil {
+		return "rule", err
+	}
+
+	return "", nil
+}
+
+func (s *RuleRequest) nfrule() model.NFRule {
+	r := model.NFRule{
+		IPProto:   s.IPProto,
+		Direction: s.Direction,
+		SPorts:    s.SPorts,
+		DPorts:    s.DPorts,
+	}
+	for _, v := range s.SAddrs {
+		r.SAddrs = append(r.SAddrs, net.ParseIP(v))
+	}
+	for _, v := range s.DAddrs {
+		r.DAddrs = append(r.DAddrs, net.ParseIP(v))
+	}
+
+	return r
+}
+
+// RuleListItem model.
+type RuleListItem struct {
+	Index     int      `json:"index"`
+	IPProto   string   `json:"ipproto"`
+	Direction string   `json:"direction"`
+	SPorts    []uint16 `json:"sports"`
+	DPorts    []uint16 `json:"dports"`
+	SAddrs    []net.IP `json:"saddrs"`
+	DAddrs    []net.IP `json:"daddrs"`
+}
+
+// RulesResponse model.
+type RulesResponse []RuleListItem
+
+func newRulesResponse(rules model.NFRules) RulesResponse {
+	response := make(RulesResponse, 0, len(rules))
+	for idx, r := range rules {
+		response = append(response, RuleListItem{
+			Index:     idx,
+			IPProto:   r.IPProto,
+			Direction: r.Direction,
+			SPorts:    r.SPorts,
+			DPorts:    r.DPorts,
+			SAddrs:    r.SAddrs,
+			DAddrs:    r.DAddrs,
+		})
+	}
+
+	return response
+}
+
+func (s RulesResponse) marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
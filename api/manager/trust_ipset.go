@@ -5,8 +5,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"net"
 	"net/http"
+	"time"
 
 	"wgnetwork/model"
 )
@@ -68,8 +68,20 @@ func (api *API) trustIPSetAdd(
 		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
 	}
 
-	ip := net.ParseIP(request.IP).To4()
-	ipset.Add(ip)
+	// omit error check, we already validate this value
+	e, _ := model.NewManagerSSHTrustIP(
+		request.IP, request.Comment, parseExpiresAt(request.ExpiresAt))
+
+	// interval set doesn't accept overlapping ranges
+	if v, ok := ipset.Active(time.Now()).Overlapped(e); ok {
+		msg := fmt.Sprintf("overlaps %s", v)
+		err = errors.New("validation error")
+		b := validateError{"ip", msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	ipset.Add(e)
 
 	err = ipset.Store(tx)
 	if err != nil {
@@ -83,6 +95,8 @@ func (api *API) trustIPSetAdd(
 		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
 	}
 
+	api.notify()
+
 	return json.RawMessage(`{"msg": "ok"}`), nil
 }
 
@@ -143,8 +157,9 @@ func (api *API) trustIPSetRemove(
 		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
 	}
 
-	ip := net.ParseIP(request.IP).To4()
-	ipset.Remove(ip)
+	// omit error check, we already validate this value
+	ipnet, _ := model.ParseTrustIPNet(request.IP)
+	ipset.Remove(ipnet)
 
 	err = ipset.Store(tx)
 	if err != nil {
@@ -158,19 +173,49 @@ func (api *API) trustIPSetRemove(
 		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
 	}
 
+	api.notify()
+
 	return json.RawMessage(`{"msg": "ok"}`), nil
 }
 
 // TrustIPSetRequest model.
 type TrustIPSetRequest struct {
+	// IP is ipv4 address or cidr range.
 	IP string `json:"ip"`
+
+	// Comment and ExpiresAt are optional, they are used on add only.
+	Comment string `json:"comment,omitempty"`
+	// ExpiresAt is an optional expiration time in RFC 3339 format.
+	ExpiresAt string `json:"expires_at,omitempty"`
 }
 
 func (s *TrustIPSetRequest) validate() (string, error) {
-	if net.ParseIP(s.IP).To4() == nil {
+	if len(s.IP) == 0 {
 		err := errors.New("required")
 		return "ip", err
 	}
+	_, err := model.ParseTrustIPNet(s.IP)
+	if err != nil {
+		err = errors.New("bad value")
+		return "ip", err
+	}
+
+	if len(s.Comment) > 64 {
+		err := errors.New("length should be lower than 65")
+		return "comment", err
+	}
+
+	if len(s.ExpiresAt) > 0 {
+		t, err := time.Parse(time.RFC3339, s.ExpiresAt)
+		if err != nil {
+			err = errors.New("bad value")
+			return "expires_at", err
+		}
+		if !t.After(time.Now()) {
+			err = errors.New("should be in the future")
+			return "expires_at", err
+		}
+	}
 
 	return "", nil
 }
@@ -223,13 +268,30 @@ func (api *API) trustIPSet(
 		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
 	}
 
-	response := TrustIPSetResponse(ipset)
+	now := time.Now()
+	response := make(TrustIPSetResponse, len(ipset))
+	for i, e := range ipset {
+		response[i] = TrustIPSetItem{
+			IP:        e.String(),
+			Comment:   e.Comment,
+			ExpiresAt: e.ExpiresAt,
+			Expired:   e.Expired(now),
+		}
+	}
 
 	return response.marshal(), nil
 }
 
+// TrustIPSetItem model.
+type TrustIPSetItem struct {
+	IP        string     `json:"ip"`
+	Comment   string     `json:"comment"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Expired   bool       `json:"expired"`
+}
+
 // TrustIPSetResponse model.
-type TrustIPSetResponse []net.IP
+type TrustIPSetResponse []TrustIPSetItem
 
 func (s TrustIPSetResponse) marshal() json.RawMessage {
 	b, _ := json.Marshal(s)
@@ -95,6 +95,8 @@ func (api *API) userCreate(
 		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
 	}
 
+	api.notify()
+
 	return response.marshal(), nil
 }
 
@@ -221,6 +223,8 @@ func (api *API) userEdit(
 		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
 	}
 
+	api.notify()
+
 	response := UserResponse{
 		UUID:      u.UUID,
 		Name:      u.Name,
@@ -330,6 +334,8 @@ func (api *API) userRemove(
 		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
 	}
 
+	api.notify()
+
 	return json.RawMessage(`{"msg": "ok"}`), nil
 }
 
@@ -4,22 +4,29 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 
 	"wgnetwork/model"
+	"wgnetwork/pkg/wgmngr"
 )
 
 // wgCfg handler
 func (api *API) wgCfg(
-	ctx context.Context, w http.ResponseWriter, _ json.RawMessage,
+	ctx context.Context, w http.ResponseWriter, r json.RawMessage,
 ) (json.RawMessage, error) {
-	if api.cfg.AuthRequired {
-		tx, err := api.db.Begin(false) // non-writeable tx
-		if err != nil {
-			return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
-		}
-		defer tx.Rollback()
+	tx, err := api.db.Begin(false) // non-writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
 
+	if api.cfg.AuthRequired {
 		ip, s, err := sessionCtx(ctx)
 		if err != nil {
 			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
@@ -46,25 +53,571 @@ func (api *API) wgCfg(
 		w.Header().Set("x-session", s)
 	}
 
+	// params are optional, default network is used if omitted
+	request := new(WgCfgRequest)
+	if len(r) > 0 {
+		err = json.Unmarshal(r, &request)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+	}
+
+	n, ok := api.network(request.Network)
+	if !ok {
+		err = errors.New("validation error")
+		b := validateError{"network", "unknown network"}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	response, err := api.wgCfgResponse(tx, n)
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	return response.marshal(), nil
+}
+
+func (api *API) wgCfgResponse(tx *bolt.Tx, n Network) (WgCfgResponse, error) {
+	wgs, _, err := model.LoadWgServer(tx, api.networkKey(n))
+	if err != nil {
+		return WgCfgResponse{}, err
+	}
+
 	response := WgCfgResponse{
-		WanIP:    api.cfg.WanIP.String(),
-		WgInet:   api.cfg.WgInet.String(),
-		WgPort:   api.cfg.WgPort,
-		WgPubKey: api.cfg.WgPubKey.String(),
-	}.marshal()
+		Network:   n.Name,
+		WanIP:     api.cfg.WanIP().String(),
+		Endpoints: api.endpoints(wgs, n),
+		WgInet:    n.WgInet.String(),
+		WgPort:    n.WgPort,
+		WgPubKey:  n.WgManager.PublicKey().String(),
+	}
+	if n.WgInet6 != nil {
+		response.WgInet6 = n.WgInet6.String()
+	}
+	if !wgs.RotatedAt.IsZero() {
+		response.RotatedAt = &wgs.RotatedAt
+	}
 
 	return response, nil
 }
 
+// WgCfgRequest model.
+type WgCfgRequest struct {
+	Network string `json:"network"`
+}
+
+// Marshall returns the json encoding of WgCfgRequest.
+func (s WgCfgRequest) Marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
+
 // WgCfgResponse model.
 type WgCfgResponse struct {
-	WanIP    string `json:"wanip"`
-	WgInet   string `json:"wg_inet"`
-	WgPort   uint16 `json:"wg_port"`
-	WgPubKey string `json:"wg_pubkey"`
+	Network   string     `json:"network"`
+	WanIP     string     `json:"wanip"`
+	Endpoints []string   `json:"endpoints"`
+	WgInet    string     `json:"wg_inet"`
+	WgInet6   string     `json:"wg_inet6,omitempty"`
+	WgPort    uint16     `json:"wg_port"`
+	WgPubKey  string     `json:"wg_pubkey"`
+	RotatedAt *time.Time `json:"rotated_at"`
 }
 
 func (s WgCfgResponse) marshal() json.RawMessage {
 	b, _ := json.Marshal(s)
 	return json.RawMessage(b)
 }
+
+// wgNetworks handler
+func (api *API) wgNetworks(
+	ctx context.Context, w http.ResponseWriter, _ json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(false) // non-writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	response := make(WgNetworksResponse, len(api.cfg.Networks))
+	for i, n := range api.cfg.Networks {
+		response[i], err = api.wgCfgResponse(tx, n)
+		if err != nil {
+			return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+		}
+	}
+
+	return response.marshal(), nil
+}
+
+// WgNetworksResponse model.
+type WgNetworksResponse []WgCfgResponse
+
+func (s WgNetworksResponse) marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
+
+// wgRotateKey handler
+func (api *API) wgRotateKey(
+	ctx context.Context, w http.ResponseWriter, r json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(true) // writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	request := new(WgRotateKeyRequest)
+	err = json.Unmarshal(r, &request)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	n, ok := api.network(request.Network)
+	if !ok {
+		err = errors.New("validation error")
+		b := validateError{"network", "unknown network"}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	wgs, _, err := model.LoadWgServer(tx, api.networkKey(n))
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	prevKey := wgs.PrivateKey
+
+	wgs.PrivateKey, err = wgtypes.GeneratePrivateKey()
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	wgs.RotatedAt = time.Now().UTC().Truncate(time.Second)
+
+	err = wgs.Store(tx)
+	if err != nil {
+		err = fmt.Errorf("can't store wg server: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	err = n.WgManager.SetPrivateKey(wgs.PrivateKey)
+	if err != nil {
+		err = fmt.Errorf("can't apply private key: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		// keep the interface in sync with the stored key
+		if err := n.WgManager.SetPrivateKey(prevKey); err != nil {
+			api.log.Errorf("can't restore private key: %v", err)
+		}
+		err = fmt.Errorf("can't commit tx: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	response := WgRotateKeyResponse{
+		WgCfgResponse: WgCfgResponse{
+			Network:   n.Name,
+			WanIP:     api.cfg.WanIP().String(),
+			Endpoints: api.endpoints(wgs, n),
+			WgInet:    n.WgInet.String(),
+			WgPort:    n.WgPort,
+			WgPubKey:  wgs.PrivateKey.PublicKey().String(),
+			RotatedAt: &wgs.RotatedAt,
+		},
+	}
+	if n.WgInet6 != nil {
+		response.WgInet6 = n.WgInet6.String()
+	}
+
+	if !request.Configs {
+		return response.marshal(), nil
+	}
+
+	tx, err = api.db.Begin(false) // non-writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	devices, err := model.LoadDevices(tx)
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	devices = devices.Network(api.networkKey(n))
+
+	users, err := model.LoadUsers(tx)
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	usernames := make(map[string]string, len(users))
+	for _, u := range users {
+		usernames[u.UUID] = u.Name
+	}
+
+	response.Devices = make([]DeviceCreateResponse, len(devices))
+	for i, d := range devices {
+		allowedIPs := d.AllowedIPs(n.WgIPNet6, devices.Routes(d.IPNetwork.IP))
+
+		response.Devices[i] = DeviceCreateResponse{
+			UserUUID:   d.UserUUID,
+			UserName:   usernames[d.UserUUID],
+			Label:      d.Label,
+			WANForward: d.WANForward,
+			Disabled:   d.Disabled,
+			Network:    n.Name,
+
+			ExpiresAt: d.ExpiresAt,
+			Expired:   d.Expired(time.Now()),
+
+			WgDeviceInet:       d.CIDR().String(),
+			WgDevicePort:       n.WgPort,
+			WgDevicePubKey:     d.PubKey.String(),
+			WgDeviceAllowedIPs: make([]string, len(allowedIPs)),
+			WgDeviceRoutes:     formatRoutes(d.Routes),
+
+			WgInet:   n.WgInet.String(),
+			WgIPNet:  n.WgIPNet.String(),
+			WgIP:     n.WgInet.IP.String(),
+			WgPort:   n.WgPort,
+			WgPubKey: response.WgPubKey,
+
+			WanIP:     api.cfg.WanIP().String(),
+			Endpoints: response.Endpoints,
+		}
+		response.Devices[i].WgDeviceInet6,
+			response.Devices[i].WgIPNet6,
+			response.Devices[i].WgIP6 = deviceInet6(d, n)
+		if d.PresharedKey != [wgtypes.KeyLen]byte{} {
+			response.Devices[i].WgDevicePresharedKey = d.PresharedKey.String()
+		}
+		for idx, item := range allowedIPs {
+			response.Devices[i].WgDeviceAllowedIPs[idx] = item.String()
+		}
+	}
+
+	return response.marshal(), nil
+}
+
+// WgRotateKeyRequest model.
+type WgRotateKeyRequest struct {
+	Network string `json:"network"`
+
+	// Configs requests client configs of every device to be re-issued.
+	Configs bool `json:"configs"`
+}
+
+// Marshall returns the json encoding of WgRotateKeyRequest.
+func (s WgRotateKeyRequest) Marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
+
+// WgRotateKeyResponse model.
+type WgRotateKeyResponse struct {
+	WgCfgResponse
+
+	Devices []DeviceCreateResponse `json:"devices,omitempty"`
+}
+
+func (s WgRotateKeyResponse) marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
+
+// wgEndpointsSet handler
+func (api *API) wgEndpointsSet(
+	ctx context.Context, w http.ResponseWriter, r json.RawMessage,
+) (json.RawMessage, error) {
+	tx, err := api.db.Begin(true) // writeable tx
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	defer tx.Rollback()
+
+	if api.cfg.AuthRequired {
+		ip, s, err := sessionCtx(ctx)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+		if s == "" {
+			err := errors.New("session not found")
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		d, err := model.LoadDevice(tx, ip)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		u, err := model.SessionUser(tx, api.cfg.SessionSecret, s)
+		if err != nil {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		if u.UUID != d.UserUUID {
+			return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+		}
+
+		w.Header().Set("x-session", s)
+	}
+
+	request := new(WgEndpointsSetRequest)
+	err = json.Unmarshal(r, &request)
+	if err != nil {
+		return rpcError{Code: 400, Message: "bad request"}.marshal(), err
+	}
+
+	field, err := request.validate()
+	if err != nil {
+		msg := err.Error()
+		err = errors.New("validation error")
+		b := validateError{field, msg}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	n, ok := api.network(request.Network)
+	if !ok {
+		err = errors.New("validation error")
+		b := validateError{"network", "unknown network"}.marshal()
+		b = rpcError{Code: 401, Message: "bad request", Data: b}.marshal()
+		return b, err
+	}
+
+	wgs, ok, err := model.LoadWgServer(tx, api.networkKey(n))
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+	if !ok {
+		err = errors.New("wg server not found")
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	wgs.Endpoints = request.endpoints()
+	err = wgs.Store(tx)
+	if err != nil {
+		err = fmt.Errorf("can't store wg server: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	response, err := api.wgCfgResponse(tx, n)
+	if err != nil {
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("can't commit tx: %v", err)
+		return rpcError{Code: 500, Message: "bad gateway"}.marshal(), err
+	}
+
+	return response.marshal(), nil
+}
+
+// WgEndpointsSetRequest model.
+type WgEndpointsSetRequest struct {
+	Network string `json:"network"`
+
+	// Endpoints replace the endpoints of the network,
+	// empty value restores the configured ones.
+	Endpoints []string `json:"endpoints"`
+}
+
+func (s *WgEndpointsSetRequest) validate() (string, error) {
+	for i, v := range s.Endpoints {
+		e, err := model.ParseEndpoint(v)
+		if err != nil {
+			return "endpoints", err
+		}
+
+		for _, item := range s.Endpoints[:i] {
+			prev, _ := model.ParseEndpoint(item)
+			if prev == e {
+				err = fmt.Errorf("duplicate endpoint %q", v)
+				return "endpoints", err
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// endpoints of the validated request.
+func (s *WgEndpointsSetRequest) endpoints() []model.Endpoint {
+	endpoints := make([]model.Endpoint, len(s.Endpoints))
+	for i, v := range s.Endpoints {
+		endpoints[i], _ = model.ParseEndpoint(v) // validated
+	}
+
+	return endpoints
+}
+
+// Marshall returns the json encoding of WgEndpointsSetRequest.
+func (s WgEndpointsSetRequest) Marshal() json.RawMessage {
+	b, _ := json.Marshal(s)
+	return json.RawMessage(b)
+}
+
+// endpoints of the network as host:port, the primary one goes first,
+// the wan ip address is used unless endpoints are set or configured.
+func (api *API) endpoints(wgs model.WgServer, n Network) []string {
+	endpoints := wgs.Endpoints
+	if len(endpoints) == 0 {
+		endpoints = api.cfg.Endpoints
+	}
+	if len(endpoints) == 0 {
+		e := model.Endpoint{Host: api.cfg.WanIP().String()}
+		return []string{e.Addr(n.WgPort)}
+	}
+
+	addrs := make([]string, len(endpoints))
+	for i, e := range endpoints {
+		addrs[i] = e.Addr(n.WgPort)
+	}
+
+	return addrs
+}
+
+// networkEndpoints loads the endpoints of the network.
+func (api *API) networkEndpoints(tx *bolt.Tx, n Network) ([]string, error) {
+	wgs, _, err := model.LoadWgServer(tx, api.networkKey(n))
+	if err != nil {
+		return nil, err
+	}
+
+	return api.endpoints(wgs, n), nil
+}
+
+// peerStats reads live state of the wireguard peers, on failure error is
+// logged and empty result returned, so stored state is still served.
+func (api *API) peerStats() map[wgtypes.Key]wgmngr.PeerStat {
+	stats := map[wgtypes.Key]wgmngr.PeerStat{}
+	for _, n := range api.cfg.Networks {
+		m, err := n.WgManager.PeerStats()
+		if err != nil {
+			api.log.Errorf(
+				"can't read wireguard peers state of %q network: %v",
+				n.Name, err)
+			continue
+		}
+
+		for k, v := range m {
+			stats[k] = v
+		}
+	}
+
+	return stats
+}
+
+// network by name, empty name stands for the default network.
+func (api *API) network(name string) (Network, bool) {
+	if name == "" {
+		return api.cfg.Networks[0], true
+	}
+
+	for _, n := range api.cfg.Networks {
+		if n.Name == name {
+			return n, true
+		}
+	}
+
+	return Network{}, false
+}
+
+// deviceNetwork returns network the device belongs to.
+func (api *API) deviceNetwork(d model.Device) (Network, error) {
+	n, ok := api.network(d.Network)
+	if !ok {
+		err := fmt.Errorf(
+			"device %s: unknown network %q", d.IPNetwork.IP, d.Network)
+		return Network{}, err
+	}
+
+	return n, nil
+}
+
+// networkKey returns name of the network in database,
+// the default network is stored with empty name.
+func (api *API) networkKey(n Network) string {
+	if n.Name == api.cfg.Networks[0].Name {
+		return ""
+	}
+
+	return n.Name
+}
+
+// wgIPNets of all networks.
+// hostNets returns the networks of the host, none if unknown.
+func (api *API) hostNets() ([]*net.IPNet, error) {
+	if api.cfg.HostNets == nil {
+		return nil, nil
+	}
+
+	return api.cfg.HostNets()
+}
+
+func (api *API) wgIPNets() []*net.IPNet {
+	ipnets := make([]*net.IPNet, len(api.cfg.Networks))
+	for i, n := range api.cfg.Networks {
+		ipnets[i] = n.WgIPNet
+	}
+
+	return ipnets
+}
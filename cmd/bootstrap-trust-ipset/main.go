@@ -4,7 +4,6 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	"net"
 	"os"
 	"strings"
 	"time"
@@ -29,7 +28,7 @@ func main() {
 	// define args
 	dbpathFlag := flag.String("dbpath", "", "dbpath")
 	ipsFlag := stringsFlag{}
-	flag.Var(&ipsFlag, "trustip", "device ip")
+	flag.Var(&ipsFlag, "trustip", "ip address or cidr range")
 
 	// parse args
 	flag.Parse()
@@ -49,17 +48,17 @@ func main() {
 		return
 	}
 
-	ips := make([]net.IP, len(ipsFlag.v))
+	ips := make([]string, len(ipsFlag.v))
 	for i := 0; i < len(ipsFlag.v); i++ {
-		ip := net.ParseIP(ipsFlag.v[i]).To4()
-		if ip == nil {
+		_, err := model.ParseTrustIPNet(ipsFlag.v[i])
+		if err != nil {
 			err = fmt.Errorf("bad ip address value %q", ipsFlag.v[i])
 			log.Errorf("failed to parse params: %v", err)
 			flag.Usage()
 			return
 		}
 
-		ips[i] = ip
+		ips[i] = ipsFlag.v[i]
 	}
 	if len(ips) == 0 {
 		err := errors.New("trustip required")
@@ -76,7 +75,7 @@ func main() {
 	}
 }
 
-func execute(log *log.Logger, dbpath string, ips []net.IP) error {
+func execute(log *log.Logger, dbpath string, ips []string) error {
 	db, err := bolt.Open(
 		dbpath, 0600, &bolt.Options{Timeout: 1 * time.Second})
 	if err != nil {
@@ -101,8 +100,12 @@ func execute(log *log.Logger, dbpath string, ips []net.IP) error {
 	}
 
 	for i := 0; i < len(ips); i++ {
-		ipset.Add(ips[i])
-		os.Stdout.WriteString("added: " + ips[i].String() + "\n")
+		e, err := model.NewManagerSSHTrustIP(ips[i], "", nil)
+		if err != nil {
+			return err
+		}
+		ipset.Add(e)
+		os.Stdout.WriteString("added: " + e.String() + "\n")
 	}
 
 	err = ipset.Store(tx)
@@ -21,22 +21,59 @@ func main() {
 	}
 
 	actionWgCfg := cli.NewActionWgCfg(log)
+	actionWgRotateKey := cli.NewActionWgRotateKey(log)
+	actionWgEndpointsSet := cli.NewActionWgEndpointsSet(log)
 	actionUserCreate := cli.NewActionUserCreate(log)
 	actionUserEdit := cli.NewActionUserEdit(log)
 	actionUserRemove := cli.NewActionUserRemove(log)
 	actionUser := cli.NewActionUser(log)
 	actionUsers := cli.NewActionUsers(log)
+	actionUserRuleAdd := cli.NewActionUserRuleAdd(log)
+	actionUserRuleRemove := cli.NewActionUserRuleRemove(log)
+	actionUserRules := cli.NewActionUserRules(log)
 	actionDeviceCreate := cli.NewActionDeviceCreate(log)
 	actionDeviceEdit := cli.NewActionDeviceEdit(log)
 	actionDeviceRemove := cli.NewActionDeviceRemove(log)
+	actionDeviceRotateKey := cli.NewActionDeviceRotateKey(log)
 	actionDevice := cli.NewActionDevice(log)
 	actionDevices := cli.NewActionDevices(log)
+	actionDeviceRuleAdd := cli.NewActionDeviceRuleAdd(log)
+	actionDeviceRuleRemove := cli.NewActionDeviceRuleRemove(log)
+	actionDeviceRules := cli.NewActionDeviceRules(log)
+	actionDeviceEgressAdd := cli.NewActionDeviceEgressAdd(log)
+	actionDeviceEgressRemove := cli.NewActionDeviceEgressRemove(log)
+	actionDeviceEgress := cli.NewActionDeviceEgress(log)
+	actionDevicePairAdd := cli.NewActionDevicePairAdd(log)
+	actionDevicePairRemove := cli.NewActionDevicePairRemove(log)
+	actionDevicePairs := cli.NewActionDevicePairs(log)
+	actionGroupCreate := cli.NewActionGroupCreate(log)
+	actionGroupRemove := cli.NewActionGroupRemove(log)
+	actionGroupDeviceAdd := cli.NewActionGroupDeviceAdd(log)
+	actionGroupDeviceRemove := cli.NewActionGroupDeviceRemove(log)
+	actionGroupPolicyAdd := cli.NewActionGroupPolicyAdd(log)
+	actionGroupPolicyRemove := cli.NewActionGroupPolicyRemove(log)
+	actionGroup := cli.NewActionGroup(log)
+	actionGroups := cli.NewActionGroups(log)
+	actionPortForwardAdd := cli.NewActionPortForwardAdd(log)
+	actionPortForwardRemove := cli.NewActionPortForwardRemove(log)
+	actionPortForwards := cli.NewActionPortForwards(log)
+	actionEgressRuleAdd := cli.NewActionEgressRuleAdd(log)
+	actionEgressRuleRemove := cli.NewActionEgressRuleRemove(log)
+	actionEgressRules := cli.NewActionEgressRules(log)
+	actionFirewallPreview := cli.NewActionFirewallPreview(log)
+	actionFirewallBlacklist := cli.NewActionFirewallBlacklist(log)
+	actionFirewallUnban := cli.NewActionFirewallUnban(log)
+	actionFirewallStats := cli.NewActionFirewallStats(log)
+	actionFirewallConfirm := cli.NewActionFirewallConfirm(log)
+	actionFirewallPending := cli.NewActionFirewallPending(log)
 	actionTrustIPSetAdd := cli.NewActionTrustIPSetAdd(log)
 	actionTrustIPSetRemove := cli.NewActionTrustIPSetRemove(log)
 	actionTrustIPSet := cli.NewActionTrustIPSet(log)
 	actionDomainCreate := cli.NewActionDomainCreate(log)
 	actionDomainARecordSet := cli.NewActionDomainARecordSet(log)
 	actionDomainARecordRemove := cli.NewActionDomainARecordRemove(log)
+	actionDomainAAAARecordSet := cli.NewActionDomainAAAARecordSet(log)
+	actionDomainAAAARecordRemove := cli.NewActionDomainAAAARecordRemove(log)
 	actionDomainCNameRecordSet := cli.NewActionDomainCNameRecordSet(log)
 	actionDomainCNameRecordRemove := cli.NewActionDomainCNameRecordRemove(log)
 	actionDomainRemove := cli.NewActionDomainRemove(log)
@@ -48,22 +85,59 @@ func main() {
 
 	if flag.NArg() == 0 {
 		actionWgCfg.Usage()
+		actionWgRotateKey.Usage()
+		actionWgEndpointsSet.Usage()
 		actionUserCreate.Usage()
 		actionUserEdit.Usage()
 		actionUserRemove.Usage()
 		actionUser.Usage()
 		actionUsers.Usage()
+		actionUserRuleAdd.Usage()
+		actionUserRuleRemove.Usage()
+		actionUserRules.Usage()
 		actionDeviceCreate.Usage()
 		actionDeviceEdit.Usage()
 		actionDeviceRemove.Usage()
+		actionDeviceRotateKey.Usage()
 		actionDevice.Usage()
 		actionDevices.Usage()
+		actionDeviceRuleAdd.Usage()
+		actionDeviceRuleRemove.Usage()
+		actionDeviceRules.Usage()
+		actionDeviceEgressAdd.Usage()
+		actionDeviceEgressRemove.Usage()
+		actionDeviceEgress.Usage()
+		actionDevicePairAdd.Usage()
+		actionDevicePairRemove.Usage()
+		actionDevicePairs.Usage()
+		actionGroupCreate.Usage()
+		actionGroupRemove.Usage()
+		actionGroupDeviceAdd.Usage()
+		actionGroupDeviceRemove.Usage()
+		actionGroupPolicyAdd.Usage()
+		actionGroupPolicyRemove.Usage()
+		actionGroup.Usage()
+		actionGroups.Usage()
+		actionPortForwardAdd.Usage()
+		actionPortForwardRemove.Usage()
+		actionPortForwards.Usage()
+		actionEgressRuleAdd.Usage()
+		actionEgressRuleRemove.Usage()
+		actionEgressRules.Usage()
+		actionFirewallPreview.Usage()
+		actionFirewallBlacklist.Usage()
+		actionFirewallUnban.Usage()
+		actionFirewallStats.Usage()
+		actionFirewallConfirm.Usage()
+		actionFirewallPending.Usage()
 		actionTrustIPSetAdd.Usage()
 		actionTrustIPSetRemove.Usage()
 		actionTrustIPSet.Usage()
 		actionDomainCreate.Usage()
 		actionDomainARecordSet.Usage()
 		actionDomainARecordRemove.Usage()
+		actionDomainAAAARecordSet.Usage()
+		actionDomainAAAARecordRemove.Usage()
 		actionDomainCNameRecordSet.Usage()
 		actionDomainCNameRecordRemove.Usage()
 		actionDomainRemove.Usage()
@@ -82,6 +156,10 @@ func main() {
 	switch args[0] {
 	case "wgcfg":
 		action = actionWgCfg
+	case "wg-rotate-key":
+		action = actionWgRotateKey
+	case "wg-endpoints-set":
+		action = actionWgEndpointsSet
 	case "user-create":
 		action = actionUserCreate
 	case "user-edit":
@@ -92,16 +170,82 @@ func main() {
 		action = actionUser
 	case "users":
 		action = actionUsers
+	case "user-rule-add":
+		action = actionUserRuleAdd
+	case "user-rule-remove":
+		action = actionUserRuleRemove
+	case "user-rules":
+		action = actionUserRules
 	case "device-create":
 		action = actionDeviceCreate
 	case "device-edit":
 		action = actionDeviceEdit
 	case "device-remove":
 		action = actionDeviceRemove
+	case "device-rotate-key":
+		action = actionDeviceRotateKey
 	case "device":
 		action = actionDevice
 	case "devices":
 		action = actionDevices
+	case "device-rule-add":
+		action = actionDeviceRuleAdd
+	case "device-rule-remove":
+		action = actionDeviceRuleRemove
+	case "device-rules":
+		action = actionDeviceRules
+	case "device-egress-add":
+		action = actionDeviceEgressAdd
+	case "device-egress-remove":
+		action = actionDeviceEgressRemove
+	case "device-egress":
+		action = actionDeviceEgress
+	case "device-pair-add":
+		action = actionDevicePairAdd
+	case "device-pair-remove":
+		action = actionDevicePairRemove
+	case "device-pairs":
+		action = actionDevicePairs
+	case "group-create":
+		action = actionGroupCreate
+	case "group-remove":
+		action = actionGroupRemove
+	case "group-device-add":
+		action = actionGroupDeviceAdd
+	case "group-device-remove":
+		action = actionGroupDeviceRemove
+	case "group-policy-add":
+		action = actionGroupPolicyAdd
+	case "group-policy-remove":
+		action = actionGroupPolicyRemove
+	case "group":
+		action = actionGroup
+	case "groups":
+		action = actionGroups
+	case "port-forward-add":
+		action = actionPortForwardAdd
+	case "port-forward-remove":
+		action = actionPortForwardRemove
+	case "port-forwards":
+		action = actionPortForwards
+	case "egress-rule-add":
+		action = actionEgressRuleAdd
+	case "egress-rule-remove":
+		action = actionEgressRuleRemove
+	case "egress-rules":
+		action = actionEgressRules
+	case "firewall-preview":
+		action = actionFirewallPreview
+	case "firewall-blacklist":
+		action = actionFirewallBlacklist
+	case "firewall-unban":
+		action = actionFirewallUnban
+	case "firewall-stats":
+		action = actionFirewallStats
+	case "firewall-confirm":
+		action = actionFirewallConfirm
+	case "firewall-pending":
+		action = actionFirewallPending
 	case "trust-ipset-add":
 		action = actionTrustIPSetAdd
 	case "trust-ipset-remove":
@@ -114,6 +258,10 @@ func main() {
 		action = actionDomainARecordSet
 	case "domain-a-record-remove":
 		action = actionDomainARecordRemove
+	case "domain-aaaa-record-set":
+		action = actionDomainAAAARecordSet
+	case "domain-aaaa-record-remove":
+		action = actionDomainAAAARecordRemove
 	case "domain-cname-record-set":
 		action = actionDomainCNameRecordSet
 	case "domain-cname-record-remove":
@@ -3,8 +3,15 @@ package wgnetwork
 import (
 	"fmt"
 	"net"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/miekg/dns"
+
+	"wgnetwork/firewall"
+	"wgnetwork/model"
 	"wgnetwork/pkg/envconfig"
 	"wgnetwork/pkg/ipcalc"
 )
@@ -19,15 +26,60 @@ type config struct {
 	WGIface  string `env:"WG_IFACE" default:"wg0"`
 	WGPort   uint16 `env:"WG_PORT" default:"51820"`
 	WGCIDR   string `env:"WG_CIDR" default:"172.16.0.1/24"`
+	WGName   string `env:"WG_NAME" default:"default"`
+
+	// WGCIDR6 is an optional ipv6 ula prefix of the default network,
+	// the devices get ipv6 addresses along with ipv4 ones.
+	WGCIDR6 string `env:"WG_CIDR6"`
+
+	// WGNetworks describes additional networks,
+	// format of item is name:iface:port:cidr[:dnszone[:cidr6]].
+	WGNetworks []string `env:"WG_NETWORKS"`
+
+	// WGIsolated lists names of the isolated networks, new connections
+	// between the devices are dropped unless the target is a server
+	// device or the devices are paired.
+	WGIsolated []string `env:"WG_ISOLATED"`
+
+	// WGEndpoints the devices connect the server at instead of the wan
+	// ip address, format of item is host[:port], the first one is the
+	// primary and the port of the network is used if omitted.
+	WGEndpoints []string `env:"WG_ENDPOINTS"`
 
 	NFTEnabled          bool   `env:"NFT_ENABLED" default:"false"`
 	NFTNetworkNamespace string `env:"NFT_NETWORK_NAMESPACE"`
 	NFTDefaultPolicy    string `env:"NFT_DEFAULT_POLICY" default:"drop"`
 
+	// NFTMode is flush to own the whole ruleset or table to keep the rules
+	// in the dedicated wgnetwork table next to the other firewall users.
+	NFTMode string `env:"NFT_MODE" default:"flush"`
+
 	NFTIfaces []string `env:"NFT_IFACES"`
 
 	NFTTrustPorts []uint16 `env:"NFT_TRUST_PORTS" default:"22"`
 
+	// NFTTrustRate is the new connections per minute to the trust ports
+	// and NFTWGRate is the handshake initiations per second to the
+	// wireguard ports from a source, the sources over the rate are
+	// banned for NFTBanTimeout. Zero rate turns the limit off.
+	NFTTrustRate  uint32        `env:"NFT_TRUST_RATE" default:"0"`
+	NFTWGRate     uint32        `env:"NFT_WG_RATE" default:"0"`
+	NFTBanTimeout time.Duration `env:"NFT_BAN_TIMEOUT" default:"10m"`
+
+	// NFTDropLogRate is the log messages per minute of the packets dropped
+	// by the default policy, zero turns the logging off.
+	NFTDropLogRate uint32 `env:"NFT_DROP_LOG_RATE" default:"0"`
+
+	// NFTConfirmTimeout the firewall changes made by api are to be
+	// confirmed within, the state is rolled back to the confirmed one
+	// otherwise. Zero value turns the confirmation off.
+	NFTConfirmTimeout time.Duration `env:"NFT_CONFIRM_TIMEOUT" default:"0s"`
+
+	// NFTMasquerade translates the wan traffic to the address of the wan
+	// interface whatever it is at the moment instead of the address
+	// detected on start, it suits the dynamic addresses.
+	NFTMasquerade bool `env:"NFT_MASQUERADE" default:"false"`
+
 	DNSTcpPort       int      `env:"DNS_TCP_PORT" default:"53"`
 	DNSUdpPort       int      `env:"DNS_UDP_PORT" default:"53"`
 	DNSResolverAddrs []string `env:"DNS_RESOLVER_ADDRS" default:"8.8.8.8:53,8.8.4.4:53,1.1.1.1:53"`
@@ -36,6 +88,10 @@ type config struct {
 	APIHTTPPort      int      `env:"API_HTTP_PORT" default:"8080"`
 	APIUnixSocket    string   `env:"API_UNIX_SOCKET" default:"/tmp/wgmanager.sock"`
 
+	// DevicePurgeDays removes devices expired for more than the number of
+	// days, zero value keeps expired devices.
+	DevicePurgeDays int `env:"DEVICE_PURGE_DAYS" default:"0"`
+
 	OTPIssuer     string        `env:"OTP_ISSUER" default:"wgnetwork"`
 	HTTPOrigin    string        `env:"HTTPORIGIN"`
 	SessionSecret string        `env:"SESSION_SECRET" default:"secret"`
@@ -45,6 +101,11 @@ type config struct {
 	wgIfaceIPNet *net.IPNet
 	wgIfaceInet  *net.IPNet
 
+	// networks served, the first one is the default network
+	networks []wgNetwork
+
+	endpoints []model.Endpoint
+
 	dnsTcpAddr   string
 	dnsUdpAddr   string
 	apiHTTPAddr  string
@@ -80,6 +141,88 @@ func loadConfig() (config, error) {
 	cfg.dnsUdpAddr = fmt.Sprintf("%s:%d", hostname, cfg.DNSUdpPort)
 	cfg.apiHTTPAddr = fmt.Sprintf("%s:%d", hostname, cfg.APIHTTPPort)
 
+	if !networkNameRe.MatchString(cfg.WGName) {
+		return config{}, fmt.Errorf("bad network name %q", cfg.WGName)
+	}
+	defaultNetwork := wgNetwork{
+		name:       cfg.WGName,
+		iface:      cfg.WGIface,
+		port:       cfg.WGPort,
+		dnsZone:    cfg.DNSZone,
+		ifaceIP:    cfg.wgIfaceIP,
+		ifaceIPNet: cfg.wgIfaceIPNet,
+		ifaceInet:  cfg.wgIfaceInet,
+		dnsTcpAddr: cfg.dnsTcpAddr,
+		dnsUdpAddr: cfg.dnsUdpAddr,
+	}
+	if cfg.WGCIDR6 != "" {
+		err = defaultNetwork.setCIDR6(cfg.WGCIDR6)
+		if err != nil {
+			return config{}, err
+		}
+	}
+	cfg.networks = []wgNetwork{defaultNetwork}
+	for _, v := range cfg.WGNetworks {
+		n, err := parseWGNetwork(v, cfg)
+		if err != nil {
+			return config{}, err
+		}
+
+		for _, item := range cfg.networks {
+			switch {
+			case item.name == n.name:
+				err = fmt.Errorf("network %q: duplicate name", n.name)
+			case item.iface == n.iface:
+				err = fmt.Errorf("network %q: iface %q in use", n.name, n.iface)
+			case item.port == n.port:
+				err = fmt.Errorf("network %q: port %d in use", n.name, n.port)
+			case item.ifaceIPNet.Contains(n.ifaceIPNet.IP),
+				n.ifaceIPNet.Contains(item.ifaceIPNet.IP):
+				err = fmt.Errorf(
+					"network %q: cidr overlaps with network %q",
+					n.name, item.name)
+			case item.ifaceIPNet6 != nil && n.ifaceIPNet6 != nil &&
+				(item.ifaceIPNet6.Contains(n.ifaceIPNet6.IP) ||
+					n.ifaceIPNet6.Contains(item.ifaceIPNet6.IP)):
+				err = fmt.Errorf(
+					"network %q: cidr6 overlaps with network %q",
+					n.name, item.name)
+			}
+			if err != nil {
+				return config{}, err
+			}
+		}
+
+		cfg.networks = append(cfg.networks, n)
+	}
+
+	for _, name := range cfg.WGIsolated {
+		found := false
+		for i := range cfg.networks {
+			if cfg.networks[i].name == name {
+				cfg.networks[i].isolated = true
+				found = true
+			}
+		}
+		if !found {
+			return config{}, fmt.Errorf("isolated network %q not found", name)
+		}
+	}
+
+	for _, v := range cfg.WGEndpoints {
+		e, err := model.ParseEndpoint(v)
+		if err != nil {
+			return config{}, err
+		}
+		cfg.endpoints = append(cfg.endpoints, e)
+	}
+
+	switch cfg.NFTMode {
+	case firewall.ModeFlush, firewall.ModeTable:
+	default:
+		return config{}, fmt.Errorf("bad nft mode %q", cfg.NFTMode)
+	}
+
 	cfg.feHTTPAddr = fmt.Sprintf("%s:%d", hostname, cfg.FEHTTPPort)
 	if cfg.HTTPOrigin != "" {
 		cfg.feHTTPOrigin = cfg.HTTPOrigin
@@ -93,3 +236,111 @@ func loadConfig() (config, error) {
 
 	return cfg, err
 }
+
+// wgNetwork describes wireguard network served.
+type wgNetwork struct {
+	name    string
+	iface   string
+	port    uint16
+	dnsZone string
+
+	ifaceIP    net.IP
+	ifaceIPNet *net.IPNet
+	ifaceInet  *net.IPNet
+
+	// optional ipv6 ula prefix of the network
+	ifaceIP6    net.IP
+	ifaceIPNet6 *net.IPNet
+	ifaceInet6  *net.IPNet
+
+	dnsTcpAddr string
+	dnsUdpAddr string
+
+	isolated bool
+}
+
+// ula is the ipv6 unique local address range.
+var ula = &net.IPNet{
+	IP:   net.IP{0xfc, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+	Mask: net.CIDRMask(7, 128),
+}
+
+// setCIDR6 sets ipv6 ula prefix of the network, the interface ipv6
+// address shares the host part with the interface ipv4 address.
+func (n *wgNetwork) setCIDR6(v string) error {
+	ip, ipnet, err := net.ParseCIDR(v)
+	if err != nil {
+		return fmt.Errorf("network %q: %v", n.name, err)
+	}
+	if ip.To4() != nil || !ula.Contains(ip) {
+		return fmt.Errorf("network %q: ipv6 ula prefix expected", n.name)
+	}
+
+	ones, bits := n.ifaceIPNet.Mask.Size()
+	ones6, bits6 := ipnet.Mask.Size()
+	if bits6-ones6 < bits-ones {
+		return fmt.Errorf(
+			"network %q: cidr6 should not be smaller than cidr", n.name)
+	}
+
+	n.ifaceIPNet6 = ipnet
+	n.ifaceIP6 = ipcalc.MapIP(n.ifaceIP, n.ifaceIPNet, ipnet)
+	n.ifaceInet6 = &net.IPNet{IP: n.ifaceIP6, Mask: ipnet.Mask}
+
+	return nil
+}
+
+var networkNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// parseWGNetwork from name:iface:port:cidr[:dnszone[:cidr6]] value, dns
+// zone defaults to the network name within default zone.
+func parseWGNetwork(v string, cfg config) (wgNetwork, error) {
+	// cidr6 is the last part, it contains colons itself
+	parts := strings.SplitN(v, ":", 6)
+	if len(parts) < 4 {
+		return wgNetwork{}, fmt.Errorf("bad network value %q", v)
+	}
+
+	n := wgNetwork{
+		name:  parts[0],
+		iface: parts[1],
+	}
+	if !networkNameRe.MatchString(n.name) {
+		return wgNetwork{}, fmt.Errorf("bad network name %q", n.name)
+	}
+	if n.iface == "" {
+		return wgNetwork{}, fmt.Errorf("network %q: iface required", n.name)
+	}
+
+	port, err := strconv.ParseUint(parts[2], 10, 16)
+	if err != nil || port == 0 {
+		return wgNetwork{}, fmt.Errorf("network %q: bad port", n.name)
+	}
+	n.port = uint16(port)
+
+	n.ifaceIP, n.ifaceIPNet, err = ipcalc.ParseCIDR(parts[3])
+	if err != nil {
+		return wgNetwork{}, fmt.Errorf("network %q: %v", n.name, err)
+	}
+	if n.ifaceIP == nil {
+		return wgNetwork{}, fmt.Errorf("network %q: ipv4 cidr expected", n.name)
+	}
+	n.ifaceInet = &net.IPNet{IP: n.ifaceIP, Mask: n.ifaceIPNet.Mask}
+
+	n.dnsZone = fmt.Sprintf("%s.%s", n.name, cfg.DNSZone)
+	if len(parts) > 4 && parts[4] != "" {
+		n.dnsZone = dns.Fqdn(parts[4])
+	}
+
+	if len(parts) > 5 {
+		err = n.setCIDR6(parts[5])
+		if err != nil {
+			return wgNetwork{}, err
+		}
+	}
+
+	n.dnsTcpAddr = fmt.Sprintf("%s:%d", n.ifaceIP, cfg.DNSTcpPort)
+	n.dnsUdpAddr = fmt.Sprintf("%s:%d", n.ifaceIP, cfg.DNSUdpPort)
+
+	return n, nil
+}
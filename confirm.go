@@ -0,0 +1,163 @@
+package wgnetwork
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"wgnetwork/model"
+)
+
+// confirm of the firewall changes made by api, the firewall settings
+// are restored from the snapshot of the confirmed state unless
+// the changes are confirmed before the deadline, the firewall follows
+// the database on refresh.
+type confirm struct {
+	mu sync.Mutex
+
+	timeout time.Duration
+
+	// snapshots of the state applied last and of the confirmed one
+	applied   *model.Snapshot
+	confirmed *model.Snapshot
+
+	// notified is set by the change made by api until it's refreshed
+	notified bool
+
+	// deadline of the pending changes, zero when nothing is pending
+	deadline time.Time
+	timer    *time.Timer
+
+	// rollback is set until the restored state is refreshed
+	rollback bool
+
+	// expiredc signals the deadline is passed, it's buffered
+	expiredc chan struct{}
+}
+
+// newConfirm constructor.
+func newConfirm(timeout time.Duration) *confirm {
+	c := &confirm{
+		timeout:  timeout,
+		expiredc: make(chan struct{}, 1),
+	}
+
+	return c
+}
+
+// notify about the change made by api.
+func (c *confirm) notify() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.notified = true
+}
+
+// begin the refresh, it reports whether the changes made by api
+// are refreshed.
+func (c *confirm) begin() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	notified := c.notified
+	c.notified = false
+
+	return notified
+}
+
+// end the refresh of the snapshot state, the firewall changes made by api
+// start the deadline unless it's started already.
+func (c *confirm) end(snapshot *model.Snapshot, notified, changed, failed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if failed && notified {
+		// to be refreshed again
+		c.notified = true
+	}
+
+	// the state applied on start and the restored one are confirmed
+	if c.confirmed == nil || c.rollback {
+		if !failed {
+			c.applied = snapshot
+			c.confirmed = snapshot
+			c.rollback = false
+		}
+		return
+	}
+
+	if !failed {
+		c.applied = snapshot
+	}
+
+	if !c.deadline.IsZero() {
+		return
+	}
+
+	if changed && notified {
+		c.deadline = time.Now().Add(c.timeout)
+		c.timer = time.AfterFunc(c.timeout, func() {
+			select {
+			case c.expiredc <- struct{}{}:
+			default: // rollback is pending already
+			}
+		})
+		return
+	}
+
+	// the automatic changes like expiration are confirmed
+	// by the next refresh
+	if !changed && !failed {
+		c.confirmed = snapshot
+	}
+}
+
+// Confirm the pending changes.
+func (c *confirm) Confirm() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.deadline.IsZero() {
+		return errors.New("no changes pending confirmation")
+	}
+
+	c.timer.Stop()
+	c.deadline = time.Time{}
+	c.confirmed = c.applied
+
+	return nil
+}
+
+// Deadline of the pending changes, nil when nothing is pending.
+func (c *confirm) Deadline() *time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.deadline.IsZero() {
+		return nil
+	}
+
+	t := c.deadline
+	return &t
+}
+
+// restore the confirmed state by fn when the deadline of the pending
+// changes is passed, it reports whether the state is restored.
+func (c *confirm) restore(fn func(*model.Snapshot) error) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.deadline.IsZero() || time.Now().Before(c.deadline) {
+		return false, nil
+	}
+
+	err := fn(c.confirmed)
+	if err != nil {
+		// the deadline is kept to try again
+		return false, err
+	}
+	c.deadline = time.Time{}
+	c.rollback = true
+
+	return true, nil
+}
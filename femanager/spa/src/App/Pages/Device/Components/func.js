@@ -57,19 +57,28 @@ function download() {
 }
 
 function excludePrivateNetworks(wgipnet, ipnets) {
-  if (ipnets.length != 1) {
-    return ipnets
+  let result = [];
+  for (let i = 0; i < ipnets.length; i++) {
+    if (ipnets[i].includes(':')) {
+      result.push(ipnets[i]);
+      continue;
+    }
+    let size = ipnets[i].split('/');
+    size = (size.length > 1) ? size[1] : -1;
+    if (size < 0) {
+      result.push(wgipnet);
+    } else if (size > 0) {
+      result.push(ipnets[i]);
+    } else {
+      result = result.concat(publicNetworks(wgipnet));
+    }
   }
 
-  let size = ipnets[0].split('/');
-  size = (size.length > 1) ? size[1] : -1;
-  if (size < 0) {
-    return [wgipnet]
-  } else if (size > 0) {
-    return ipnets
-  }
+  return result
+}
 
-  ipnets = [
+function publicNetworks(wgipnet) {
+  let ipnets = [
     wgipnet,
     '1.0.0.0/8',
     '2.0.0.0/8',
@@ -108,8 +117,36 @@ function excludePrivateNetworks(wgipnet, ipnets) {
   return ipnets
 }
 
+function formatBytes(n) {
+  const units = ['B', 'KiB', 'MiB', 'GiB', 'TiB', 'PiB'];
+  let i = 0;
+  while (n >= 1024 && i < units.length - 1) {
+    n /= 1024;
+    i++;
+  }
+
+  return (i > 0 ? n.toFixed(2) : n) + ' ' + units[i];
+}
+
+function formatHandshake(lastHandshake) {
+  if (!lastHandshake) {
+    return 'never';
+  }
+
+  let seconds = Math.floor((Date.now() - Date.parse(lastHandshake)) / 1000);
+  if (seconds < 60) {
+    return seconds + 's ago';
+  } else if (seconds < 3600) {
+    return Math.floor(seconds / 60) + 'm ' + (seconds % 60) + 's ago';
+  }
+
+  return new Date(lastHandshake).toLocaleString();
+}
+
 export {
   copyToClipboard,
+  formatBytes,
+  formatHandshake,
   download,
   excludePrivateNetworks,
 };
@@ -33,9 +33,11 @@ async function getDevice(client, session, ip) {
 
   let wgcfg = {
     wgDeviceInet: device['wg_device_inet'],
+    wgDeviceInet6: device['wg_device_inet6'] || '',
     wgDevicePort: device['wg_device_port'],
     wgDevicePubKey: device['wg_device_pubkey'],
     wgDeviceAllowedIPs: device['wg_device_allowed_ips'],
+    wgDevicePresharedKey: device['wg_device_psk'],
     wgDeviceDNS: ['8.8.8.8', '8.8.4.4'],
 
     wgServerInet: device['wg_server_inet'],
@@ -45,6 +47,7 @@ async function getDevice(client, session, ip) {
     wgServerPubKey: device['wg_server_pubkey'],
 
     serverWanIP: device['server_wanip'],
+    serverEndpoints: device['server_endpoints'] || [],
   };
 
   ip = device['wg_device_inet'].split('/');
@@ -53,7 +56,20 @@ async function getDevice(client, session, ip) {
   device = {
     ip: ip,
     label: device['label'],
+    network: device['network'],
     wanForward: device['wan_forward'],
+    server: device['server'],
+    disabled: device['disabled'],
+    expiresAt: device['expires_at'],
+    expired: device['expired'],
+    routes: device['wg_device_routes'],
+    peer: {
+      online: device['wg_device_online'],
+      endpoint: device['wg_device_endpoint'],
+      lastHandshake: device['wg_device_last_handshake'],
+      rxBytes: device['wg_device_rx_bytes'],
+      txBytes: device['wg_device_tx_bytes']
+    },
     user: {
       uuid: device['user_uuid'],
       name: device['user_name']
@@ -78,8 +94,11 @@ async function getUsers(client, session) {
   }
 
   let users = [];
+  let networks = [];
   try {
-    users = await client.Fetch('manager/users', {}, session);
+    [users, networks] = await Promise.all([
+      client.Fetch('manager/users', {}, session),
+      client.Fetch('manager/wg/networks', {}, session)]);
   } catch (err) {
     return Promise.reject(err);
   }
@@ -91,9 +110,17 @@ async function getUsers(client, session) {
     };
   }
 
+  for (let i = 0; i < networks.length; i++) {
+    networks[i] = {
+      name: networks[i]['network'],
+      inet: networks[i]['wg_inet'],
+    };
+  }
+
   let result = {
     session: check.session,
     users: users,
+    networks: networks,
   }
 
   return Promise.resolve(result);
@@ -111,8 +138,44 @@ function formValidate(label, uuid) {
   return true;
 }
 
+function splitRoutes(value) {
+  let routes = [];
+  let items = value.split(',');
+  for (let i = 0; i < items.length; i++) {
+    let item = items[i].trim();
+    if (item.length > 0) {
+      routes.push(item);
+    }
+  }
+
+  return routes;
+}
+
+// inputDateTime formats RFC 3339 value for the datetime-local input.
+function inputDateTime(value) {
+  if (!value) {
+    return '';
+  }
+
+  let t = new Date(value);
+  t.setMinutes(t.getMinutes() - t.getTimezoneOffset());
+  return t.toISOString().slice(0, 16);
+}
+
+// rfc3339DateTime formats datetime-local input value as RFC 3339.
+function rfc3339DateTime(value) {
+  if (!value) {
+    return '';
+  }
+
+  return new Date(value).toISOString();
+}
+
 export {
   checkSession,
+  splitRoutes,
+  inputDateTime,
+  rfc3339DateTime,
   getDevice,
   getUsers,
   formValidate,
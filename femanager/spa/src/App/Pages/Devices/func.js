@@ -32,8 +32,13 @@ async function getDevices(client, session) {
     devices[i] = {
       ipnetwork: devices[i]['ipnetwork'],
       label: devices[i]['label'],
+      network: devices[i]['network'],
       username: username,
-      ip: ip
+      ip: ip,
+      disabled: devices[i]['disabled'],
+      expired: devices[i]['expired'],
+      online: devices[i]['wg_device_online'],
+      endpoint: devices[i]['wg_device_endpoint']
     };
   }
 
@@ -1,5 +1,7 @@
 "use strict"
 
+import { rfc3339DateTime } from '../Device/func.js';
+
 async function getData(client, s) {
   let check = {};
   try {
@@ -8,11 +10,11 @@ async function getData(client, s) {
     return Promise.reject(err);
   }
 
-  let wgCfg = {};
+  let wgNetworks = [];
   let ipSet = [];
   try {
-    [wgCfg, ipSet] = await Promise.all([
-      client.Fetch('manager/wg/cfg', {}, s),
+    [wgNetworks, ipSet] = await Promise.all([
+      client.Fetch('manager/wg/networks', {}, s),
       client.Fetch('manager/trust/ipset', {}, s)]);
   } catch (err) {
     return Promise.reject(err);
@@ -20,17 +22,21 @@ async function getData(client, s) {
 
   let result = {
     session: check['session'],
-    wgCfg: wgCfg,
+    wgNetworks: wgNetworks,
     ipSet: ipSet
   };
 
   return Promise.resolve(result);
 }
 
-async function ipsetAdd(client, s, ip) {
+async function ipsetAdd(client, s, ip, comment, expiresAt) {
   let r = {};
   try {
-    let p = {'ip': ip};
+    let p = {
+      'ip': ip,
+      'comment': comment,
+      'expires_at': rfc3339DateTime(expiresAt)
+    };
     r = await client.Fetch('manager/trust/ipset/add', p, s);
   } catch (err) {
     return Promise.reject(err);
@@ -0,0 +1,42 @@
+package firewall
+
+import "net"
+
+// Directions of the acl rule.
+const (
+	// ACLDirectionOut matches connections initiated by the device.
+	ACLDirectionOut = "out"
+	// ACLDirectionIn matches connections initiated to the device.
+	ACLDirectionIn = "in"
+)
+
+// ACL restricts forwarding of the device within the wireguard network.
+// The device is not restricted in the direction it has no rules for.
+type ACL struct {
+	IP  net.IP
+	IP6 net.IP // optional
+
+	Rules []ACLRule
+}
+
+// ACLRule allows connections of the device.
+type ACLRule struct {
+	Proto     string // tcp, udp, icmp or empty for any
+	Direction string
+	SPorts    []uint16
+	DPorts    []uint16
+
+	// Addrs of the remote side, empty list matches any.
+	Addrs []net.IP
+}
+
+// restricted reports whether the acl has rules of the direction.
+func (a ACL) restricted(direction string) bool {
+	for _, r := range a.Rules {
+		if r.Direction == direction {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,14 @@
+package firewall
+
+import "net"
+
+// Bandwidth limits of the device forwarded traffic in kbit/s,
+// zero rate turns the limit off. The traffic of the subnets routed
+// behind the device shares the limit with the device addresses.
+type Bandwidth struct {
+	IP       net.IP
+	IP6      net.IP // optional
+	Routes   []net.IPNet
+	Download uint32
+	Upload   uint32
+}
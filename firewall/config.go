@@ -1,12 +1,56 @@
 package firewall
 
+import (
+	"time"
+)
+
+// Modes of the ruleset management.
+const (
+	// ModeFlush replaces the whole ruleset with filter and nat tables.
+	ModeFlush = "flush"
+	// ModeTable keeps the ruleset in the own wgnetwork table only,
+	// the tables of the other firewall users are left untouched.
+	ModeTable = "table"
+)
+
 // Config for nftables.
 type Config struct {
 	Enabled          bool
 	NetworkNamespace string
 	DefaultPolicy    string
-	WGIface          string
-	WGPort           uint16
+	Mode             string
+	WGNetworks       []WGNetwork
 	Ifaces           []string
 	TrustPorts       []uint16
+	RateLimit        RateLimit
+
+	// DropLogRate is the log messages per minute of the packets dropped
+	// by the default policy, zero turns the logging off.
+	DropLogRate uint32
+
+	// Masquerade the wan traffic instead of the source translation
+	// to the wan ip address, the address may change at any time.
+	Masquerade bool
+}
+
+// RateLimit of the public ports by the source address, the sources over
+// the rate are put in the blacklist. Zero rate turns the limit off.
+type RateLimit struct {
+	// TrustRate is the new connections per minute to the trust ports.
+	TrustRate uint32
+	// WGRate is the handshake initiations per second to the wireguard ports.
+	WGRate uint32
+	// BanTimeout the source is kept in the blacklist for.
+	BanTimeout time.Duration
+}
+
+// WGNetwork describes wireguard network to filter.
+type WGNetwork struct {
+	Name  string
+	Iface string
+	Port  uint16
+
+	// Isolated network drops new connections between the devices
+	// unless the target is a server device or the devices are paired.
+	Isolated bool
 }
@@ -0,0 +1,9 @@
+package firewall
+
+// Counter is the named counter of the ruleset, the counters of the device
+// drops are named by the device address with device_ prefix.
+type Counter struct {
+	Name    string
+	Packets uint64
+	Bytes   uint64
+}
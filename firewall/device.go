@@ -0,0 +1,10 @@
+package firewall
+
+import "net"
+
+// Device of the wireguard network, the packets of the device dropped
+// by the default policy are counted.
+type Device struct {
+	IP  net.IP
+	IP6 net.IP // optional
+}
@@ -0,0 +1,32 @@
+package firewall
+
+import "net"
+
+// EgressRule matches connections forwarded to wan by the destination,
+// empty ports or nets list matches any.
+type EgressRule struct {
+	// Allow rules turn the policy into allow-only list,
+	// the rest rules block matching connections.
+	Allow  bool
+	Proto  string // tcp, udp or empty for any
+	DPorts []uint16
+	DNets  []net.IPNet
+}
+
+// Egress policy of the device, it's applied along with the global rules.
+type Egress struct {
+	IP    net.IP
+	IP6   net.IP // optional
+	Rules []EgressRule
+}
+
+// allowOnly reports whether any of the rules is allow rule.
+func allowOnly(rules []EgressRule) bool {
+	for _, r := range rules {
+		if r.Allow {
+			return true
+		}
+	}
+
+	return false
+}
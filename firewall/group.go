@@ -0,0 +1,18 @@
+package firewall
+
+import "net"
+
+// Group of the devices within the wireguard network.
+type Group struct {
+	Name string
+	IPs  []net.IP
+}
+
+// GroupPolicy allows new connections from the devices
+// of the group to the devices of the target group.
+type GroupPolicy struct {
+	From   string
+	To     string
+	Proto  string // tcp, udp, icmp or empty for any
+	DPorts []uint16
+}
@@ -0,0 +1,244 @@
+//go:build linux
+// +build linux
+
+package firewall
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"github.com/mdlayher/netlink"
+)
+
+// hostRuleset found before the own ruleset is applied,
+// it's restored on cleanup.
+type hostRuleset struct {
+	tables     []*nftables.Table
+	chains     []*nftables.Chain
+	flowtables []*nftables.Flowtable
+	sets       []hostSet
+	objs       []nftables.Obj
+	rules      []*nftables.Rule
+}
+
+// hostSet with its elements.
+type hostSet struct {
+	set      *nftables.Set
+	elements []nftables.SetElement
+}
+
+// captureHostRuleset lists the whole ruleset, it fails if the ruleset
+// can't be restored as is, e.g. a rule has the expressions unknown
+// to nftables package.
+func captureHostRuleset(c *nftables.Conn) (*hostRuleset, error) {
+	tables, err := c.ListTables()
+	if err != nil {
+		return nil, err
+	}
+	chains, err := c.ListChains()
+	if err != nil {
+		return nil, err
+	}
+
+	h := &hostRuleset{
+		tables: tables,
+		chains: chains,
+	}
+	for _, t := range tables {
+		flowtables, err := c.ListFlowtables(t)
+		if err != nil {
+			return nil, err
+		}
+		h.flowtables = append(h.flowtables, flowtables...)
+
+		sets, err := c.GetSets(t)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range sets {
+			elements, err := c.GetSetElements(s)
+			if err != nil {
+				return nil, err
+			}
+			h.sets = append(h.sets, hostSet{set: s, elements: elements})
+		}
+
+		objs, err := c.GetObjects(t)
+		if err != nil {
+			return nil, err
+		}
+		h.objs = append(h.objs, objs...)
+
+		counts, err := ruleExprCounts(c.NetNS, t)
+		if err != nil {
+			return nil, err
+		}
+		for _, ch := range chains {
+			if ch.Table.Name != t.Name || ch.Table.Family != t.Family {
+				continue
+			}
+
+			rules, err := c.GetRules(t, ch)
+			if err != nil {
+				return nil, err
+			}
+			for _, r := range rules {
+				if len(r.Exprs) != counts[r.Handle] {
+					return nil, fmt.Errorf(
+						"rule %d of chain %s of table %s "+
+							"has unsupported expressions",
+						r.Handle, ch.Name, t.Name)
+				}
+				r.Chain = ch
+			}
+			h.rules = append(h.rules, rules...)
+		}
+	}
+
+	return h, nil
+}
+
+// ruleExprCounts returns the number of expressions of the table rules
+// by the rule handle, GetRules skips the expressions it doesn't know.
+func ruleExprCounts(netns int, t *nftables.Table) (map[uint64]int, error) {
+	conn, err := netlink.Dial(
+		unix.NETLINK_NETFILTER, &netlink.Config{NetNS: netns})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	data, err := netlink.MarshalAttributes([]netlink.Attribute{
+		{Type: unix.NFTA_RULE_TABLE, Data: []byte(t.Name + "\x00")},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// nfgenmsg header of the family, version and resource id
+	header := []byte{byte(t.Family), unix.NFNETLINK_V0, 0, 0}
+	msgs, err := conn.Execute(netlink.Message{
+		Header: netlink.Header{
+			Type: netlink.HeaderType(
+				(unix.NFNL_SUBSYS_NFTABLES << 8) | unix.NFT_MSG_GETRULE),
+			Flags: netlink.Request | netlink.Dump,
+		},
+		Data: append(header, data...),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint64]int, len(msgs))
+	for _, msg := range msgs {
+		ad, err := netlink.NewAttributeDecoder(msg.Data[4:])
+		if err != nil {
+			return nil, err
+		}
+		ad.ByteOrder = binary.BigEndian
+
+		var handle uint64
+		var count int
+		for ad.Next() {
+			switch ad.Type() {
+			case unix.NFTA_RULE_HANDLE:
+				handle = ad.Uint64()
+			case unix.NFTA_RULE_EXPRESSIONS:
+				ad.Nested(func(nad *netlink.AttributeDecoder) error {
+					for nad.Next() {
+						count++
+					}
+					return nil
+				})
+			}
+		}
+		if err := ad.Err(); err != nil {
+			return nil, err
+		}
+		counts[handle] = count
+	}
+
+	return counts, nil
+}
+
+// restore the ruleset, the anonymous sets get the new ids
+// the rules refer them by.
+func (h *hostRuleset) restore(c *nftables.Conn) error {
+	c.FlushRuleset()
+
+	for _, t := range h.tables {
+		c.AddTable(t)
+	}
+	for _, ch := range h.chains {
+		c.AddChain(ch)
+	}
+	for _, f := range h.flowtables {
+		c.AddFlowtable(f)
+	}
+
+	// anonymous sets by the table and the name given by kernel
+	anonymous := make(map[hostSetKey]*nftables.Set)
+	for _, v := range h.sets {
+		s := *v.set
+		if s.Anonymous {
+			anonymous[newHostSetKey(s.Table, s.Name)] = &s
+		}
+
+		// the ids are allocated again
+		s.ID = 0
+		err := c.AddSet(&s, v.elements)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, o := range h.objs {
+		c.AddObj(o)
+	}
+
+	for _, r := range h.rules {
+		rule := *r
+		rule.Handle = 0
+		rule.Position = 0
+		rule.Exprs = make([]expr.Any, len(r.Exprs))
+		for i, e := range r.Exprs {
+			switch e := e.(type) {
+			case *expr.Lookup:
+				s, ok := anonymous[newHostSetKey(rule.Table, e.SetName)]
+				if ok {
+					lookup := *e
+					lookup.SetName, lookup.SetID = s.Name, s.ID
+					rule.Exprs[i] = &lookup
+					continue
+				}
+			case *expr.Dynset:
+				s, ok := anonymous[newHostSetKey(rule.Table, e.SetName)]
+				if ok {
+					dynset := *e
+					dynset.SetName, dynset.SetID = s.Name, s.ID
+					rule.Exprs[i] = &dynset
+					continue
+				}
+			}
+			rule.Exprs[i] = e
+		}
+		c.AddRule(&rule)
+	}
+
+	return c.Flush()
+}
+
+// hostSetKey identifies the set by the table and the set name.
+type hostSetKey struct {
+	family nftables.TableFamily
+	table  string
+	name   string
+}
+
+func newHostSetKey(t *nftables.Table, name string) hostSetKey {
+	return hostSetKey{family: t.Family, table: t.Name, name: name}
+}
@@ -0,0 +1,25 @@
+package firewall
+
+import "net"
+
+// Isolation of the devices within the isolated wireguard network.
+type Isolation struct {
+	// Servers are reachable by every device of the network.
+	Servers []net.IP
+	// Routes are the subnets routed behind the servers, reachable
+	// the same way.
+	Routes []net.IPNet
+	// Pairs of the devices reachable by each other.
+	Pairs []Pair
+}
+
+// Pair of the devices, the addresses are of the same family.
+type Pair struct {
+	A net.IP
+	B net.IP
+
+	// ARoutes and BRoutes are the subnets routed behind the devices,
+	// of the family of the addresses.
+	ARoutes []net.IPNet
+	BRoutes []net.IPNet
+}
@@ -4,6 +4,7 @@
 package firewall
 
 import (
+	"errors"
 	"net"
 )
 
@@ -22,25 +23,97 @@ func Init(Config, []uint16) (*NFTables, error) {
 }
 
 // UpdateTrustIPs mock method.
-func (nft *NFTables) UpdateTrustIPs(_, _ []net.IP) error {
+func (nft *NFTables) UpdateTrustIPs(_ []TrustIP) error {
 	return nil
 }
 
 // UpdateWGManagerIPs mock method.
-func (nft *NFTables) UpdateWGManagerIPs(_, _ []net.IP) error {
+func (nft *NFTables) UpdateWGManagerIPs(_ string, _, _ []net.IP) error {
 	return nil
 }
 
 // UpdateWGForwardWanIPs mock method.
-func (nft *NFTables) UpdateWGForwardWanIPs(_, _ []net.IP) error {
+func (nft *NFTables) UpdateWGForwardWanIPs(_ string, _, _ []net.IP) error {
 	return nil
 }
 
+// UpdateWGACLs mock method.
+func (nft *NFTables) UpdateWGACLs(_ string, _ []ACL) error {
+	return nil
+}
+
+// UpdateEgress mock method.
+func (nft *NFTables) UpdateEgress(_ []EgressRule) error {
+	return nil
+}
+
+// UpdateWGEgress mock method.
+func (nft *NFTables) UpdateWGEgress(_ string, _ []Egress) error {
+	return nil
+}
+
+// UpdateWGIsolation mock method.
+func (nft *NFTables) UpdateWGIsolation(_ string, _ Isolation) error {
+	return nil
+}
+
+// UpdateWGBandwidth mock method.
+func (nft *NFTables) UpdateWGBandwidth(_ string, _ []Bandwidth) error {
+	return nil
+}
+
+// UpdateWGGroups mock method.
+func (nft *NFTables) UpdateWGGroups(
+	_ string, _ []Group, _ []GroupPolicy,
+) error {
+	return nil
+}
+
+// UpdatePortForwards mock method.
+func (nft *NFTables) UpdatePortForwards(_ []PortForward) error {
+	return nil
+}
+
+// UpdateWGDevices mock method.
+func (nft *NFTables) UpdateWGDevices(_ string, _ []Device) error {
+	return nil
+}
+
+// Stats mock method.
+func (nft *NFTables) Stats() ([]Counter, error) {
+	return nil, nil
+}
+
+// Blacklist mock method.
+func (nft *NFTables) Blacklist() ([]net.IP, error) {
+	return nil, nil
+}
+
+// Unban mock method.
+func (nft *NFTables) Unban(_ net.IP) error {
+	return nil
+}
+
+// Revision mock method.
+func (nft *NFTables) Revision() uint64 {
+	return 0
+}
+
+// Preview mock method, the ruleset is built on linux only.
+func (nft *NFTables) Preview() (string, error) {
+	return "", errors.New("firewall preview is unsupported on this os")
+}
+
 // Cleanup mock method.
 func (nft *NFTables) Cleanup() error {
 	return nil
 }
 
+// UpdateWan mock method.
+func (nft *NFTables) UpdateWan() (bool, error) {
+	return false, nil
+}
+
 // WanIP returns ip address of wan interface.
 func (nft *NFTables) WanIP() net.IP {
 	return nft.wanIP
@@ -4,8 +4,12 @@
 package firewall
 
 import (
+	"fmt"
 	"net"
 	"runtime"
+	"sort"
+	"sync"
+	"time"
 
 	"golang.org/x/sys/unix"
 
@@ -21,17 +25,54 @@ import (
 
 const loIface = "lo"
 
+// meterTimeout of the rate limit state of the idle source.
+const meterTimeout = time.Minute
+
+// Names of the counters, the counter of the wireguard port gets
+// the network suffix.
+const (
+	counterInputDrop    = "input_drop"
+	counterForwardDrop  = "forward_drop"
+	counterOutputDrop   = "output_drop"
+	counterBlacklist    = "blacklist_drop"
+	counterTrustLimit   = "trust_ratelimit"
+	counterWGLimit      = "wg_ratelimit"
+	counterTrustAccept  = "trust_accept"
+	counterWGAccept     = "wg_accept"
+	counterDevicePrefix = "device_"
+)
+
+// conn describes the operations the ruleset is built with, it's satisfied
+// by *nftables.Conn and by the recorder of the preview.
+type conn interface {
+	AddTable(*nftables.Table) *nftables.Table
+	DelTable(*nftables.Table)
+	AddChain(*nftables.Chain) *nftables.Chain
+	FlushChain(*nftables.Chain)
+	DelChain(*nftables.Chain)
+	AddSet(*nftables.Set, []nftables.SetElement) error
+	SetAddElements(*nftables.Set, []nftables.SetElement) error
+	SetDeleteElements(*nftables.Set, []nftables.SetElement) error
+	FlushSet(*nftables.Set)
+	DelSet(*nftables.Set)
+	AddRule(*nftables.Rule) *nftables.Rule
+	AddObj(nftables.Obj) nftables.Obj
+	DeleteObject(nftables.Obj)
+	FlushRuleset()
+}
+
 // NFTables struct.
 type NFTables struct {
+	mu sync.Mutex
+
 	cfg Config
 
 	originNetNS netns.NsHandle
 	targetNetNS netns.NsHandle
 
-	wanIface string
-	wanIP    net.IP
-	wgIface  string
-	wgPort   uint16
+	wanIface   string
+	wanIP      net.IP
+	wgNetworks []*wgNetwork
 
 	tFilter  *nftables.Table
 	cInput   *nftables.Chain
@@ -39,15 +80,161 @@ type NFTables struct {
 	cOutput  *nftables.Chain
 
 	tNAT         *nftables.Table
+	cPrerouting  *nftables.Chain
 	cPostrouting *nftables.Chain
 
-	filterSetTrustIP     *nftables.Set
-	filterSetWGManagerIP *nftables.Set
-	filterSetWGForwardIP *nftables.Set
+	// chain of port forward rules jumped from forward chain
+	cPortForward *nftables.Chain
+	portForwards []PortForward
+
+	// chain of global egress rules jumped from forward chain
+	cEgress *nftables.Chain
+	egress  []EgressRule
+
+	filterSetTrustIP *nftables.Set
+	trustIPs         []TrustIP
+
+	// dynamic sets of the sources banned by the rate limits
+	filterSetBlacklist  *nftables.Set
+	filterSetBlacklist6 *nftables.Set
+
+	// dynamic sets of the rate limits state by the source address
+	filterSetTrustMeter  *nftables.Set
+	filterSetTrustMeter6 *nftables.Set
+	filterSetWGMeter     *nftables.Set
+	filterSetWGMeter6    *nftables.Set
+
+	// elements of the named ip sets by the set name
+	elements map[string][]net.IP
 
 	managerPorts []uint16
 
 	applied bool
+
+	// ruleset of the host replaced in flush mode, it's restored on cleanup,
+	// hostErr is the reason it isn't captured
+	host    *hostRuleset
+	hostErr error
+
+	// revision is increased by every change of the applied ruleset
+	revision uint64
+}
+
+// wgNetwork filtered.
+type wgNetwork struct {
+	name     string
+	iface    string
+	port     uint16
+	isolated bool
+
+	// suffix of the set and chain names, empty for the default network
+	suffix string
+
+	filterSetWGManagerIP *nftables.Set
+	filterSetWGForwardIP *nftables.Set
+
+	filterSetWGManagerIP6 *nftables.Set
+	filterSetWGForwardIP6 *nftables.Set
+
+	// chains of device acl rules by the connection direction
+	cACLOut *nftables.Chain
+	cACLIn  *nftables.Chain
+
+	acls []ACL
+
+	// chain of group policy rules
+	cGroup *nftables.Chain
+
+	groups   []Group
+	policies []GroupPolicy
+
+	// chain of the device counters on the default drop path
+	cDrop *nftables.Chain
+
+	devices []Device
+
+	// chain of device egress rules
+	cEgress *nftables.Chain
+
+	egress []Egress
+
+	// chain of the isolation rules, it's jumped in isolated network only
+	cIsolation *nftables.Chain
+
+	isolation Isolation
+
+	// chain of device bandwidth limits jumped first from forward chain
+	cBandwidth *nftables.Chain
+
+	bandwidth []Bandwidth
+}
+
+// managerSets returns ipv4 and ipv6 sets of manager devices.
+func (n *wgNetwork) managerSets() []*nftables.Set {
+	return []*nftables.Set{n.filterSetWGManagerIP, n.filterSetWGManagerIP6}
+}
+
+// groupSets returns ipv4 and ipv6 sets of the group devices.
+func (n *wgNetwork) groupSets(
+	t *nftables.Table,
+	group string,
+) []*nftables.Set {
+	return []*nftables.Set{
+		{
+			Name:    "wggroup_" + group + n.suffix,
+			Table:   t,
+			KeyType: nftables.TypeIPAddr,
+		},
+		{
+			Name:    "wggroup6_" + group + n.suffix,
+			Table:   t,
+			KeyType: nftables.TypeIP6Addr,
+		},
+	}
+}
+
+// bandwidthChains returns upload and download chains of the device
+// limits, all the addresses and routes of the device jump to the same
+// chains to share the single rate.
+func (n *wgNetwork) bandwidthChains(
+	t *nftables.Table,
+	ip net.IP,
+) []*nftables.Chain {
+	return []*nftables.Chain{
+		{
+			Name:  "wgupload_" + ip.String() + n.suffix,
+			Table: t,
+		},
+		{
+			Name:  "wgdownload_" + ip.String() + n.suffix,
+			Table: t,
+		},
+	}
+}
+
+// limitedChains returns the bandwidth chains of the devices with the
+// limits turned on.
+func (n *wgNetwork) limitedChains(
+	t *nftables.Table,
+	bandwidth []Bandwidth,
+) []*nftables.Chain {
+	var chains []*nftables.Chain
+	for _, b := range bandwidth {
+		bc := n.bandwidthChains(t, b.IP)
+		if b.Upload > 0 {
+			chains = append(chains, bc[0])
+		}
+		if b.Download > 0 {
+			chains = append(chains, bc[1])
+		}
+	}
+
+	return chains
+}
+
+// forwardSets returns ipv4 and ipv6 sets of wan forwarding devices.
+func (n *wgNetwork) forwardSets() []*nftables.Set {
+	return []*nftables.Set{n.filterSetWGForwardIP, n.filterSetWGForwardIP6}
 }
 
 // Init nftables firewall.
@@ -61,12 +248,45 @@ func Init(
 		return nil, err
 	}
 
+	nft := newNFTables(cfg, managerPorts, wanIface, wanIP)
+
+	// the failed capture leaves the default policy filtering on cleanup
+	nft.captureHost()
+
+	err = nft.apply()
+	if err != nil {
+		return nil, err
+	}
+
+	return nft, nil
+}
+
+// newNFTables constructor, nothing is applied.
+func newNFTables(
+	cfg Config,
+	managerPorts []uint16,
+	wanIface string,
+	wanIP net.IP,
+) *NFTables {
 	defaultPolicy := nftables.ChainPolicyDrop
 	if cfg.DefaultPolicy == "accept" {
 		defaultPolicy = nftables.ChainPolicyAccept
 	}
 
-	tFilter := &nftables.Table{Family: nftables.TableFamilyIPv4, Name: "filter"}
+	tFilter := &nftables.Table{Family: nftables.TableFamilyINet, Name: "filter"}
+	tNAT := &nftables.Table{Family: nftables.TableFamilyINet, Name: "nat"}
+	if cfg.Mode == ModeTable {
+		// base chains of the own table are hooked at the usual priorities,
+		// a packet has to be accepted by every table to pass, so the base
+		// chains accept and the drop rules match the own traffic only
+		tFilter = &nftables.Table{
+			Family: nftables.TableFamilyINet,
+			Name:   "wgnetwork",
+		}
+		tNAT = tFilter
+		defaultPolicy = nftables.ChainPolicyAccept
+	}
+
 	cInput := &nftables.Chain{
 		Name:     "input",
 		Table:    tFilter,
@@ -92,7 +312,13 @@ func Init(
 		Policy:   &defaultPolicy,
 	}
 
-	tNAT := &nftables.Table{Family: nftables.TableFamilyIPv4, Name: "nat"}
+	cPrerouting := &nftables.Chain{
+		Name:     "prerouting",
+		Table:    tNAT,
+		Type:     nftables.ChainTypeNAT,
+		Priority: nftables.ChainPriorityNATDest,
+		Hooknum:  nftables.ChainHookPrerouting,
+	}
 	cPostrouting := &nftables.Chain{
 		Name:     "postrouting",
 		Table:    tNAT,
@@ -101,29 +327,152 @@ func Init(
 		Hooknum:  nftables.ChainHookPostrouting,
 	}
 
-	filterSetTrustIP := &nftables.Set{
-		Name:    "trust_ipset",
-		Table:   tFilter,
-		KeyType: nftables.TypeIPAddr,
+	cPortForward := &nftables.Chain{
+		Name:  "port_forward",
+		Table: tFilter,
 	}
-	filterSetWGManagerIP := &nftables.Set{
-		Name:    "wgmanager_ipset",
-		Table:   tFilter,
-		KeyType: nftables.TypeIPAddr,
+
+	cEgress := &nftables.Chain{
+		Name:  "egress",
+		Table: tFilter,
 	}
-	filterSetWGForwardIP := &nftables.Set{
-		Name:    "wgforward_ipset",
-		Table:   tFilter,
-		KeyType: nftables.TypeIPAddr,
+
+	filterSetTrustIP := &nftables.Set{
+		Name:       "trust_ipset",
+		Table:      tFilter,
+		KeyType:    nftables.TypeIPAddr,
+		Interval:   true,
+		HasTimeout: true,
+	}
+
+	filterSetBlacklist := &nftables.Set{
+		Name:       "blacklist_ipset",
+		Table:      tFilter,
+		KeyType:    nftables.TypeIPAddr,
+		Dynamic:    true,
+		HasTimeout: true,
+	}
+	filterSetBlacklist6 := &nftables.Set{
+		Name:       "blacklist_ipset6",
+		Table:      tFilter,
+		KeyType:    nftables.TypeIP6Addr,
+		Dynamic:    true,
+		HasTimeout: true,
+	}
+
+	filterSetTrustMeter := &nftables.Set{
+		Name:       "trust_meter",
+		Table:      tFilter,
+		KeyType:    nftables.TypeIPAddr,
+		Dynamic:    true,
+		HasTimeout: true,
+		Timeout:    meterTimeout,
+	}
+	filterSetTrustMeter6 := &nftables.Set{
+		Name:       "trust_meter6",
+		Table:      tFilter,
+		KeyType:    nftables.TypeIP6Addr,
+		Dynamic:    true,
+		HasTimeout: true,
+		Timeout:    meterTimeout,
+	}
+	filterSetWGMeter := &nftables.Set{
+		Name:       "wg_meter",
+		Table:      tFilter,
+		KeyType:    nftables.TypeIPAddr,
+		Dynamic:    true,
+		HasTimeout: true,
+		Timeout:    meterTimeout,
+	}
+	filterSetWGMeter6 := &nftables.Set{
+		Name:       "wg_meter6",
+		Table:      tFilter,
+		KeyType:    nftables.TypeIP6Addr,
+		Dynamic:    true,
+		HasTimeout: true,
+		Timeout:    meterTimeout,
+	}
+
+	wgNetworks := make([]*wgNetwork, len(cfg.WGNetworks))
+	for i, n := range cfg.WGNetworks {
+		// sets of the default network keep their names
+		suffix := ""
+		if i > 0 {
+			suffix = "_" + n.Name
+		}
+
+		wgNetworks[i] = &wgNetwork{
+			name:     n.Name,
+			iface:    n.Iface,
+			port:     n.Port,
+			isolated: n.Isolated,
+
+			suffix: suffix,
+
+			filterSetWGManagerIP: &nftables.Set{
+				Name:    "wgmanager_ipset" + suffix,
+				Table:   tFilter,
+				KeyType: nftables.TypeIPAddr,
+			},
+			filterSetWGForwardIP: &nftables.Set{
+				Name:    "wgforward_ipset" + suffix,
+				Table:   tFilter,
+				KeyType: nftables.TypeIPAddr,
+			},
+
+			filterSetWGManagerIP6: &nftables.Set{
+				Name:    "wgmanager_ipset6" + suffix,
+				Table:   tFilter,
+				KeyType: nftables.TypeIP6Addr,
+			},
+			filterSetWGForwardIP6: &nftables.Set{
+				Name:    "wgforward_ipset6" + suffix,
+				Table:   tFilter,
+				KeyType: nftables.TypeIP6Addr,
+			},
+
+			cACLOut: &nftables.Chain{
+				Name:  "wgacl_out" + suffix,
+				Table: tFilter,
+			},
+			cACLIn: &nftables.Chain{
+				Name:  "wgacl_in" + suffix,
+				Table: tFilter,
+			},
+
+			cGroup: &nftables.Chain{
+				Name:  "wggroup" + suffix,
+				Table: tFilter,
+			},
+
+			cDrop: &nftables.Chain{
+				Name:  "wgdrop" + suffix,
+				Table: tFilter,
+			},
+
+			cEgress: &nftables.Chain{
+				Name:  "wgegress" + suffix,
+				Table: tFilter,
+			},
+
+			cIsolation: &nftables.Chain{
+				Name:  "wgisolation" + suffix,
+				Table: tFilter,
+			},
+
+			cBandwidth: &nftables.Chain{
+				Name:  "wgbandwidth" + suffix,
+				Table: tFilter,
+			},
+		}
 	}
 
 	nft := &NFTables{
 		cfg: cfg,
 
-		wanIface: wanIface,
-		wanIP:    wanIP,
-		wgIface:  cfg.WGIface,
-		wgPort:   cfg.WGPort,
+		wanIface:   wanIface,
+		wanIP:      wanIP,
+		wgNetworks: wgNetworks,
 
 		tFilter:  tFilter,
 		cInput:   cInput,
@@ -131,21 +480,29 @@ func Init(
 		cOutput:  cOutput,
 
 		tNAT:         tNAT,
+		cPrerouting:  cPrerouting,
 		cPostrouting: cPostrouting,
 
-		filterSetTrustIP:     filterSetTrustIP,
-		filterSetWGManagerIP: filterSetWGManagerIP,
-		filterSetWGForwardIP: filterSetWGForwardIP,
+		cPortForward: cPortForward,
 
-		managerPorts: managerPorts,
-	}
+		cEgress: cEgress,
 
-	err = nft.apply()
-	if err != nil {
-		return nil, err
+		filterSetTrustIP: filterSetTrustIP,
+
+		filterSetBlacklist:  filterSetBlacklist,
+		filterSetBlacklist6: filterSetBlacklist6,
+
+		filterSetTrustMeter:  filterSetTrustMeter,
+		filterSetTrustMeter6: filterSetTrustMeter6,
+		filterSetWGMeter:     filterSetWGMeter,
+		filterSetWGMeter6:    filterSetWGMeter6,
+
+		elements: make(map[string][]net.IP),
+
+		managerPorts: managerPorts,
 	}
 
-	return nft, nil
+	return nft
 }
 
 // networkNamespaceBind target by name.
@@ -206,6 +563,21 @@ func (nft *NFTables) networkNamespaceRelease() error {
 	return nil
 }
 
+// reset the ruleset, in table mode only the own table is removed.
+func (nft *NFTables) reset(c conn) {
+	if nft.cfg.Mode != ModeTable {
+		// cmd: nft flush ruleset
+		c.FlushRuleset()
+		return
+	}
+
+	// the table is added first to be deleted whether it exists or not
+	// cmd: nft add table inet wgnetwork
+	// cmd: nft delete table inet wgnetwork
+	c.AddTable(nft.tFilter)
+	c.DelTable(nft.tFilter)
+}
+
 // apply rules
 func (nft *NFTables) apply() error {
 	if !nft.cfg.Enabled {
@@ -220,70 +592,236 @@ func (nft *NFTables) apply() error {
 	// release network namespace finally
 	defer nft.networkNamespaceRelease()
 
-	c.FlushRuleset()
+	err = nft.build(c)
+	if err != nil {
+		return err
+	}
+
+	// apply configuration
+	err = c.Flush()
+	if err != nil {
+		return err
+	}
+	nft.applied = true
+
+	return nil
+}
+
+// build the whole ruleset.
+func (nft *NFTables) build(c conn) error {
+	nft.reset(c)
 	//
 	// Init Tables and Chains.
 	//
 
 	// add filter table
-	// cmd: nft add table ip filter
+	// cmd: nft add table inet filter
 	c.AddTable(nft.tFilter)
 	// add input chain of filter table
-	// cmd: nft add chain ip filter input \
+	// cmd: nft add chain inet filter input \
 	// { type filter hook input priority 0 \; policy drop\; }
 	c.AddChain(nft.cInput)
 	// add forward chain
-	// cmd: nft add chain ip filter forward \
+	// cmd: nft add chain inet filter forward \
 	// { type filter hook forward priority 0 \; policy drop\; }
 	c.AddChain(nft.cForward)
 	// add output chain
-	// cmd: nft add chain ip filter output \
+	// cmd: nft add chain inet filter output \
 	// { type filter hook output priority 0 \; policy drop\; }
 	c.AddChain(nft.cOutput)
 
-	// add nat table
-	// cmd: nft add table ip nat
-	c.AddTable(nft.tNAT)
+	// add nat table, it's the same table in table mode
+	// cmd: nft add table inet nat
+	if nft.tNAT != nft.tFilter {
+		c.AddTable(nft.tNAT)
+	}
+	// add prerouting chain
+	// cmd: nft add chain inet nat prerouting \
+	// { type nat hook prerouting priority -100 \; }
+	c.AddChain(nft.cPrerouting)
 	// add postrouting chain
-	// cmd: nft add chain ip nat postrouting \
+	// cmd: nft add chain inet nat postrouting \
 	// { type nat hook postrouting priority 100 \; }
 	c.AddChain(nft.cPostrouting)
 
+	// add port_forward chain
+	// cmd: nft add chain inet filter port_forward
+	c.AddChain(nft.cPortForward)
+
+	// add egress chain
+	// cmd: nft add chain inet filter egress
+	c.AddChain(nft.cEgress)
+
 	//
 	// Init sets.
 	//
 
 	// add trust_ipset
-	// cmd: nft add set ip filter trust_ipset { type ipv4_addr\; }
+	// cmd: nft add set inet filter trust_ipset \
+	// { type ipv4_addr\; flags interval,timeout\; }
 	// --
 	// set trust_ipset {
 	//         type ipv4_addr
+	//         flags interval,timeout
 	// }
-	err = c.AddSet(nft.filterSetTrustIP, nil)
+	err := c.AddSet(nft.filterSetTrustIP, nft.trustElements(time.Now()))
 	if err != nil {
 		return err
 	}
 
-	// add wgmanager_ipset
-	// cmd: nft add set ip filter wgmanager_ipset { type ipv4_addr\; }
+	// add blacklist_ipset and blacklist_ipset6
+	// cmd: nft add set inet filter blacklist_ipset \
+	// { type ipv4_addr\; flags dynamic,timeout\; }
 	// --
-	// set wgmanager_ipset {
+	// set blacklist_ipset {
 	//         type ipv4_addr
+	//         flags dynamic,timeout
 	// }
-	err = c.AddSet(nft.filterSetWGManagerIP, nil)
+	err = c.AddSet(nft.filterSetBlacklist, nil)
+	if err != nil {
+		return err
+	}
+	err = c.AddSet(nft.filterSetBlacklist6, nil)
 	if err != nil {
 		return err
 	}
 
-	// add wgforward_ipset
-	// cmd: nft add set ip filter wgforward_ipset { type ipv4_addr\; }
+	// add trust_meter and trust_meter6
+	// cmd: nft add set inet filter trust_meter \
+	// { type ipv4_addr\; flags dynamic,timeout\; timeout 1m\; }
 	// --
-	// set wgforward_ipset {
+	// set trust_meter {
 	//         type ipv4_addr
+	//         flags dynamic,timeout
+	//         timeout 1m
 	// }
-	err = c.AddSet(nft.filterSetWGForwardIP, nil)
-	if err != nil {
-		return err
+	if nft.cfg.RateLimit.TrustRate > 0 {
+		err = c.AddSet(nft.filterSetTrustMeter, nil)
+		if err != nil {
+			return err
+		}
+		err = c.AddSet(nft.filterSetTrustMeter6, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	// add wg_meter and wg_meter6
+	// cmd: nft add set inet filter wg_meter \
+	// { type ipv4_addr\; flags dynamic,timeout\; timeout 1m\; }
+	if nft.cfg.RateLimit.WGRate > 0 {
+		err = c.AddSet(nft.filterSetWGMeter, nil)
+		if err != nil {
+			return err
+		}
+		err = c.AddSet(nft.filterSetWGMeter6, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	//
+	// Init counters.
+	//
+
+	nft.addCounters(c)
+
+	for _, n := range nft.wgNetworks {
+		// add wgmanager_ipset
+		// cmd: nft add set inet filter wgmanager_ipset { type ipv4_addr\; }
+		// --
+		// set wgmanager_ipset {
+		//         type ipv4_addr
+		// }
+		err = c.AddSet(
+			n.filterSetWGManagerIP, nft.setElements(n.filterSetWGManagerIP))
+		if err != nil {
+			return err
+		}
+
+		// add wgforward_ipset
+		// cmd: nft add set inet filter wgforward_ipset { type ipv4_addr\; }
+		// --
+		// set wgforward_ipset {
+		//         type ipv4_addr
+		// }
+		err = c.AddSet(
+			n.filterSetWGForwardIP, nft.setElements(n.filterSetWGForwardIP))
+		if err != nil {
+			return err
+		}
+
+		// add wgmanager_ipset6
+		// cmd: nft add set inet filter wgmanager_ipset6 { type ipv6_addr\; }
+		// --
+		// set wgmanager_ipset6 {
+		//         type ipv6_addr
+		// }
+		err = c.AddSet(
+			n.filterSetWGManagerIP6, nft.setElements(n.filterSetWGManagerIP6))
+		if err != nil {
+			return err
+		}
+
+		// add wgforward_ipset6
+		// cmd: nft add set inet filter wgforward_ipset6 { type ipv6_addr\; }
+		// --
+		// set wgforward_ipset6 {
+		//         type ipv6_addr
+		// }
+		err = c.AddSet(
+			n.filterSetWGForwardIP6, nft.setElements(n.filterSetWGForwardIP6))
+		if err != nil {
+			return err
+		}
+
+		// add acl chains
+		// cmd: nft add chain inet filter wgacl_out
+		// cmd: nft add chain inet filter wgacl_in
+		c.AddChain(n.cACLOut)
+		c.AddChain(n.cACLIn)
+		err = nft.aclRules(c, n)
+		if err != nil {
+			return err
+		}
+
+		// add group chain and sets
+		// cmd: nft add chain inet filter wggroup
+		c.AddChain(n.cGroup)
+		err = nft.groupRules(c, n)
+		if err != nil {
+			return err
+		}
+
+		// add device drop chain and counters
+		// cmd: nft add chain inet filter wgdrop
+		// cmd: nft add counter inet filter device_172.16.0.2
+		c.AddChain(n.cDrop)
+		for _, d := range n.devices {
+			c.AddObj(nft.counter(deviceCounterName(d)))
+		}
+		nft.deviceRules(c, n)
+
+		// add device egress chain
+		// cmd: nft add chain inet filter wgegress
+		c.AddChain(n.cEgress)
+		err = nft.wgEgressRules(c, n)
+		if err != nil {
+			return err
+		}
+
+		// add isolation chain
+		// cmd: nft add chain inet filter wgisolation
+		c.AddChain(n.cIsolation)
+		err = nft.isolationRules(c, n)
+		if err != nil {
+			return err
+		}
+
+		// add bandwidth chain
+		// cmd: nft add chain inet filter wgbandwidth
+		c.AddChain(n.cBandwidth)
+		nft.bandwidthRules(c, n)
 	}
 
 	//
@@ -300,6 +838,10 @@ func (nft *NFTables) apply() error {
 	if err != nil {
 		return err
 	}
+	err = nft.inputRateLimitRules(c, nft.wanIface)
+	if err != nil {
+		return err
+	}
 	err = nft.inputTrustIPSetRules(c, nft.wanIface)
 	if err != nil {
 		return err
@@ -316,15 +858,26 @@ func (nft *NFTables) apply() error {
 	if err != nil {
 		return err
 	}
-	err = nft.sdnRules(c)
+	nft.forwardBaseRules(c)
+	for _, n := range nft.wgNetworks {
+		err = nft.sdnRules(c, n)
+		if err != nil {
+			return err
+		}
+		err = nft.sdnForwardRules(c, n)
+		if err != nil {
+			return err
+		}
+	}
+	nft.natRules(c)
+	err = nft.portForwardRules(c)
 	if err != nil {
 		return err
 	}
-	err = nft.sdnForwardRules(c)
+	err = nft.egressRules(c)
 	if err != nil {
 		return err
 	}
-	nft.natRules(c)
 
 	for _, iface := range nft.cfg.Ifaces {
 		if iface == nft.wanIface {
@@ -339,6 +892,10 @@ func (nft *NFTables) apply() error {
 		if err != nil {
 			return err
 		}
+		err = nft.inputRateLimitRules(c, iface)
+		if err != nil {
+			return err
+		}
 		err = nft.inputTrustIPSetRules(c, iface)
 		if err != nil {
 			return err
@@ -357,19 +914,255 @@ func (nft *NFTables) apply() error {
 		}
 	}
 
-	// apply configuration
-	err = c.Flush()
-	if err != nil {
-		return err
+	// the default drop path goes after every other rule
+	return nft.dropRules(c)
+}
+
+// dropPolicy reports whether the default policy drops the packets.
+func (nft *NFTables) dropPolicy() bool {
+	return nft.cfg.DefaultPolicy != "accept"
+}
+
+// counter object of the filter table.
+func (nft *NFTables) counter(name string) *nftables.CounterObj {
+	return &nftables.CounterObj{Table: nft.tFilter, Name: name}
+}
+
+// deviceCounterName by the ipv4 address of the device.
+func deviceCounterName(d Device) string {
+	return counterDevicePrefix + d.IP.String()
+}
+
+// addCounters of the rules, the counters of the devices are added along
+// with the device drop chains.
+func (nft *NFTables) addCounters(c conn) {
+	names := []string{counterBlacklist, counterTrustAccept}
+	if nft.dropPolicy() {
+		names = append(names,
+			counterInputDrop, counterForwardDrop, counterOutputDrop)
+	}
+	if nft.cfg.RateLimit.TrustRate > 0 {
+		names = append(names, counterTrustLimit)
+	}
+	if nft.cfg.RateLimit.WGRate > 0 {
+		names = append(names, counterWGLimit)
+	}
+	for _, n := range nft.wgNetworks {
+		names = append(names, counterWGAccept+n.suffix)
+	}
+
+	for _, name := range names {
+		// cmd: nft add counter inet filter input_drop
+		c.AddObj(nft.counter(name))
+	}
+}
+
+// dropRules count the packets going to the default drop policy,
+// the packets of the devices are counted by the device chains. In table
+// mode the base chains accept, so the packets of the wireguard interfaces
+// and of the trust and wireguard ports on the public interfaces are
+// dropped explicitly and the traffic of the other firewall users is left
+// to their tables.
+func (nft *NFTables) dropRules(c conn) error {
+	if !nft.dropPolicy() {
+		return nil
+	}
+
+	// cmd: nft add rule inet filter input meta iifname "wg0" jump wgdrop
+	// --
+	// iifname "wg0" jump wgdrop
+	for _, n := range nft.wgNetworks {
+		for _, chain := range []*nftables.Chain{nft.cInput, nft.cForward} {
+			exprs := make([]expr.Any, 0, 3)
+			exprs = append(exprs, nfutils.SetIIF(n.iface)...)
+			exprs = append(exprs, nfutils.ExprJump(n.cDrop.Name))
+			rule := &nftables.Rule{
+				Table: nft.tFilter,
+				Chain: chain,
+				Exprs: exprs}
+			c.AddRule(rule)
+		}
+	}
+
+	matches := map[*nftables.Chain][][]expr.Any{
+		nft.cInput:   {nil},
+		nft.cForward: {nil},
+		nft.cOutput:  {nil},
+	}
+	var drops map[*nftables.Chain][][]expr.Any
+	if nft.cfg.Mode == ModeTable {
+		// the anonymous sets are bound to a single rule,
+		// so the drop rules get the matches of their own
+		var err error
+		matches, err = nft.tableDropMatches(c)
+		if err != nil {
+			return err
+		}
+		drops, err = nft.tableDropMatches(c)
+		if err != nil {
+			return err
+		}
+	}
+
+	// cmd: nft add rule inet filter input counter name "input_drop" \
+	// limit rate 10/minute log prefix "wgnetwork input drop: "
+	// --
+	// counter name "input_drop" limit rate 10/minute log prefix "wgnetwork input drop: "
+	//
+	// in table mode the drop goes by a rule of its own, the limit
+	// of the log breaks the rule once the rate is over
+	// cmd: nft add rule inet wgnetwork input meta iifname "wg0" \
+	// counter name "input_drop" limit rate 10/minute \
+	// log prefix "wgnetwork input drop: "
+	// cmd: nft add rule inet wgnetwork input meta iifname "wg0" drop
+	// --
+	// iifname "wg0" counter name "input_drop" limit rate 10/minute log prefix "wgnetwork input drop: "
+	// iifname "wg0" drop
+	counters := map[*nftables.Chain]string{
+		nft.cInput:   counterInputDrop,
+		nft.cForward: counterForwardDrop,
+		nft.cOutput:  counterOutputDrop,
+	}
+	for _, chain := range []*nftables.Chain{nft.cInput, nft.cForward, nft.cOutput} {
+		for i, match := range matches[chain] {
+			exprs := make([]expr.Any, 0, len(match)+3)
+			exprs = append(exprs, match...)
+			exprs = append(exprs, nfutils.ExprCounterRef(counters[chain]))
+			if nft.cfg.DropLogRate > 0 {
+				exprs = append(exprs,
+					nfutils.ExprLimit(
+						uint64(nft.cfg.DropLogRate), expr.LimitTimeMinute),
+					nfutils.ExprLog("wgnetwork "+chain.Name+" drop: "))
+			}
+			rule := &nftables.Rule{
+				Table: nft.tFilter,
+				Chain: chain,
+				Exprs: exprs}
+			c.AddRule(rule)
+
+			if drops == nil {
+				continue
+			}
+			exprs = make([]expr.Any, 0, len(drops[chain][i])+1)
+			exprs = append(exprs, drops[chain][i]...)
+			exprs = append(exprs, nfutils.ExprDrop())
+			rule = &nftables.Rule{
+				Table: nft.tFilter,
+				Chain: chain,
+				Exprs: exprs}
+			c.AddRule(rule)
+		}
 	}
-	nft.applied = true
 
 	return nil
 }
 
+// tableDropMatches of the packets dropped in table mode by chain:
+// the wireguard interfaces and the trust and wireguard ports
+// of the public interfaces.
+func (nft *NFTables) tableDropMatches(
+	c conn,
+) (map[*nftables.Chain][][]expr.Any, error) {
+	matches := make(map[*nftables.Chain][][]expr.Any, 3)
+
+	// iifname "wg0"
+	// oifname "wg0"
+	for _, n := range nft.wgNetworks {
+		matches[nft.cInput] = append(matches[nft.cInput],
+			nfutils.SetIIF(n.iface))
+		matches[nft.cForward] = append(matches[nft.cForward],
+			nfutils.SetIIF(n.iface), nfutils.SetOIF(n.iface))
+		matches[nft.cOutput] = append(matches[nft.cOutput],
+			nfutils.SetOIF(n.iface))
+	}
+
+	ports := make([]uint16, len(nft.wgNetworks))
+	for i, n := range nft.wgNetworks {
+		ports[i] = n.port
+	}
+
+	ifaces := []string{nft.wanIface}
+	for _, iface := range nft.cfg.Ifaces {
+		if iface != nft.wanIface {
+			ifaces = append(ifaces, iface)
+		}
+	}
+
+	// iifname "eth0" tcp dport { 22 }
+	// iifname "eth0" udp dport { 51820 }
+	// oifname "eth0" tcp sport { 22 }
+	// oifname "eth0" udp sport { 51820 }
+	for _, iface := range ifaces {
+		sets := make([]*nftables.Set, 4)
+		for i := range sets {
+			sets[i] = nfutils.GetPortSet(nft.tFilter)
+			elems := nft.cfg.trustPorts()
+			if i%2 == 1 {
+				elems = nfutils.GetPortElems(ports)
+			}
+			err := c.AddSet(sets[i], elems)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		exprs := make([]expr.Any, 0, 8)
+		exprs = append(exprs, nfutils.SetIIF(iface)...)
+		exprs = append(exprs, nfutils.SetProtoTCP()...)
+		exprs = append(exprs, nfutils.SetDPortSet(sets[0])...)
+		matches[nft.cInput] = append(matches[nft.cInput], exprs)
+
+		exprs = make([]expr.Any, 0, 8)
+		exprs = append(exprs, nfutils.SetIIF(iface)...)
+		exprs = append(exprs, nfutils.SetProtoUDP()...)
+		exprs = append(exprs, nfutils.SetDPortSet(sets[1])...)
+		matches[nft.cInput] = append(matches[nft.cInput], exprs)
+
+		exprs = make([]expr.Any, 0, 8)
+		exprs = append(exprs, nfutils.SetOIF(iface)...)
+		exprs = append(exprs, nfutils.SetProtoTCP()...)
+		exprs = append(exprs, nfutils.SetSPortSet(sets[2])...)
+		matches[nft.cOutput] = append(matches[nft.cOutput], exprs)
+
+		exprs = make([]expr.Any, 0, 8)
+		exprs = append(exprs, nfutils.SetOIF(iface)...)
+		exprs = append(exprs, nfutils.SetProtoUDP()...)
+		exprs = append(exprs, nfutils.SetSPortSet(sets[3])...)
+		matches[nft.cOutput] = append(matches[nft.cOutput], exprs)
+	}
+
+	return matches, nil
+}
+
+// deviceRules count the packets of the devices on the default drop path.
+func (nft *NFTables) deviceRules(c conn, n *wgNetwork) {
+	// cmd: nft add rule inet filter wgdrop ip saddr 172.16.0.2 \
+	// counter name "device_172.16.0.2" return
+	// --
+	// ip saddr 172.16.0.2 counter name "device_172.16.0.2" return
+	for _, d := range n.devices {
+		name := deviceCounterName(d)
+		for _, ip := range []net.IP{d.IP, d.IP6} {
+			if ip == nil {
+				continue
+			}
+
+			exprs := make([]expr.Any, 0, 6)
+			exprs = append(exprs, nfutils.SetSAddr(ip)...)
+			exprs = append(exprs,
+				nfutils.ExprCounterRef(name), nfutils.ExprReturn())
+			rule := &nftables.Rule{
+				Table: nft.tFilter,
+				Chain: n.cDrop,
+				Exprs: exprs}
+			c.AddRule(rule)
+		}
+	}
+}
+
 // inputLocalIfaceRules to apply.
-func (nft *NFTables) inputLocalIfaceRules(c *nftables.Conn) {
-	// cmd: nft add rule ip filter input meta iifname "lo" accept
+func (nft *NFTables) inputLocalIfaceRules(c conn) {
+	// cmd: nft add rule inet filter input meta iifname "lo" accept
 	// --
 	// iifname "lo" accept
 	exprs := make([]expr.Any, 0, 3)
@@ -381,7 +1174,7 @@ func (nft *NFTables) inputLocalIfaceRules(c *nftables.Conn) {
 		Exprs: exprs}
 	c.AddRule(rule)
 
-	// cmd: nft add rule ip filter input meta iifname != "lo" \
+	// cmd: nft add rule inet filter input meta iifname != "lo" \
 	// ip saddr 127.0.0.0/8 reject
 	// --
 	// iifname != "lo" ip saddr 127.0.0.0/8 reject with icmp type prot-unreachable
@@ -401,8 +1194,8 @@ func (nft *NFTables) inputLocalIfaceRules(c *nftables.Conn) {
 }
 
 // outputLocalIfaceRules to apply.
-func (nft *NFTables) outputLocalIfaceRules(c *nftables.Conn) {
-	// cmd: nft add rule ip filter output meta oifname "lo" accept
+func (nft *NFTables) outputLocalIfaceRules(c conn) {
+	// cmd: nft add rule inet filter output meta oifname "lo" accept
 	// --
 	// oifname "lo" accept
 	exprs := make([]expr.Any, 0, 3)
@@ -416,8 +1209,8 @@ func (nft *NFTables) outputLocalIfaceRules(c *nftables.Conn) {
 }
 
 // inputHostBaseRules to apply.
-func (nft *NFTables) inputHostBaseRules(c *nftables.Conn, iface string) error {
-	// cmd: nft add rule ip filter input meta iifname "eth0" ip protocol icmp \
+func (nft *NFTables) inputHostBaseRules(c conn, iface string) error {
+	// cmd: nft add rule inet filter input meta iifname "eth0" ip protocol icmp \
 	// ct state { established, related } accept
 	// --
 	// iifname "eth0" ip protocol icmp ct state { established, related } accept
@@ -441,8 +1234,24 @@ func (nft *NFTables) inputHostBaseRules(c *nftables.Conn, iface string) error {
 		Exprs: exprs}
 	c.AddRule(rule)
 
-	// cmd: nft add rule ip filter input meta iifname "eth0" \
-	// ip protocol udp udp sport 53 \
+	// neighbor discovery doesn't work without icmpv6
+	// cmd: nft add rule inet filter input meta iifname "eth0" \
+	// meta l4proto ipv6-icmp accept
+	// --
+	// iifname "eth0" meta l4proto ipv6-icmp accept
+	exprs = make([]expr.Any, 0, 5)
+	exprs = append(exprs, nfutils.SetIIF(iface)...)
+	exprs = append(exprs, nfutils.SetProtoICMPv6()...)
+	exprs = append(exprs, nfutils.ExprAccept())
+
+	rule = &nftables.Rule{
+		Table: nft.tFilter,
+		Chain: nft.cInput,
+		Exprs: exprs}
+	c.AddRule(rule)
+
+	// cmd: nft add rule inet filter input meta iifname "eth0" \
+	// ip protocol udp udp sport 53 \
 	// ct state established accept
 	// --
 	// iifname "eth0" udp sport domain ct state established accept
@@ -459,7 +1268,7 @@ func (nft *NFTables) inputHostBaseRules(c *nftables.Conn, iface string) error {
 		Exprs: exprs}
 	c.AddRule(rule)
 
-	// cmd: nft add rule ip filter input meta iifname "eth0" \
+	// cmd: nft add rule inet filter input meta iifname "eth0" \
 	// ip protocol tcp tcp sport 53 \
 	// ct state established accept
 	// --
@@ -477,7 +1286,7 @@ func (nft *NFTables) inputHostBaseRules(c *nftables.Conn, iface string) error {
 		Exprs: exprs}
 	c.AddRule(rule)
 
-	// cmd: nft add rule ip filter input meta iifname "eth0" \
+	// cmd: nft add rule inet filter input meta iifname "eth0" \
 	// ip protocol tcp tcp sport { 80, 443 } \
 	// ct state established accept
 	// --
@@ -508,8 +1317,8 @@ func (nft *NFTables) inputHostBaseRules(c *nftables.Conn, iface string) error {
 }
 
 // outputHostBaseRules to apply.
-func (nft *NFTables) outputHostBaseRules(c *nftables.Conn, iface string) error {
-	// cmd: nft add rule ip filter output meta oifname "eth0" ip protocol icmp \
+func (nft *NFTables) outputHostBaseRules(c conn, iface string) error {
+	// cmd: nft add rule inet filter output meta oifname "eth0" ip protocol icmp \
 	// ct state { new, established } accept
 	// --
 	// oifname "eth0" ip protocol icmp ct state { established, new } accept
@@ -533,7 +1342,22 @@ func (nft *NFTables) outputHostBaseRules(c *nftables.Conn, iface string) error {
 		Exprs: exprs}
 	c.AddRule(rule)
 
-	// cmd: nft add rule ip filter output meta oifname "eth0" \
+	// cmd: nft add rule inet filter output meta oifname "eth0" \
+	// meta l4proto ipv6-icmp accept
+	// --
+	// oifname "eth0" meta l4proto ipv6-icmp accept
+	exprs = make([]expr.Any, 0, 5)
+	exprs = append(exprs, nfutils.SetOIF(iface)...)
+	exprs = append(exprs, nfutils.SetProtoICMPv6()...)
+	exprs = append(exprs, nfutils.ExprAccept())
+
+	rule = &nftables.Rule{
+		Table: nft.tFilter,
+		Chain: nft.cOutput,
+		Exprs: exprs}
+	c.AddRule(rule)
+
+	// cmd: nft add rule inet filter output meta oifname "eth0" \
 	// ip protocol udp udp dport 53 \
 	// ct state { new, established } accept
 	// --
@@ -558,7 +1382,7 @@ func (nft *NFTables) outputHostBaseRules(c *nftables.Conn, iface string) error {
 		Exprs: exprs}
 	c.AddRule(rule)
 
-	// cmd: nft add rule ip filter output meta oifname "eth0" \
+	// cmd: nft add rule inet filter output meta oifname "eth0" \
 	// ip protocol tcp tcp dport 53 \
 	// ct state { new, established } accept
 	// --
@@ -583,7 +1407,7 @@ func (nft *NFTables) outputHostBaseRules(c *nftables.Conn, iface string) error {
 		Exprs: exprs}
 	c.AddRule(rule)
 
-	// cmd: nft add rule ip filter output meta oifname "eth0" \
+	// cmd: nft add rule inet filter output meta oifname "eth0" \
 	// ip protocol tcp tcp dport { 80, 443 } \
 	// ct state { new, established } accept
 	// --
@@ -619,9 +1443,136 @@ func (nft *NFTables) outputHostBaseRules(c *nftables.Conn, iface string) error {
 	return nil
 }
 
+// inputRateLimitRules to apply, the sources over the rate limits of the
+// public ports are dropped for the ban timeout. The sources of the trust
+// ipset are never banned, the lockout by a burst of the own connections
+// isn't possible.
+func (nft *NFTables) inputRateLimitRules(c conn, iface string) error {
+	// cmd: nft add rule inet filter input meta iifname "eth0" \
+	// ip saddr != @trust_ipset ip saddr @blacklist_ipset \
+	// counter name "blacklist_drop" drop
+	// --
+	// iifname "eth0" ip saddr != @trust_ipset ip saddr @blacklist_ipset counter name "blacklist_drop" drop
+	for _, set := range []*nftables.Set{
+		nft.filterSetBlacklist, nft.filterSetBlacklist6,
+	} {
+		exprs := make([]expr.Any, 0, 12)
+		exprs = append(exprs, nfutils.SetIIF(iface)...)
+		exprs = append(exprs, nft.trustExemption(set)...)
+		exprs = append(exprs, nfutils.SetSAddrSet(set)...)
+		exprs = append(exprs, nfutils.ExprCounterRef(counterBlacklist))
+		exprs = append(exprs, nfutils.ExprDrop())
+		rule := &nftables.Rule{
+			Table: nft.tFilter,
+			Chain: nft.cInput,
+			Exprs: exprs}
+		c.AddRule(rule)
+	}
+
+	rl := nft.cfg.RateLimit
+	blacklists := []*nftables.Set{
+		nft.filterSetBlacklist, nft.filterSetBlacklist6,
+	}
+
+	// cmd: nft add rule inet filter input meta iifname "eth0" \
+	// ip protocol tcp tcp dport { 22 } ct state new \
+	// ip saddr != @trust_ipset \
+	// add @trust_meter { ip saddr limit rate over 10/minute } \
+	// add @blacklist_ipset { ip saddr timeout 10m } \
+	// counter name "trust_ratelimit" drop
+	// --
+	// iifname "eth0" tcp dport { 22 } ct state new ip saddr != @trust_ipset add @trust_meter { ip saddr limit rate over 10/minute } add @blacklist_ipset { ip saddr timeout 10m } counter name "trust_ratelimit" drop
+	if rl.TrustRate > 0 {
+		meters := []*nftables.Set{
+			nft.filterSetTrustMeter, nft.filterSetTrustMeter6,
+		}
+		for i := range meters {
+			portSet := nfutils.GetPortSet(nft.tFilter)
+			err := c.AddSet(portSet, nft.cfg.trustPorts())
+			if err != nil {
+				return err
+			}
+
+			exprs := make([]expr.Any, 0, 22)
+			exprs = append(exprs, nfutils.SetIIF(iface)...)
+			exprs = append(exprs, nfutils.SetProtoTCP()...)
+			exprs = append(exprs, nfutils.SetDPortSet(portSet)...)
+			exprs = append(exprs, nfutils.SetConntrackStateNew()...)
+			exprs = append(exprs, nft.trustExemption(meters[i])...)
+			exprs = append(exprs, nfutils.SetSAddrLimit(
+				meters[i], uint64(rl.TrustRate), expr.LimitTimeMinute)...)
+			exprs = append(exprs, nfutils.SetSAddrAddSet(
+				blacklists[i], rl.BanTimeout)...)
+			exprs = append(exprs, nfutils.ExprCounterRef(counterTrustLimit))
+			exprs = append(exprs, nfutils.ExprDrop())
+			rule := &nftables.Rule{
+				Table: nft.tFilter,
+				Chain: nft.cInput,
+				Exprs: exprs}
+			c.AddRule(rule)
+		}
+	}
+
+	// the handshake initiations are metered only, the data packets
+	// of the peers are never counted
+	// cmd: nft add rule inet filter input meta iifname "eth0" \
+	// ip protocol udp udp dport { 51820 } @th,64,8 1 \
+	// ip saddr != @trust_ipset \
+	// add @wg_meter { ip saddr limit rate over 20/second } \
+	// add @blacklist_ipset { ip saddr timeout 10m } \
+	// counter name "wg_ratelimit" drop
+	// --
+	// iifname "eth0" udp dport { 51820 } @th,64,8 1 ip saddr != @trust_ipset add @wg_meter { ip saddr limit rate over 20/second } add @blacklist_ipset { ip saddr timeout 10m } counter name "wg_ratelimit" drop
+	if rl.WGRate > 0 {
+		ports := make([]uint16, len(nft.wgNetworks))
+		for i, n := range nft.wgNetworks {
+			ports[i] = n.port
+		}
+
+		meters := []*nftables.Set{nft.filterSetWGMeter, nft.filterSetWGMeter6}
+		for i := range meters {
+			portSet := nfutils.GetPortSet(nft.tFilter)
+			err := c.AddSet(portSet, nfutils.GetPortElems(ports))
+			if err != nil {
+				return err
+			}
+
+			exprs := make([]expr.Any, 0, 22)
+			exprs = append(exprs, nfutils.SetIIF(iface)...)
+			exprs = append(exprs, nfutils.SetProtoUDP()...)
+			exprs = append(exprs, nfutils.SetDPortSet(portSet)...)
+			exprs = append(exprs, nfutils.SetWGHandshakeInitiation()...)
+			exprs = append(exprs, nft.trustExemption(meters[i])...)
+			exprs = append(exprs, nfutils.SetSAddrLimit(
+				meters[i], uint64(rl.WGRate), expr.LimitTimeSecond)...)
+			exprs = append(exprs, nfutils.SetSAddrAddSet(
+				blacklists[i], rl.BanTimeout)...)
+			exprs = append(exprs, nfutils.ExprCounterRef(counterWGLimit))
+			exprs = append(exprs, nfutils.ExprDrop())
+			rule := &nftables.Rule{
+				Table: nft.tFilter,
+				Chain: nft.cInput,
+				Exprs: exprs}
+			c.AddRule(rule)
+		}
+	}
+
+	return nil
+}
+
+// trustExemption matches the sources out of the trust ipset, the ipset
+// is of ipv4 addresses, so nothing is matched for the ipv6 sets.
+func (nft *NFTables) trustExemption(set *nftables.Set) []expr.Any {
+	if set.KeyType == nftables.TypeIP6Addr {
+		return nil
+	}
+
+	return nfutils.SetSAddrNotSet(nft.filterSetTrustIP)
+}
+
 // inputTrustIPSetRules to apply.
-func (nft *NFTables) inputTrustIPSetRules(c *nftables.Conn, iface string) error {
-	// cmd: nft add rule ip filter input meta iifname "eth0" ip protocol icmp \
+func (nft *NFTables) inputTrustIPSetRules(c conn, iface string) error {
+	// cmd: nft add rule inet filter input meta iifname "eth0" ip protocol icmp \
 	// icmp type echo-request ip saddr @trust_ipset ct state new accept
 	// --
 	// iifname "eth0" icmp type echo-request ip saddr @trust_ipset ct state new accept
@@ -638,11 +1589,11 @@ func (nft *NFTables) inputTrustIPSetRules(c *nftables.Conn, iface string) error
 		Exprs: exprs}
 	c.AddRule(rule)
 
-	// cmd: nft add rule ip filter input meta iifname "eth0" \
+	// cmd: nft add rule inet filter input meta iifname "eth0" \
 	// ip protocol tcp tcp dport { 5522 } ip saddr @trust_ipset \
-	// ct state { new, established } accept
+	// ct state { new, established } counter name "trust_accept" accept
 	// --
-	// iifname "eth0" tcp dport { 5522 } ip saddr @trust_ipset ct state { established, new } accept
+	// iifname "eth0" tcp dport { 5522 } ip saddr @trust_ipset ct state { established, new } counter name "trust_accept" accept
 	ctStateSet := nfutils.GetConntrackStateSet(nft.tFilter)
 	elems := nfutils.GetConntrackStateSetElems(
 		[]string{"new", "established"})
@@ -657,12 +1608,13 @@ func (nft *NFTables) inputTrustIPSetRules(c *nftables.Conn, iface string) error
 		return err
 	}
 
-	exprs = make([]expr.Any, 0, 11)
+	exprs = make([]expr.Any, 0, 12)
 	exprs = append(exprs, nfutils.SetIIF(iface)...)
 	exprs = append(exprs, nfutils.SetProtoTCP()...)
 	exprs = append(exprs, nfutils.SetDPortSet(portSet)...)
 	exprs = append(exprs, nfutils.SetSAddrSet(nft.filterSetTrustIP)...)
 	exprs = append(exprs, nfutils.SetConntrackStateSet(ctStateSet)...)
+	exprs = append(exprs, nfutils.ExprCounterRef(counterTrustAccept))
 	exprs = append(exprs, nfutils.ExprAccept())
 	rule = &nftables.Rule{
 		Table: nft.tFilter,
@@ -674,8 +1626,8 @@ func (nft *NFTables) inputTrustIPSetRules(c *nftables.Conn, iface string) error
 }
 
 // outputTrustIPSetRules to apply.
-func (nft *NFTables) outputTrustIPSetRules(c *nftables.Conn, iface string) error {
-	// cmd: nft add rule ip filter output meta oifname "eth0" \
+func (nft *NFTables) outputTrustIPSetRules(c conn, iface string) error {
+	// cmd: nft add rule inet filter output meta oifname "eth0" \
 	// ip protocol tcp tcp sport { 5522 } ip daddr @trust_ipset \
 	// ct state established accept
 	// --
@@ -703,55 +1655,60 @@ func (nft *NFTables) outputTrustIPSetRules(c *nftables.Conn, iface string) error
 }
 
 // inputPublicRules to apply.
-func (nft *NFTables) inputPublicRules(c *nftables.Conn, iface string) error {
-	// cmd: nft add rule ip filter input meta iifname "eth0" \
-	// ip protocol udp udp dport 51820 accept
+func (nft *NFTables) inputPublicRules(c conn, iface string) error {
+	// cmd: nft add rule inet filter input meta iifname "eth0" \
+	// ip protocol udp udp dport 51820 counter name "wg_accept" accept
 	// --
-	// iifname "eth0" udp dport 51820 accept
-
-	exprs := make([]expr.Any, 0, 9)
-	exprs = append(exprs, nfutils.SetIIF(iface)...)
-	exprs = append(exprs, nfutils.SetProtoUDP()...)
-	exprs = append(exprs, nfutils.SetDPort(nft.wgPort)...)
-	exprs = append(exprs, nfutils.ExprAccept())
-	rule := &nftables.Rule{
-		Table: nft.tFilter,
-		Chain: nft.cInput,
-		Exprs: exprs}
-	c.AddRule(rule)
+	// iifname "eth0" udp dport 51820 counter name "wg_accept" accept
+
+	for _, n := range nft.wgNetworks {
+		exprs := make([]expr.Any, 0, 10)
+		exprs = append(exprs, nfutils.SetIIF(iface)...)
+		exprs = append(exprs, nfutils.SetProtoUDP()...)
+		exprs = append(exprs, nfutils.SetDPort(n.port)...)
+		exprs = append(exprs, nfutils.ExprCounterRef(counterWGAccept+n.suffix))
+		exprs = append(exprs, nfutils.ExprAccept())
+		rule := &nftables.Rule{
+			Table: nft.tFilter,
+			Chain: nft.cInput,
+			Exprs: exprs}
+		c.AddRule(rule)
+	}
 
 	return nil
 }
 
 // outputPublicRules to apply.
-func (nft *NFTables) outputPublicRules(c *nftables.Conn, iface string) error {
-	// cmd: nft add rule ip filter output meta oifname "eth0" \
+func (nft *NFTables) outputPublicRules(c conn, iface string) error {
+	// cmd: nft add rule inet filter output meta oifname "eth0" \
 	// ip protocol udp udp sport 51820 accept
 	// --
 	// oifname "eth0" udp sport 51820 accept
 
-	exprs := make([]expr.Any, 0, 10)
-	exprs = append(exprs, nfutils.SetOIF(iface)...)
-	exprs = append(exprs, nfutils.SetProtoUDP()...)
-	exprs = append(exprs, nfutils.SetSPort(nft.wgPort)...)
-	exprs = append(exprs, nfutils.ExprAccept())
-	rule := &nftables.Rule{
-		Table: nft.tFilter,
-		Chain: nft.cOutput,
-		Exprs: exprs}
-	c.AddRule(rule)
+	for _, n := range nft.wgNetworks {
+		exprs := make([]expr.Any, 0, 10)
+		exprs = append(exprs, nfutils.SetOIF(iface)...)
+		exprs = append(exprs, nfutils.SetProtoUDP()...)
+		exprs = append(exprs, nfutils.SetSPort(n.port)...)
+		exprs = append(exprs, nfutils.ExprAccept())
+		rule := &nftables.Rule{
+			Table: nft.tFilter,
+			Chain: nft.cOutput,
+			Exprs: exprs}
+		c.AddRule(rule)
+	}
 
 	return nil
 }
 
-// sdnRules to apply.
-func (nft *NFTables) sdnRules(c *nftables.Conn) error {
-	// cmd: nft add rule ip filter input meta iifname "wg0" ip protocol icmp \
+// sdnRules of the wireguard network to apply.
+func (nft *NFTables) sdnRules(c conn, n *wgNetwork) error {
+	// cmd: nft add rule inet filter input meta iifname "wg0" ip protocol icmp \
 	// icmp type echo-request ct state new accept
 	// --
 	// iifname "wg0" icmp type echo-request ct state new accept
 	exprs := make([]expr.Any, 0, 12)
-	exprs = append(exprs, nfutils.SetIIF(nft.wgIface)...)
+	exprs = append(exprs, nfutils.SetIIF(n.iface)...)
 	exprs = append(exprs, nfutils.SetProtoICMP()...)
 	exprs = append(exprs, nfutils.SetICMPTypeEchoRequest()...)
 	exprs = append(exprs, nfutils.SetConntrackStateNew()...)
@@ -762,7 +1719,7 @@ func (nft *NFTables) sdnRules(c *nftables.Conn) error {
 		Exprs: exprs}
 	c.AddRule(rule)
 
-	// cmd: nft add rule ip filter input meta iifname "wg0" ip protocol icmp \
+	// cmd: nft add rule inet filter input meta iifname "wg0" ip protocol icmp \
 	// ct state { established, related } accept
 	// --
 	// iifname "wg0" ip protocol icmp ct state { established, related } accept
@@ -775,7 +1732,7 @@ func (nft *NFTables) sdnRules(c *nftables.Conn) error {
 	}
 
 	exprs = make([]expr.Any, 0, 7)
-	exprs = append(exprs, nfutils.SetIIF(nft.wgIface)...)
+	exprs = append(exprs, nfutils.SetIIF(n.iface)...)
 	exprs = append(exprs, nfutils.SetProtoICMP()...)
 	exprs = append(exprs, nfutils.SetConntrackStateSet(ctStateSet)...)
 	exprs = append(exprs, nfutils.ExprAccept())
@@ -786,7 +1743,22 @@ func (nft *NFTables) sdnRules(c *nftables.Conn) error {
 		Exprs: exprs}
 	c.AddRule(rule)
 
-	// cmd: nft add rule ip filter input meta iifname "wg0" \
+	// cmd: nft add rule inet filter input meta iifname "wg0" \
+	// meta l4proto ipv6-icmp accept
+	// --
+	// iifname "wg0" meta l4proto ipv6-icmp accept
+	exprs = make([]expr.Any, 0, 5)
+	exprs = append(exprs, nfutils.SetIIF(n.iface)...)
+	exprs = append(exprs, nfutils.SetProtoICMPv6()...)
+	exprs = append(exprs, nfutils.ExprAccept())
+
+	rule = &nftables.Rule{
+		Table: nft.tFilter,
+		Chain: nft.cInput,
+		Exprs: exprs}
+	c.AddRule(rule)
+
+	// cmd: nft add rule inet filter input meta iifname "wg0" \
 	// ip protocol tcp tcp dport { 80, 8080 } ip saddr @wgmanager_ipset \
 	// ct state { new, established } accept
 	// --
@@ -810,18 +1782,20 @@ func (nft *NFTables) sdnRules(c *nftables.Conn) error {
 		return err
 	}
 
-	exprs = make([]expr.Any, 0, 9)
-	exprs = append(exprs, nfutils.SetIIF(nft.wgIface)...)
-	exprs = append(exprs, nfutils.SetProtoTCP()...)
-	exprs = append(exprs, nfutils.SetDPortSet(portSet)...)
-	exprs = append(exprs, nfutils.SetSAddrSet(nft.filterSetWGManagerIP)...)
-	exprs = append(exprs, nfutils.SetConntrackStateSet(ctStateSet)...)
-	exprs = append(exprs, nfutils.ExprAccept())
-	rule = &nftables.Rule{
-		Table: nft.tFilter,
-		Chain: nft.cInput,
-		Exprs: exprs}
-	c.AddRule(rule)
+	for _, set := range n.managerSets() {
+		exprs = make([]expr.Any, 0, 11)
+		exprs = append(exprs, nfutils.SetIIF(n.iface)...)
+		exprs = append(exprs, nfutils.SetProtoTCP()...)
+		exprs = append(exprs, nfutils.SetDPortSet(portSet)...)
+		exprs = append(exprs, nfutils.SetSAddrSet(set)...)
+		exprs = append(exprs, nfutils.SetConntrackStateSet(ctStateSet)...)
+		exprs = append(exprs, nfutils.ExprAccept())
+		rule = &nftables.Rule{
+			Table: nft.tFilter,
+			Chain: nft.cInput,
+			Exprs: exprs}
+		c.AddRule(rule)
+	}
 
 	ctStateSet = nfutils.GetConntrackStateSet(nft.tFilter)
 	elems = nfutils.GetConntrackStateSetElems(
@@ -831,7 +1805,7 @@ func (nft *NFTables) sdnRules(c *nftables.Conn) error {
 		return err
 	}
 	exprs = make([]expr.Any, 0, 10)
-	exprs = append(exprs, nfutils.SetIIF(nft.wgIface)...)
+	exprs = append(exprs, nfutils.SetIIF(n.iface)...)
 	exprs = append(exprs, nfutils.SetProtoUDP()...)
 	exprs = append(exprs, nfutils.SetDPort(53)...)
 	// exprs = append(exprs, nfutils.SetConntrackStateSet(ctStateSet)...)
@@ -850,7 +1824,7 @@ func (nft *NFTables) sdnRules(c *nftables.Conn) error {
 		return err
 	}
 	exprs = make([]expr.Any, 0, 10)
-	exprs = append(exprs, nfutils.SetIIF(nft.wgIface)...)
+	exprs = append(exprs, nfutils.SetIIF(n.iface)...)
 	exprs = append(exprs, nfutils.SetProtoTCP()...)
 	exprs = append(exprs, nfutils.SetDPort(53)...)
 	// exprs = append(exprs, nfutils.SetConntrackStateSet(ctStateSet)...)
@@ -861,7 +1835,7 @@ func (nft *NFTables) sdnRules(c *nftables.Conn) error {
 		Exprs: exprs}
 	c.AddRule(rule)
 
-	// cmd: nft add rule ip filter output meta oifname "wg0" ip protocol icmp \
+	// cmd: nft add rule inet filter output meta oifname "wg0" ip protocol icmp \
 	// ct state { new, established } accept
 	// --
 	// oifname "wg0" ip protocol icmp ct state { established, new } accept
@@ -874,7 +1848,7 @@ func (nft *NFTables) sdnRules(c *nftables.Conn) error {
 	}
 
 	exprs = make([]expr.Any, 0, 7)
-	exprs = append(exprs, nfutils.SetOIF(nft.wgIface)...)
+	exprs = append(exprs, nfutils.SetOIF(n.iface)...)
 	exprs = append(exprs, nfutils.SetProtoICMP()...)
 	exprs = append(exprs, nfutils.SetConntrackStateSet(ctStateSet)...)
 	exprs = append(exprs, nfutils.ExprAccept())
@@ -885,7 +1859,22 @@ func (nft *NFTables) sdnRules(c *nftables.Conn) error {
 		Exprs: exprs}
 	c.AddRule(rule)
 
-	// cmd: nft add rule ip filter output meta oifname "wg0" \
+	// cmd: nft add rule inet filter output meta oifname "wg0" \
+	// meta l4proto ipv6-icmp accept
+	// --
+	// oifname "wg0" meta l4proto ipv6-icmp accept
+	exprs = make([]expr.Any, 0, 5)
+	exprs = append(exprs, nfutils.SetOIF(n.iface)...)
+	exprs = append(exprs, nfutils.SetProtoICMPv6()...)
+	exprs = append(exprs, nfutils.ExprAccept())
+
+	rule = &nftables.Rule{
+		Table: nft.tFilter,
+		Chain: nft.cOutput,
+		Exprs: exprs}
+	c.AddRule(rule)
+
+	// cmd: nft add rule inet filter output meta oifname "wg0" \
 	// ip protocol tcp tcp sport { 80, 8080 } ip daddr @wgmanager_ipset \
 	// ct state established accept
 	// --
@@ -901,21 +1890,23 @@ func (nft *NFTables) sdnRules(c *nftables.Conn) error {
 		return err
 	}
 
-	exprs = make([]expr.Any, 0, 10)
-	exprs = append(exprs, nfutils.SetOIF(nft.wgIface)...)
-	exprs = append(exprs, nfutils.SetProtoTCP()...)
-	exprs = append(exprs, nfutils.SetSPortSet(portSet)...)
-	exprs = append(exprs, nfutils.SetDAddrSet(nft.filterSetWGManagerIP)...)
-	exprs = append(exprs, nfutils.SetConntrackStateEstablished()...)
-	exprs = append(exprs, nfutils.ExprAccept())
-	rule = &nftables.Rule{
-		Table: nft.tFilter,
-		Chain: nft.cOutput,
-		Exprs: exprs}
-	c.AddRule(rule)
+	for _, set := range n.managerSets() {
+		exprs = make([]expr.Any, 0, 12)
+		exprs = append(exprs, nfutils.SetOIF(n.iface)...)
+		exprs = append(exprs, nfutils.SetProtoTCP()...)
+		exprs = append(exprs, nfutils.SetSPortSet(portSet)...)
+		exprs = append(exprs, nfutils.SetDAddrSet(set)...)
+		exprs = append(exprs, nfutils.SetConntrackStateEstablished()...)
+		exprs = append(exprs, nfutils.ExprAccept())
+		rule = &nftables.Rule{
+			Table: nft.tFilter,
+			Chain: nft.cOutput,
+			Exprs: exprs}
+		c.AddRule(rule)
+	}
 
 	exprs = make([]expr.Any, 0, 10)
-	exprs = append(exprs, nfutils.SetOIF(nft.wgIface)...)
+	exprs = append(exprs, nfutils.SetOIF(n.iface)...)
 	exprs = append(exprs, nfutils.SetProtoUDP()...)
 	exprs = append(exprs, nfutils.SetSPort(53)...)
 	// exprs = append(exprs, nfutils.SetConntrackStateEstablished()...)
@@ -927,7 +1918,7 @@ func (nft *NFTables) sdnRules(c *nftables.Conn) error {
 	c.AddRule(rule)
 
 	exprs = make([]expr.Any, 0, 10)
-	exprs = append(exprs, nfutils.SetOIF(nft.wgIface)...)
+	exprs = append(exprs, nfutils.SetOIF(n.iface)...)
 	exprs = append(exprs, nfutils.SetProtoTCP()...)
 	exprs = append(exprs, nfutils.SetSPort(53)...)
 	// exprs = append(exprs, nfutils.SetConntrackStateEstablished()...)
@@ -941,41 +1932,76 @@ func (nft *NFTables) sdnRules(c *nftables.Conn) error {
 	return nil
 }
 
-// sdnForwardRules to apply.
-func (nft *NFTables) sdnForwardRules(c *nftables.Conn) error {
-	// cmd: nft add rule ip filter forward \
-	// ip protocol tcp tcp sport 25 drop
+// forwardBaseRules to apply.
+func (nft *NFTables) forwardBaseRules(c conn) {
+	// every forwarded packet of the devices passes bandwidth limits
+	// cmd: nft add rule inet filter forward jump wgbandwidth
 	// --
-	// tcp sport smtp drop;
-	exprs := make([]expr.Any, 0, 10)
-	exprs = append(exprs, nfutils.SetProtoTCP()...)
-	exprs = append(exprs, nfutils.SetSPort(25)...)
-	exprs = append(exprs, nfutils.ExprDrop())
+	// jump wgbandwidth;
+	for _, n := range nft.wgNetworks {
+		exprs := []expr.Any{nfutils.ExprJump(n.cBandwidth.Name)}
+		rule := &nftables.Rule{
+			Table: nft.tFilter,
+			Chain: nft.cForward,
+			Exprs: exprs}
+		c.AddRule(rule)
+	}
+
+	// cmd: nft add rule inet filter forward jump port_forward
+	// --
+	// jump port_forward;
+	exprs := []expr.Any{nfutils.ExprJump(nft.cPortForward.Name)}
 	rule := &nftables.Rule{
 		Table: nft.tFilter,
 		Chain: nft.cForward,
 		Exprs: exprs}
 	c.AddRule(rule)
+}
 
-	// cmd: nft add rule ip filter forward \
+// sdnForwardRules of the wireguard network to apply, the traffic is
+// forwarded within the network and to wan, but not between the networks.
+func (nft *NFTables) sdnForwardRules(c conn, n *wgNetwork) error {
+	// new connections to wan pass global and device egress chains
+	// cmd: nft add rule inet filter forward \
+	// meta iifname "wg0" \
+	// meta oifname "eth0" \
+	// ct state new jump egress
+	// --
+	// iifname "wg0" oifname "eth0" ct state new jump egress;
+	for _, chain := range []*nftables.Chain{nft.cEgress, n.cEgress} {
+		exprs := make([]expr.Any, 0, 10)
+		exprs = append(exprs, nfutils.SetIIF(n.iface)...)
+		exprs = append(exprs, nfutils.SetOIF(nft.wanIface)...)
+		exprs = append(exprs, nfutils.SetConntrackStateNew()...)
+		exprs = append(exprs, nfutils.ExprJump(chain.Name))
+		rule := &nftables.Rule{
+			Table: nft.tFilter,
+			Chain: nft.cForward,
+			Exprs: exprs}
+		c.AddRule(rule)
+	}
+
+	// cmd: nft add rule inet filter forward \
 	// meta iifname "wg0" \
 	// ip saddr @wgforward_ipset \
 	// meta oifname "eth0" \
 	// accept
 	// --
 	// iifname "wg0" oifname "eth0" accept;
-	exprs = make([]expr.Any, 0, 10)
-	exprs = append(exprs, nfutils.SetIIF(nft.wgIface)...)
-	exprs = append(exprs, nfutils.SetSAddrSet(nft.filterSetWGForwardIP)...)
-	exprs = append(exprs, nfutils.SetOIF(nft.wanIface)...)
-	exprs = append(exprs, nfutils.ExprAccept())
-	rule = &nftables.Rule{
-		Table: nft.tFilter,
-		Chain: nft.cForward,
-		Exprs: exprs}
-	c.AddRule(rule)
-
-	// cmd: nft add rule ip filter forward \
+	for _, set := range n.forwardSets() {
+		exprs := make([]expr.Any, 0, 10)
+		exprs = append(exprs, nfutils.SetIIF(n.iface)...)
+		exprs = append(exprs, nfutils.SetSAddrSet(set)...)
+		exprs = append(exprs, nfutils.SetOIF(nft.wanIface)...)
+		exprs = append(exprs, nfutils.ExprAccept())
+		rule := &nftables.Rule{
+			Table: nft.tFilter,
+			Chain: nft.cForward,
+			Exprs: exprs}
+		c.AddRule(rule)
+	}
+
+	// cmd: nft add rule inet filter forward \
 	// ct state { established, related } accept
 	// --
 	// ct state { established, related } accept;
@@ -987,27 +2013,70 @@ func (nft *NFTables) sdnForwardRules(c *nftables.Conn) error {
 		return err
 	}
 
-	exprs = make([]expr.Any, 0, 10)
-	exprs = append(exprs, nfutils.SetIIF(nft.wanIface)...)
-	exprs = append(exprs, nfutils.SetDAddrSet(nft.filterSetWGForwardIP)...)
-	exprs = append(exprs, nfutils.SetOIF(nft.wgIface)...)
+	for _, set := range n.forwardSets() {
+		exprs := make([]expr.Any, 0, 12)
+		exprs = append(exprs, nfutils.SetIIF(nft.wanIface)...)
+		exprs = append(exprs, nfutils.SetDAddrSet(set)...)
+		exprs = append(exprs, nfutils.SetOIF(n.iface)...)
+		exprs = append(exprs, nfutils.SetConntrackStateSet(ctStateSet)...)
+		exprs = append(exprs, nfutils.ExprAccept())
+		rule := &nftables.Rule{
+			Table: nft.tFilter,
+			Chain: nft.cForward,
+			Exprs: exprs}
+		c.AddRule(rule)
+	}
+
+	// cmd: nft add rule inet filter forward \
+	// meta iifname "wg0" \
+	// meta oifname "wg0" \
+	// ct state { established, related } accept
+	// --
+	// iifname "wg0" oifname "wg0" ct state { established, related } accept;
+	exprs := make([]expr.Any, 0, 10)
+	exprs = append(exprs, nfutils.SetIIF(n.iface)...)
+	exprs = append(exprs, nfutils.SetOIF(n.iface)...)
 	exprs = append(exprs, nfutils.SetConntrackStateSet(ctStateSet)...)
 	exprs = append(exprs, nfutils.ExprAccept())
-	rule = &nftables.Rule{
+	rule := &nftables.Rule{
 		Table: nft.tFilter,
 		Chain: nft.cForward,
 		Exprs: exprs}
 	c.AddRule(rule)
 
-	// cmd: nft add rule ip filter forward \
+	// new connections pass acl chains of both sides and group policies,
+	// isolation rules of the isolated network are the last
+	// cmd: nft add rule inet filter forward \
+	// meta iifname "wg0" \
+	// meta oifname "wg0" \
+	// jump wgacl_out
+	// --
+	// iifname "wg0" oifname "wg0" jump wgacl_out;
+	chains := []*nftables.Chain{n.cACLOut, n.cACLIn, n.cGroup}
+	if n.isolated {
+		chains = append(chains, n.cIsolation)
+	}
+	for _, chain := range chains {
+		exprs = make([]expr.Any, 0, 5)
+		exprs = append(exprs, nfutils.SetIIF(n.iface)...)
+		exprs = append(exprs, nfutils.SetOIF(n.iface)...)
+		exprs = append(exprs, nfutils.ExprJump(chain.Name))
+		rule = &nftables.Rule{
+			Table: nft.tFilter,
+			Chain: nft.cForward,
+			Exprs: exprs}
+		c.AddRule(rule)
+	}
+
+	// cmd: nft add rule inet filter forward \
 	// meta iifname "wg0" \
 	// meta oifname "wg0" \
 	// accept
 	// --
 	// iifname "wg0" oifname "wg0" accept;
-	exprs = make([]expr.Any, 0, 10)
-	exprs = append(exprs, nfutils.SetIIF(nft.wgIface)...)
-	exprs = append(exprs, nfutils.SetOIF(nft.wgIface)...)
+	exprs = make([]expr.Any, 0, 5)
+	exprs = append(exprs, nfutils.SetIIF(n.iface)...)
+	exprs = append(exprs, nfutils.SetOIF(n.iface)...)
 	exprs = append(exprs, nfutils.ExprAccept())
 	rule = &nftables.Rule{
 		Table: nft.tFilter,
@@ -1018,14 +2087,165 @@ func (nft *NFTables) sdnForwardRules(c *nftables.Conn) error {
 	return nil
 }
 
+// aclRules to apply, allowed connections return from acl chain,
+// the others of the restricted device are dropped.
+func (nft *NFTables) aclRules(c conn, n *wgNetwork) error {
+	for _, a := range n.acls {
+		for _, r := range a.Rules {
+			err := nft.aclRule(c, n, a, r)
+			if err != nil {
+				return err
+			}
+		}
+
+		// cmd: nft add rule inet filter wgacl_out ip saddr 172.16.0.2 drop
+		// --
+		// ip saddr 172.16.0.2 drop;
+		for _, ip := range []net.IP{a.IP, a.IP6} {
+			if ip == nil {
+				continue
+			}
+
+			if a.restricted(ACLDirectionOut) {
+				exprs := make([]expr.Any, 0, 5)
+				exprs = append(exprs, nfutils.SetSAddr(ip)...)
+				exprs = append(exprs, nfutils.ExprDrop())
+				c.AddRule(&nftables.Rule{
+					Table: nft.tFilter,
+					Chain: n.cACLOut,
+					Exprs: exprs})
+			}
+			if a.restricted(ACLDirectionIn) {
+				exprs := make([]expr.Any, 0, 5)
+				exprs = append(exprs, nfutils.SetDAddr(ip)...)
+				exprs = append(exprs, nfutils.ExprDrop())
+				c.AddRule(&nftables.Rule{
+					Table: nft.tFilter,
+					Chain: n.cACLIn,
+					Exprs: exprs})
+			}
+		}
+	}
+
+	return nil
+}
+
+// aclRule to apply for every address family of the device.
+func (nft *NFTables) aclRule(
+	c conn, n *wgNetwork, a ACL, r ACLRule,
+) error {
+	// cmd: nft add rule inet filter wgacl_out ip saddr 172.16.0.2 \
+	// ip daddr { 172.16.0.3 } meta l4proto tcp tcp dport { 22 } return
+	// --
+	// ip saddr 172.16.0.2 ip daddr { 172.16.0.3 } \
+	// meta l4proto tcp tcp dport { 22 } return;
+	for _, ip := range []net.IP{a.IP, a.IP6} {
+		if ip == nil {
+			continue
+		}
+		v4 := ip.To4() != nil
+
+		var addrs []net.IP
+		for _, addr := range r.Addrs {
+			if (addr.To4() != nil) == v4 {
+				addrs = append(addrs, addr)
+			}
+		}
+		if len(r.Addrs) > 0 && len(addrs) == 0 {
+			// no remote addresses of the family
+			continue
+		}
+
+		exprs := make([]expr.Any, 0, 16)
+		chain := n.cACLOut
+		if r.Direction == ACLDirectionIn {
+			chain = n.cACLIn
+			exprs = append(exprs, nfutils.SetDAddr(ip)...)
+		} else {
+			exprs = append(exprs, nfutils.SetSAddr(ip)...)
+		}
+
+		if len(addrs) > 0 {
+			set := nfutils.GetAddrSet(nft.tFilter)
+			if !v4 {
+				set = nfutils.GetAddr6Set(nft.tFilter)
+			}
+			err := c.AddSet(set, nfutils.GetAddrElems(addrs))
+			if err != nil {
+				return err
+			}
+			if r.Direction == ACLDirectionIn {
+				exprs = append(exprs, nfutils.SetSAddrSet(set)...)
+			} else {
+				exprs = append(exprs, nfutils.SetDAddrSet(set)...)
+			}
+		}
+
+		switch r.Proto {
+		case "tcp":
+			exprs = append(exprs, nfutils.SetProtoTCP()...)
+		case "udp":
+			exprs = append(exprs, nfutils.SetProtoUDP()...)
+		case "icmp":
+			if v4 {
+				exprs = append(exprs, nfutils.SetProtoICMP()...)
+			} else {
+				exprs = append(exprs, nfutils.SetProtoICMPv6()...)
+			}
+		}
+
+		if len(r.SPorts) > 0 {
+			set := nfutils.GetPortSet(nft.tFilter)
+			err := c.AddSet(set, nfutils.GetPortElems(r.SPorts))
+			if err != nil {
+				return err
+			}
+			exprs = append(exprs, nfutils.SetSPortSet(set)...)
+		}
+		if len(r.DPorts) > 0 {
+			set := nfutils.GetPortSet(nft.tFilter)
+			err := c.AddSet(set, nfutils.GetPortElems(r.DPorts))
+			if err != nil {
+				return err
+			}
+			exprs = append(exprs, nfutils.SetDPortSet(set)...)
+		}
+
+		exprs = append(exprs, nfutils.ExprReturn())
+		c.AddRule(&nftables.Rule{
+			Table: nft.tFilter,
+			Chain: chain,
+			Exprs: exprs})
+	}
+
+	return nil
+}
+
 // natRules to apply.
-func (nft *NFTables) natRules(c *nftables.Conn) {
-	// cmd: nft add rule ip nat postrouting meta oifname "eth0" \
+func (nft *NFTables) natRules(c conn) {
+	if nft.cfg.Masquerade {
+		// cmd: nft add rule inet nat postrouting meta oifname "eth0" \
+		// masquerade
+		// --
+		// oifname "eth0" masquerade
+		exprs := make([]expr.Any, 0, 3)
+		exprs = append(exprs, nfutils.SetOIF(nft.wanIface)...)
+		exprs = append(exprs, nfutils.ExprMasquerade())
+		rule := &nftables.Rule{
+			Table: nft.tNAT,
+			Chain: nft.cPostrouting,
+			Exprs: exprs}
+		c.AddRule(rule)
+		return
+	}
+
+	// cmd: nft add rule inet nat postrouting meta oifname "eth0" \
 	// snat 192.168.0.1
 	// --
 	// oifname "eth0" snat to 192.168.15.11
 	exprs := make([]expr.Any, 0, 10)
 	exprs = append(exprs, nfutils.SetOIF(nft.wanIface)...)
+	exprs = append(exprs, nfutils.SetNFProtoIPv4()...)
 	exprs = append(exprs, nfutils.ExprImmediate(nft.wanIP))
 	exprs = append(exprs, nfutils.ExprSNAT(1, 0))
 	rule := &nftables.Rule{
@@ -1033,36 +2253,137 @@ func (nft *NFTables) natRules(c *nftables.Conn) {
 		Chain: nft.cPostrouting,
 		Exprs: exprs}
 	c.AddRule(rule)
+
+	// the overlay ipv6 addresses are unique local ones
+	// cmd: nft add rule inet nat postrouting meta oifname "eth0" \
+	// meta nfproto ipv6 masquerade
+	// --
+	// oifname "eth0" meta nfproto ipv6 masquerade
+	exprs = make([]expr.Any, 0, 5)
+	exprs = append(exprs, nfutils.SetOIF(nft.wanIface)...)
+	exprs = append(exprs, nfutils.SetNFProtoIPv6()...)
+	exprs = append(exprs, nfutils.ExprMasquerade())
+	rule = &nftables.Rule{
+		Table: nft.tNAT,
+		Chain: nft.cPostrouting,
+		Exprs: exprs}
+	c.AddRule(rule)
 }
 
-// UpdateTrustIPs updates filterSetTrustIP.
-func (nft *NFTables) UpdateTrustIPs(del, add []net.IP) error {
-	if !nft.applied {
-		return nil
+// portForwardRules to apply, wan connections are translated to the device
+// in prerouting, masqueraded to the server address within the wireguard
+// network and accepted in port_forward chain along with the replies.
+func (nft *NFTables) portForwardRules(c conn) error {
+	ctStateSet := nfutils.GetConntrackStateSet(nft.tFilter)
+	elems := nfutils.GetConntrackStateSetElems(
+		[]string{"established", "related"})
+	err := c.AddSet(ctStateSet, elems)
+	if err != nil {
+		return err
 	}
 
-	return nft.updateIPSet(nft.filterSetTrustIP, del, add)
-}
+	for _, f := range nft.portForwards {
+		n, err := nft.wgNetwork(f.Network)
+		if err != nil {
+			return err
+		}
 
-// UpdateWGManagerIPs updates filterSetWGManagerIP.
-func (nft *NFTables) UpdateWGManagerIPs(del, add []net.IP) error {
-	if !nft.applied {
-		return nil
+		proto := nfutils.SetProtoTCP
+		if f.Proto == "udp" {
+			proto = nfutils.SetProtoUDP
+		}
+
+		// the connections to the host only, the forwarded ones
+		// to the same port are left as is
+		// cmd: nft add rule inet nat prerouting meta iifname "eth0" \
+		// fib daddr type local meta nfproto ipv4 \
+		// meta l4proto tcp tcp dport 8443 dnat ip to 172.16.0.2:443
+		// --
+		// iifname "eth0" fib daddr type local meta nfproto ipv4 \
+		// meta l4proto tcp tcp dport 8443 dnat ip to 172.16.0.2:443
+		exprs := make([]expr.Any, 0, 14)
+		exprs = append(exprs, nfutils.SetIIF(nft.wanIface)...)
+		exprs = append(exprs, nfutils.SetDAddrTypeLocal()...)
+		exprs = append(exprs, nfutils.SetNFProtoIPv4()...)
+		exprs = append(exprs, proto()...)
+		exprs = append(exprs, nfutils.SetDPort(f.Port)...)
+		exprs = append(exprs, nfutils.SetDNAT(f.IP, f.DevicePort)...)
+		c.AddRule(&nftables.Rule{
+			Table: nft.tNAT,
+			Chain: nft.cPrerouting,
+			Exprs: exprs})
+
+		// replies of the device are routed back through the server
+		// cmd: nft add rule inet nat postrouting meta oifname "wg0" \
+		// ip daddr 172.16.0.2 meta l4proto tcp tcp dport 443 masquerade
+		// --
+		// oifname "wg0" ip daddr 172.16.0.2 meta l4proto tcp \
+		// tcp dport 443 masquerade
+		exprs = make([]expr.Any, 0, 12)
+		exprs = append(exprs, nfutils.SetOIF(n.iface)...)
+		exprs = append(exprs, nfutils.SetDAddr(f.IP)...)
+		exprs = append(exprs, proto()...)
+		exprs = append(exprs, nfutils.SetDPort(f.DevicePort)...)
+		exprs = append(exprs, nfutils.ExprMasquerade())
+		c.AddRule(&nftables.Rule{
+			Table: nft.tNAT,
+			Chain: nft.cPostrouting,
+			Exprs: exprs})
+
+		// cmd: nft add rule inet filter port_forward \
+		// meta iifname "eth0" meta oifname "wg0" ip daddr 172.16.0.2 \
+		// meta l4proto tcp tcp dport 443 accept
+		// --
+		// iifname "eth0" oifname "wg0" ip daddr 172.16.0.2 \
+		// meta l4proto tcp tcp dport 443 accept;
+		exprs = make([]expr.Any, 0, 14)
+		exprs = append(exprs, nfutils.SetIIF(nft.wanIface)...)
+		exprs = append(exprs, nfutils.SetOIF(n.iface)...)
+		exprs = append(exprs, nfutils.SetDAddr(f.IP)...)
+		exprs = append(exprs, proto()...)
+		exprs = append(exprs, nfutils.SetDPort(f.DevicePort)...)
+		exprs = append(exprs, nfutils.ExprAccept())
+		c.AddRule(&nftables.Rule{
+			Table: nft.tFilter,
+			Chain: nft.cPortForward,
+			Exprs: exprs})
+
+		// cmd: nft add rule inet filter port_forward \
+		// meta iifname "wg0" meta oifname "eth0" ip saddr 172.16.0.2 \
+		// meta l4proto tcp tcp sport 443 \
+		// ct state { established, related } accept
+		// --
+		// iifname "wg0" oifname "eth0" ip saddr 172.16.0.2 \
+		// meta l4proto tcp tcp sport 443 \
+		// ct state { established, related } accept;
+		exprs = make([]expr.Any, 0, 16)
+		exprs = append(exprs, nfutils.SetIIF(n.iface)...)
+		exprs = append(exprs, nfutils.SetOIF(nft.wanIface)...)
+		exprs = append(exprs, nfutils.SetSAddr(f.IP)...)
+		exprs = append(exprs, proto()...)
+		exprs = append(exprs, nfutils.SetSPort(f.DevicePort)...)
+		exprs = append(exprs, nfutils.SetConntrackStateSet(ctStateSet)...)
+		exprs = append(exprs, nfutils.ExprAccept())
+		c.AddRule(&nftables.Rule{
+			Table: nft.tFilter,
+			Chain: nft.cPortForward,
+			Exprs: exprs})
 	}
 
-	return nft.updateIPSet(nft.filterSetWGManagerIP, del, add)
+	return nil
 }
 
-// UpdateWGForwardWanIPs updates filterSetWGForwardIP.
-func (nft *NFTables) UpdateWGForwardWanIPs(del, add []net.IP) error {
+// UpdatePortForwards replaces port forwards from wan to the devices.
+func (nft *NFTables) UpdatePortForwards(forwards []PortForward) error {
+	nft.mu.Lock()
+	defer nft.mu.Unlock()
+
+	nft.portForwards = forwards
+
 	if !nft.applied {
 		return nil
 	}
 
-	return nft.updateIPSet(nft.filterSetWGForwardIP, del, add)
-}
-
-func (nft *NFTables) updateIPSet(set *nftables.Set, del, add []net.IP) error {
 	// bind network namespace if it was set in config
 	c, err := nft.networkNamespaceBind()
 	if err != nil {
@@ -1071,36 +2392,29 @@ func (nft *NFTables) updateIPSet(set *nftables.Set, del, add []net.IP) error {
 	// release network namespace finally
 	defer nft.networkNamespaceRelease()
 
-	if len(del) > 0 {
-		elements := make([]nftables.SetElement, len(del))
-		for i, v := range del {
-			elements[i] = nftables.SetElement{Key: v}
-		}
-		err = c.SetDeleteElements(set, elements)
-		if err != nil {
-			return err
-		}
-	}
-
-	if len(add) > 0 {
-		elements := make([]nftables.SetElement, len(add))
-		for i, v := range add {
-			elements[i] = nftables.SetElement{Key: v}
-		}
-		err = c.SetAddElements(set, elements)
-		if err != nil {
-			return err
-		}
+	c.FlushChain(nft.cPrerouting)
+	c.FlushChain(nft.cPostrouting)
+	c.FlushChain(nft.cPortForward)
+	nft.natRules(c)
+	err = nft.portForwardRules(c)
+	if err != nil {
+		return err
 	}
 
-	return c.Flush()
+	return nft.flush(c)
 }
 
-// Cleanup rules to default policy filtering.
-func (nft *NFTables) Cleanup() error {
-	if !nft.cfg.Enabled {
+// UpdateTrustIPs replaces the ranges of filterSetTrustIP.
+func (nft *NFTables) UpdateTrustIPs(ips []TrustIP) error {
+	nft.mu.Lock()
+	defer nft.mu.Unlock()
+
+	nft.trustIPs = ips
+
+	if !nft.applied {
 		return nil
 	}
+
 	// bind network namespace if it was set in config
 	c, err := nft.networkNamespaceBind()
 	if err != nil {
@@ -1109,28 +2423,1161 @@ func (nft *NFTables) Cleanup() error {
 	// release network namespace finally
 	defer nft.networkNamespaceRelease()
 
-	filterSetTrustElements, _ := c.GetSetElements(nft.filterSetTrustIP) // omit error
+	// cmd: nft flush set inet filter trust_ipset
+	// cmd: nft add element inet filter trust_ipset \
+	// { 192.168.0.0/24 timeout 2h }
+	c.FlushSet(nft.filterSetTrustIP)
+	elems := nft.trustElements(time.Now())
+	if len(elems) > 0 {
+		err = c.SetAddElements(nft.filterSetTrustIP, elems)
+		if err != nil {
+			return err
+		}
+	}
 
-	c.FlushRuleset()
+	return nft.flush(c)
+}
 
-	// add filter table
-	// cmd: nft add table ip filter
-	c.AddTable(nft.tFilter)
-	// add input chain of filter table
-	// cmd: nft add chain ip filter input \
-	// { type filter hook input priority 0 \; policy drop\; }
-	c.AddChain(nft.cInput)
-	// add forward chain
-	// cmd: nft add chain ip filter forward \
-	// { type filter hook forward priority 0 \; policy drop\; }
-	c.AddChain(nft.cForward)
-	// add output chain
-	// cmd: nft add chain ip filter output \
-	// { type filter hook output priority 0 \; policy drop\; }
+// trustElements returns interval elements of the trusted ranges,
+// the range is closed by the element of the address following it.
+// The expiring ranges get timeout of the time left.
+func (nft *NFTables) trustElements(now time.Time) []nftables.SetElement {
+	elems := make([]nftables.SetElement, 0, 2*len(nft.trustIPs))
+	for _, v := range nft.trustIPs {
+		var timeout time.Duration
+		if v.ExpiresAt != nil {
+			timeout = v.ExpiresAt.Sub(now).Truncate(time.Second)
+			if timeout <= 0 {
+				continue
+			}
+		}
+
+		mask := v.IPNet.Mask
+		if len(mask) == net.IPv6len {
+			mask = mask[12:]
+		}
+		start := v.IPNet.IP.To4().Mask(mask)
+		if start == nil {
+			continue
+		}
+		elems = append(elems, nftables.SetElement{
+			Key:     start,
+			Timeout: timeout,
+		})
+
+		// the range up to the last address is left open
+		end := make(net.IP, len(start))
+		for i := range start {
+			end[i] = start[i] | ^mask[i]
+		}
+		for i := len(end) - 1; i >= 0; i-- {
+			end[i]++
+			if end[i] != 0 {
+				break
+			}
+		}
+		if end.Equal(net.IPv4zero) {
+			continue
+		}
+		elems = append(elems, nftables.SetElement{
+			Key:         end,
+			IntervalEnd: true,
+		})
+	}
+
+	return elems
+}
+
+// UpdateWGDevices replaces the devices counted on the default drop path.
+func (nft *NFTables) UpdateWGDevices(network string, devices []Device) error {
+	nft.mu.Lock()
+	defer nft.mu.Unlock()
+
+	n, err := nft.wgNetwork(network)
+	if err != nil {
+		return err
+	}
+
+	current := make(map[string]bool, len(n.devices))
+	for _, d := range n.devices {
+		current[deviceCounterName(d)] = true
+	}
+	n.devices = devices
+
+	if !nft.applied {
+		return nil
+	}
+
+	// bind network namespace if it was set in config
+	c, err := nft.networkNamespaceBind()
+	if err != nil {
+		return err
+	}
+	// release network namespace finally
+	defer nft.networkNamespaceRelease()
+
+	// the counters of the devices kept are left as is,
+	// the counters are removed after the rules referring them
+	// cmd: nft flush chain inet filter wgdrop
+	// cmd: nft delete counter inet filter device_172.16.0.2
+	c.FlushChain(n.cDrop)
+	for _, d := range devices {
+		name := deviceCounterName(d)
+		if current[name] {
+			delete(current, name)
+			continue
+		}
+		c.AddObj(nft.counter(name))
+	}
+	for name := range current {
+		c.DeleteObject(nft.counter(name))
+	}
+	nft.deviceRules(c, n)
+
+	return nft.flush(c)
+}
+
+// Stats returns the named counters of the ruleset.
+func (nft *NFTables) Stats() ([]Counter, error) {
+	nft.mu.Lock()
+	defer nft.mu.Unlock()
+
+	if !nft.applied {
+		return nil, nil
+	}
+
+	// bind network namespace if it was set in config
+	c, err := nft.networkNamespaceBind()
+	if err != nil {
+		return nil, err
+	}
+	// release network namespace finally
+	defer nft.networkNamespaceRelease()
+
+	// cmd: nft list counters table inet filter
+	objs, err := c.GetObjects(nft.tFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	counters := make([]Counter, 0, len(objs))
+	for _, obj := range objs {
+		v, ok := obj.(*nftables.CounterObj)
+		if !ok {
+			continue
+		}
+		counters = append(counters, Counter{
+			Name:    v.Name,
+			Packets: v.Packets,
+			Bytes:   v.Bytes,
+		})
+	}
+	sort.Slice(counters, func(i, j int) bool {
+		return counters[i].Name < counters[j].Name
+	})
+
+	return counters, nil
+}
+
+// Blacklist returns the sources banned by the rate limits.
+func (nft *NFTables) Blacklist() ([]net.IP, error) {
+	nft.mu.Lock()
+	defer nft.mu.Unlock()
+
+	if !nft.applied {
+		return nil, nil
+	}
+
+	// bind network namespace if it was set in config
+	c, err := nft.networkNamespaceBind()
+	if err != nil {
+		return nil, err
+	}
+	// release network namespace finally
+	defer nft.networkNamespaceRelease()
+
+	// cmd: nft list set inet filter blacklist_ipset
+	var ips []net.IP
+	for _, set := range []*nftables.Set{
+		nft.filterSetBlacklist, nft.filterSetBlacklist6,
+	} {
+		elems, err := c.GetSetElements(set)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range elems {
+			ips = append(ips, net.IP(e.Key))
+		}
+	}
+
+	return ips, nil
+}
+
+// Unban removes the source from the blacklist.
+func (nft *NFTables) Unban(ip net.IP) error {
+	nft.mu.Lock()
+	defer nft.mu.Unlock()
+
+	if !nft.applied {
+		return nil
+	}
+
+	set, key := nft.filterSetBlacklist6, ip.To16()
+	if ip4 := ip.To4(); ip4 != nil {
+		set, key = nft.filterSetBlacklist, ip4
+	}
+
+	// bind network namespace if it was set in config
+	c, err := nft.networkNamespaceBind()
+	if err != nil {
+		return err
+	}
+	// release network namespace finally
+	defer nft.networkNamespaceRelease()
+
+	// cmd: nft delete element inet filter blacklist_ipset { 192.0.2.10 }
+	err = c.SetDeleteElements(set, []nftables.SetElement{{Key: key}})
+	if err != nil {
+		return err
+	}
+
+	return c.Flush()
+}
+
+// UpdateWGManagerIPs updates filterSetWGManagerIP of the network.
+func (nft *NFTables) UpdateWGManagerIPs(network string, del, add []net.IP) error {
+	nft.mu.Lock()
+	defer nft.mu.Unlock()
+
+	n, err := nft.wgNetwork(network)
+	if err != nil {
+		return err
+	}
+
+	del4, del6 := splitIPs(del)
+	add4, add6 := splitIPs(add)
+	nft.trackIPSet(n.filterSetWGManagerIP, del4, add4)
+	nft.trackIPSet(n.filterSetWGManagerIP6, del6, add6)
+
+	if !nft.applied {
+		return nil
+	}
+
+	return nft.updateIPSets(
+		n.filterSetWGManagerIP, n.filterSetWGManagerIP6, del, add)
+}
+
+// UpdateWGForwardWanIPs updates filterSetWGForwardIP of the network.
+func (nft *NFTables) UpdateWGForwardWanIPs(network string, del, add []net.IP) error {
+	nft.mu.Lock()
+	defer nft.mu.Unlock()
+
+	n, err := nft.wgNetwork(network)
+	if err != nil {
+		return err
+	}
+
+	del4, del6 := splitIPs(del)
+	add4, add6 := splitIPs(add)
+	nft.trackIPSet(n.filterSetWGForwardIP, del4, add4)
+	nft.trackIPSet(n.filterSetWGForwardIP6, del6, add6)
+
+	if !nft.applied {
+		return nil
+	}
+
+	return nft.updateIPSets(
+		n.filterSetWGForwardIP, n.filterSetWGForwardIP6, del, add)
+}
+
+// UpdateWGACLs replaces acl rules of the network.
+func (nft *NFTables) UpdateWGACLs(network string, acls []ACL) error {
+	nft.mu.Lock()
+	defer nft.mu.Unlock()
+
+	n, err := nft.wgNetwork(network)
+	if err != nil {
+		return err
+	}
+	n.acls = acls
+
+	if !nft.applied {
+		return nil
+	}
+
+	// bind network namespace if it was set in config
+	c, err := nft.networkNamespaceBind()
+	if err != nil {
+		return err
+	}
+	// release network namespace finally
+	defer nft.networkNamespaceRelease()
+
+	c.FlushChain(n.cACLOut)
+	c.FlushChain(n.cACLIn)
+	err = nft.aclRules(c, n)
+	if err != nil {
+		return err
+	}
+
+	return nft.flush(c)
+}
+
+// egressRules to apply, blocked connections are dropped in egress chain,
+// allowed ones return, the rest are dropped if any rule allows.
+func (nft *NFTables) egressRules(c conn) error {
+	// cmd: nft add rule inet filter egress \
+	// meta l4proto tcp tcp dport { 25 } drop
+	// --
+	// meta l4proto tcp tcp dport { smtp } drop;
+	for _, allow := range []bool{false, true} {
+		for _, r := range nft.egress {
+			if r.Allow != allow {
+				continue
+			}
+			err := nft.egressRule(c, nft.cEgress, nil, r)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// cmd: nft add rule inet filter egress drop
+	// --
+	// drop;
+	if allowOnly(nft.egress) {
+		c.AddRule(&nftables.Rule{
+			Table: nft.tFilter,
+			Chain: nft.cEgress,
+			Exprs: []expr.Any{nfutils.ExprDrop()}})
+	}
+
+	return nil
+}
+
+// wgEgressRules of the network devices to apply, the rules are applied
+// in the same order as global ones, restricted per source address.
+func (nft *NFTables) wgEgressRules(c conn, n *wgNetwork) error {
+	for _, e := range n.egress {
+		for _, ip := range []net.IP{e.IP, e.IP6} {
+			if ip == nil {
+				continue
+			}
+
+			for _, allow := range []bool{false, true} {
+				for _, r := range e.Rules {
+					if r.Allow != allow {
+						continue
+					}
+					err := nft.egressRule(c, n.cEgress, ip, r)
+					if err != nil {
+						return err
+					}
+				}
+			}
+
+			// cmd: nft add rule inet filter wgegress ip saddr 172.16.0.2 drop
+			// --
+			// ip saddr 172.16.0.2 drop;
+			if allowOnly(e.Rules) {
+				exprs := make([]expr.Any, 0, 5)
+				exprs = append(exprs, nfutils.SetSAddr(ip)...)
+				exprs = append(exprs, nfutils.ExprDrop())
+				c.AddRule(&nftables.Rule{
+					Table: nft.tFilter,
+					Chain: n.cEgress,
+					Exprs: exprs})
+			}
+		}
+	}
+
+	return nil
+}
+
+// egressRule to apply for every destination net of the rule,
+// the source address is optional, only nets of its family are matched.
+func (nft *NFTables) egressRule(
+	c conn, chain *nftables.Chain, saddr net.IP, r EgressRule,
+) error {
+	// cmd: nft add rule inet filter wgegress ip saddr 172.16.0.2 \
+	// ip daddr 10.0.0.0/8 meta l4proto tcp tcp dport { 443 } return
+	// --
+	// ip saddr 172.16.0.2 ip daddr 10.0.0.0/8 \
+	// meta l4proto tcp tcp dport { https } return;
+	dnets := []*net.IPNet{nil} // matches any destination
+	if len(r.DNets) > 0 {
+		dnets = dnets[:0]
+		for i := range r.DNets {
+			v4 := r.DNets[i].IP.To4() != nil
+			if saddr != nil && (saddr.To4() != nil) != v4 {
+				continue
+			}
+			dnets = append(dnets, &r.DNets[i])
+		}
+	}
+
+	for _, dnet := range dnets {
+		exprs := make([]expr.Any, 0, 20)
+		if saddr != nil {
+			exprs = append(exprs, nfutils.SetSAddr(saddr)...)
+		}
+		if dnet != nil {
+			exprs = append(exprs, nfutils.SetDAddrNet(*dnet)...)
+		}
+
+		switch r.Proto {
+		case "tcp":
+			exprs = append(exprs, nfutils.SetProtoTCP()...)
+		case "udp":
+			exprs = append(exprs, nfutils.SetProtoUDP()...)
+		}
+
+		if len(r.DPorts) > 0 {
+			set := nfutils.GetPortSet(nft.tFilter)
+			err := c.AddSet(set, nfutils.GetPortElems(r.DPorts))
+			if err != nil {
+				return err
+			}
+			exprs = append(exprs, nfutils.SetDPortSet(set)...)
+		}
+
+		if r.Allow {
+			exprs = append(exprs, nfutils.ExprReturn())
+		} else {
+			exprs = append(exprs, nfutils.ExprDrop())
+		}
+		c.AddRule(&nftables.Rule{
+			Table: nft.tFilter,
+			Chain: chain,
+			Exprs: exprs})
+	}
+
+	return nil
+}
+
+// UpdateEgress replaces global egress rules.
+func (nft *NFTables) UpdateEgress(rules []EgressRule) error {
+	nft.mu.Lock()
+	defer nft.mu.Unlock()
+
+	nft.egress = rules
+
+	if !nft.applied {
+		return nil
+	}
+
+	// bind network namespace if it was set in config
+	c, err := nft.networkNamespaceBind()
+	if err != nil {
+		return err
+	}
+	// release network namespace finally
+	defer nft.networkNamespaceRelease()
+
+	c.FlushChain(nft.cEgress)
+	err = nft.egressRules(c)
+	if err != nil {
+		return err
+	}
+
+	return nft.flush(c)
+}
+
+// UpdateWGEgress replaces device egress rules of the network.
+func (nft *NFTables) UpdateWGEgress(network string, egress []Egress) error {
+	nft.mu.Lock()
+	defer nft.mu.Unlock()
+
+	n, err := nft.wgNetwork(network)
+	if err != nil {
+		return err
+	}
+	n.egress = egress
+
+	if !nft.applied {
+		return nil
+	}
+
+	// bind network namespace if it was set in config
+	c, err := nft.networkNamespaceBind()
+	if err != nil {
+		return err
+	}
+	// release network namespace finally
+	defer nft.networkNamespaceRelease()
+
+	c.FlushChain(n.cEgress)
+	err = nft.wgEgressRules(c, n)
+	if err != nil {
+		return err
+	}
+
+	return nft.flush(c)
+}
+
+// groupRules to apply, connections allowed by the policies return
+// from group chain, the others of the grouped devices are dropped.
+func (nft *NFTables) groupRules(c conn, n *wgNetwork) error {
+	// cmd: nft add set inet filter wggroup_staging { type ipv4_addr\; }
+	// cmd: nft flush set inet filter wggroup_staging
+	// cmd: nft add element inet filter wggroup_staging { 172.16.0.2 }
+	sets := make(map[string][]*nftables.Set, len(n.groups))
+	for _, g := range n.groups {
+		sets[g.Name] = n.groupSets(nft.tFilter, g.Name)
+		for _, set := range sets[g.Name] {
+			err := c.AddSet(set, nil)
+			if err != nil {
+				return err
+			}
+			c.FlushSet(set)
+
+			var ips []net.IP
+			for _, ip := range g.IPs {
+				v4 := ip.To4() != nil
+				if v4 == (set.KeyType == nftables.TypeIPAddr) {
+					ips = append(ips, ip)
+				}
+			}
+			if len(ips) == 0 {
+				continue
+			}
+			err = c.SetAddElements(set, nfutils.GetAddrElems(ips))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// cmd: nft add rule inet filter wggroup \
+	// ip saddr @wggroup_developers ip daddr @wggroup_staging \
+	// meta l4proto tcp tcp dport { 22, 443 } return
+	// --
+	// ip saddr @wggroup_developers ip daddr @wggroup_staging \
+	// meta l4proto tcp tcp dport { 22, 443 } return;
+	for _, p := range n.policies {
+		from, ok := sets[p.From]
+		if !ok {
+			continue
+		}
+		to, ok := sets[p.To]
+		if !ok {
+			continue
+		}
+
+		for i := range from {
+			exprs := make([]expr.Any, 0, 16)
+			exprs = append(exprs, nfutils.SetSAddrSet(from[i])...)
+			exprs = append(exprs, nfutils.SetDAddrSet(to[i])...)
+
+			switch p.Proto {
+			case "tcp":
+				exprs = append(exprs, nfutils.SetProtoTCP()...)
+			case "udp":
+				exprs = append(exprs, nfutils.SetProtoUDP()...)
+			case "icmp":
+				if from[i].KeyType == nftables.TypeIPAddr {
+					exprs = append(exprs, nfutils.SetProtoICMP()...)
+				} else {
+					exprs = append(exprs, nfutils.SetProtoICMPv6()...)
+				}
+			}
+
+			if len(p.DPorts) > 0 {
+				set := nfutils.GetPortSet(nft.tFilter)
+				err := c.AddSet(set, nfutils.GetPortElems(p.DPorts))
+				if err != nil {
+					return err
+				}
+				exprs = append(exprs, nfutils.SetDPortSet(set)...)
+			}
+
+			exprs = append(exprs, nfutils.ExprReturn())
+			c.AddRule(&nftables.Rule{
+				Table: nft.tFilter,
+				Chain: n.cGroup,
+				Exprs: exprs})
+		}
+	}
+
+	// cmd: nft add rule inet filter wggroup ip saddr @wggroup_staging drop
+	// cmd: nft add rule inet filter wggroup ip daddr @wggroup_staging drop
+	for _, g := range n.groups {
+		for _, set := range sets[g.Name] {
+			exprs := make([]expr.Any, 0, 5)
+			exprs = append(exprs, nfutils.SetSAddrSet(set)...)
+			exprs = append(exprs, nfutils.ExprDrop())
+			c.AddRule(&nftables.Rule{
+				Table: nft.tFilter,
+				Chain: n.cGroup,
+				Exprs: exprs})
+
+			exprs = make([]expr.Any, 0, 5)
+			exprs = append(exprs, nfutils.SetDAddrSet(set)...)
+			exprs = append(exprs, nfutils.ExprDrop())
+			c.AddRule(&nftables.Rule{
+				Table: nft.tFilter,
+				Chain: n.cGroup,
+				Exprs: exprs})
+		}
+	}
+
+	return nil
+}
+
+// isolationRules to apply, new connections to the servers and between
+// the paired devices return from isolation chain, the others are dropped.
+func (nft *NFTables) isolationRules(c conn, n *wgNetwork) error {
+	if !n.isolated {
+		return nil
+	}
+
+	// cmd: nft add rule inet filter wgisolation \
+	// ip daddr { 172.16.0.2 } return
+	// --
+	// ip daddr { 172.16.0.2 } return;
+	servers, servers6 := splitIPs(n.isolation.Servers)
+	for _, ips := range [][]net.IP{servers, servers6} {
+		if len(ips) == 0 {
+			continue
+		}
+
+		set := nfutils.GetAddrSet(nft.tFilter)
+		if ips[0].To4() == nil {
+			set = nfutils.GetAddr6Set(nft.tFilter)
+		}
+		err := c.AddSet(set, nfutils.GetAddrElems(ips))
+		if err != nil {
+			return err
+		}
+
+		exprs := make([]expr.Any, 0, 5)
+		exprs = append(exprs, nfutils.SetDAddrSet(set)...)
+		exprs = append(exprs, nfutils.ExprReturn())
+		c.AddRule(&nftables.Rule{
+			Table: nft.tFilter,
+			Chain: n.cIsolation,
+			Exprs: exprs})
+	}
+
+	// cmd: nft add rule inet filter wgisolation \
+	// ip daddr 10.10.0.0/24 return
+	// --
+	// ip daddr 10.10.0.0/24 return;
+	for _, route := range n.isolation.Routes {
+		exprs := make([]expr.Any, 0, 6)
+		exprs = append(exprs, nfutils.SetDAddrNet(route)...)
+		exprs = append(exprs, nfutils.ExprReturn())
+		c.AddRule(&nftables.Rule{
+			Table: nft.tFilter,
+			Chain: n.cIsolation,
+			Exprs: exprs})
+	}
+
+	// cmd: nft add rule inet filter wgisolation \
+	// ip saddr 172.16.0.3 ip daddr 172.16.0.4 return
+	// cmd: nft add rule inet filter wgisolation \
+	// ip saddr 172.16.0.4 ip daddr 172.16.0.3 return
+	// cmd: nft add rule inet filter wgisolation \
+	// ip saddr 172.16.0.3 ip daddr 10.10.0.0/24 return
+	for _, p := range n.isolation.Pairs {
+		a := deviceNets(p.A, p.ARoutes)
+		b := deviceNets(p.B, p.BRoutes)
+		for _, nets := range [][2][]net.IPNet{{a, b}, {b, a}} {
+			for _, src := range nets[0] {
+				for _, dst := range nets[1] {
+					exprs := make([]expr.Any, 0, 12)
+					exprs = append(exprs, saddrNet(src)...)
+					exprs = append(exprs, daddrNet(dst)...)
+					exprs = append(exprs, nfutils.ExprReturn())
+					c.AddRule(&nftables.Rule{
+						Table: nft.tFilter,
+						Chain: n.cIsolation,
+						Exprs: exprs})
+				}
+			}
+		}
+	}
+
+	// cmd: nft add rule inet filter wgisolation drop
+	// --
+	// drop;
+	c.AddRule(&nftables.Rule{
+		Table: nft.tFilter,
+		Chain: n.cIsolation,
+		Exprs: []expr.Any{nfutils.ExprDrop()}})
+
+	return nil
+}
+
+// deviceNets returns the host net of the device address followed
+// by the routes of the device of the same family.
+func deviceNets(ip net.IP, routes []net.IPNet) []net.IPNet {
+	bits := 8 * net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 8*net.IPv4len
+	}
+
+	nets := make([]net.IPNet, 0, len(routes)+1)
+	nets = append(nets, net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	for _, route := range routes {
+		if (route.IP.To4() != nil) == (bits == 8*net.IPv4len) {
+			nets = append(nets, route)
+		}
+	}
+
+	return nets
+}
+
+// saddrNet matches the source address or net, the host nets are matched
+// by the address.
+func saddrNet(ipnet net.IPNet) []expr.Any {
+	if ones, bits := ipnet.Mask.Size(); ones == bits {
+		return nfutils.SetSAddr(ipnet.IP)
+	}
+	return nfutils.SetSAddrNet(ipnet)
+}
+
+// daddrNet matches the destination address or net, the host nets are
+// matched by the address.
+func daddrNet(ipnet net.IPNet) []expr.Any {
+	if ones, bits := ipnet.Mask.Size(); ones == bits {
+		return nfutils.SetDAddr(ipnet.IP)
+	}
+	return nfutils.SetDAddrNet(ipnet)
+}
+
+// UpdateWGIsolation replaces servers and device pairs of the network.
+func (nft *NFTables) UpdateWGIsolation(
+	network string,
+	isolation Isolation,
+) error {
+	nft.mu.Lock()
+	defer nft.mu.Unlock()
+
+	n, err := nft.wgNetwork(network)
+	if err != nil {
+		return err
+	}
+	n.isolation = isolation
+
+	if !nft.applied || !n.isolated {
+		return nil
+	}
+
+	// bind network namespace if it was set in config
+	c, err := nft.networkNamespaceBind()
+	if err != nil {
+		return err
+	}
+	// release network namespace finally
+	defer nft.networkNamespaceRelease()
+
+	c.FlushChain(n.cIsolation)
+	err = nft.isolationRules(c, n)
+	if err != nil {
+		return err
+	}
+
+	return nft.flush(c)
+}
+
+// bandwidthRules to apply, the packets of the device over the rate
+// are dropped, upload is limited by the source address on the way from
+// wireguard interface and download by the destination on the way to it.
+// The addresses and routes of the device jump to the chains of its own
+// with the single limit, so they share the rate of the device.
+func (nft *NFTables) bandwidthRules(c conn, n *wgNetwork) {
+	// cmd: nft add chain inet filter wgupload_172.16.0.2
+	// cmd: nft add rule inet filter wgupload_172.16.0.2 \
+	// limit rate over 125000 bytes/second drop
+	// cmd: nft add rule inet filter wgbandwidth meta iifname "wg0" \
+	// ip saddr 172.16.0.2 jump wgupload_172.16.0.2
+	// cmd: nft add rule inet filter wgbandwidth meta iifname "wg0" \
+	// ip saddr 10.10.0.0/24 jump wgupload_172.16.0.2
+	// --
+	// cmd: nft add chain inet filter wgdownload_172.16.0.2
+	// cmd: nft add rule inet filter wgdownload_172.16.0.2 \
+	// limit rate over 125000 bytes/second drop
+	// cmd: nft add rule inet filter wgbandwidth meta oifname "wg0" \
+	// ip daddr 172.16.0.2 jump wgdownload_172.16.0.2
+	for _, b := range n.bandwidth {
+		var nets []net.IPNet
+		for _, ip := range []net.IP{b.IP, b.IP6} {
+			if ip != nil {
+				nets = append(nets, deviceNets(ip, b.Routes)...)
+			}
+		}
+
+		chains := n.bandwidthChains(nft.tFilter, b.IP)
+		up, down := chains[0], chains[1]
+
+		if b.Upload > 0 {
+			c.AddChain(up)
+			c.AddRule(&nftables.Rule{
+				Table: nft.tFilter,
+				Chain: up,
+				Exprs: []expr.Any{
+					nfutils.ExprLimitBytesOver(
+						kbitToBytes(b.Upload), expr.LimitTimeSecond),
+					nfutils.ExprDrop(),
+				}})
+
+			for _, ipnet := range nets {
+				exprs := make([]expr.Any, 0, 10)
+				exprs = append(exprs, nfutils.SetIIF(n.iface)...)
+				exprs = append(exprs, saddrNet(ipnet)...)
+				exprs = append(exprs, nfutils.ExprJump(up.Name))
+				c.AddRule(&nftables.Rule{
+					Table: nft.tFilter,
+					Chain: n.cBandwidth,
+					Exprs: exprs})
+			}
+		}
+
+		if b.Download > 0 {
+			c.AddChain(down)
+			c.AddRule(&nftables.Rule{
+				Table: nft.tFilter,
+				Chain: down,
+				Exprs: []expr.Any{
+					nfutils.ExprLimitBytesOver(
+						kbitToBytes(b.Download), expr.LimitTimeSecond),
+					nfutils.ExprDrop(),
+				}})
+
+			for _, ipnet := range nets {
+				exprs := make([]expr.Any, 0, 10)
+				exprs = append(exprs, nfutils.SetOIF(n.iface)...)
+				exprs = append(exprs, daddrNet(ipnet)...)
+				exprs = append(exprs, nfutils.ExprJump(down.Name))
+				c.AddRule(&nftables.Rule{
+					Table: nft.tFilter,
+					Chain: n.cBandwidth,
+					Exprs: exprs})
+			}
+		}
+	}
+}
+
+// kbitToBytes converts the rate in kbit/s to bytes/s.
+func kbitToBytes(rate uint32) uint64 {
+	return uint64(rate) * 1000 / 8
+}
+
+// UpdateWGBandwidth replaces device bandwidth limits of the network.
+func (nft *NFTables) UpdateWGBandwidth(
+	network string,
+	bandwidth []Bandwidth,
+) error {
+	nft.mu.Lock()
+	defer nft.mu.Unlock()
+
+	n, err := nft.wgNetwork(network)
+	if err != nil {
+		return err
+	}
+	prev := n.bandwidth
+	n.bandwidth = bandwidth
+
+	if !nft.applied {
+		return nil
+	}
+
+	// bind network namespace if it was set in config
+	c, err := nft.networkNamespaceBind()
+	if err != nil {
+		return err
+	}
+	// release network namespace finally
+	defer nft.networkNamespaceRelease()
+
+	// chains of the removed limits are released after the jumps to them
+	c.FlushChain(n.cBandwidth)
+	current := make(map[string]struct{}, 2*len(bandwidth))
+	for _, chain := range n.limitedChains(nft.tFilter, bandwidth) {
+		current[chain.Name] = struct{}{}
+	}
+	for _, chain := range n.limitedChains(nft.tFilter, prev) {
+		c.FlushChain(chain)
+		if _, ok := current[chain.Name]; !ok {
+			c.DelChain(chain)
+		}
+	}
+	nft.bandwidthRules(c, n)
+
+	return nft.flush(c)
+}
+
+// UpdateWGGroups replaces groups and group policies of the network.
+func (nft *NFTables) UpdateWGGroups(
+	network string,
+	groups []Group,
+	policies []GroupPolicy,
+) error {
+	nft.mu.Lock()
+	defer nft.mu.Unlock()
+
+	n, err := nft.wgNetwork(network)
+	if err != nil {
+		return err
+	}
+	prev := n.groups
+	n.groups = groups
+	n.policies = policies
+
+	if !nft.applied {
+		return nil
+	}
+
+	// bind network namespace if it was set in config
+	c, err := nft.networkNamespaceBind()
+	if err != nil {
+		return err
+	}
+	// release network namespace finally
+	defer nft.networkNamespaceRelease()
+
+	// sets of the removed groups are released after the rules
+	c.FlushChain(n.cGroup)
+	current := make(map[string]struct{}, len(groups))
+	for _, g := range groups {
+		current[g.Name] = struct{}{}
+	}
+	for _, g := range prev {
+		if _, ok := current[g.Name]; ok {
+			continue
+		}
+		for _, set := range n.groupSets(nft.tFilter, g.Name) {
+			c.DelSet(set)
+		}
+	}
+
+	err = nft.groupRules(c, n)
+	if err != nil {
+		return err
+	}
+
+	return nft.flush(c)
+}
+
+func (nft *NFTables) wgNetwork(name string) (*wgNetwork, error) {
+	for _, n := range nft.wgNetworks {
+		if n.name == name {
+			return n, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown network %q", name)
+}
+
+// updateIPSets updates ipv4 and ipv6 sets by the address family.
+func (nft *NFTables) updateIPSets(set, set6 *nftables.Set, del, add []net.IP) error {
+	del4, del6 := splitIPs(del)
+	add4, add6 := splitIPs(add)
+
+	err := nft.updateIPSet(set, del4, add4)
+	if err != nil {
+		return err
+	}
+
+	return nft.updateIPSet(set6, del6, add6)
+}
+
+func (nft *NFTables) updateIPSet(set *nftables.Set, del, add []net.IP) error {
+	if len(del) == 0 && len(add) == 0 {
+		return nil
+	}
+
+	// bind network namespace if it was set in config
+	c, err := nft.networkNamespaceBind()
+	if err != nil {
+		return err
+	}
+	// release network namespace finally
+	defer nft.networkNamespaceRelease()
+
+	if len(del) > 0 {
+		elements := make([]nftables.SetElement, len(del))
+		for i, v := range del {
+			elements[i] = nftables.SetElement{Key: v}
+		}
+		err = c.SetDeleteElements(set, elements)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(add) > 0 {
+		elements := make([]nftables.SetElement, len(add))
+		for i, v := range add {
+			elements[i] = nftables.SetElement{Key: v}
+		}
+		err = c.SetAddElements(set, elements)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nft.flush(c)
+}
+
+// flush the changes of the applied ruleset.
+func (nft *NFTables) flush(c *nftables.Conn) error {
+	err := c.Flush()
+	if err != nil {
+		return err
+	}
+	nft.revision++
+
+	return nil
+}
+
+// Revision returns the number of the changes of the applied ruleset,
+// it's increased by every update.
+func (nft *NFTables) Revision() uint64 {
+	nft.mu.Lock()
+	defer nft.mu.Unlock()
+
+	return nft.revision
+}
+
+// trackIPSet keeps the elements of the named set to build it again.
+func (nft *NFTables) trackIPSet(set *nftables.Set, del, add []net.IP) {
+	elems := nft.elements[set.Name]
+	for _, ip := range del {
+		for i := range elems {
+			if elems[i].Equal(ip) {
+				elems = append(elems[:i], elems[i+1:]...)
+				break
+			}
+		}
+	}
+	for _, ip := range add {
+		found := false
+		for i := range elems {
+			if elems[i].Equal(ip) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			elems = append(elems, ip)
+		}
+	}
+	nft.elements[set.Name] = elems
+}
+
+// setElements returns the tracked elements of the named set.
+func (nft *NFTables) setElements(set *nftables.Set) []nftables.SetElement {
+	elems := nft.elements[set.Name]
+	if set.KeyType == nftables.TypeIP6Addr {
+		elements := make([]nftables.SetElement, len(elems))
+		for i, ip := range elems {
+			elements[i] = nftables.SetElement{Key: ip.To16()}
+		}
+		return elements
+	}
+
+	return nfutils.GetAddrElems(elems)
+}
+
+// splitIPs by the address family.
+func splitIPs(ips []net.IP) ([]net.IP, []net.IP) {
+	var ips4, ips6 []net.IP
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			ips4 = append(ips4, ip4)
+		} else {
+			ips6 = append(ips6, ip.To16())
+		}
+	}
+
+	return ips4, ips6
+}
+
+// captureHost ruleset to be restored on cleanup, the own table
+// is removed only in table mode.
+func (nft *NFTables) captureHost() {
+	if !nft.cfg.Enabled || nft.cfg.Mode == ModeTable {
+		return
+	}
+
+	// bind network namespace if it was set in config
+	c, err := nft.networkNamespaceBind()
+	if err != nil {
+		nft.hostErr = err
+		return
+	}
+	// release network namespace finally
+	defer nft.networkNamespaceRelease()
+
+	nft.host, nft.hostErr = captureHostRuleset(c)
+}
+
+// Cleanup rules, the ruleset found on the host is restored,
+// the own table is removed in table mode. The rules fall back to
+// default policy filtering if the host ruleset can't be restored.
+func (nft *NFTables) Cleanup() error {
+	nft.mu.Lock()
+	defer nft.mu.Unlock()
+
+	if !nft.cfg.Enabled {
+		return nil
+	}
+	// bind network namespace if it was set in config
+	c, err := nft.networkNamespaceBind()
+	if err != nil {
+		return err
+	}
+	// release network namespace finally
+	defer nft.networkNamespaceRelease()
+
+	if nft.cfg.Mode == ModeTable {
+		// the tables of the other firewall users are left untouched
+		nft.reset(c)
+		err = c.Flush()
+		if err != nil {
+			return err
+		}
+		nft.applied = false
+
+		return nil
+	}
+
+	hostErr := nft.hostErr
+	if nft.host != nil {
+		hostErr = nft.host.restore(c)
+		if hostErr == nil {
+			nft.applied = false
+			return nil
+		}
+
+		// the failed batch is dropped along with the connection
+		c = &nftables.Conn{NetNS: c.NetNS}
+	}
+
+	filterSetTrustElements, _ := c.GetSetElements(nft.filterSetTrustIP) // omit error
+
+	nft.reset(c)
+
+	// add filter table
+	// cmd: nft add table inet filter
+	c.AddTable(nft.tFilter)
+	// add input chain of filter table
+	// cmd: nft add chain inet filter input \
+	// { type filter hook input priority 0 \; policy drop\; }
+	c.AddChain(nft.cInput)
+	// add forward chain
+	// cmd: nft add chain inet filter forward \
+	// { type filter hook forward priority 0 \; policy drop\; }
+	c.AddChain(nft.cForward)
+	// add output chain
+	// cmd: nft add chain inet filter output \
+	// { type filter hook output priority 0 \; policy drop\; }
 	c.AddChain(nft.cOutput)
 
 	// add trust_ipset
-	// cmd: nft add set ip filter trust_ipset { type ipv4_addr\; }
+	// cmd: nft add set inet filter trust_ipset { type ipv4_addr\; }
 	err = c.AddSet(nft.filterSetTrustIP, nil)
 	if err != nil {
 		return err
@@ -1164,11 +3611,82 @@ func (nft *NFTables) Cleanup() error {
 	}
 	nft.applied = false
 
-	return nil
+	return fmt.Errorf("host ruleset isn't restored: %v", hostErr)
+}
+
+// UpdateWan detects the wan interface and ip address again, the ruleset
+// is rebuilt when the interface is changed and the nat rules are replaced
+// when the address is changed. It reports whether anything is changed.
+func (nft *NFTables) UpdateWan() (changed bool, err error) {
+	wanIface, _, wanIP, err := ifconfig.IPAddr()
+	if err != nil {
+		return false, err
+	}
+
+	nft.mu.Lock()
+	defer nft.mu.Unlock()
+
+	ifaceChanged := wanIface != nft.wanIface
+	ipChanged := !wanIP.Equal(nft.wanIP)
+	if !ifaceChanged && !ipChanged {
+		return false, nil
+	}
+
+	prevIface, prevIP := nft.wanIface, nft.wanIP
+	nft.wanIface, nft.wanIP = wanIface, wanIP
+	defer func() {
+		if err != nil {
+			// to be updated again
+			nft.wanIface, nft.wanIP = prevIface, prevIP
+		}
+	}()
+
+	if !nft.applied {
+		return true, nil
+	}
+	if !ifaceChanged && nft.cfg.Masquerade {
+		// the masquerade follows the address
+		return true, nil
+	}
+
+	// bind network namespace if it was set in config
+	c, err := nft.networkNamespaceBind()
+	if err != nil {
+		return false, err
+	}
+	// release network namespace finally
+	defer nft.networkNamespaceRelease()
+
+	if ifaceChanged {
+		// the wan interface is matched by the most of the chains
+		err = nft.build(c)
+		if err != nil {
+			return false, err
+		}
+	} else {
+		c.FlushChain(nft.cPrerouting)
+		c.FlushChain(nft.cPostrouting)
+		c.FlushChain(nft.cPortForward)
+		nft.natRules(c)
+		err = nft.portForwardRules(c)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	err = nft.flush(c)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
 }
 
 // WanIP returns ip address of wan interface.
 func (nft *NFTables) WanIP() net.IP {
+	nft.mu.Lock()
+	defer nft.mu.Unlock()
+
 	return nft.wanIP
 }
 
@@ -1177,7 +3695,7 @@ func (nft *NFTables) IfacesIPs() ([]net.IP, error) {
 	ips := make([]net.IP, 0, len(nft.cfg.Ifaces))
 
 	for _, v := range nft.cfg.Ifaces {
-		if v == nft.wanIface || v == nft.wgIface {
+		if v == nft.wanIface || nft.isWGIface(v) {
 			continue
 		}
 
@@ -1205,3 +3723,13 @@ func (nft *NFTables) IfacesIPs() ([]net.IP, error) {
 
 	return ips, nil
 }
+
+func (nft *NFTables) isWGIface(iface string) bool {
+	for _, n := range nft.wgNetworks {
+		if n.iface == iface {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,12 @@
+package firewall
+
+import "net"
+
+// PortForward of the wan port to the port of the device.
+type PortForward struct {
+	Network    string // wireguard network of the device
+	Proto      string // tcp or udp
+	Port       uint16
+	IP         net.IP
+	DevicePort uint16
+}
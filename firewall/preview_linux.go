@@ -0,0 +1,1026 @@
+//go:build linux
+// +build linux
+
+package firewall
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/binaryutil"
+	"github.com/google/nftables/expr"
+)
+
+// Preview renders the ruleset as nft -f text without applying it,
+// named sets are rendered with the current elements.
+func (nft *NFTables) Preview() (string, error) {
+	nft.mu.Lock()
+	defer nft.mu.Unlock()
+
+	r := newRecorder()
+	err := nft.build(r)
+	if err != nil {
+		return "", err
+	}
+
+	return r.render()
+}
+
+// recorder keeps the ruleset built by NFTables instead of sending it
+// to the kernel.
+type recorder struct {
+	// statements of the ruleset reset
+	preamble []string
+
+	tables []*nftables.Table
+	chains []*nftables.Chain
+	sets   []*recordedSet
+	rules  map[string][]*nftables.Rule
+
+	counters []*nftables.CounterObj
+
+	// last id of the anonymous sets
+	setID uint32
+}
+
+// recordedSet with its elements.
+type recordedSet struct {
+	set   *nftables.Set
+	elems []nftables.SetElement
+}
+
+func newRecorder() *recorder {
+	r := &recorder{
+		rules: make(map[string][]*nftables.Rule),
+	}
+
+	return r
+}
+
+// AddTable records the table once.
+func (r *recorder) AddTable(t *nftables.Table) *nftables.Table {
+	if r.table(t) == nil {
+		r.tables = append(r.tables, t)
+	}
+
+	return t
+}
+
+// DelTable drops the table with its content, the table is added first
+// to be deleted whether it exists or not.
+func (r *recorder) DelTable(t *nftables.Table) {
+	r.preamble = append(r.preamble,
+		fmt.Sprintf("add table %s %s", tableFamily(t.Family), t.Name),
+		fmt.Sprintf("delete table %s %s", tableFamily(t.Family), t.Name))
+
+	tables := r.tables[:0]
+	for _, v := range r.tables {
+		if !sameTable(v, t) {
+			tables = append(tables, v)
+		}
+	}
+	r.tables = tables
+
+	chains := r.chains[:0]
+	for _, v := range r.chains {
+		if sameTable(v.Table, t) {
+			delete(r.rules, chainKey(v.Table, v.Name))
+			continue
+		}
+		chains = append(chains, v)
+	}
+	r.chains = chains
+
+	sets := r.sets[:0]
+	for _, v := range r.sets {
+		if !sameTable(v.set.Table, t) {
+			sets = append(sets, v)
+		}
+	}
+	r.sets = sets
+
+	counters := r.counters[:0]
+	for _, v := range r.counters {
+		if !sameTable(v.Table, t) {
+			counters = append(counters, v)
+		}
+	}
+	r.counters = counters
+}
+
+// AddChain records the chain once.
+func (r *recorder) AddChain(c *nftables.Chain) *nftables.Chain {
+	for _, v := range r.chains {
+		if sameTable(v.Table, c.Table) && v.Name == c.Name {
+			return c
+		}
+	}
+	r.chains = append(r.chains, c)
+
+	return c
+}
+
+// FlushChain drops the rules of the chain.
+func (r *recorder) FlushChain(c *nftables.Chain) {
+	delete(r.rules, chainKey(c.Table, c.Name))
+}
+
+// DelChain drops the chain with the rules.
+func (r *recorder) DelChain(c *nftables.Chain) {
+	chains := r.chains[:0]
+	for _, v := range r.chains {
+		if !sameTable(v.Table, c.Table) || v.Name != c.Name {
+			chains = append(chains, v)
+		}
+	}
+	r.chains = chains
+	delete(r.rules, chainKey(c.Table, c.Name))
+}
+
+// AddSet records the set with the elements, anonymous sets get an id
+// to be looked up by.
+func (r *recorder) AddSet(s *nftables.Set, vals []nftables.SetElement) error {
+	if s.Anonymous {
+		if !s.Constant {
+			return fmt.Errorf("anonymous set must be constant")
+		}
+		if s.ID == 0 {
+			r.setID++
+			s.ID = r.setID
+			s.Name = "__set%d"
+		}
+		r.sets = append(r.sets, &recordedSet{set: s, elems: vals})
+		return nil
+	}
+
+	if r.namedSet(s.Table, s.Name) == nil {
+		r.sets = append(r.sets, &recordedSet{set: s})
+	}
+
+	return r.SetAddElements(s, vals)
+}
+
+// SetAddElements adds the elements to the named set.
+func (r *recorder) SetAddElements(s *nftables.Set, vals []nftables.SetElement) error {
+	v := r.namedSet(s.Table, s.Name)
+	if v == nil {
+		return fmt.Errorf("unknown set %q", s.Name)
+	}
+
+	for _, e := range vals {
+		if indexElement(v.elems, e) < 0 {
+			v.elems = append(v.elems, e)
+		}
+	}
+
+	return nil
+}
+
+// SetDeleteElements deletes the elements from the named set.
+func (r *recorder) SetDeleteElements(s *nftables.Set, vals []nftables.SetElement) error {
+	v := r.namedSet(s.Table, s.Name)
+	if v == nil {
+		return fmt.Errorf("unknown set %q", s.Name)
+	}
+
+	for _, e := range vals {
+		i := indexElement(v.elems, e)
+		if i >= 0 {
+			v.elems = append(v.elems[:i], v.elems[i+1:]...)
+		}
+	}
+
+	return nil
+}
+
+// FlushSet drops the elements of the named set.
+func (r *recorder) FlushSet(s *nftables.Set) {
+	v := r.namedSet(s.Table, s.Name)
+	if v != nil {
+		v.elems = nil
+	}
+}
+
+// DelSet drops the named set.
+func (r *recorder) DelSet(s *nftables.Set) {
+	sets := r.sets[:0]
+	for _, v := range r.sets {
+		if v.set.Anonymous ||
+			!sameTable(v.set.Table, s.Table) || v.set.Name != s.Name {
+			sets = append(sets, v)
+		}
+	}
+	r.sets = sets
+}
+
+// AddRule appends the rule to the chain.
+func (r *recorder) AddRule(rule *nftables.Rule) *nftables.Rule {
+	key := chainKey(rule.Table, rule.Chain.Name)
+	r.rules[key] = append(r.rules[key], rule)
+
+	return rule
+}
+
+// AddObj records the counter once.
+func (r *recorder) AddObj(o nftables.Obj) nftables.Obj {
+	v, ok := o.(*nftables.CounterObj)
+	if !ok || r.counter(v.Table, v.Name) != nil {
+		return o
+	}
+	r.counters = append(r.counters, v)
+
+	return o
+}
+
+// DeleteObject drops the counter.
+func (r *recorder) DeleteObject(o nftables.Obj) {
+	v, ok := o.(*nftables.CounterObj)
+	if !ok {
+		return
+	}
+
+	counters := r.counters[:0]
+	for _, c := range r.counters {
+		if !sameTable(c.Table, v.Table) || c.Name != v.Name {
+			counters = append(counters, c)
+		}
+	}
+	r.counters = counters
+}
+
+// FlushRuleset drops everything recorded.
+func (r *recorder) FlushRuleset() {
+	r.preamble = append(r.preamble, "flush ruleset")
+	r.tables = nil
+	r.chains = nil
+	r.sets = nil
+	r.rules = make(map[string][]*nftables.Rule)
+	r.counters = nil
+}
+
+func (r *recorder) table(t *nftables.Table) *nftables.Table {
+	for _, v := range r.tables {
+		if sameTable(v, t) {
+			return v
+		}
+	}
+
+	return nil
+}
+
+func (r *recorder) counter(
+	t *nftables.Table,
+	name string,
+) *nftables.CounterObj {
+	for _, v := range r.counters {
+		if sameTable(v.Table, t) && v.Name == name {
+			return v
+		}
+	}
+
+	return nil
+}
+
+func (r *recorder) namedSet(t *nftables.Table, name string) *recordedSet {
+	for _, v := range r.sets {
+		if !v.set.Anonymous && sameTable(v.set.Table, t) && v.set.Name == name {
+			return v
+		}
+	}
+
+	return nil
+}
+
+// lookupSet by the name, anonymous sets by the id.
+func (r *recorder) lookupSet(
+	t *nftables.Table,
+	e *expr.Lookup,
+) *recordedSet {
+	for _, v := range r.sets {
+		if !sameTable(v.set.Table, t) {
+			continue
+		}
+		if v.set.Anonymous {
+			if v.set.ID == e.SetID && v.set.Name == e.SetName {
+				return v
+			}
+			continue
+		}
+		if v.set.Name == e.SetName {
+			return v
+		}
+	}
+
+	return nil
+}
+
+// render the ruleset, regular chains are declared before the base ones
+// to be jumped to.
+func (r *recorder) render() (string, error) {
+	var b strings.Builder
+
+	for _, s := range r.preamble {
+		b.WriteString(s + "\n")
+	}
+
+	for _, t := range r.tables {
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "table %s %s {\n", tableFamily(t.Family), t.Name)
+
+		blocks := make([]string, 0)
+		for _, v := range r.sets {
+			if v.set.Anonymous || !sameTable(v.set.Table, t) {
+				continue
+			}
+			blocks = append(blocks, renderSet(v))
+		}
+		for _, v := range r.counters {
+			if sameTable(v.Table, t) {
+				blocks = append(blocks, renderCounter(v))
+			}
+		}
+
+		var chains, baseChains []*nftables.Chain
+		for _, c := range r.chains {
+			if !sameTable(c.Table, t) {
+				continue
+			}
+			if c.Hooknum != nil {
+				baseChains = append(baseChains, c)
+			} else {
+				chains = append(chains, c)
+			}
+		}
+		for _, c := range append(chains, baseChains...) {
+			block, err := r.renderChain(c)
+			if err != nil {
+				return "", err
+			}
+			blocks = append(blocks, block)
+		}
+
+		b.WriteString(strings.Join(blocks, "\n"))
+		b.WriteString("}\n")
+	}
+
+	return b.String(), nil
+}
+
+func renderSet(v *recordedSet) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "\tset %s {\n", v.set.Name)
+	fmt.Fprintf(&b, "\t\ttype %s\n", v.set.KeyType.Name)
+
+	var flags []string
+	if v.set.Dynamic {
+		flags = append(flags, "dynamic")
+	}
+	if v.set.Interval {
+		flags = append(flags, "interval")
+	}
+	if v.set.HasTimeout {
+		flags = append(flags, "timeout")
+	}
+	if len(flags) > 0 {
+		fmt.Fprintf(&b, "\t\tflags %s\n", strings.Join(flags, ","))
+	}
+	if v.set.Timeout > 0 {
+		fmt.Fprintf(&b, "\t\ttimeout %s\n", v.set.Timeout)
+	}
+
+	var values []string
+	if v.set.Interval {
+		values = intervalValues(v.elems)
+	} else {
+		// elements are sorted to keep the output stable
+		elems := make([]nftables.SetElement, len(v.elems))
+		copy(elems, v.elems)
+		sort.Slice(elems, func(i, j int) bool {
+			return bytes.Compare(elems[i].Key, elems[j].Key) < 0
+		})
+		if len(elems) > 0 {
+			values = []string{joinElements(v.set, elems)}
+		}
+	}
+	if len(values) > 0 {
+		fmt.Fprintf(&b, "\t\telements = { %s }\n", strings.Join(values, ", "))
+	}
+	b.WriteString("\t}\n")
+
+	return b.String()
+}
+
+func renderCounter(v *nftables.CounterObj) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "\tcounter %s {\n", v.Name)
+	fmt.Fprintf(&b, "\t\tpackets %d bytes %d\n", v.Packets, v.Bytes)
+	b.WriteString("\t}\n")
+
+	return b.String()
+}
+
+// intervalValues of ip ranges, the range starts with an element and ends
+// before the following interval end element.
+func intervalValues(elems []nftables.SetElement) []string {
+	type interval struct {
+		start   net.IP
+		end     net.IP
+		timeout time.Duration
+	}
+
+	var intervals []interval
+	for i := 0; i < len(elems); i++ {
+		if elems[i].IntervalEnd {
+			continue
+		}
+
+		v := interval{
+			start:   net.IP(elems[i].Key),
+			timeout: elems[i].Timeout,
+		}
+		// the range without end element lasts up to the last address
+		v.end = make(net.IP, len(v.start))
+		for j := range v.end {
+			v.end[j] = 0xff
+		}
+		if i+1 < len(elems) && elems[i+1].IntervalEnd {
+			v.end = previousIP(net.IP(elems[i+1].Key))
+			i++
+		}
+		intervals = append(intervals, v)
+	}
+
+	sort.Slice(intervals, func(i, j int) bool {
+		return bytes.Compare(intervals[i].start, intervals[j].start) < 0
+	})
+
+	values := make([]string, len(intervals))
+	for i, v := range intervals {
+		values[i] = ipRange(v.start, v.end)
+		if v.timeout > 0 {
+			values[i] += " timeout " + v.timeout.String()
+		}
+	}
+
+	return values
+}
+
+// ipRange in cidr notation if possible, as start-end range otherwise.
+func ipRange(start, end net.IP) string {
+	bits := len(start) * 8
+	for ones := 0; ones <= bits; ones++ {
+		mask := net.CIDRMask(ones, bits)
+		if !start.Mask(mask).Equal(start) {
+			continue
+		}
+		last := make(net.IP, len(start))
+		for i := range start {
+			last[i] = start[i] | ^mask[i]
+		}
+		if !last.Equal(end) {
+			continue
+		}
+		if ones == bits {
+			return start.String()
+		}
+		return fmt.Sprintf("%s/%d", start, ones)
+	}
+
+	return start.String() + "-" + end.String()
+}
+
+func previousIP(ip net.IP) net.IP {
+	prev := make(net.IP, len(ip))
+	copy(prev, ip)
+	for i := len(prev) - 1; i >= 0; i-- {
+		prev[i]--
+		if prev[i] != 0xff {
+			break
+		}
+	}
+
+	return prev
+}
+
+func (r *recorder) renderChain(c *nftables.Chain) (string, error) {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "\tchain %s {\n", c.Name)
+	if c.Hooknum != nil {
+		fmt.Fprintf(&b, "\t\ttype %s hook %s priority %d;",
+			c.Type, chainHook(*c.Hooknum), chainPriority(c.Priority))
+		if c.Policy != nil {
+			policy := "accept"
+			if *c.Policy == nftables.ChainPolicyDrop {
+				policy = "drop"
+			}
+			fmt.Fprintf(&b, " policy %s;", policy)
+		}
+		b.WriteString("\n")
+	}
+	for _, rule := range r.rules[chainKey(c.Table, c.Name)] {
+		s, err := r.renderRule(rule)
+		if err != nil {
+			return "", fmt.Errorf("chain %s: %v", c.Name, err)
+		}
+		fmt.Fprintf(&b, "\t\t%s\n", s)
+	}
+	b.WriteString("\t}\n")
+
+	return b.String(), nil
+}
+
+// operand kinds of the loaded register.
+const (
+	operandNone = iota
+	operandIfname
+	operandNFProto
+	operandL4Proto
+	operandAddr
+	operandPort
+	operandICMPType
+	operandCtState
+	operandAddrType
+	operandNumber
+)
+
+// renderRule decodes the expressions made by nfutils into the statements.
+func (r *recorder) renderRule(rule *nftables.Rule) (string, error) {
+	var (
+		stmts []string
+
+		// loaded operand and its mask
+		left string
+		kind int
+		mask []byte
+
+		l4proto byte
+		imm     = make(map[uint32][]byte)
+	)
+
+	for _, e := range rule.Exprs {
+		switch e := e.(type) {
+		case *expr.Meta:
+			mask = nil
+			switch e.Key {
+			case expr.MetaKeyIIFNAME:
+				left, kind = "iifname", operandIfname
+			case expr.MetaKeyOIFNAME:
+				left, kind = "oifname", operandIfname
+			case expr.MetaKeyNFPROTO:
+				left, kind = "meta nfproto", operandNFProto
+			case expr.MetaKeyL4PROTO:
+				left, kind = "meta l4proto", operandL4Proto
+			default:
+				return "", fmt.Errorf("unsupported meta key %d", e.Key)
+			}
+
+		case *expr.Payload:
+			mask = nil
+			switch e.Base {
+			case expr.PayloadBaseNetworkHeader:
+				switch {
+				case e.Offset == 12 && e.Len == 4:
+					left = "ip saddr"
+				case e.Offset == 16 && e.Len == 4:
+					left = "ip daddr"
+				case e.Offset == 8 && e.Len == 16:
+					left = "ip6 saddr"
+				case e.Offset == 24 && e.Len == 16:
+					left = "ip6 daddr"
+				default:
+					return "", fmt.Errorf(
+						"unsupported network header offset %d", e.Offset)
+				}
+				kind = operandAddr
+
+				// the family match is implied by the address
+				family := "meta nfproto ipv4"
+				if e.Len == 16 {
+					family = "meta nfproto ipv6"
+				}
+				if n := len(stmts); n > 0 && stmts[n-1] == family {
+					stmts = stmts[:n-1]
+				}
+
+			case expr.PayloadBaseTransportHeader:
+				proto := l4protoName(l4proto)
+				if proto == "ipv6-icmp" {
+					proto = "icmpv6"
+				}
+				switch {
+				case e.Offset == 0 && e.Len == 2:
+					left, kind = proto+" sport", operandPort
+				case e.Offset == 2 && e.Len == 2:
+					left, kind = proto+" dport", operandPort
+				case e.Offset == 0 && e.Len == 1:
+					left, kind = proto+" type", operandICMPType
+				case e.Offset >= 8:
+					// raw payload past the udp header
+					left = fmt.Sprintf("@th,%d,%d", e.Offset*8, e.Len*8)
+					kind = operandNumber
+				default:
+					return "", fmt.Errorf(
+						"unsupported transport header offset %d", e.Offset)
+				}
+				if proto == strconv.Itoa(int(l4proto)) {
+					left = "th" + strings.TrimPrefix(left, proto)
+				}
+
+			default:
+				return "", fmt.Errorf("unsupported payload base %d", e.Base)
+			}
+
+		case *expr.Ct:
+			if e.Key != expr.CtKeySTATE {
+				return "", fmt.Errorf("unsupported ct key %d", e.Key)
+			}
+			left, kind, mask = "ct state", operandCtState, nil
+
+		case *expr.Fib:
+			if !e.ResultADDRTYPE || !e.FlagDADDR {
+				return "", fmt.Errorf("unsupported fib")
+			}
+			left, kind, mask = "fib daddr type", operandAddrType, nil
+
+		case *expr.Bitwise:
+			mask = e.Mask
+
+		case *expr.Cmp:
+			op := ""
+			switch e.Op {
+			case expr.CmpOpEq:
+			case expr.CmpOpNeq:
+				op = "!= "
+			default:
+				return "", fmt.Errorf("unsupported cmp op %d", e.Op)
+			}
+
+			switch {
+			case kind == operandCtState && mask != nil:
+				// bits of the state are tested by the mask
+				stmts = append(stmts, left+" "+ctStateNames(mask))
+			case kind == operandAddr && mask != nil:
+				ones, _ := net.IPMask(mask).Size()
+				stmts = append(stmts, fmt.Sprintf(
+					"%s %s%s/%d", left, op, net.IP(e.Data), ones))
+			default:
+				if kind == operandL4Proto && e.Op == expr.CmpOpEq {
+					l4proto = e.Data[0]
+				}
+				stmts = append(stmts,
+					left+" "+op+operandValue(kind, left, e.Data))
+			}
+			kind, mask = operandNone, nil
+
+		case *expr.Lookup:
+			v := r.lookupSet(rule.Table, e)
+			if v == nil {
+				return "", fmt.Errorf("unknown set %q", e.SetName)
+			}
+			op := ""
+			if e.Invert {
+				op = "!= "
+			}
+			ref := "@" + v.set.Name
+			if v.set.Anonymous {
+				ref = "{ " + joinElements(v.set, v.elems) + " }"
+			}
+			stmts = append(stmts, left+" "+op+ref)
+			kind, mask = operandNone, nil
+
+		case *expr.Dynset:
+			if r.namedSet(rule.Table, e.SetName) == nil {
+				return "", fmt.Errorf("unknown set %q", e.SetName)
+			}
+			op := "add"
+			if e.Operation == unix.NFT_DYNSET_OP_UPDATE {
+				op = "update"
+			}
+			elem := left
+			if e.Timeout > 0 {
+				elem += " timeout " + e.Timeout.String()
+			}
+			for _, x := range e.Exprs {
+				limit, ok := x.(*expr.Limit)
+				if !ok {
+					return "", fmt.Errorf("unsupported dynset expression %T", x)
+				}
+				elem += " " + limitStatement(limit)
+			}
+			stmts = append(stmts,
+				fmt.Sprintf("%s @%s { %s }", op, e.SetName, elem))
+			kind, mask = operandNone, nil
+
+		case *expr.Limit:
+			stmts = append(stmts, limitStatement(e))
+
+		case *expr.Objref:
+			if r.counter(rule.Table, e.Name) == nil {
+				return "", fmt.Errorf("unknown counter %q", e.Name)
+			}
+			stmts = append(stmts, fmt.Sprintf("counter name %q", e.Name))
+
+		case *expr.Log:
+			stmts = append(stmts, fmt.Sprintf("log prefix %q", e.Data))
+
+		case *expr.Immediate:
+			imm[e.Register] = e.Data
+
+		case *expr.NAT:
+			typ := "snat"
+			if e.Type == expr.NATTypeDestNAT {
+				typ = "dnat"
+			}
+			family := "ip"
+			if e.Family == unix.NFPROTO_IPV6 {
+				family = "ip6"
+			}
+			to := net.IP(imm[e.RegAddrMin]).String()
+			if e.RegProtoMin != 0 {
+				port := binary.BigEndian.Uint16(imm[e.RegProtoMin])
+				to += ":" + strconv.Itoa(int(port))
+			}
+			stmts = append(stmts, fmt.Sprintf("%s %s to %s", typ, family, to))
+
+		case *expr.Masq:
+			stmts = append(stmts, "masquerade")
+
+		case *expr.Verdict:
+			switch e.Kind {
+			case expr.VerdictAccept:
+				stmts = append(stmts, "accept")
+			case expr.VerdictDrop:
+				stmts = append(stmts, "drop")
+			case expr.VerdictReturn:
+				stmts = append(stmts, "return")
+			case expr.VerdictJump:
+				stmts = append(stmts, "jump "+e.Chain)
+			case expr.VerdictGoto:
+				stmts = append(stmts, "goto "+e.Chain)
+			default:
+				return "", fmt.Errorf("unsupported verdict %d", e.Kind)
+			}
+
+		case *expr.Reject:
+			stmts = append(stmts, rejectStatement(e))
+
+		default:
+			return "", fmt.Errorf("unsupported expression %T", e)
+		}
+	}
+
+	return strings.Join(stmts, " "), nil
+}
+
+// limitStatement of the packet rate.
+func limitStatement(e *expr.Limit) string {
+	unit := "second"
+	switch e.Unit {
+	case expr.LimitTimeMinute:
+		unit = "minute"
+	case expr.LimitTimeHour:
+		unit = "hour"
+	case expr.LimitTimeDay:
+		unit = "day"
+	case expr.LimitTimeWeek:
+		unit = "week"
+	}
+
+	stmt := "limit rate "
+	if e.Over {
+		stmt += "over "
+	}
+	if e.Type == expr.LimitTypePktBytes {
+		stmt += fmt.Sprintf("%d bytes/%s", e.Rate, unit)
+		if e.Burst > 0 {
+			stmt += fmt.Sprintf(" burst %d bytes", e.Burst)
+		}
+		return stmt
+	}
+	stmt += fmt.Sprintf("%d/%s", e.Rate, unit)
+	if e.Burst > 0 {
+		stmt += fmt.Sprintf(" burst %d packets", e.Burst)
+	}
+
+	return stmt
+}
+
+func operandValue(kind int, left string, data []byte) string {
+	switch kind {
+	case operandIfname:
+		return strconv.Quote(string(bytes.TrimRight(data, "\x00")))
+	case operandNFProto:
+		switch data[0] {
+		case unix.NFPROTO_IPV4:
+			return "ipv4"
+		case unix.NFPROTO_IPV6:
+			return "ipv6"
+		}
+		return strconv.Itoa(int(data[0]))
+	case operandL4Proto:
+		return l4protoName(data[0])
+	case operandAddr:
+		return net.IP(data).String()
+	case operandPort:
+		return strconv.Itoa(int(binary.BigEndian.Uint16(data)))
+	case operandICMPType:
+		return icmpTypeName(strings.HasPrefix(left, "icmpv6"), data[0])
+	case operandCtState:
+		return ctStateNames(data)
+	case operandNumber:
+		var v uint64
+		for _, b := range data {
+			v = v<<8 | uint64(b)
+		}
+		return strconv.FormatUint(v, 10)
+	case operandAddrType:
+		if binaryutil.NativeEndian.Uint32(data) == unix.RTN_LOCAL {
+			return "local"
+		}
+	}
+
+	return "0x" + hex.EncodeToString(data)
+}
+
+// joinElements of the set by its key type.
+func joinElements(s *nftables.Set, elems []nftables.SetElement) string {
+	values := make([]string, len(elems))
+	for i, e := range elems {
+		switch s.KeyType.Name {
+		case nftables.TypeIPAddr.Name, nftables.TypeIP6Addr.Name:
+			values[i] = net.IP(e.Key).String()
+		case nftables.TypeInetService.Name:
+			values[i] = strconv.Itoa(int(binary.BigEndian.Uint16(e.Key)))
+		case "ct_state":
+			values[i] = ctStateNames(e.Key)
+		default:
+			values[i] = "0x" + hex.EncodeToString(e.Key)
+		}
+	}
+
+	return strings.Join(values, ", ")
+}
+
+// ctStateNames of the state bits in host byte order.
+func ctStateNames(b []byte) string {
+	bits := binary.LittleEndian.Uint32(b)
+
+	var names []string
+	for _, v := range []struct {
+		bit  uint32
+		name string
+	}{
+		{1, "invalid"},
+		{2, "established"},
+		{4, "related"},
+		{8, "new"},
+		{64, "untracked"},
+	} {
+		if bits&v.bit != 0 {
+			names = append(names, v.name)
+		}
+	}
+	if len(names) == 1 {
+		return names[0]
+	}
+
+	return "{ " + strings.Join(names, ", ") + " }"
+}
+
+func l4protoName(p byte) string {
+	switch p {
+	case unix.IPPROTO_ICMP:
+		return "icmp"
+	case unix.IPPROTO_TCP:
+		return "tcp"
+	case unix.IPPROTO_UDP:
+		return "udp"
+	case unix.IPPROTO_ICMPV6:
+		return "ipv6-icmp"
+	}
+
+	return strconv.Itoa(int(p))
+}
+
+func icmpTypeName(v6 bool, t byte) string {
+	switch {
+	case !v6 && t == 0, v6 && t == 129:
+		return "echo-reply"
+	case !v6 && t == 8, v6 && t == 128:
+		return "echo-request"
+	}
+
+	return strconv.Itoa(int(t))
+}
+
+func rejectStatement(e *expr.Reject) string {
+	switch e.Type {
+	case unix.NFT_REJECT_TCP_RST:
+		return "reject with tcp reset"
+	case unix.NFT_REJECT_ICMP_UNREACH:
+		names := map[uint8]string{
+			0:  "net-unreachable",
+			1:  "host-unreachable",
+			2:  "prot-unreachable",
+			3:  "port-unreachable",
+			9:  "net-prohibited",
+			10: "host-prohibited",
+			13: "admin-prohibited",
+		}
+		if name, ok := names[e.Code]; ok {
+			return "reject with icmp type " + name
+		}
+		return "reject with icmp type " + strconv.Itoa(int(e.Code))
+	case unix.NFT_REJECT_ICMPX_UNREACH:
+		names := map[uint8]string{
+			0: "port-unreachable",
+			1: "admin-prohibited",
+			2: "no-route",
+			3: "host-unreachable",
+		}
+		if name, ok := names[e.Code]; ok {
+			return "reject with icmpx type " + name
+		}
+		return "reject with icmpx type " + strconv.Itoa(int(e.Code))
+	}
+
+	return "reject"
+}
+
+func indexElement(elems []nftables.SetElement, e nftables.SetElement) int {
+	for i := range elems {
+		if bytes.Equal(elems[i].Key, e.Key) &&
+			elems[i].IntervalEnd == e.IntervalEnd {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func chainKey(t *nftables.Table, chain string) string {
+	return tableFamily(t.Family) + " " + t.Name + " " + chain
+}
+
+func sameTable(a, b *nftables.Table) bool {
+	return a.Family == b.Family && a.Name == b.Name
+}
+
+func tableFamily(f nftables.TableFamily) string {
+	switch f {
+	case nftables.TableFamilyINet:
+		return "inet"
+	case nftables.TableFamilyIPv4:
+		return "ip"
+	case nftables.TableFamilyIPv6:
+		return "ip6"
+	case nftables.TableFamilyARP:
+		return "arp"
+	case nftables.TableFamilyNetdev:
+		return "netdev"
+	case nftables.TableFamilyBridge:
+		return "bridge"
+	}
+
+	return strconv.Itoa(int(f))
+}
+
+func chainHook(h nftables.ChainHook) string {
+	switch h {
+	case *nftables.ChainHookPrerouting:
+		return "prerouting"
+	case *nftables.ChainHookInput:
+		return "input"
+	case *nftables.ChainHookForward:
+		return "forward"
+	case *nftables.ChainHookOutput:
+		return "output"
+	case *nftables.ChainHookPostrouting:
+		return "postrouting"
+	}
+
+	return strconv.Itoa(int(h))
+}
+
+func chainPriority(p *nftables.ChainPriority) int32 {
+	if p == nil {
+		return 0
+	}
+
+	return int32(*p)
+}
@@ -0,0 +1,256 @@
+//go:build linux
+// +build linux
+
+package firewall
+
+import (
+	"flag"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestPreview(t *testing.T) {
+	tests := []struct {
+		name string
+		mode string
+	}{
+		{"flush", ModeFlush},
+		{"table", ModeTable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nft := testNFTables(t, tt.mode)
+
+			got, err := nft.Preview()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			golden := filepath.Join("testdata", "preview_"+tt.name+".nft")
+			if *update {
+				err = os.WriteFile(golden, []byte(got), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("preview mismatch %s, run go test -update\n%s",
+					golden, got)
+			}
+
+			// the limit breaks the rule once the rate is over,
+			// the verdict after it would be skipped
+			for _, line := range strings.Split(got, "\n") {
+				line = strings.TrimSpace(line)
+				if !strings.Contains(line, "limit rate ") ||
+					strings.Contains(line, "limit rate over ") {
+					continue
+				}
+				if strings.HasSuffix(line, " drop") ||
+					strings.HasSuffix(line, " accept") {
+					t.Errorf("verdict after the limit: %s", line)
+				}
+			}
+
+			// the addresses and routes of the device share the limit
+			// of its chain, the limit by the address would be separate
+			for _, line := range strings.Split(got, "\n") {
+				if strings.Contains(line, " bytes/second") &&
+					(strings.Contains(line, "saddr ") ||
+						strings.Contains(line, "daddr ")) {
+					t.Errorf("bandwidth limit by the address: %s", line)
+				}
+			}
+		})
+	}
+}
+
+// testNFTables with the state of every kind, nothing is applied.
+func testNFTables(t *testing.T, mode string) *NFTables {
+	cfg := Config{
+		Enabled:       true,
+		DefaultPolicy: "drop",
+		Mode:          mode,
+		WGNetworks: []WGNetwork{
+			{Name: "default", Iface: "wg0", Port: 51820},
+			{Name: "lab", Iface: "wg1", Port: 51821, Isolated: true},
+		},
+		Ifaces:     []string{"eth0", "eth1"},
+		TrustPorts: []uint16{22},
+		RateLimit: RateLimit{
+			TrustRate:  10,
+			WGRate:     20,
+			BanTimeout: 10 * time.Minute,
+		},
+		DropLogRate: 10,
+	}
+
+	nft := newNFTables(cfg, []uint16{443}, "eth0", net.IPv4(192, 0, 2, 1).To4())
+
+	err := nft.UpdateTrustIPs([]TrustIP{
+		{
+			IPNet: net.IPNet{
+				IP:   net.IPv4(198, 51, 100, 0).To4(),
+				Mask: net.CIDRMask(24, 32),
+			},
+		},
+		{
+			IPNet: net.IPNet{
+				IP:   net.IPv4(203, 0, 113, 7).To4(),
+				Mask: net.CIDRMask(32, 32),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = nft.UpdateWGManagerIPs("default", nil, []net.IP{
+		net.IPv4(172, 16, 0, 2),
+		net.ParseIP("fd00::2"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = nft.UpdateWGForwardWanIPs("default", nil, []net.IP{
+		net.IPv4(172, 16, 0, 2),
+		net.IPv4(172, 16, 0, 3),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = nft.UpdateWGForwardWanIPs("default", []net.IP{
+		net.IPv4(172, 16, 0, 3),
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = nft.UpdateWGDevices("default", []Device{
+		{IP: net.IPv4(172, 16, 0, 2), IP6: net.ParseIP("fd00::2")},
+		{IP: net.IPv4(172, 16, 0, 3)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = nft.UpdateWGACLs("default", []ACL{
+		{
+			IP:  net.IPv4(172, 16, 0, 3),
+			IP6: net.ParseIP("fd00::3"),
+			Rules: []ACLRule{
+				{
+					Proto:     "tcp",
+					Direction: ACLDirectionOut,
+					DPorts:    []uint16{22, 443},
+					Addrs:     []net.IP{net.IPv4(172, 16, 0, 4)},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = nft.UpdateWGGroups("default",
+		[]Group{
+			{Name: "developers", IPs: []net.IP{net.IPv4(172, 16, 0, 2)}},
+			{Name: "staging", IPs: []net.IP{net.IPv4(172, 16, 0, 5)}},
+		},
+		[]GroupPolicy{
+			{From: "developers", To: "staging", Proto: "tcp", DPorts: []uint16{22}},
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = nft.UpdateEgress([]EgressRule{
+		{Proto: "tcp", DPorts: []uint16{25}},
+		{
+			DNets: []net.IPNet{
+				{IP: net.IPv4(10, 0, 0, 0).To4(), Mask: net.CIDRMask(8, 32)},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = nft.UpdateWGEgress("default", []Egress{
+		{
+			IP:  net.IPv4(172, 16, 0, 2),
+			IP6: net.ParseIP("fd00::2"),
+			Rules: []EgressRule{
+				{
+					Allow:  true,
+					Proto:  "tcp",
+					DPorts: []uint16{443},
+					DNets: []net.IPNet{
+						{IP: net.IPv4(198, 51, 100, 0).To4(), Mask: net.CIDRMask(24, 32)},
+						{IP: net.ParseIP("2001:db8::"), Mask: net.CIDRMask(32, 128)},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = nft.UpdateWGIsolation("lab", Isolation{
+		Servers: []net.IP{net.IPv4(172, 17, 0, 2), net.ParseIP("fd01::2")},
+		Routes: []net.IPNet{
+			{IP: net.IPv4(10, 20, 0, 0).To4(), Mask: net.CIDRMask(24, 32)},
+		},
+		Pairs: []Pair{
+			{
+				A: net.IPv4(172, 17, 0, 3),
+				B: net.IPv4(172, 17, 0, 4),
+				BRoutes: []net.IPNet{
+					{IP: net.IPv4(10, 30, 0, 0).To4(), Mask: net.CIDRMask(24, 32)},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = nft.UpdateWGBandwidth("default", []Bandwidth{
+		{
+			IP:       net.IPv4(172, 16, 0, 2),
+			IP6:      net.ParseIP("fd00::2"),
+			Download: 10000,
+			Upload:   2000,
+		},
+		{
+			IP: net.IPv4(172, 16, 0, 3),
+			Routes: []net.IPNet{
+				{IP: net.IPv4(10, 40, 0, 0).To4(), Mask: net.CIDRMask(24, 32)},
+			},
+			Download: 1000,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = nft.UpdatePortForwards([]PortForward{
+		{
+			Network:    "default",
+			Proto:      "tcp",
+			Port:       8443,
+			IP:         net.IPv4(172, 16, 0, 2),
+			DevicePort: 443,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return nft
+}
@@ -0,0 +1,13 @@
+package firewall
+
+import (
+	"net"
+	"time"
+)
+
+// TrustIP is the range trusted for remote access to the server,
+// the range is removed by the firewall at the expiration time.
+type TrustIP struct {
+	IPNet     net.IPNet
+	ExpiresAt *time.Time
+}
@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net"
+	"sort"
+	"time"
 
 	bolt "go.etcd.io/bbolt"
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
@@ -14,11 +17,42 @@ import (
 
 // Device model.
 type Device struct {
-	IPNetwork  IPNetwork    `json:"ipnetwork"`
-	PubKey     wgtypes.Key  `json:"pub_key"`
-	Label      string       `json:"label"`
-	WANForward bool         `json:"wan_forward"`
-	allowedIPs []*net.IPNet `json:"allowed_ips"`
+	IPNetwork    IPNetwork    `json:"ipnetwork"`
+	PubKey       wgtypes.Key  `json:"pub_key"`
+	PresharedKey wgtypes.Key  `json:"preshared_key"`
+	Label        string       `json:"label"`
+	WANForward   bool         `json:"wan_forward"`
+	Routes       []*net.IPNet `json:"routes"`
+
+	// Server device is reachable by the other devices
+	// of the isolated network.
+	Server bool `json:"server"`
+
+	// DownloadRate and UploadRate limit the forwarded traffic
+	// of the device in kbit/s, zero value turns the limit off.
+	DownloadRate uint32 `json:"download_rate,omitempty"`
+	UploadRate   uint32 `json:"upload_rate,omitempty"`
+
+	// Disabled device keeps its record, ip and dns entries,
+	// but it's not served as a peer.
+	Disabled bool `json:"disabled"`
+
+	// ExpiresAt is the optional time the device stops being served at.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	// Rules restrict the traffic of the device within the network.
+	Rules NFRules `json:"rules,omitempty"`
+
+	// Egress restricts the connections of the device forwarded to wan,
+	// it's applied along with the global egress rules.
+	Egress EgressRules `json:"egress,omitempty"`
+
+	// Groups the device is tagged into.
+	Groups []string `json:"groups,omitempty"`
+
+	// Network name the device belongs to,
+	// empty value stands for the default network.
+	Network string `json:"network"`
 
 	UserUUID string `json:"user_uuid"`
 }
@@ -69,18 +103,76 @@ func LoadDevice(tx *bolt.Tx, ip net.IP) (Device, error) {
 	d.IPNetwork.IP = d.IPNetwork.IP.To4()
 	d.IPNetwork.Net.IP = d.IPNetwork.Net.IP.To4()
 
-	for i := range d.allowedIPs {
-		d.allowedIPs[i].IP = d.allowedIPs[i].IP.To4()
+	for i := range d.Routes {
+		d.Routes[i].IP = d.Routes[i].IP.To4()
 	}
 
 	return d, nil
 }
 
+// Expired reports whether the device is expired at the time.
+func (d *Device) Expired(now time.Time) bool {
+	return d.ExpiresAt != nil && !now.Before(*d.ExpiresAt)
+}
+
+// Active reports whether the device is served at the time,
+// it's neither disabled nor expired.
+func (d *Device) Active(now time.Time) bool {
+	return !d.Disabled && !d.Expired(now)
+}
+
+// InGroup reports whether the device is tagged into the group.
+func (d *Device) InGroup(name string) bool {
+	for _, v := range d.Groups {
+		if v == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AddGroup tags the device into the group.
+func (d *Device) AddGroup(name string) {
+	if d.InGroup(name) {
+		return
+	}
+
+	d.Groups = append(d.Groups, name)
+	sort.Strings(d.Groups)
+}
+
+// RemoveGroup untags the device from the group.
+func (d *Device) RemoveGroup(name string) {
+	groups := make([]string, 0, len(d.Groups))
+	for _, v := range d.Groups {
+		if v != name {
+			groups = append(groups, v)
+		}
+	}
+
+	if len(groups) == 0 {
+		groups = nil
+	}
+	d.Groups = groups
+}
+
 // CIDR for device.
 func (d *Device) CIDR() *net.IPNet {
 	return &net.IPNet{IP: d.IPNetwork.IP, Mask: net.IPv4Mask(255, 255, 255, 255)}
 }
 
+// CIDR6 for device within ipv6 prefix of the network,
+// nil if the network has no ipv6 prefix.
+func (d *Device) CIDR6(ipnet6 *net.IPNet) *net.IPNet {
+	if ipnet6 == nil {
+		return nil
+	}
+
+	ip := ipcalc.MapIP(d.IPNetwork.IP, d.IPNetwork.Net, ipnet6)
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
+
 // Store to database.
 func (d *Device) Store(tx *bolt.Tx) error {
 	if !tx.Writable() {
@@ -102,26 +194,40 @@ func (d *Device) Store(tx *bolt.Tx) error {
 	return bucket.Put(key, value)
 }
 
-// AllowedIPs for device
-func (d *Device) AllowedIPs() []*net.IPNet {
+// AllowedIPs for device, ipnet6 is the optional ipv6 prefix of the network,
+// routes are the subnets routed behind the other devices.
+func (d *Device) AllowedIPs(
+	ipnet6 *net.IPNet,
+	routes []*net.IPNet,
+) []*net.IPNet {
 	var ipnets []*net.IPNet
 
-	if len(d.allowedIPs) > 0 {
-		for i := range d.allowedIPs {
-			ipnets = append(ipnets, d.allowedIPs[i])
-		}
-	} else if d.WANForward {
+	if d.WANForward {
 		ipnets = []*net.IPNet{
 			&net.IPNet{
 				IP:   net.IPv4(0, 0, 0, 0).To4(),
 				Mask: net.IPv4Mask(0, 0, 0, 0)},
 		}
+		if ipnet6 != nil {
+			ipnets = append(ipnets, &net.IPNet{
+				IP:   net.IPv6zero,
+				Mask: net.CIDRMask(0, 128)})
+		}
 	} else {
 		ipnets = []*net.IPNet{
 			&net.IPNet{
 				IP:   d.IPNetwork.Net.IP,
 				Mask: d.IPNetwork.Net.Mask},
 		}
+		if ipnet6 != nil {
+			ipnets = append(ipnets, &net.IPNet{
+				IP:   ipnet6.IP.Mask(ipnet6.Mask),
+				Mask: ipnet6.Mask})
+		}
+	}
+
+	for i := range routes {
+		ipnets = append(ipnets, routes[i])
 	}
 
 	return ipnets
@@ -133,6 +239,16 @@ func RemoveDevice(tx *bolt.Tx, ip net.IP) error {
 		return errors.New("tx not writable")
 	}
 
+	// port forwards to the device and its pairs are dropped along with it
+	err := RemoveDevicePortForwards(tx, ip)
+	if err != nil {
+		return err
+	}
+	err = RemoveDevicePairs(tx, ip)
+	if err != nil {
+		return err
+	}
+
 	bname := []byte("devices")
 	bucket := tx.Bucket(bname)
 	if bucket == nil {
@@ -143,6 +259,22 @@ func RemoveDevice(tx *bolt.Tx, ip net.IP) error {
 	return bucket.Delete(key)
 }
 
+// PubKeyExists reports whether any device uses the public key.
+func PubKeyExists(tx *bolt.Tx, pk wgtypes.Key) (bool, error) {
+	devices, err := LoadDevices(tx)
+	if err != nil {
+		return false, err
+	}
+
+	for i := range devices {
+		if bytes.Compare(pk[:], devices[i].PubKey[:]) == 0 {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
 // Devices type
 type Devices []Device
 
@@ -169,8 +301,8 @@ func LoadDevices(tx *bolt.Tx) (Devices, error) {
 		d.IPNetwork.IP = d.IPNetwork.IP.To4()
 		d.IPNetwork.Net.IP = d.IPNetwork.Net.IP.To4()
 
-		for i := range d.allowedIPs {
-			d.allowedIPs[i].IP = d.allowedIPs[i].IP.To4()
+		for i := range d.Routes {
+			d.Routes[i].IP = d.Routes[i].IP.To4()
 		}
 
 		devices[i] = d
@@ -180,14 +312,146 @@ func LoadDevices(tx *bolt.Tx) (Devices, error) {
 	return devices, nil
 }
 
-// NFRule model.
-type NFRule struct {
-	IPProto   string   `json:"ipproto"`
-	Direction string   `json:"direction"`
-	SPorts    []uint16 `json:"sports"`
-	DPorts    []uint16 `json:"dports"`
-	SAddrs    []net.IP `json:"saddrs"`
-	DAddrs    []net.IP `json:"daddrs"`
+// Network returns devices belonging to the network.
+func (s Devices) Network(name string) Devices {
+	devices := make(Devices, 0, len(s))
+	for i := range s {
+		if s[i].Network == name {
+			devices = append(devices, s[i])
+		}
+	}
+
+	return devices
+}
+
+// Active returns devices served at the time.
+func (s Devices) Active(now time.Time) Devices {
+	devices := make(Devices, 0, len(s))
+	for i := range s {
+		if s[i].Active(now) {
+			devices = append(devices, s[i])
+		}
+	}
+
+	return devices
+}
+
+// Inactive returns disabled or expired devices at the time.
+func (s Devices) Inactive(now time.Time) Devices {
+	devices := make(Devices, 0)
+	for i := range s {
+		if !s[i].Active(now) {
+			devices = append(devices, s[i])
+		}
+	}
+
+	return devices
+}
+
+// Group returns devices tagged into the group.
+func (s Devices) Group(name string) Devices {
+	devices := make(Devices, 0)
+	for i := range s {
+		if s[i].InGroup(name) {
+			devices = append(devices, s[i])
+		}
+	}
+
+	return devices
+}
+
+// ExpiredBefore returns devices expired before the time.
+func (s Devices) ExpiredBefore(t time.Time) Devices {
+	devices := make(Devices, 0)
+	for i := range s {
+		if s[i].ExpiresAt != nil && s[i].ExpiresAt.Before(t) {
+			devices = append(devices, s[i])
+		}
+	}
+
+	return devices
+}
+
+// Routes returns subnets routed behind the devices,
+// except the device with specified ip.
+func (s Devices) Routes(ip net.IP) []*net.IPNet {
+	var routes []*net.IPNet
+	for i := range s {
+		if s[i].IPNetwork.IP.Equal(ip) {
+			continue
+		}
+
+		routes = append(routes, s[i].Routes...)
+	}
+
+	return routes
+}
+
+// CheckRoutes validates subnets to be routed behind the device with
+// specified ip, the routes shouldn't be the default one and overlap with
+// wireguard networks, the host networks, each other and the routes
+// of other devices.
+func CheckRoutes(
+	tx *bolt.Tx,
+	ip net.IP,
+	ipnets []*net.IPNet,
+	host []*net.IPNet,
+	routes []*net.IPNet,
+) error {
+	for i := range routes {
+		if routes[i].IP.To4() == nil {
+			return fmt.Errorf("%s: ipv4 network expected", routes[i])
+		}
+
+		if !routes[i].IP.Equal(routes[i].IP.Mask(routes[i].Mask)) {
+			return fmt.Errorf("%s: network address expected", routes[i])
+		}
+
+		if ones, _ := routes[i].Mask.Size(); ones == 0 {
+			return fmt.Errorf("%s: default route isn't allowed", routes[i])
+		}
+
+		for _, ipnet := range host {
+			if overlaps(routes[i], ipnet) {
+				return fmt.Errorf(
+					"%s: overlaps with host network %s", routes[i], ipnet)
+			}
+		}
+
+		for _, ipnet := range ipnets {
+			if overlaps(routes[i], ipnet) {
+				return fmt.Errorf(
+					"%s: overlaps with wireguard network", routes[i])
+			}
+		}
+
+		for j := i + 1; j < len(routes); j++ {
+			if overlaps(routes[i], routes[j]) {
+				return fmt.Errorf("%s: overlaps with %s", routes[i], routes[j])
+			}
+		}
+	}
+
+	devices, err := LoadDevices(tx)
+	if err != nil {
+		return err
+	}
+
+	for _, route := range devices.Routes(ip) {
+		for i := range routes {
+			if overlaps(routes[i], route) {
+				return fmt.Errorf(
+					"%s: overlaps with %s routed by another device",
+					routes[i], route)
+			}
+		}
+	}
+
+	return nil
+}
+
+func overlaps(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
 }
 
 // IPNetwork model.
@@ -217,15 +481,29 @@ func AllocateIP(
 		return IPNetwork{}, errors.New("tx not writable")
 	}
 
-	bname := []byte("devices")
-	bucket, err := tx.CreateBucketIfNotExists(bname)
+	devices, err := LoadDevices(tx)
 	if err != nil {
 		return IPNetwork{}, err
 	}
 
+	// the devices of other networks share the bucket
+	// and don't take address space of the network
+	var cnt int
+	for i := range devices {
+		if bytes.Compare(pk[:], devices[i].PubKey[:]) == 0 {
+			err = errors.New("public key already exists")
+			return IPNetwork{}, err
+		}
+
+		if ipnet.Contains(devices[i].IPNetwork.IP) {
+			devices[cnt] = devices[i]
+			cnt++
+		}
+	}
+	devices = devices[:cnt]
+
 	var ip net.IP
 
-	cnt := bucket.Stats().KeyN
 	_, size := ipcalc.NetworkSize(ipnet)
 	if cnt == int(size)-1 {
 		err = errors.New("can't allocate ip, address space is full")
@@ -239,11 +517,6 @@ func AllocateIP(
 		return IPNetwork{IP: ip, Net: ipnet}, nil
 	}
 
-	devices, err := LoadDevices(tx)
-	if err != nil {
-		return IPNetwork{}, err
-	}
-
 	firstip := ips[0]
 	if bytes.Compare(firstip[:], ipnet.IP[:]) == 0 {
 		firstip = ips[1]
@@ -253,11 +526,6 @@ func AllocateIP(
 	}
 
 	for i := 0; i < len(devices); i++ {
-		if bytes.Compare(pk[:], devices[i].PubKey[:]) == 0 {
-			err = errors.New("public key already exists")
-			return IPNetwork{}, err
-		}
-
 		if ip != nil {
 			continue
 		}
@@ -0,0 +1,169 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"sort"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// DevicePair model, allows new connections between the devices
+// of the isolated network both ways.
+type DevicePair struct {
+	A net.IP `json:"a"`
+	B net.IP `json:"b"`
+}
+
+// NewDevicePair constructor, the addresses are ordered
+// to keep a single record of the pair.
+func NewDevicePair(a, b net.IP) DevicePair {
+	a, b = a.To4(), b.To4()
+	if bytes.Compare(a, b) > 0 {
+		a, b = b, a
+	}
+
+	return DevicePair{A: a, B: b}
+}
+
+// Validate device pair.
+func (p *DevicePair) Validate() error {
+	if p.A.To4() == nil || p.B.To4() == nil {
+		return errors.New("bad ip")
+	}
+
+	if p.A.Equal(p.B) {
+		return errors.New("device can't be paired with itself")
+	}
+
+	return nil
+}
+
+// Has reports whether the device is a side of the pair.
+func (p *DevicePair) Has(ip net.IP) bool {
+	return p.A.Equal(ip) || p.B.Equal(ip)
+}
+
+func devicePairKey(a, b net.IP) []byte {
+	p := NewDevicePair(a, b)
+	key := make([]byte, 0, 2*net.IPv4len)
+	key = append(key, p.A...)
+	key = append(key, p.B...)
+
+	return key
+}
+
+// IsDevicePairExists reports whether the devices are paired.
+func IsDevicePairExists(tx *bolt.Tx, a, b net.IP) bool {
+	bname := []byte("device_pairs")
+	bucket := tx.Bucket(bname)
+	if bucket == nil {
+		return false
+	}
+
+	return bucket.Get(devicePairKey(a, b)) != nil
+}
+
+// Store to database.
+func (p *DevicePair) Store(tx *bolt.Tx) error {
+	if !tx.Writable() {
+		return errors.New("tx not writable")
+	}
+
+	bname := []byte("device_pairs")
+	bucket, err := tx.CreateBucketIfNotExists(bname)
+	if err != nil {
+		return err
+	}
+
+	key := devicePairKey(p.A, p.B)
+	value, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put(key, value)
+}
+
+// RemoveDevicePair from database
+func RemoveDevicePair(tx *bolt.Tx, a, b net.IP) error {
+	if !tx.Writable() {
+		return errors.New("tx not writable")
+	}
+
+	bname := []byte("device_pairs")
+	bucket := tx.Bucket(bname)
+	if bucket == nil {
+		return errors.New("not found")
+	}
+
+	return bucket.Delete(devicePairKey(a, b))
+}
+
+// RemoveDevicePairs removes pairs of the device.
+func RemoveDevicePairs(tx *bolt.Tx, ip net.IP) error {
+	pairs, err := LoadDevicePairs(tx)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range pairs.Device(ip) {
+		err = RemoveDevicePair(tx, p.A, p.B)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DevicePairs type
+type DevicePairs []DevicePair
+
+// LoadDevicePairs returns all device pairs from database
+// sorted by the addresses.
+func LoadDevicePairs(tx *bolt.Tx) (DevicePairs, error) {
+	bname := []byte("device_pairs")
+	bucket := tx.Bucket(bname)
+	if bucket == nil {
+		return nil, nil
+	}
+
+	cnt := bucket.Stats().KeyN
+	pairs := make(DevicePairs, 0, cnt)
+
+	c := bucket.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		p := DevicePair{}
+		err := json.Unmarshal(v, &p)
+		if err != nil {
+			return nil, err
+		}
+
+		pairs = append(pairs, p)
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		v := bytes.Compare(pairs[i].A.To4(), pairs[j].A.To4())
+		if v != 0 {
+			return v < 0
+		}
+		return bytes.Compare(pairs[i].B.To4(), pairs[j].B.To4()) < 0
+	})
+
+	return pairs, nil
+}
+
+// Device returns pairs of the device.
+func (s DevicePairs) Device(ip net.IP) DevicePairs {
+	pairs := make(DevicePairs, 0)
+	for i := range s {
+		if s[i].Has(ip) {
+			pairs = append(pairs, s[i])
+		}
+	}
+
+	return pairs
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestDevicePair(t *testing.T) {
+	dbpath := "test.db"
+	db, err := bolt.Open(
+		dbpath, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+		return
+	}
+	defer db.Close()
+
+	bname := []byte("device_pairs")
+	err = deleteBucket(db, bname)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	tx, err := db.Begin(true) // writeable tx
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer tx.Rollback()
+
+	ip := net.IPv4(172, 16, 0, 2)
+	pairs := []DevicePair{
+		NewDevicePair(net.IPv4(172, 16, 0, 5), ip),
+		NewDevicePair(ip, net.IPv4(172, 16, 0, 3)),
+		NewDevicePair(net.IPv4(172, 16, 0, 3), net.IPv4(172, 16, 0, 4)),
+	}
+	for _, p := range pairs {
+		err = p.Validate()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		err = p.Store(tx)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	if !IsDevicePairExists(tx, net.IPv4(172, 16, 0, 3), ip) {
+		t.Errorf("pair expected to exist in any order")
+	}
+
+	loaded, err := LoadDevicePairs(tx)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(loaded) != 3 || !loaded[0].B.Equal(net.IPv4(172, 16, 0, 3)) {
+		t.Errorf("unexpected device pairs: %v", loaded)
+		return
+	}
+	if len(loaded.Device(ip)) != 2 {
+		t.Errorf("expected 2 pairs of the device")
+	}
+
+	err = RemoveDevice(tx, ip.To4())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	loaded, err = LoadDevicePairs(tx)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(loaded) != 1 || loaded[0].Has(ip) {
+		t.Errorf("expected pairs of the device removed: %v", loaded)
+	}
+
+	p := NewDevicePair(ip, ip)
+	if p.Validate() == nil {
+		t.Errorf("device paired with itself should fail")
+	}
+}
@@ -259,6 +259,144 @@ func TestDevice(t *testing.T) {
 	tx.Rollback()
 }
 
+func TestCheckRoutes(t *testing.T) {
+	dbpath := "test.db"
+	db, err := bolt.Open(
+		dbpath, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+		return
+	}
+	defer db.Close()
+
+	bname := []byte("devices")
+	err = deleteBucket(db, bname)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, ipnet, err := net.ParseCIDR("172.16.0.0/24")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, ipnet2, err := net.ParseCIDR("172.17.0.0/24")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sk, err := wgtypes.GeneratePrivateKey()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	d, err := createDevice(db, ipnet, sk.PublicKey(), "router1", "")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, route, _ := net.ParseCIDR("192.168.10.0/24")
+	d.Routes = []*net.IPNet{route}
+	d, err = storeDevice(db, d)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, hostnet, _ := net.ParseCIDR("192.0.2.0/24")
+	_, hostroute, _ := net.ParseCIDR("10.10.0.0/16")
+	host := []*net.IPNet{hostnet, hostroute}
+
+	cases := []struct {
+		name   string
+		ip     net.IP
+		routes []string
+		ok     bool
+	}{
+		{"own routes", d.IPNetwork.IP, []string{"192.168.10.0/24"}, true},
+		{"other device", net.IPv4(172, 16, 0, 9).To4(), []string{"192.168.11.0/24"}, true},
+		{"overlap other device", net.IPv4(172, 16, 0, 9).To4(), []string{"192.168.0.0/16"}, false},
+		{"overlap wg network", d.IPNetwork.IP, []string{"172.16.0.128/25"}, false},
+		{"overlap other wg network", d.IPNetwork.IP, []string{"172.17.0.0/16"}, false},
+		{"default route", d.IPNetwork.IP, []string{"0.0.0.0/0"}, false},
+		{"overlap host network", d.IPNetwork.IP, []string{"10.10.0.0/24"}, false},
+		{"within host network", d.IPNetwork.IP, []string{"192.0.2.128/25"}, false},
+		{"overlap each other", d.IPNetwork.IP, []string{"10.0.0.0/8", "10.1.0.0/16"}, false},
+	}
+
+	tx, err := db.Begin(false) // non-writeable tx
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer tx.Rollback()
+
+	for _, c := range cases {
+		routes := make([]*net.IPNet, len(c.routes))
+		for i := range c.routes {
+			_, routes[i], _ = net.ParseCIDR(c.routes[i])
+		}
+
+		err := CheckRoutes(
+			tx, c.ip, []*net.IPNet{ipnet, ipnet2}, host, routes)
+		if (err == nil) != c.ok {
+			t.Errorf("%s: unexpected result %v", c.name, err)
+		}
+	}
+}
+
+func TestAllocateIPNetworks(t *testing.T) {
+	dbpath := "test.db"
+	db, err := bolt.Open(
+		dbpath, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+		return
+	}
+	defer db.Close()
+
+	bname := []byte("devices")
+	err = deleteBucket(db, bname)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	ipnets := make([]*net.IPNet, 2)
+	for i, cidr := range []string{"172.16.0.1/29", "172.17.0.1/29"} {
+		ip, ipnet, err := ipcalc.ParseCIDR(cidr)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		ipnets[i] = &net.IPNet{IP: ip, Mask: ipnet.Mask}
+	}
+
+	expected := []string{
+		"172.16.0.2", "172.17.0.2", "172.16.0.3", "172.17.0.3",
+	}
+	for i := range expected {
+		sk, err := wgtypes.GeneratePrivateKey()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		d, err := createDevice(db, ipnets[i%2], sk.PublicKey(), "device", "")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if d.IPNetwork.IP.String() != expected[i] {
+			t.Errorf("expected %s, got %s", expected[i], d.IPNetwork.IP)
+		}
+	}
+}
+
 func createDevice(
 	db *bolt.DB,
 	ipnet *net.IPNet,
@@ -343,3 +481,66 @@ func removeDevice(db *bolt.DB, ip net.IP) error {
 
 	return nil
 }
+
+func TestDeviceCIDR6(t *testing.T) {
+	_, ipnet, _ := net.ParseCIDR("172.16.0.0/24")
+	_, ipnet6, _ := net.ParseCIDR("fd00:16::/120")
+
+	d := &Device{IPNetwork: IPNetwork{
+		IP:  net.IPv4(172, 16, 0, 9).To4(),
+		Net: ipnet}}
+
+	if v := d.CIDR6(nil); v != nil {
+		t.Errorf("unexpected cidr6 %s", v)
+	}
+
+	v := d.CIDR6(ipnet6)
+	if v == nil || v.String() != "fd00:16::9/128" {
+		t.Errorf("unexpected cidr6 %s", v)
+	}
+
+	d.WANForward = true
+	ipnets := d.AllowedIPs(ipnet6, nil)
+	if len(ipnets) != 2 || ipnets[1].String() != "::/0" {
+		t.Errorf("unexpected allowed ips %v", ipnets)
+	}
+
+	d.WANForward = false
+	ipnets = d.AllowedIPs(ipnet6, nil)
+	if len(ipnets) != 2 || ipnets[1].String() != ipnet6.String() {
+		t.Errorf("unexpected allowed ips %v", ipnets)
+	}
+}
+
+func TestDevicesActive(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	devices := Devices{
+		{Label: "laptop"},
+		{Label: "phone", Disabled: true},
+		{Label: "router", ExpiresAt: &future},
+		{Label: "guest", ExpiresAt: &past},
+	}
+
+	active := devices.Active(now)
+	if len(active) != 2 || active[0].Label != "laptop" || active[1].Label != "router" {
+		t.Errorf("unexpected active devices %v", active)
+	}
+
+	inactive := devices.Inactive(now)
+	if len(inactive) != 2 || inactive[0].Label != "phone" || inactive[1].Label != "guest" {
+		t.Errorf("unexpected inactive devices %v", inactive)
+	}
+
+	expired := devices.ExpiredBefore(now.Add(-time.Minute))
+	if len(expired) != 1 || expired[0].Label != "guest" {
+		t.Errorf("unexpected expired devices %v", expired)
+	}
+
+	expired = devices.ExpiredBefore(now.Add(-2 * time.Hour))
+	if len(expired) != 0 {
+		t.Errorf("unexpected expired devices %v", expired)
+	}
+}
@@ -14,6 +14,7 @@ import (
 type Domain struct {
 	Name  string       `json:"name"`
 	A     []ARecord    `json:"a,omitempty"`
+	AAAA  []AAAARecord `json:"aaaa,omitempty"`
 	CNAME *CNAMERecord `json:"cname,omitempty"`
 }
 
@@ -92,10 +93,58 @@ func (d *Domain) RemoveA(ip net.IP) {
 	d.sortA()
 }
 
+// SetAAAA record.
+func (d *Domain) SetAAAA(r AAAARecord) {
+	if r.AAAA.To4() != nil || r.AAAA.To16() == nil {
+		return
+	}
+	ip := r.AAAA.To16()
+	r.AAAA = ip
+
+	if d.AAAA == nil {
+		d.AAAA = make([]AAAARecord, 0, 1)
+	}
+
+	i, found := d.isAAAAExists(ip)
+	if found {
+		d.AAAA[i] = r
+		return
+	}
+
+	d.AAAA = append(d.AAAA, r)
+	d.sortAAAA()
+
+	d.CNAME = nil
+}
+
+// RemoveAAAA record.
+func (d *Domain) RemoveAAAA(ip net.IP) {
+	if ip.To4() != nil || ip.To16() == nil {
+		return
+	}
+	ip = ip.To16()
+
+	i, found := d.isAAAAExists(ip)
+	if !found {
+		return
+	}
+
+	if len(d.AAAA) == 1 {
+		d.AAAA = nil
+		return
+	}
+
+	d.AAAA[i] = d.AAAA[len(d.AAAA)-1]
+	d.AAAA[len(d.AAAA)-1] = AAAARecord{}
+	d.AAAA = d.AAAA[:len(d.AAAA)-1]
+	d.sortAAAA()
+}
+
 // SetCNAME record.
 func (d *Domain) SetCNAME(r CNAMERecord) {
 	d.CNAME = &r
 	d.A = nil
+	d.AAAA = nil
 }
 
 // RemoveCNAME record.
@@ -138,6 +187,20 @@ func (d *Domain) isAExists(ip net.IP) (int, bool) {
 	return i, found
 }
 
+func (d *Domain) sortAAAA() {
+	sort.Slice(d.AAAA, func(i, j int) bool {
+		return bytes.Compare(d.AAAA[i].AAAA, d.AAAA[j].AAAA) < 0
+	})
+}
+
+func (d *Domain) isAAAAExists(ip net.IP) (int, bool) {
+	i, found := sort.Find(len(d.AAAA), func(i int) int {
+		return bytes.Compare(ip, d.AAAA[i].AAAA)
+	})
+
+	return i, found
+}
+
 // RemoveDomain from database
 func RemoveDomain(tx *bolt.Tx, name string) error {
 	if !tx.Writable() {
@@ -208,6 +271,12 @@ type ARecord struct {
 	A   net.IP `json:"a"`
 }
 
+// AAAARecord model.
+type AAAARecord struct {
+	TTL  uint32 `json:"ttl"`
+	AAAA net.IP `json:"aaaa"`
+}
+
 // CNAMERecord model.
 type CNAMERecord struct {
 	TTL    uint32 `json:"ttl"`
@@ -43,6 +43,31 @@ func TestDomain(t *testing.T) {
 	}
 }
 
+func TestDomainAAAA(t *testing.T) {
+	d := NewDomain("chat.wgnetwork.")
+
+	d.SetAAAA(AAAARecord{TTL: 30, AAAA: net.ParseIP("fd00::10")})
+	d.SetAAAA(AAAARecord{TTL: 30, AAAA: net.ParseIP("fd00::2")})
+	d.SetAAAA(AAAARecord{TTL: 30, AAAA: net.IPv4(172, 16, 0, 10)})
+	if len(d.AAAA) != 2 {
+		t.Errorf("expected 2 records, got %d", len(d.AAAA))
+		return
+	}
+	if !d.AAAA[0].AAAA.Equal(net.ParseIP("fd00::2")) {
+		t.Errorf("expected sorted records, got %s first", d.AAAA[0].AAAA)
+	}
+
+	d.RemoveAAAA(net.ParseIP("fd00::2"))
+	if len(d.AAAA) != 1 || !d.AAAA[0].AAAA.Equal(net.ParseIP("fd00::10")) {
+		t.Errorf("unexpected records after remove: %v", d.AAAA)
+	}
+
+	d.SetCNAME(CNAMERecord{TTL: 30, Target: "www.wgnetwork."})
+	if d.AAAA != nil {
+		t.Errorf("expected records to be cleared by cname")
+	}
+}
+
 func createDomain(db *bolt.DB, name string) (Domain, error) {
 	tx, err := db.Begin(true) // writeable tx
 	if err != nil {
@@ -0,0 +1,182 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Actions of the egress rule.
+const (
+	// EgressActionBlock drops connections matching the rule.
+	EgressActionBlock = "block"
+	// EgressActionAllow allows connections matching the rule,
+	// any allow rule turns the policy into allow-only list.
+	EgressActionAllow = "allow"
+)
+
+// EgressRule model, matches connections forwarded to wan
+// by the destination; empty ports or nets list matches any.
+type EgressRule struct {
+	Action  string   `json:"action"`
+	IPProto string   `json:"ipproto"`
+	DPorts  []uint16 `json:"dports"`
+	DNets   []string `json:"dnets"`
+}
+
+// Validate rule.
+func (r *EgressRule) Validate() error {
+	switch r.Action {
+	case EgressActionBlock, EgressActionAllow:
+	default:
+		return fmt.Errorf("unknown action %q", r.Action)
+	}
+
+	switch r.IPProto {
+	case "tcp", "udp":
+	case "":
+		if len(r.DPorts) > 0 {
+			return errors.New("ports require tcp or udp ipproto")
+		}
+	default:
+		return fmt.Errorf("unknown ipproto %q", r.IPProto)
+	}
+
+	if r.IPProto == "" && len(r.DNets) == 0 {
+		return errors.New("rule should match ipproto or dnets")
+	}
+
+	for _, v := range r.DNets {
+		if _, err := ParseEgressNet(v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// IPNets returns parsed destination nets of the rule.
+func (r *EgressRule) IPNets() []net.IPNet {
+	ipnets := make([]net.IPNet, 0, len(r.DNets))
+	for _, v := range r.DNets {
+		ipnet, err := ParseEgressNet(v)
+		if err != nil {
+			continue
+		}
+		ipnets = append(ipnets, ipnet)
+	}
+
+	return ipnets
+}
+
+// ParseEgressNet parses ipv4 or ipv6 address or cidr range,
+// the address is treated as a single address range.
+func ParseEgressNet(v string) (net.IPNet, error) {
+	if ip := net.ParseIP(v); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			return net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+		}
+		return net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+	}
+
+	ip, ipnet, err := net.ParseCIDR(v)
+	if err != nil {
+		return net.IPNet{}, fmt.Errorf("bad address or cidr %q", v)
+	}
+	if !ip.Equal(ipnet.IP) {
+		return net.IPNet{}, fmt.Errorf("host bits are set in %q", v)
+	}
+
+	return *ipnet, nil
+}
+
+// EgressRules list.
+type EgressRules []EgressRule
+
+// DefaultEgressRules blocks outgoing smtp connections,
+// it's the policy used until the rules are stored.
+func DefaultEgressRules() EgressRules {
+	return EgressRules{
+		{
+			Action:  EgressActionBlock,
+			IPProto: "tcp",
+			DPorts:  []uint16{25},
+		},
+	}
+}
+
+// Add rule to the list, the nets are stored in cidr notation.
+func (s EgressRules) Add(r EgressRule) EgressRules {
+	dnets := make([]string, 0, len(r.DNets))
+	for _, ipnet := range r.IPNets() {
+		dnets = append(dnets, ipnet.String())
+	}
+	r.DNets = dnets
+
+	return append(s, r)
+}
+
+// Remove rule by index from the list.
+func (s EgressRules) Remove(idx int) (EgressRules, error) {
+	if idx < 0 || idx >= len(s) {
+		return s, errors.New("not found")
+	}
+
+	rules := make(EgressRules, 0, len(s)-1)
+	rules = append(rules, s[:idx]...)
+	rules = append(rules, s[idx+1:]...)
+
+	return rules, nil
+}
+
+// Store global egress rules.
+func (s EgressRules) Store(tx *bolt.Tx) error {
+	if !tx.Writable() {
+		return errors.New("tx not writable")
+	}
+
+	bname := []byte("managers")
+	bucket, err := tx.CreateBucketIfNotExists(bname)
+	if err != nil {
+		return err
+	}
+
+	if s == nil {
+		s = EgressRules{}
+	}
+
+	key := []byte("egress_rules")
+	value, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put(key, value)
+}
+
+// LoadEgressRules returns global egress rules from database,
+// the default rules are returned if nothing is stored yet.
+func LoadEgressRules(tx *bolt.Tx) (EgressRules, error) {
+	bname := []byte("managers")
+	bucket := tx.Bucket(bname)
+	if bucket == nil {
+		return DefaultEgressRules(), nil
+	}
+
+	key := []byte("egress_rules")
+	v := bucket.Get(key)
+	if v == nil {
+		return DefaultEgressRules(), nil
+	}
+
+	rules := EgressRules{}
+	err := json.Unmarshal(v, &rules)
+	if err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
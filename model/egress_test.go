@@ -0,0 +1,104 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestEgressRuleValidate(t *testing.T) {
+	cases := []struct {
+		name string
+		rule EgressRule
+		ok   bool
+	}{
+		{"block smtp", EgressRule{Action: "block", IPProto: "tcp", DPorts: []uint16{25}}, true},
+		{"allow net", EgressRule{Action: "allow", DNets: []string{"10.0.0.0/8"}}, true},
+		{"allow host6", EgressRule{Action: "allow", IPProto: "udp", DNets: []string{"2001:db8::1"}}, true},
+		{"unknown action", EgressRule{Action: "drop", IPProto: "tcp"}, false},
+		{"unknown proto", EgressRule{Action: "block", IPProto: "icmp"}, false},
+		{"ports without proto", EgressRule{Action: "block", DPorts: []uint16{25}}, false},
+		{"match any", EgressRule{Action: "block"}, false},
+		{"bad net", EgressRule{Action: "block", DNets: []string{"10.0.0"}}, false},
+		{"host bits", EgressRule{Action: "block", DNets: []string{"10.0.0.1/8"}}, false},
+	}
+
+	for _, c := range cases {
+		err := c.rule.Validate()
+		if (err == nil) != c.ok {
+			t.Errorf("%s: unexpected result %v", c.name, err)
+		}
+	}
+}
+
+func TestEgressRules(t *testing.T) {
+	dbpath := "test.db"
+	db, err := bolt.Open(
+		dbpath, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+		return
+	}
+	defer db.Close()
+
+	bname := []byte("managers")
+	err = deleteBucket(db, bname)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	tx, err := db.Begin(true) // writeable tx
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer tx.Rollback()
+
+	rules, err := LoadEgressRules(tx)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(rules) != 1 || rules[0].DPorts[0] != 25 {
+		t.Errorf("default rules expected: %v", rules)
+		return
+	}
+
+	rules, err = rules.Remove(0)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = rules.Store(tx)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	rules, err = LoadEgressRules(tx)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(rules) != 0 {
+		t.Errorf("stored empty rules expected: %v", rules)
+		return
+	}
+
+	rules = rules.Add(EgressRule{
+		Action: "allow",
+		DNets:  []string{"192.168.1.1", "2001:db8::/32"},
+	})
+	if rules[0].DNets[0] != "192.168.1.1/32" {
+		t.Errorf("net expected to be normalized: %v", rules[0].DNets)
+	}
+	if ipnets := rules[0].IPNets(); len(ipnets) != 2 {
+		t.Errorf("unexpected nets: %v", ipnets)
+	}
+
+	_, err = rules.Remove(1)
+	if err == nil {
+		t.Errorf("out of range index should fail")
+	}
+}
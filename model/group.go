@@ -0,0 +1,185 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var groupNameRe = regexp.MustCompile(`^[a-z0-9_-]{1,16}$`)
+
+// Group model, devices are tagged into the group by its name.
+type Group struct {
+	Name string `json:"name"`
+
+	// Policies allow connections from the devices of the group.
+	Policies GroupPolicies `json:"policies,omitempty"`
+}
+
+// NewGroup constructor.
+func NewGroup(name string) Group {
+	g := Group{Name: name}
+	return g
+}
+
+// ValidateGroupName reports whether the name is suitable for the group,
+// it's used in nftables set names so it's kept short.
+func ValidateGroupName(name string) error {
+	if !groupNameRe.MatchString(name) {
+		return errors.New(
+			"up to 16 lowercase letters, digits, '-' or '_' expected")
+	}
+
+	return nil
+}
+
+// LoadGroup constructor
+func LoadGroup(tx *bolt.Tx, name string) (Group, error) {
+	bname := []byte("groups")
+	bucket := tx.Bucket(bname)
+	if bucket == nil {
+		return Group{}, errors.New("not found")
+	}
+
+	key := []byte(name)
+	v := bucket.Get(key)
+	if v == nil {
+		return Group{}, errors.New("not found")
+	}
+
+	g := Group{}
+	err := json.Unmarshal(v, &g)
+	if err != nil {
+		return Group{}, err
+	}
+
+	return g, nil
+}
+
+// RemovePolicies targeting the group.
+func (g *Group) RemovePolicies(to string) {
+	policies := make(GroupPolicies, 0, len(g.Policies))
+	for _, p := range g.Policies {
+		if p.To != to {
+			policies = append(policies, p)
+		}
+	}
+	g.Policies = policies
+}
+
+// Store to database.
+func (g *Group) Store(tx *bolt.Tx) error {
+	if !tx.Writable() {
+		return errors.New("tx not writable")
+	}
+
+	bname := []byte("groups")
+	bucket, err := tx.CreateBucketIfNotExists(bname)
+	if err != nil {
+		return err
+	}
+
+	key := []byte(g.Name)
+	value, err := json.Marshal(g)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put(key, value)
+}
+
+// RemoveGroup from database
+func RemoveGroup(tx *bolt.Tx, name string) error {
+	if !tx.Writable() {
+		return errors.New("tx not writable")
+	}
+
+	bname := []byte("groups")
+	bucket := tx.Bucket(bname)
+	if bucket == nil {
+		return errors.New("not found")
+	}
+
+	key := []byte(name)
+	return bucket.Delete(key)
+}
+
+// Groups type
+type Groups []Group
+
+// LoadGroups returns all groups from database sorted by name.
+func LoadGroups(tx *bolt.Tx) (Groups, error) {
+	bname := []byte("groups")
+	bucket := tx.Bucket(bname)
+	if bucket == nil {
+		return nil, nil
+	}
+
+	cnt := bucket.Stats().KeyN
+	groups := make(Groups, 0, cnt)
+
+	c := bucket.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		g := Group{}
+		err := json.Unmarshal(v, &g)
+		if err != nil {
+			return nil, err
+		}
+
+		groups = append(groups, g)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+
+	return groups, nil
+}
+
+// GroupPolicy model, allows new connections to the devices
+// of the target group.
+type GroupPolicy struct {
+	To      string   `json:"to"`
+	IPProto string   `json:"ipproto"`
+	DPorts  []uint16 `json:"dports"`
+}
+
+// Validate policy.
+func (p *GroupPolicy) Validate() error {
+	err := ValidateGroupName(p.To)
+	if err != nil {
+		return fmt.Errorf("bad target group: %v", err)
+	}
+
+	switch p.IPProto {
+	case "tcp", "udp":
+	case "", "icmp":
+		if len(p.DPorts) > 0 {
+			return errors.New("ports require tcp or udp ipproto")
+		}
+	default:
+		return fmt.Errorf("unknown ipproto %q", p.IPProto)
+	}
+
+	return nil
+}
+
+// GroupPolicies list.
+type GroupPolicies []GroupPolicy
+
+// Remove policy by index from the list.
+func (s GroupPolicies) Remove(idx int) (GroupPolicies, error) {
+	if idx < 0 || idx >= len(s) {
+		return s, errors.New("not found")
+	}
+
+	policies := make(GroupPolicies, 0, len(s)-1)
+	policies = append(policies, s[:idx]...)
+	policies = append(policies, s[idx+1:]...)
+
+	return policies, nil
+}
@@ -0,0 +1,118 @@
+package model
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestGroup(t *testing.T) {
+	dbpath := "test.db"
+	db, err := bolt.Open(
+		dbpath, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+		return
+	}
+	defer db.Close()
+
+	bname := []byte("groups")
+	err = deleteBucket(db, bname)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	tx, err := db.Begin(true) // writeable tx
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer tx.Rollback()
+
+	for _, name := range []string{"staging", "developers"} {
+		g := NewGroup(name)
+		if name == "developers" {
+			g.Policies = GroupPolicies{
+				{To: "staging", IPProto: "tcp", DPorts: []uint16{22, 443}},
+			}
+		}
+		err = g.Store(tx)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	groups, err := LoadGroups(tx)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(groups) != 2 || groups[0].Name != "developers" {
+		t.Errorf("unexpected groups: %v", groups)
+		return
+	}
+	if len(groups[0].Policies) != 1 {
+		t.Errorf("expected 1 policy, got %d", len(groups[0].Policies))
+	}
+
+	groups[0].RemovePolicies("staging")
+	if len(groups[0].Policies) != 0 {
+		t.Errorf("expected policies to be removed")
+	}
+
+	err = RemoveGroup(tx, "staging")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, err = LoadGroup(tx, "staging")
+	if err == nil {
+		t.Errorf("expected removed group not found")
+	}
+}
+
+func TestGroupPolicyValidate(t *testing.T) {
+	tests := []struct {
+		policy GroupPolicy
+		ok     bool
+	}{
+		{GroupPolicy{To: "staging"}, true},
+		{GroupPolicy{To: "git", IPProto: "tcp", DPorts: []uint16{22}}, true},
+		{GroupPolicy{To: "Staging"}, false},
+		{GroupPolicy{To: ""}, false},
+		{GroupPolicy{To: "staging", IPProto: "icmp", DPorts: []uint16{1}}, false},
+		{GroupPolicy{To: "staging", IPProto: "sctp"}, false},
+	}
+
+	for i, tc := range tests {
+		err := tc.policy.Validate()
+		if (err == nil) != tc.ok {
+			t.Errorf("case %d: unexpected result: %v", i, err)
+		}
+	}
+}
+
+func TestDeviceGroups(t *testing.T) {
+	d := Device{IPNetwork: IPNetwork{IP: net.IPv4(172, 16, 0, 2).To4()}}
+	d.AddGroup("staging")
+	d.AddGroup("developers")
+	d.AddGroup("staging")
+	if len(d.Groups) != 2 || d.Groups[0] != "developers" {
+		t.Errorf("unexpected groups: %v", d.Groups)
+	}
+
+	devices := Devices{d, {}}
+	if len(devices.Group("staging")) != 1 {
+		t.Errorf("expected 1 device in the group")
+	}
+
+	d.RemoveGroup("developers")
+	d.RemoveGroup("staging")
+	if d.Groups != nil {
+		t.Errorf("expected no groups, got %v", d.Groups)
+	}
+}
@@ -4,69 +4,211 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net"
 	"sort"
+	"time"
 
 	bolt "go.etcd.io/bbolt"
 )
 
-// ManagerSSHTrustIPSet model.
-type ManagerSSHTrustIPSet []net.IP
+// ManagerSSHTrustIP is an entry of the trusted ipset,
+// a single address or a cidr range.
+type ManagerSSHTrustIP struct {
+	IPNet net.IPNet `json:"-"`
 
-func (ips *ManagerSSHTrustIPSet) Add(ip net.IP) {
-	if ips == nil {
-		return
+	// Comment describes whom the access is granted to.
+	Comment string `json:"comment,omitempty"`
+
+	// ExpiresAt is the optional time the access is revoked at.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// NewManagerSSHTrustIP parses the address or the cidr range.
+func NewManagerSSHTrustIP(
+	v string,
+	comment string,
+	expiresAt *time.Time,
+) (ManagerSSHTrustIP, error) {
+	ipnet, err := ParseTrustIPNet(v)
+	if err != nil {
+		return ManagerSSHTrustIP{}, err
+	}
+
+	e := ManagerSSHTrustIP{
+		IPNet:     ipnet,
+		Comment:   comment,
+		ExpiresAt: expiresAt,
+	}
+
+	return e, nil
+}
+
+// ParseTrustIPNet parses ipv4 address or cidr range,
+// the address is treated as /32 range.
+func ParseTrustIPNet(v string) (net.IPNet, error) {
+	if ip := net.ParseIP(v).To4(); ip != nil {
+		return net.IPNet{IP: ip, Mask: net.CIDRMask(32, 32)}, nil
+	}
+
+	ip, ipnet, err := net.ParseCIDR(v)
+	if err != nil || ip.To4() == nil {
+		return net.IPNet{}, fmt.Errorf("bad ipv4 address or cidr %q", v)
+	}
+	if !ip.Equal(ipnet.IP) {
+		return net.IPNet{}, fmt.Errorf("host bits are set in %q", v)
+	}
+
+	return net.IPNet{IP: ipnet.IP.To4(), Mask: ipnet.Mask}, nil
+}
+
+// String returns the address of single address entry,
+// the cidr notation otherwise.
+func (e ManagerSSHTrustIP) String() string {
+	if ones, _ := e.IPNet.Mask.Size(); ones == 32 {
+		return e.IPNet.IP.String()
+	}
+
+	return e.IPNet.String()
+}
+
+// Expired reports whether the entry is expired at the time.
+func (e ManagerSSHTrustIP) Expired(now time.Time) bool {
+	return e.ExpiresAt != nil && !now.Before(*e.ExpiresAt)
+}
+
+// Overlaps reports whether the ranges of the entries intersect.
+func (e ManagerSSHTrustIP) Overlaps(o ManagerSSHTrustIP) bool {
+	return e.IPNet.Contains(o.IPNet.IP) || o.IPNet.Contains(e.IPNet.IP)
+}
+
+// MarshalJSON encodes the range in cidr notation.
+func (e ManagerSSHTrustIP) MarshalJSON() ([]byte, error) {
+	type entry ManagerSSHTrustIP
+	v := struct {
+		Net string `json:"net"`
+		entry
+	}{
+		Net:   e.IPNet.String(),
+		entry: entry(e),
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes the entry, the plain address of the previous
+// format is accepted as well.
+func (e *ManagerSSHTrustIP) UnmarshalJSON(b []byte) error {
+	var ip net.IP
+	if err := json.Unmarshal(b, &ip); err == nil {
+		ipnet, err := ParseTrustIPNet(ip.String())
+		if err != nil {
+			return err
+		}
+		*e = ManagerSSHTrustIP{IPNet: ipnet}
+		return nil
+	}
+
+	type entry ManagerSSHTrustIP
+	v := struct {
+		Net string `json:"net"`
+		*entry
+	}{
+		entry: (*entry)(e),
+	}
+	err := json.Unmarshal(b, &v)
+	if err != nil {
+		return err
 	}
 
-	ip = ip.To4()
-	if ip == nil {
+	e.IPNet, err = ParseTrustIPNet(v.Net)
+	return err
+}
+
+// ManagerSSHTrustIPSet model.
+type ManagerSSHTrustIPSet []ManagerSSHTrustIP
+
+// Add the entry, the entry of the same range is replaced.
+func (ips *ManagerSSHTrustIPSet) Add(e ManagerSSHTrustIP) {
+	if ips == nil {
 		return
 	}
 
-	_, found := ips.isExists(ip)
+	i, found := ips.isExists(e.IPNet)
 	if found {
+		(*ips)[i] = e
 		return
 	}
 
-	*ips = append(*ips, ip)
+	*ips = append(*ips, e)
 	ips.sort()
 }
 
-func (ips *ManagerSSHTrustIPSet) Remove(ip net.IP) {
+// Remove the entry of the range.
+func (ips *ManagerSSHTrustIPSet) Remove(ipnet net.IPNet) {
 	if ips == nil {
 		return
 	}
 
-	ip = ip.To4()
-	if ip == nil {
+	i, found := ips.isExists(ipnet)
+	if !found {
 		return
 	}
 
-	i, found := ips.isExists(ip)
-	if !found {
-		return
+	*ips = append((*ips)[:i], (*ips)[i+1:]...)
+}
+
+// Overlapped returns the entry of another range intersecting the entry.
+func (ips ManagerSSHTrustIPSet) Overlapped(
+	e ManagerSSHTrustIP,
+) (ManagerSSHTrustIP, bool) {
+	for _, v := range ips {
+		if v.IPNet.String() == e.IPNet.String() {
+			continue
+		}
+		if v.Overlaps(e) {
+			return v, true
+		}
 	}
 
-	(*ips)[i] = (*ips)[len(*ips)-1]
-	(*ips)[len(*ips)-1] = nil
-	(*ips) = (*ips)[:len(*ips)-1]
-	ips.sort()
+	return ManagerSSHTrustIP{}, false
+}
+
+// Active returns the entries not expired at the time.
+func (ips ManagerSSHTrustIPSet) Active(now time.Time) ManagerSSHTrustIPSet {
+	result := make(ManagerSSHTrustIPSet, 0, len(ips))
+	for i := range ips {
+		if !ips[i].Expired(now) {
+			result = append(result, ips[i])
+		}
+	}
+
+	return result
 }
 
 func (ips ManagerSSHTrustIPSet) sort() {
 	sort.Slice(ips, func(i, j int) bool {
-		return bytes.Compare(ips[i], ips[j]) < 0
+		return compareIPNet(ips[i].IPNet, ips[j].IPNet) < 0
 	})
 }
 
-func (ips ManagerSSHTrustIPSet) isExists(ip net.IP) (int, bool) {
+func (ips ManagerSSHTrustIPSet) isExists(ipnet net.IPNet) (int, bool) {
 	i, found := sort.Find(len(ips), func(i int) int {
-		return bytes.Compare(ip, ips[i])
+		return compareIPNet(ipnet, ips[i].IPNet)
 	})
 
 	return i, found
 }
 
+func compareIPNet(a, b net.IPNet) int {
+	v := bytes.Compare(a.IP.To4(), b.IP.To4())
+	if v != 0 {
+		return v
+	}
+
+	return bytes.Compare(a.Mask, b.Mask)
+}
+
 func (ips ManagerSSHTrustIPSet) Store(tx *bolt.Tx) error {
 	if !tx.Writable() {
 		return errors.New("tx not writable")
@@ -87,7 +229,7 @@ func (ips ManagerSSHTrustIPSet) Store(tx *bolt.Tx) error {
 	return bucket.Put(key, value)
 }
 
-// LoadManagerSSHTrustIPSet returns all ips from database.
+// LoadManagerSSHTrustIPSet returns all entries from database.
 func LoadManagerSSHTrustIPSet(tx *bolt.Tx) (ManagerSSHTrustIPSet, error) {
 	bname := []byte("managers")
 	bucket := tx.Bucket(bname)
@@ -101,16 +243,12 @@ func LoadManagerSSHTrustIPSet(tx *bolt.Tx) (ManagerSSHTrustIPSet, error) {
 		return ManagerSSHTrustIPSet{}, nil
 	}
 
-	ips := []net.IP{}
-	err := json.Unmarshal(v, &ips)
+	ipset := ManagerSSHTrustIPSet{}
+	err := json.Unmarshal(v, &ipset)
 	if err != nil {
 		return nil, err
 	}
-
-	ipset := make(ManagerSSHTrustIPSet, len(ips))
-	for i := range ips {
-		ipset[i] = ips[i].To4()
-	}
+	ipset.sort()
 
 	return ipset, nil
 }
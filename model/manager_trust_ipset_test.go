@@ -0,0 +1,121 @@
+package model
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestManagerSSHTrustIPSet(t *testing.T) {
+	dbpath := "test.db"
+	db, err := bolt.Open(
+		dbpath, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+		return
+	}
+	defer db.Close()
+
+	bname := []byte("managers")
+	err = deleteBucket(db, bname)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	tx, err := db.Begin(true) // writeable tx
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer tx.Rollback()
+
+	// entries of the previous format are plain addresses
+	bucket, err := tx.CreateBucketIfNotExists(bname)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = bucket.Put([]byte("ssh_trust_ipset"), []byte(`["10.0.0.9","10.0.0.1"]`))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	ipset, err := LoadManagerSSHTrustIPSet(tx)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(ipset) != 2 || ipset[0].String() != "10.0.0.1" {
+		t.Errorf("unexpected legacy ipset: %v", ipset)
+		return
+	}
+
+	expiresAt := time.Now().Add(-time.Minute)
+	e, err := NewManagerSSHTrustIP("192.168.0.0/24", "office", &expiresAt)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	ipset.Add(e)
+
+	err = ipset.Store(tx)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	ipset, err = LoadManagerSSHTrustIPSet(tx)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(ipset) != 3 || ipset[2].String() != "192.168.0.0/24" ||
+		ipset[2].Comment != "office" || ipset[2].ExpiresAt == nil {
+		t.Errorf("unexpected ipset: %v", ipset)
+		return
+	}
+
+	active := ipset.Active(time.Now())
+	if len(active) != 2 {
+		t.Errorf("expired entry is active: %v", active)
+	}
+
+	host, _ := NewManagerSSHTrustIP("192.168.0.10", "", nil)
+	if v, ok := ipset.Overlapped(host); !ok || v.String() != "192.168.0.0/24" {
+		t.Errorf("overlapped range not found for %s", host)
+	}
+
+	ipset.Remove(e.IPNet)
+	ipset.Remove(net.IPNet{IP: net.IPv4(10, 0, 0, 1).To4(), Mask: net.CIDRMask(32, 32)})
+	if len(ipset) != 1 || ipset[0].String() != "10.0.0.9" {
+		t.Errorf("unexpected ipset after remove: %v", ipset)
+	}
+}
+
+func TestParseTrustIPNet(t *testing.T) {
+	tests := []struct {
+		v    string
+		want string
+		ok   bool
+	}{
+		{"10.0.0.1", "10.0.0.1/32", true},
+		{"10.0.0.0/8", "10.0.0.0/8", true},
+		{"10.0.0.1/8", "", false},
+		{"fd00::1", "", false},
+		{"office", "", false},
+	}
+	for _, tt := range tests {
+		ipnet, err := ParseTrustIPNet(tt.v)
+		if (err == nil) != tt.ok {
+			t.Errorf("%q: unexpected error %v", tt.v, err)
+			continue
+		}
+		if tt.ok && ipnet.String() != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.v, ipnet.String(), tt.want)
+		}
+	}
+}
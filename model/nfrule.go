@@ -0,0 +1,103 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// Directions of the rule.
+const (
+	// NFRuleDirectionOut matches connections initiated by the device.
+	NFRuleDirectionOut = "out"
+	// NFRuleDirectionIn matches connections initiated to the device.
+	NFRuleDirectionIn = "in"
+)
+
+// NFRule model, allows connections matching the rule.
+//
+// Source addresses are the remote side of "in" rules, destination
+// addresses are the remote side of "out" rules; empty list matches any.
+type NFRule struct {
+	IPProto   string   `json:"ipproto"`
+	Direction string   `json:"direction"`
+	SPorts    []uint16 `json:"sports"`
+	DPorts    []uint16 `json:"dports"`
+	SAddrs    []net.IP `json:"saddrs"`
+	DAddrs    []net.IP `json:"daddrs"`
+}
+
+// Validate rule.
+func (r *NFRule) Validate() error {
+	switch r.Direction {
+	case NFRuleDirectionOut:
+		if len(r.SAddrs) > 0 {
+			return errors.New("saddrs are not allowed for out direction")
+		}
+	case NFRuleDirectionIn:
+		if len(r.DAddrs) > 0 {
+			return errors.New("daddrs are not allowed for in direction")
+		}
+	default:
+		return fmt.Errorf("unknown direction %q", r.Direction)
+	}
+
+	switch r.IPProto {
+	case "tcp", "udp":
+	case "", "icmp":
+		if len(r.SPorts) > 0 || len(r.DPorts) > 0 {
+			return errors.New("ports require tcp or udp ipproto")
+		}
+	default:
+		return fmt.Errorf("unknown ipproto %q", r.IPProto)
+	}
+
+	for _, ip := range r.Addrs() {
+		if ip == nil || ip.IsUnspecified() {
+			return errors.New("bad address")
+		}
+	}
+
+	return nil
+}
+
+// Addrs returns remote side addresses of the rule.
+func (r *NFRule) Addrs() []net.IP {
+	if r.Direction == NFRuleDirectionIn {
+		return r.SAddrs
+	}
+
+	return r.DAddrs
+}
+
+// NFRules list.
+type NFRules []NFRule
+
+// Add rule to the list.
+func (s NFRules) Add(r NFRule) NFRules {
+	for i := range r.SAddrs {
+		if ip := r.SAddrs[i].To4(); ip != nil {
+			r.SAddrs[i] = ip
+		}
+	}
+	for i := range r.DAddrs {
+		if ip := r.DAddrs[i].To4(); ip != nil {
+			r.DAddrs[i] = ip
+		}
+	}
+
+	return append(s, r)
+}
+
+// Remove rule by index from the list.
+func (s NFRules) Remove(idx int) (NFRules, error) {
+	if idx < 0 || idx >= len(s) {
+		return s, errors.New("not found")
+	}
+
+	rules := make(NFRules, 0, len(s)-1)
+	rules = append(rules, s[:idx]...)
+	rules = append(rules, s[idx+1:]...)
+
+	return rules, nil
+}
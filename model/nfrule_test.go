@@ -0,0 +1,63 @@
+package model
+
+import (
+	"net"
+	"testing"
+)
+
+func TestNFRuleValidate(t *testing.T) {
+	cases := []struct {
+		name string
+		rule NFRule
+		ok   bool
+	}{
+		{"out any", NFRule{Direction: "out"}, true},
+		{"in tcp port", NFRule{Direction: "in", IPProto: "tcp", DPorts: []uint16{22}}, true},
+		{"out host", NFRule{Direction: "out", DAddrs: []net.IP{net.IPv4(172, 16, 0, 2)}}, true},
+		{"out icmp", NFRule{Direction: "out", IPProto: "icmp"}, true},
+		{"unknown direction", NFRule{Direction: "both"}, false},
+		{"unknown proto", NFRule{Direction: "out", IPProto: "sctp"}, false},
+		{"ports without proto", NFRule{Direction: "out", DPorts: []uint16{80}}, false},
+		{"icmp ports", NFRule{Direction: "out", IPProto: "icmp", DPorts: []uint16{80}}, false},
+		{"out saddrs", NFRule{Direction: "out", SAddrs: []net.IP{net.IPv4(172, 16, 0, 2)}}, false},
+		{"in daddrs", NFRule{Direction: "in", DAddrs: []net.IP{net.IPv4(172, 16, 0, 2)}}, false},
+		{"unspecified addr", NFRule{Direction: "out", DAddrs: []net.IP{net.IPv4zero}}, false},
+	}
+
+	for _, c := range cases {
+		err := c.rule.Validate()
+		if (err == nil) != c.ok {
+			t.Errorf("%s: unexpected result %v", c.name, err)
+		}
+	}
+}
+
+func TestNFRules(t *testing.T) {
+	var rules NFRules
+	rules = rules.Add(NFRule{Direction: "out", IPProto: "tcp", DPorts: []uint16{22}})
+	rules = rules.Add(NFRule{Direction: "out", DAddrs: []net.IP{net.ParseIP("172.16.0.2")}})
+	rules = rules.Add(NFRule{Direction: "in", IPProto: "icmp"})
+
+	if len(rules) != 3 {
+		t.Fatalf("unexpected rules count %d", len(rules))
+	}
+	if len(rules[1].DAddrs[0]) != net.IPv4len {
+		t.Errorf("ipv4 address expected to be normalized")
+	}
+
+	removed, err := rules.Remove(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 2 || removed[1].Direction != "in" {
+		t.Errorf("unexpected rules %v", removed)
+	}
+	if len(rules) != 3 {
+		t.Errorf("origin rules should be kept")
+	}
+
+	_, err = rules.Remove(3)
+	if err == nil {
+		t.Errorf("out of range index should fail")
+	}
+}
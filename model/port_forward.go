@@ -0,0 +1,194 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// PortForward model, forwards connections to the wan port
+// to the port of the device.
+type PortForward struct {
+	Proto      string `json:"proto"`
+	Port       uint16 `json:"port"`
+	IP         net.IP `json:"ip"`
+	DevicePort uint16 `json:"device_port"`
+}
+
+// NewPortForward constructor.
+func NewPortForward(
+	proto string,
+	port uint16,
+	ip net.IP,
+	devicePort uint16,
+) PortForward {
+	f := PortForward{
+		Proto:      proto,
+		Port:       port,
+		IP:         ip.To4(),
+		DevicePort: devicePort,
+	}
+
+	return f
+}
+
+// Validate port forward.
+func (f *PortForward) Validate() error {
+	switch f.Proto {
+	case "tcp", "udp":
+	default:
+		return fmt.Errorf("unknown proto %q", f.Proto)
+	}
+
+	if f.Port == 0 {
+		return errors.New("bad port")
+	}
+
+	if f.IP.To4() == nil {
+		return errors.New("bad ip")
+	}
+
+	if f.DevicePort == 0 {
+		return errors.New("bad device port")
+	}
+
+	return nil
+}
+
+func portForwardKey(proto string, port uint16) []byte {
+	return []byte(fmt.Sprintf("%s:%d", proto, port))
+}
+
+// LoadPortForward constructor
+func LoadPortForward(
+	tx *bolt.Tx,
+	proto string,
+	port uint16,
+) (PortForward, error) {
+	bname := []byte("port_forwards")
+	bucket := tx.Bucket(bname)
+	if bucket == nil {
+		return PortForward{}, errors.New("not found")
+	}
+
+	v := bucket.Get(portForwardKey(proto, port))
+	if v == nil {
+		return PortForward{}, errors.New("not found")
+	}
+
+	f := PortForward{}
+	err := json.Unmarshal(v, &f)
+	if err != nil {
+		return PortForward{}, err
+	}
+
+	return f, nil
+}
+
+// Store to database.
+func (f *PortForward) Store(tx *bolt.Tx) error {
+	if !tx.Writable() {
+		return errors.New("tx not writable")
+	}
+
+	bname := []byte("port_forwards")
+	bucket, err := tx.CreateBucketIfNotExists(bname)
+	if err != nil {
+		return err
+	}
+
+	key := portForwardKey(f.Proto, f.Port)
+	value, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put(key, value)
+}
+
+// RemovePortForward from database
+func RemovePortForward(tx *bolt.Tx, proto string, port uint16) error {
+	if !tx.Writable() {
+		return errors.New("tx not writable")
+	}
+
+	bname := []byte("port_forwards")
+	bucket := tx.Bucket(bname)
+	if bucket == nil {
+		return errors.New("not found")
+	}
+
+	return bucket.Delete(portForwardKey(proto, port))
+}
+
+// RemoveDevicePortForwards removes port forwards to the device.
+func RemoveDevicePortForwards(tx *bolt.Tx, ip net.IP) error {
+	forwards, err := LoadPortForwards(tx)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range forwards.Device(ip) {
+		err = RemovePortForward(tx, f.Proto, f.Port)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PortForwards type
+type PortForwards []PortForward
+
+// LoadPortForwards returns all port forwards from database
+// sorted by proto and port.
+func LoadPortForwards(tx *bolt.Tx) (PortForwards, error) {
+	bname := []byte("port_forwards")
+	bucket := tx.Bucket(bname)
+	if bucket == nil {
+		return nil, nil
+	}
+
+	cnt := bucket.Stats().KeyN
+	forwards := make(PortForwards, 0, cnt)
+
+	c := bucket.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		f := PortForward{}
+		err := json.Unmarshal(v, &f)
+		if err != nil {
+			return nil, err
+		}
+
+		forwards = append(forwards, f)
+	}
+
+	sort.Slice(forwards, func(i, j int) bool {
+		if forwards[i].Proto != forwards[j].Proto {
+			return forwards[i].Proto < forwards[j].Proto
+		}
+		return forwards[i].Port < forwards[j].Port
+	})
+
+	return forwards, nil
+}
+
+// Device returns port forwards to the device.
+func (s PortForwards) Device(ip net.IP) PortForwards {
+	ip = ip.To4()
+
+	forwards := make(PortForwards, 0)
+	for i := range s {
+		if bytes.Equal(s[i].IP.To4(), ip) {
+			forwards = append(forwards, s[i])
+		}
+	}
+
+	return forwards
+}
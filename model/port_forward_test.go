@@ -0,0 +1,102 @@
+package model
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestPortForward(t *testing.T) {
+	dbpath := "test.db"
+	db, err := bolt.Open(
+		dbpath, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+		return
+	}
+	defer db.Close()
+
+	bname := []byte("port_forwards")
+	err = deleteBucket(db, bname)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	tx, err := db.Begin(true) // writeable tx
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer tx.Rollback()
+
+	ip := net.IPv4(172, 16, 0, 2)
+	forwards := []PortForward{
+		NewPortForward("tcp", 8443, ip, 443),
+		NewPortForward("udp", 5353, ip, 53),
+		NewPortForward("tcp", 8080, net.IPv4(172, 16, 0, 3), 80),
+	}
+	for _, f := range forwards {
+		err = f.Validate()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		err = f.Store(tx)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	loaded, err := LoadPortForwards(tx)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(loaded) != 3 || loaded[0].Port != 8080 {
+		t.Errorf("unexpected port forwards: %v", loaded)
+		return
+	}
+	if len(loaded.Device(ip)) != 2 {
+		t.Errorf("expected 2 port forwards to the device")
+	}
+
+	err = RemoveDevice(tx, ip.To4())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	loaded, err = LoadPortForwards(tx)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(loaded) != 1 || loaded[0].Port != 8080 {
+		t.Errorf("expected port forwards of the device removed: %v", loaded)
+	}
+}
+
+func TestPortForwardValidate(t *testing.T) {
+	ip := net.IPv4(172, 16, 0, 2)
+	tests := []struct {
+		forward PortForward
+		ok      bool
+	}{
+		{NewPortForward("tcp", 8443, ip, 443), true},
+		{NewPortForward("icmp", 8443, ip, 443), false},
+		{NewPortForward("tcp", 0, ip, 443), false},
+		{NewPortForward("tcp", 8443, ip, 0), false},
+		{NewPortForward("udp", 53, net.ParseIP("fd00::2"), 53), false},
+	}
+
+	for i, tc := range tests {
+		err := tc.forward.Validate()
+		if (err == nil) != tc.ok {
+			t.Errorf("case %d: unexpected result: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package model
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Snapshot of the firewall settings to restore them later, the records
+// the firewall and the routes are built from only. Devices and users keep
+// everything but the firewall fields, the routes and the state of being
+// served, the ones added or removed after the snapshot is taken are left
+// as is, the keys, sessions and dns are never touched.
+type Snapshot struct {
+	trustIPSet   ManagerSSHTrustIPSet
+	egressRules  EgressRules
+	groups       Groups
+	portForwards PortForwards
+	devicePairs  DevicePairs
+
+	// firewall fields of the devices by ip and rules of the users by uuid
+	devices map[string]deviceFirewall
+	users   map[string]NFRules
+}
+
+// deviceFirewall keeps the device fields the firewall is built from.
+type deviceFirewall struct {
+	wanForward   bool
+	server       bool
+	downloadRate uint32
+	uploadRate   uint32
+	rules        NFRules
+	egress       EgressRules
+	groups       []string
+	routes       []*net.IPNet
+	disabled     bool
+	expiresAt    *time.Time
+}
+
+// TakeSnapshot of the firewall settings.
+func TakeSnapshot(tx *bolt.Tx) (*Snapshot, error) {
+	var err error
+	s := &Snapshot{}
+
+	s.trustIPSet, err = LoadManagerSSHTrustIPSet(tx)
+	if err != nil {
+		return nil, err
+	}
+	s.egressRules, err = LoadEgressRules(tx)
+	if err != nil {
+		return nil, err
+	}
+	s.groups, err = LoadGroups(tx)
+	if err != nil {
+		return nil, err
+	}
+	s.portForwards, err = LoadPortForwards(tx)
+	if err != nil {
+		return nil, err
+	}
+	s.devicePairs, err = LoadDevicePairs(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	devices, err := LoadDevices(tx)
+	if err != nil {
+		return nil, err
+	}
+	s.devices = make(map[string]deviceFirewall, len(devices))
+	for _, d := range devices {
+		s.devices[d.IPNetwork.IP.String()] = deviceFirewall{
+			wanForward:   d.WANForward,
+			server:       d.Server,
+			downloadRate: d.DownloadRate,
+			uploadRate:   d.UploadRate,
+			rules:        d.Rules,
+			egress:       d.Egress,
+			groups:       d.Groups,
+			routes:       d.Routes,
+			disabled:     d.Disabled,
+			expiresAt:    d.ExpiresAt,
+		}
+	}
+
+	users, err := LoadUsers(tx)
+	if err != nil {
+		return nil, err
+	}
+	s.users = make(map[string]NFRules, len(users))
+	for _, u := range users {
+		s.users[u.UUID] = u.Rules
+	}
+
+	return s, nil
+}
+
+// Restore the firewall settings from snapshot, the port forwards
+// and the pairs of the devices removed since are skipped.
+func (s *Snapshot) Restore(tx *bolt.Tx) error {
+	if !tx.Writable() {
+		return errors.New("tx not writable")
+	}
+
+	// everything is loaded before the buckets are changed
+	devices, err := LoadDevices(tx)
+	if err != nil {
+		return err
+	}
+	users, err := LoadUsers(tx)
+	if err != nil {
+		return err
+	}
+	groups, err := LoadGroups(tx)
+	if err != nil {
+		return err
+	}
+	forwards, err := LoadPortForwards(tx)
+	if err != nil {
+		return err
+	}
+	pairs, err := LoadDevicePairs(tx)
+	if err != nil {
+		return err
+	}
+
+	exists := func(ip net.IP) bool {
+		for _, d := range devices {
+			if d.IPNetwork.IP.Equal(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	err = s.trustIPSet.Store(tx)
+	if err != nil {
+		return err
+	}
+	err = s.egressRules.Store(tx)
+	if err != nil {
+		return err
+	}
+
+	for _, g := range groups {
+		err = RemoveGroup(tx, g.Name)
+		if err != nil {
+			return err
+		}
+	}
+	for _, g := range s.groups {
+		err = g.Store(tx)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, f := range forwards {
+		err = RemovePortForward(tx, f.Proto, f.Port)
+		if err != nil {
+			return err
+		}
+	}
+	for _, f := range s.portForwards {
+		if !exists(f.IP) {
+			continue
+		}
+		err = f.Store(tx)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, p := range pairs {
+		err = RemoveDevicePair(tx, p.A, p.B)
+		if err != nil {
+			return err
+		}
+	}
+	for _, p := range s.devicePairs {
+		if !exists(p.A) || !exists(p.B) {
+			continue
+		}
+		err = p.Store(tx)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, d := range devices {
+		err = s.restoreDevice(tx, d)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, u := range users {
+		rules, ok := s.users[u.UUID]
+		if !ok {
+			continue
+		}
+		u.Rules = rules
+		err = u.Store(tx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// restoreDevice firewall fields, the device added since keeps its fields
+// but the groups removed by the restore.
+func (s *Snapshot) restoreDevice(tx *bolt.Tx, d Device) error {
+	v, ok := s.devices[d.IPNetwork.IP.String()]
+	if ok {
+		d.WANForward = v.wanForward
+		d.Server = v.server
+		d.DownloadRate = v.downloadRate
+		d.UploadRate = v.uploadRate
+		d.Rules = v.rules
+		d.Egress = v.egress
+		d.Groups = v.groups
+		d.Routes = v.routes
+		d.Disabled = v.disabled
+		d.ExpiresAt = v.expiresAt
+	}
+
+	groups := d.Groups
+	d.Groups = nil
+	for _, name := range groups {
+		for _, g := range s.groups {
+			if g.Name == name {
+				d.AddGroup(name)
+				break
+			}
+		}
+	}
+
+	return d.Store(tx)
+}
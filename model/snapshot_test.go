@@ -0,0 +1,167 @@
+package model
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestSnapshot(t *testing.T) {
+	dbpath := "test.db"
+	db, err := bolt.Open(
+		dbpath, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+		return
+	}
+	defer db.Close()
+
+	for _, name := range []string{
+		"device_pairs", "devices", "users", "groups", "port_forwards",
+	} {
+		err = deleteBucket(db, []byte(name))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	_, ipnet, _ := net.ParseCIDR("172.16.0.0/24")
+	a, b := net.IPv4(172, 16, 0, 2).To4(), net.IPv4(172, 16, 0, 3).To4()
+	c := net.IPv4(172, 16, 0, 4).To4()
+
+	_, route, _ := net.ParseCIDR("192.168.10.0/24")
+	_, badRoute, _ := net.ParseCIDR("10.0.0.0/8")
+	expiresAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var snapshot *Snapshot
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, ip := range []net.IP{a, b} {
+			d := Device{
+				IPNetwork: IPNetwork{IP: ip, Net: ipnet},
+				Routes:    []*net.IPNet{route},
+			}
+			if ip.Equal(b) {
+				d.Routes = nil
+				d.ExpiresAt = &expiresAt
+			}
+			err := d.Store(tx)
+			if err != nil {
+				return err
+			}
+		}
+		p := NewDevicePair(a, b)
+		return p.Store(tx)
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		snapshot, err = TakeSnapshot(tx)
+		return err
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// the firewall changes to be rolled back along with the device
+	// added after the snapshot to be kept
+	err = db.Update(func(tx *bolt.Tx) error {
+		err := RemoveDevicePair(tx, a, b)
+		if err != nil {
+			return err
+		}
+
+		d, err := LoadDevice(tx, a)
+		if err != nil {
+			return err
+		}
+		d.WANForward = true
+		d.Routes = []*net.IPNet{badRoute}
+		d.Disabled = true
+		d.Label = "renamed"
+		err = d.Store(tx)
+		if err != nil {
+			return err
+		}
+
+		d, err = LoadDevice(tx, b)
+		if err != nil {
+			return err
+		}
+		d.ExpiresAt = nil
+		err = d.Store(tx)
+		if err != nil {
+			return err
+		}
+
+		g := NewGroup("admins")
+		err = g.Store(tx)
+		if err != nil {
+			return err
+		}
+
+		added := Device{
+			IPNetwork: IPNetwork{IP: c, Net: ipnet},
+			Groups:    []string{"admins"},
+		}
+		return added.Store(tx)
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	tx, err := db.Begin(true) // writeable tx
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer tx.Rollback()
+
+	err = snapshot.Restore(tx)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !IsDevicePairExists(tx, a, b) {
+		t.Errorf("device pair expected to be restored")
+	}
+	d, err := LoadDevice(tx, a)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if d.WANForward || d.Disabled || d.Label != "renamed" {
+		t.Errorf("unexpected restored device: %v, %v, %q",
+			d.WANForward, d.Disabled, d.Label)
+	}
+	if len(d.Routes) != 1 || d.Routes[0].String() != route.String() {
+		t.Errorf("unexpected restored routes: %v", d.Routes)
+	}
+	d, err = LoadDevice(tx, b)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if d.ExpiresAt == nil || !d.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("unexpected restored expiration: %v", d.ExpiresAt)
+	}
+	_, err = LoadGroup(tx, "admins")
+	if err == nil {
+		t.Errorf("group added after snapshot expected to be removed")
+	}
+	added, err := LoadDevice(tx, c)
+	if err != nil {
+		t.Errorf("device added after snapshot expected to be kept: %v", err)
+		return
+	}
+	if len(added.Groups) != 0 {
+		t.Errorf("removed group expected to be untagged: %v", added.Groups)
+	}
+}
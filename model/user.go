@@ -31,6 +31,9 @@ type User struct {
 	Session   userSession `json:"session"`
 
 	Devices []net.IP `json:"devices"`
+
+	// Rules restrict the traffic of all the user devices.
+	Rules NFRules `json:"rules,omitempty"`
 }
 
 // NewUser constructor
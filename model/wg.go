@@ -0,0 +1,217 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// WgServer model.
+type WgServer struct {
+	// Network name, empty value stands for the default network.
+	Network    string
+	PrivateKey wgtypes.Key
+	RotatedAt  time.Time
+
+	// Endpoints the devices connect the server at, the first one
+	// is the primary. Empty value stands for the configured endpoints.
+	Endpoints []Endpoint
+}
+
+// LoadWgServer of the network from database,
+// ok is false if private key wasn't stored yet.
+func LoadWgServer(tx *bolt.Tx, network string) (WgServer, bool, error) {
+	bname := []byte("wg")
+	bucket := tx.Bucket(bname)
+	if bucket != nil && network != "" {
+		// additional networks are stored in the nested buckets
+		bucket = bucket.Bucket([]byte(network))
+	}
+	if bucket == nil {
+		return WgServer{Network: network}, false, nil
+	}
+
+	v := bucket.Get([]byte("cfg"))
+	if v == nil {
+		return WgServer{Network: network}, false, nil
+	}
+
+	sk, err := wgtypes.NewKey(v)
+	if err != nil {
+		return WgServer{}, false, err
+	}
+
+	s := WgServer{Network: network, PrivateKey: sk}
+
+	v = bucket.Get([]byte("rotated_at"))
+	if v != nil {
+		err = s.RotatedAt.UnmarshalText(v)
+		if err != nil {
+			return WgServer{}, false, err
+		}
+	}
+
+	v = bucket.Get([]byte("endpoints"))
+	if v != nil {
+		err = json.Unmarshal(v, &s.Endpoints)
+		if err != nil {
+			return WgServer{}, false, err
+		}
+	}
+
+	return s, true, nil
+}
+
+// Store to database.
+func (s *WgServer) Store(tx *bolt.Tx) error {
+	if !tx.Writable() {
+		return errors.New("tx not writable")
+	}
+
+	bname := []byte("wg")
+	bucket, err := tx.CreateBucketIfNotExists(bname)
+	if err != nil {
+		return err
+	}
+	if s.Network != "" {
+		bucket, err = bucket.CreateBucketIfNotExists([]byte(s.Network))
+		if err != nil {
+			return err
+		}
+	}
+
+	// value must remain valid for the life of the transaction
+	sk := s.PrivateKey
+	err = bucket.Put([]byte("cfg"), sk[:])
+	if err != nil {
+		return err
+	}
+
+	if s.RotatedAt.IsZero() {
+		err = bucket.Delete([]byte("rotated_at"))
+	} else {
+		var v []byte
+		v, err = s.RotatedAt.MarshalText()
+		if err != nil {
+			return err
+		}
+		err = bucket.Put([]byte("rotated_at"), v)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(s.Endpoints) == 0 {
+		return bucket.Delete([]byte("endpoints"))
+	}
+
+	v, err := json.Marshal(s.Endpoints)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put([]byte("endpoints"), v)
+}
+
+// Endpoint the devices connect the server at, the hostname or ip address
+// and the optional port, the port of the network is used if omitted.
+type Endpoint struct {
+	Host string
+	Port uint16
+}
+
+// ParseEndpoint parses host[:port] value, ipv6 address is enclosed
+// in square brackets if the port is given.
+func ParseEndpoint(v string) (Endpoint, error) {
+	host, port := v, ""
+	switch {
+	case strings.HasPrefix(v, "[") && strings.HasSuffix(v, "]"):
+		host = v[1 : len(v)-1]
+	case strings.HasPrefix(v, "["), strings.Count(v, ":") == 1:
+		var err error
+		host, port, err = net.SplitHostPort(v)
+		if err != nil {
+			return Endpoint{}, fmt.Errorf("bad endpoint %q", v)
+		}
+	}
+
+	e := Endpoint{Host: strings.TrimSuffix(strings.ToLower(host), ".")}
+	if net.ParseIP(e.Host) == nil && !isHostname(e.Host) {
+		return Endpoint{}, fmt.Errorf("bad endpoint host %q", host)
+	}
+
+	if port != "" {
+		p, err := strconv.ParseUint(port, 10, 16)
+		if err != nil || p == 0 {
+			return Endpoint{}, fmt.Errorf("bad endpoint port %q", port)
+		}
+		e.Port = uint16(p)
+	}
+
+	return e, nil
+}
+
+// isHostname reports whether v is a valid dns hostname.
+func isHostname(v string) bool {
+	if len(v) == 0 || len(v) > 253 {
+		return false
+	}
+
+	for _, label := range strings.Split(v, ".") {
+		if len(label) == 0 || len(label) > 63 ||
+			label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			switch {
+			case c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '-':
+			default:
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// Addr returns host:port of the endpoint, port is used
+// if the endpoint has no port.
+func (e Endpoint) Addr(port uint16) string {
+	if e.Port != 0 {
+		port = e.Port
+	}
+
+	return net.JoinHostPort(e.Host, strconv.FormatUint(uint64(port), 10))
+}
+
+// String returns host[:port] value.
+func (e Endpoint) String() string {
+	if e.Port == 0 {
+		return e.Host
+	}
+
+	return e.Addr(e.Port)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (e Endpoint) MarshalText() ([]byte, error) {
+	return []byte(e.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (e *Endpoint) UnmarshalText(text []byte) error {
+	v, err := ParseEndpoint(string(text))
+	if err != nil {
+		return err
+	}
+	*e = v
+
+	return nil
+}
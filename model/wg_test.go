@@ -0,0 +1,206 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestWgServer(t *testing.T) {
+	dbpath := "test.db"
+	db, err := bolt.Open(
+		dbpath, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+		return
+	}
+	defer db.Close()
+
+	bname := []byte("wg")
+	err = deleteBucket(db, bname)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	tx, err := db.Begin(true) // writeable tx
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer tx.Rollback()
+
+	_, ok, err := LoadWgServer(tx, "")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if ok {
+		t.Error("expected no wg server stored")
+		return
+	}
+
+	sk, err := wgtypes.GeneratePrivateKey()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	s := WgServer{PrivateKey: sk}
+	err = s.Store(tx)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	s, ok, err = LoadWgServer(tx, "")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !ok || s.PrivateKey != sk || !s.RotatedAt.IsZero() {
+		t.Errorf("unexpected wg server: %v, %v", ok, s.RotatedAt)
+		return
+	}
+
+	rotatedAt := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+	s.RotatedAt = rotatedAt
+	err = s.Store(tx)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	s, _, err = LoadWgServer(tx, "")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !s.RotatedAt.Equal(rotatedAt) {
+		t.Errorf("expected rotated at %v, got %v", rotatedAt, s.RotatedAt)
+		return
+	}
+
+	// additional network has its own key
+	_, ok, err = LoadWgServer(tx, "contractors")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if ok {
+		t.Error("expected no wg server of network stored")
+		return
+	}
+
+	sk2, err := wgtypes.GeneratePrivateKey()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	s = WgServer{Network: "contractors", PrivateKey: sk2}
+	err = s.Store(tx)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	s, ok, err = LoadWgServer(tx, "contractors")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !ok || s.PrivateKey != sk2 {
+		t.Error("unexpected wg server of network")
+		return
+	}
+
+	s, _, err = LoadWgServer(tx, "")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if s.PrivateKey != sk {
+		t.Error("default network key was overwritten")
+		return
+	}
+
+	// endpoints are kept along with the key
+	s.Endpoints = []Endpoint{{Host: "vpn.example.com"}, {Host: "::1", Port: 443}}
+	err = s.Store(tx)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	s, _, err = LoadWgServer(tx, "")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(s.Endpoints) != 2 ||
+		s.Endpoints[0].String() != "vpn.example.com" ||
+		s.Endpoints[1].String() != "[::1]:443" {
+		t.Errorf("unexpected endpoints: %v", s.Endpoints)
+		return
+	}
+
+	s.Endpoints = nil
+	err = s.Store(tx)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	s, _, err = LoadWgServer(tx, "")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(s.Endpoints) != 0 {
+		t.Errorf("expected endpoints to be reset, got %v", s.Endpoints)
+	}
+}
+
+func TestParseEndpoint(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+		addr     string
+		ok       bool
+	}{
+		{"vpn.example.com", "vpn.example.com", "vpn.example.com:51820", true},
+		{"VPN.Example.com.", "vpn.example.com", "vpn.example.com:51820", true},
+		{"vpn.example.com:443", "vpn.example.com:443", "vpn.example.com:443", true},
+		{"203.0.113.1", "203.0.113.1", "203.0.113.1:51820", true},
+		{"203.0.113.1:51821", "203.0.113.1:51821", "203.0.113.1:51821", true},
+		{"2001:db8::1", "2001:db8::1", "[2001:db8::1]:51820", true},
+		{"[2001:db8::1]", "2001:db8::1", "[2001:db8::1]:51820", true},
+		{"[2001:db8::1]:443", "[2001:db8::1]:443", "[2001:db8::1]:443", true},
+		{"", "", "", false},
+		{"vpn..example.com", "", "", false},
+		{"-vpn.example.com", "", "", false},
+		{"vpn_example.com", "", "", false},
+		{"vpn.example.com:0", "", "", false},
+		{"vpn.example.com:65536", "", "", false},
+		{"vpn.example.com:port", "", "", false},
+		{"[vpn.example.com]:443x", "", "", false},
+	}
+
+	for _, tc := range tests {
+		e, err := ParseEndpoint(tc.value)
+		if (err == nil) != tc.ok {
+			t.Errorf("%q: unexpected error: %v", tc.value, err)
+			continue
+		}
+		if !tc.ok {
+			continue
+		}
+		if e.String() != tc.expected {
+			t.Errorf("%q: expected %q, got %q", tc.value, tc.expected, e)
+		}
+		if e.Addr(51820) != tc.addr {
+			t.Errorf("%q: expected addr %q, got %q",
+				tc.value, tc.addr, e.Addr(51820))
+		}
+	}
+}
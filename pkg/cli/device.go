@@ -12,6 +12,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"wgnetwork/api/manager"
 	"wgnetwork/pkg/pretty"
@@ -116,20 +117,38 @@ func (a *ActionDevice) Execute(args []string) error {
 		return err
 	}
 
-	table := pretty.NewTable(5)
-	table.SetHeader([]string{"label", "wan forward", "allowed ips", "user name", "user uuid"})
+	table := pretty.NewTable(12)
+	table.SetHeader([]string{"label", "network", "wan forward", "server", "disabled", "download", "upload", "allowed ips", "routes", "user name", "user uuid", "expires"})
 
 	table.AddRow([]string{
 		result.Label,
+		result.Network,
 		strconv.FormatBool(result.WANForward),
+		strconv.FormatBool(result.Server),
+		strconv.FormatBool(result.Disabled),
+		formatRate(result.DownloadRate),
+		formatRate(result.UploadRate),
 		strings.Join(result.WgDeviceAllowedIPs, "\n"),
+		strings.Join(result.WgDeviceRoutes, "\n"),
 		result.UserName,
-		result.UserUUID})
+		result.UserUUID,
+		formatExpires(result.ExpiresAt, result.Expired)})
+
+	os.Stdout.WriteString(table.Render())
+
+	table = pretty.NewTable(5)
+	table.SetHeader([]string{"online", "endpoint", "latest handshake", "received", "sent"})
+
+	table.AddRow([]string{
+		strconv.FormatBool(result.WgDeviceOnline),
+		result.WgDeviceEndpoint,
+		formatHandshake(result.WgDeviceLastHandshake),
+		formatBytes(result.WgDeviceRxBytes),
+		formatBytes(result.WgDeviceTxBytes)})
 
 	os.Stdout.WriteString(table.Render())
 
 	privKey := "<PLACEHOLDER>"
-	addr := fmt.Sprintf("%s:%d", result.WanIP, result.WgPort)
 
 	ipnets := make([]*net.IPNet, len(result.WgDeviceAllowedIPs))
 	for i := 0; i < len(result.WgDeviceAllowedIPs); i++ {
@@ -153,10 +172,11 @@ func (a *ActionDevice) Execute(args []string) error {
 	cfg := buildWgCfg(
 		privKey,
 		result.WgPubKey,
-		result.WgDeviceInet,
+		result.WgDevicePresharedKey,
+		joinAddrs(result.WgDeviceInet, result.WgDeviceInet6),
 		result.WgIP,
 		strings.Join(allowedIPs, ", "),
-		addr)
+		result.Endpoints)
 	os.Stdout.WriteString("\ntunnel config:\n")
 	os.Stdout.WriteString(cfg)
 
@@ -181,17 +201,37 @@ func (a *ActionDevice) validate() error {
 	return nil
 }
 
+// allowedIPs replaces default route with public address ranges,
+// so private networks are still reachable directly.
 func allowedIPs(wgipnet *net.IPNet, ipnets []*net.IPNet) []*net.IPNet {
-	if len(ipnets) != 1 {
-		return ipnets
+	result := make([]*net.IPNet, 0, len(ipnets))
+	for i := range ipnets {
+		ones, _ := ipnets[i].Mask.Size()
+		if ones > 0 || ipnets[i].IP.To4() == nil {
+			result = append(result, ipnets[i])
+			continue
+		}
+
+		result = append(result, publicIPNets(wgipnet)...)
 	}
 
-	ones, _ := ipnets[0].Mask.Size()
-	if ones > 0 {
-		return ipnets
+	return result
+}
+
+// joinAddrs of the tunnel config, empty values are omitted.
+func joinAddrs(addrs ...string) string {
+	result := make([]string, 0, len(addrs))
+	for _, v := range addrs {
+		if v != "" {
+			result = append(result, v)
+		}
 	}
 
-	ipnets = []*net.IPNet{
+	return strings.Join(result, ", ")
+}
+
+func publicIPNets(wgipnet *net.IPNet) []*net.IPNet {
+	ipnets := []*net.IPNet{
 		wgipnet,
 		// 1.0.0.0/8
 		{IP: net.IPv4(1, 0, 0, 0).To4(), Mask: net.IPv4Mask(255, 0, 0, 0)},
@@ -263,3 +303,46 @@ func allowedIPs(wgipnet *net.IPNet, ipnets []*net.IPNet) []*net.IPNet {
 
 	return ipnets
 }
+
+func formatExpires(t *time.Time, expired bool) string {
+	if t == nil {
+		return "never"
+	}
+
+	v := t.Local().Format(time.RFC3339)
+	if expired {
+		v += " (expired)"
+	}
+	return v
+}
+
+func formatRate(rate uint32) string {
+	if rate == 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%d kbit/s", rate)
+}
+
+func formatHandshake(t *time.Time) string {
+	if t == nil {
+		return "never"
+	}
+
+	d := time.Since(*t).Truncate(time.Second)
+	return fmt.Sprintf("%s ago", d)
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.2f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
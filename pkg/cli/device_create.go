@@ -12,6 +12,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/zyablitsev/qrencode-go/qrencode"
@@ -31,7 +32,12 @@ type ActionDeviceCreate struct {
 	userUUID   *string
 	label      *string
 	wanForward *bool
+	routes     *string
 	wgPubKey   *string
+	psk        *bool
+	wgPSK      *string
+	network    *string
+	expiresAt  *string
 }
 
 // NewActionDeviceCreate constructor.
@@ -56,10 +62,30 @@ func NewActionDeviceCreate(log logger) *ActionDeviceCreate {
 		"wan_forward",
 		false,
 		"wan_forward")
+	routes := flagset.String(
+		"routes",
+		"",
+		"comma separated subnets routed behind the device")
 	wgPubKey := flagset.String(
 		"wg_pubkey",
 		"",
 		"wireguard public key")
+	psk := flagset.Bool(
+		"psk",
+		false,
+		"generate wireguard preshared key")
+	wgPSK := flagset.String(
+		"wg_psk",
+		"",
+		"wireguard preshared key")
+	network := flagset.String(
+		"network",
+		"",
+		"network name (optional, default network if omitted)")
+	expiresAt := flagset.String(
+		"expires_at",
+		"",
+		"expiration time in RFC 3339 format (optional)")
 
 	a := &ActionDeviceCreate{
 		flagset: flagset,
@@ -69,7 +95,12 @@ func NewActionDeviceCreate(log logger) *ActionDeviceCreate {
 		userUUID:   userUUID,
 		label:      label,
 		wanForward: wanForward,
+		routes:     routes,
 		wgPubKey:   wgPubKey,
+		psk:        psk,
+		wgPSK:      wgPSK,
+		network:    network,
+		expiresAt:  expiresAt,
 	}
 
 	return a
@@ -102,7 +133,14 @@ func (a *ActionDeviceCreate) Execute(args []string) error {
 		UserUUID:    *a.userUUID,
 		Label:       *a.label,
 		WANForward:  *a.wanForward,
+		Routes:      splitRoutes(*a.routes),
 		WGPublicKey: *a.wgPubKey,
+		Network:     *a.network,
+
+		PresharedKey:   *a.psk,
+		WGPresharedKey: *a.wgPSK,
+
+		ExpiresAt: *a.expiresAt,
 	}.Marshal()
 	b = rpcapi.Request{
 		Method: "manager/device/create",
@@ -141,15 +179,18 @@ func (a *ActionDeviceCreate) Execute(args []string) error {
 		return err
 	}
 
-	table := pretty.NewTable(5)
-	table.SetHeader([]string{"label", "wan forward", "allowed ips", "user name", "user uuid"})
+	table := pretty.NewTable(8)
+	table.SetHeader([]string{"label", "network", "wan forward", "allowed ips", "routes", "user name", "user uuid", "expires"})
 
 	table.AddRow([]string{
 		result.Label,
+		result.Network,
 		strconv.FormatBool(result.WANForward),
 		strings.Join(result.WgDeviceAllowedIPs, "\n"),
+		strings.Join(result.WgDeviceRoutes, "\n"),
 		result.UserName,
-		result.UserUUID})
+		result.UserUUID,
+		formatExpires(result.ExpiresAt, result.Expired)})
 
 	os.Stdout.WriteString(table.Render())
 
@@ -157,7 +198,6 @@ func (a *ActionDeviceCreate) Execute(args []string) error {
 	if result.WgDevicePrivKey != "" {
 		privKey = result.WgDevicePrivKey
 	}
-	addr := fmt.Sprintf("%s:%d", result.WanIP, result.WgPort)
 
 	ipnets := make([]*net.IPNet, len(result.WgDeviceAllowedIPs))
 	for i := 0; i < len(result.WgDeviceAllowedIPs); i++ {
@@ -181,10 +221,11 @@ func (a *ActionDeviceCreate) Execute(args []string) error {
 	cfg := buildWgCfg(
 		privKey,
 		result.WgPubKey,
-		result.WgDeviceInet,
+		result.WgDevicePresharedKey,
+		joinAddrs(result.WgDeviceInet, result.WgDeviceInet6),
 		result.WgIP,
 		strings.Join(allowedIPs, ", "),
-		addr)
+		result.Endpoints)
 	os.Stdout.WriteString("\ntunnel config:\n")
 	os.Stdout.WriteString(cfg)
 
@@ -219,6 +260,13 @@ func (a *ActionDeviceCreate) validate() error {
 		return errors.New("label required")
 	}
 
+	for _, v := range splitRoutes(*a.routes) {
+		_, _, err = net.ParseCIDR(v)
+		if err != nil {
+			return errors.New("bad routes value")
+		}
+	}
+
 	if a.wgPubKey != nil && len(*a.wgPubKey) > 0 {
 		_, err = wgtypes.ParseKey(*a.wgPubKey)
 		if err != nil {
@@ -226,12 +274,40 @@ func (a *ActionDeviceCreate) validate() error {
 		}
 	}
 
+	if a.wgPSK != nil && len(*a.wgPSK) > 0 {
+		_, err = wgtypes.ParseKey(*a.wgPSK)
+		if err != nil {
+			return errors.New("bad wg_psk value")
+		}
+	}
+
+	if a.expiresAt != nil && len(*a.expiresAt) > 0 {
+		_, err = time.Parse(time.RFC3339, *a.expiresAt)
+		if err != nil {
+			return errors.New("bad expires_at value")
+		}
+	}
+
 	return nil
 }
 
+func splitRoutes(v string) []string {
+	routes := []string{}
+	for _, item := range strings.Split(v, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		routes = append(routes, item)
+	}
+
+	return routes
+}
+
 func buildWgCfg(
-	sk, pk string,
-	address, dns, allowedIPs, endpoint string,
+	sk, pk, psk string,
+	address, dns, allowedIPs string,
+	endpoints []string,
 ) string {
 	var buf strings.Builder
 	nl := "\n"
@@ -248,10 +324,20 @@ func buildWgCfg(
 	buf.WriteString(nl)
 	buf.WriteString("PublicKey = " + pk)
 	buf.WriteString(nl)
+	if psk != "" {
+		buf.WriteString("PresharedKey = " + psk)
+		buf.WriteString(nl)
+	}
 	buf.WriteString("AllowedIPs = " + allowedIPs)
 	buf.WriteString(nl)
-	buf.WriteString("Endpoint = " + endpoint)
-	buf.WriteString(nl)
+	for i, v := range endpoints {
+		// the alternative endpoints are left for the device to choose
+		if i > 0 {
+			buf.WriteString("# ")
+		}
+		buf.WriteString("Endpoint = " + v)
+		buf.WriteString(nl)
+	}
 	buf.WriteString("PersistentKeepalive = 25")
 	buf.WriteString(nl)
 
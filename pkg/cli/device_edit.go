@@ -7,11 +7,13 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"net"
 	"net/http"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"wgnetwork/api/manager"
 	"wgnetwork/pkg/pretty"
@@ -27,7 +29,13 @@ type ActionDeviceEdit struct {
 	ip         *string
 	label      *string
 	wanForward *bool
+	server     *bool
+	disabled   *bool
+	download   *uint
+	upload     *uint
+	routes     *string
 	wgPubKey   *string
+	expiresAt  *string
 }
 
 // NewActionDeviceEdit constructor.
@@ -52,10 +60,34 @@ func NewActionDeviceEdit(log logger) *ActionDeviceEdit {
 		"wan_forward",
 		false,
 		"wan_forward")
+	server := flagset.Bool(
+		"server",
+		false,
+		"server device reachable by every device of the isolated network")
+	disabled := flagset.Bool(
+		"disabled",
+		false,
+		"disable the device keeping its ip and dns records")
+	download := flagset.Uint(
+		"download_rate",
+		0,
+		"download rate limit in kbit/s, zero value removes the limit")
+	upload := flagset.Uint(
+		"upload_rate",
+		0,
+		"upload rate limit in kbit/s, zero value removes the limit")
+	routes := flagset.String(
+		"routes",
+		"",
+		"comma separated subnets routed behind the device, empty value removes all")
 	wgPubKey := flagset.String(
 		"wg_pubkey",
 		"",
 		"wireguard public key")
+	expiresAt := flagset.String(
+		"expires_at",
+		"",
+		"expiration time in RFC 3339 format, empty value removes expiration")
 
 	a := &ActionDeviceEdit{
 		flagset: flagset,
@@ -65,7 +97,13 @@ func NewActionDeviceEdit(log logger) *ActionDeviceEdit {
 		ip:         ip,
 		label:      label,
 		wanForward: wanForward,
+		server:     server,
+		disabled:   disabled,
+		download:   download,
+		upload:     upload,
+		routes:     routes,
 		wgPubKey:   wgPubKey,
+		expiresAt:  expiresAt,
 	}
 
 	return a
@@ -101,6 +139,29 @@ func (a *ActionDeviceEdit) Execute(args []string) error {
 	if a.wanForward != nil {
 		request.WANForward = a.wanForward
 	}
+	a.flagset.Visit(func(f *flag.Flag) {
+		if f.Name == "routes" {
+			routes := splitRoutes(*a.routes)
+			request.Routes = &routes
+		}
+		if f.Name == "server" {
+			request.Server = a.server
+		}
+		if f.Name == "disabled" {
+			request.Disabled = a.disabled
+		}
+		if f.Name == "expires_at" {
+			request.ExpiresAt = a.expiresAt
+		}
+		if f.Name == "download_rate" {
+			rate := uint32(*a.download)
+			request.DownloadRate = &rate
+		}
+		if f.Name == "upload_rate" {
+			rate := uint32(*a.upload)
+			request.UploadRate = &rate
+		}
+	})
 	if a.wgPubKey != nil && len(*a.wgPubKey) > 0 {
 		request.WGPublicKey = a.wgPubKey
 	}
@@ -142,20 +203,26 @@ func (a *ActionDeviceEdit) Execute(args []string) error {
 		return err
 	}
 
-	table := pretty.NewTable(5)
-	table.SetHeader([]string{"label", "wan forward", "allowed ips", "user name", "user uuid"})
+	table := pretty.NewTable(12)
+	table.SetHeader([]string{"label", "network", "wan forward", "server", "disabled", "download", "upload", "allowed ips", "routes", "user name", "user uuid", "expires"})
 
 	table.AddRow([]string{
 		result.Label,
+		result.Network,
 		strconv.FormatBool(result.WANForward),
+		strconv.FormatBool(result.Server),
+		strconv.FormatBool(result.Disabled),
+		formatRate(result.DownloadRate),
+		formatRate(result.UploadRate),
 		strings.Join(result.WgDeviceAllowedIPs, "\n"),
+		strings.Join(result.WgDeviceRoutes, "\n"),
 		result.UserName,
-		result.UserUUID})
+		result.UserUUID,
+		formatExpires(result.ExpiresAt, result.Expired)})
 
 	os.Stdout.WriteString(table.Render())
 
 	privKey := "<PLACEHOLDER>"
-	addr := fmt.Sprintf("%s:%d", result.WanIP, result.WgPort)
 
 	ipnets := make([]*net.IPNet, len(result.WgDeviceAllowedIPs))
 	for i := 0; i < len(result.WgDeviceAllowedIPs); i++ {
@@ -179,10 +246,11 @@ func (a *ActionDeviceEdit) Execute(args []string) error {
 	cfg := buildWgCfg(
 		privKey,
 		result.WgPubKey,
-		result.WgDeviceInet,
+		result.WgDevicePresharedKey,
+		joinAddrs(result.WgDeviceInet, result.WgDeviceInet6),
 		result.WgIP,
 		strings.Join(allowedIPs, ", "),
-		addr)
+		result.Endpoints)
 	os.Stdout.WriteString("\ntunnel config:\n")
 	os.Stdout.WriteString(cfg)
 
@@ -204,5 +272,26 @@ func (a *ActionDeviceEdit) validate() error {
 		return errors.New("bad ip value")
 	}
 
+	for _, v := range splitRoutes(*a.routes) {
+		_, _, err := net.ParseCIDR(v)
+		if err != nil {
+			return errors.New("bad routes value")
+		}
+	}
+
+	if a.expiresAt != nil && len(*a.expiresAt) > 0 {
+		_, err := time.Parse(time.RFC3339, *a.expiresAt)
+		if err != nil {
+			return errors.New("bad expires_at value")
+		}
+	}
+
+	if *a.download > math.MaxUint32 {
+		return errors.New("bad download_rate value")
+	}
+	if *a.upload > math.MaxUint32 {
+		return errors.New("bad upload_rate value")
+	}
+
 	return nil
 }
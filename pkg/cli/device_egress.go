@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"wgnetwork/api/manager"
+	"wgnetwork/pkg/pretty"
+	"wgnetwork/pkg/rpcapi"
+)
+
+// ActionDeviceEgress object.
+type ActionDeviceEgress struct {
+	flagset *flag.FlagSet
+	log     logger
+
+	unixSocket *string
+	ip         *string
+}
+
+// NewActionDeviceEgress constructor.
+func NewActionDeviceEgress(log logger) *ActionDeviceEgress {
+	flagset := flag.NewFlagSet(
+		"device-egress",
+		flag.ExitOnError)
+
+	unixSocket := flagset.String(
+		"unix-socket",
+		"/tmp/wgmanager.sock",
+		"unix-socket")
+	ip := flagset.String(
+		"ip",
+		"",
+		"device ip")
+
+	a := &ActionDeviceEgress{
+		flagset: flagset,
+		log:     log,
+
+		unixSocket: unixSocket,
+		ip:         ip,
+	}
+
+	return a
+}
+
+// Usage prints out flagset usage.
+func (a *ActionDeviceEgress) Usage() {
+	a.flagset.Usage()
+}
+
+// Execute action.
+func (a *ActionDeviceEgress) Execute(args []string) error {
+	logPrefix := "[device-egress] Execute"
+
+	a.log.Debugf("%s: trying to parse args: %v…", logPrefix, args)
+	err := a.flagset.Parse(args)
+	if err != nil {
+		return errors.New("can't parse args")
+	}
+
+	// validate arguments
+	err = a.validate()
+	if err != nil {
+		return err
+	}
+
+	client := newHTTPClient(*a.unixSocket)
+
+	b := manager.DeviceRequest{
+		IP: *a.ip,
+	}.Marshal()
+	b = rpcapi.Request{
+		Method: "manager/device/egress",
+		Params: b,
+	}.Marshal()
+	br := bytes.NewBuffer(b)
+
+	resp, err := client.Post(
+		"http://localhost/rpc",
+		"application/json; charset=utf-8",
+		br)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("bad response status code: %d", resp.StatusCode)
+		return err
+	}
+
+	b, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	response := &rpcapi.Response{}
+	err = json.Unmarshal(b, &response)
+	if err != nil {
+		return err
+	}
+
+	result := manager.EgressRulesResponse{}
+	err = json.Unmarshal(response.Result, &result)
+	if err != nil {
+		return err
+	}
+
+	os.Stdout.WriteString(renderEgressRules(result))
+
+	a.log.Debugf("%s: done", logPrefix)
+
+	return nil
+}
+
+func (a *ActionDeviceEgress) validate() error {
+	if a.unixSocket == nil || len(*a.unixSocket) == 0 {
+		return errors.New("unix-socket required")
+	}
+
+	if a.ip == nil {
+		return errors.New("ip required")
+	}
+
+	if net.ParseIP(*a.ip).To4() == nil {
+		return errors.New("bad ip value")
+	}
+
+	return nil
+}
+
+func renderEgressRules(rules manager.EgressRulesResponse) string {
+	table := pretty.NewTable(5)
+	table.SetHeader([]string{"index", "action", "ipproto", "dports", "dnets"})
+
+	for _, r := range rules {
+		ipproto := r.IPProto
+		if ipproto == "" {
+			ipproto = "any"
+		}
+		table.AddRow([]string{
+			strconv.Itoa(r.Index),
+			r.Action,
+			ipproto,
+			joinPorts(r.DPorts),
+			strings.Join(r.DNets, ","),
+		})
+	}
+
+	return table.Render()
+}
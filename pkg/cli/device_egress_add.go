@@ -0,0 +1,189 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+
+	"wgnetwork/api/manager"
+	"wgnetwork/model"
+	"wgnetwork/pkg/rpcapi"
+)
+
+// ActionDeviceEgressAdd object.
+type ActionDeviceEgressAdd struct {
+	flagset *flag.FlagSet
+	log     logger
+
+	unixSocket *string
+	ip         *string
+	action     *string
+	ipproto    *string
+	dports     *string
+	dnets      *string
+}
+
+// NewActionDeviceEgressAdd constructor.
+func NewActionDeviceEgressAdd(log logger) *ActionDeviceEgressAdd {
+	flagset := flag.NewFlagSet(
+		"device-egress-add",
+		flag.ExitOnError)
+
+	unixSocket := flagset.String(
+		"unix-socket",
+		"/tmp/wgmanager.sock",
+		"unix-socket")
+	ip := flagset.String(
+		"ip",
+		"",
+		"device ip")
+	action := flagset.String(
+		"action",
+		"",
+		"action: block or allow")
+	ipproto := flagset.String(
+		"ipproto",
+		"",
+		"ipproto: tcp, udp or empty for any")
+	dports := flagset.String(
+		"dports",
+		"",
+		"comma separated destination ports")
+	dnets := flagset.String(
+		"dnets",
+		"",
+		"comma separated destination addresses or cidr ranges")
+
+	a := &ActionDeviceEgressAdd{
+		flagset: flagset,
+		log:     log,
+
+		unixSocket: unixSocket,
+		ip:         ip,
+		action:     action,
+		ipproto:    ipproto,
+		dports:     dports,
+		dnets:      dnets,
+	}
+
+	return a
+}
+
+// Usage prints out flagset usage.
+func (a *ActionDeviceEgressAdd) Usage() {
+	a.flagset.Usage()
+}
+
+// Execute action.
+func (a *ActionDeviceEgressAdd) Execute(args []string) error {
+	logPrefix := "[device-egress-add] Execute"
+
+	a.log.Debugf("%s: trying to parse args: %v…", logPrefix, args)
+	err := a.flagset.Parse(args)
+	if err != nil {
+		return errors.New("can't parse args")
+	}
+
+	// validate arguments
+	err = a.validate()
+	if err != nil {
+		return err
+	}
+
+	client := newHTTPClient(*a.unixSocket)
+
+	b := manager.DeviceEgressAddRequest{
+		IP: *a.ip,
+		Rule: egressRuleRequest(
+			*a.action, *a.ipproto, *a.dports, *a.dnets),
+	}.Marshal()
+	b = rpcapi.Request{
+		Method: "manager/device/egress/add",
+		Params: b,
+	}.Marshal()
+	br := bytes.NewBuffer(b)
+
+	resp, err := client.Post(
+		"http://localhost/rpc",
+		"application/json; charset=utf-8",
+		br)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("bad response status code: %d", resp.StatusCode)
+		return err
+	}
+
+	b, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	response := &rpcapi.Response{}
+	err = json.Unmarshal(b, &response)
+	if err != nil {
+		return err
+	}
+
+	os.Stdout.WriteString(string(response.Result) + "\n")
+
+	a.log.Debugf("%s: done", logPrefix)
+
+	return nil
+}
+
+func (a *ActionDeviceEgressAdd) validate() error {
+	if a.unixSocket == nil || len(*a.unixSocket) == 0 {
+		return errors.New("unix-socket required")
+	}
+
+	if a.ip == nil {
+		return errors.New("ip required")
+	}
+
+	if net.ParseIP(*a.ip).To4() == nil {
+		return errors.New("bad ip value")
+	}
+
+	return validateEgressRule(*a.action, *a.dports, *a.dnets)
+}
+
+func validateEgressRule(action, dports, dnets string) error {
+	if len(action) == 0 {
+		return errors.New("action required")
+	}
+
+	if _, err := splitPorts(dports); err != nil {
+		return errors.New("bad dports value")
+	}
+
+	for _, v := range splitRoutes(dnets) {
+		if _, err := model.ParseEgressNet(v); err != nil {
+			return errors.New("bad dnets value")
+		}
+	}
+
+	return nil
+}
+
+func egressRuleRequest(
+	action, ipproto, dports, dnets string,
+) manager.EgressRuleRequest {
+	r := manager.EgressRuleRequest{
+		Action:  action,
+		IPProto: ipproto,
+		DNets:   splitRoutes(dnets),
+	}
+	r.DPorts, _ = splitPorts(dports)
+
+	return r
+}
@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+
+	"wgnetwork/api/manager"
+	"wgnetwork/pkg/rpcapi"
+)
+
+// ActionDevicePairAdd object.
+type ActionDevicePairAdd struct {
+	flagset *flag.FlagSet
+	log     logger
+
+	unixSocket *string
+	ipA        *string
+	ipB        *string
+}
+
+// NewActionDevicePairAdd constructor.
+func NewActionDevicePairAdd(log logger) *ActionDevicePairAdd {
+	flagset := flag.NewFlagSet(
+		"device-pair-add",
+		flag.ExitOnError)
+
+	unixSocket := flagset.String(
+		"unix-socket",
+		"/tmp/wgmanager.sock",
+		"unix-socket")
+	ipA := flagset.String(
+		"a",
+		"",
+		"device ip")
+	ipB := flagset.String(
+		"b",
+		"",
+		"paired device ip")
+
+	a := &ActionDevicePairAdd{
+		flagset: flagset,
+		log:     log,
+
+		unixSocket: unixSocket,
+		ipA:        ipA,
+		ipB:        ipB,
+	}
+
+	return a
+}
+
+// Usage prints out flagset usage.
+func (a *ActionDevicePairAdd) Usage() {
+	a.flagset.Usage()
+}
+
+// Execute action.
+func (a *ActionDevicePairAdd) Execute(args []string) error {
+	logPrefix := "[device-pair-add] Execute"
+
+	a.log.Debugf("%s: trying to parse args: %v…", logPrefix, args)
+	err := a.flagset.Parse(args)
+	if err != nil {
+		return errors.New("can't parse args")
+	}
+
+	// validate arguments
+	err = a.validate()
+	if err != nil {
+		return err
+	}
+
+	client := newHTTPClient(*a.unixSocket)
+
+	b := manager.DevicePairAddRequest{
+		A: *a.ipA,
+		B: *a.ipB,
+	}.Marshal()
+	b = rpcapi.Request{
+		Method: "manager/device/pair/add",
+		Params: b,
+	}.Marshal()
+	br := bytes.NewBuffer(b)
+
+	resp, err := client.Post(
+		"http://localhost/rpc",
+		"application/json; charset=utf-8",
+		br)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("bad response status code: %d", resp.StatusCode)
+		return err
+	}
+
+	b, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	response := &rpcapi.Response{}
+	err = json.Unmarshal(b, &response)
+	if err != nil {
+		return err
+	}
+
+	os.Stdout.WriteString(string(response.Result) + "\n")
+
+	a.log.Debugf("%s: done", logPrefix)
+
+	return nil
+}
+
+func (a *ActionDevicePairAdd) validate() error {
+	if a.unixSocket == nil || len(*a.unixSocket) == 0 {
+		return errors.New("unix-socket required")
+	}
+
+	if a.ipA == nil || net.ParseIP(*a.ipA).To4() == nil {
+		return errors.New("bad a value")
+	}
+
+	if a.ipB == nil || net.ParseIP(*a.ipB).To4() == nil {
+		return errors.New("bad b value")
+	}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+
+	"wgnetwork/api/manager"
+	"wgnetwork/pkg/rpcapi"
+)
+
+// ActionDevicePairRemove object.
+type ActionDevicePairRemove struct {
+	flagset *flag.FlagSet
+	log     logger
+
+	unixSocket *string
+	ipA        *string
+	ipB        *string
+}
+
+// NewActionDevicePairRemove constructor.
+func NewActionDevicePairRemove(log logger) *ActionDevicePairRemove {
+	flagset := flag.NewFlagSet(
+		"device-pair-remove",
+		flag.ExitOnError)
+
+	unixSocket := flagset.String(
+		"unix-socket",
+		"/tmp/wgmanager.sock",
+		"unix-socket")
+	ipA := flagset.String(
+		"a",
+		"",
+		"device ip")
+	ipB := flagset.String(
+		"b",
+		"",
+		"paired device ip")
+
+	a := &ActionDevicePairRemove{
+		flagset: flagset,
+		log:     log,
+
+		unixSocket: unixSocket,
+		ipA:        ipA,
+		ipB:        ipB,
+	}
+
+	return a
+}
+
+// Usage prints out flagset usage.
+func (a *ActionDevicePairRemove) Usage() {
+	a.flagset.Usage()
+}
+
+// Execute action.
+func (a *ActionDevicePairRemove) Execute(args []string) error {
+	logPrefix := "[device-pair-remove] Execute"
+
+	a.log.Debugf("%s: trying to parse args: %v…", logPrefix, args)
+	err := a.flagset.Parse(args)
+	if err != nil {
+		return errors.New("can't parse args")
+	}
+
+	// validate arguments
+	err = a.validate()
+	if err != nil {
+		return err
+	}
+
+	client := newHTTPClient(*a.unixSocket)
+
+	b := manager.DevicePairRemoveRequest{
+		A: *a.ipA,
+		B: *a.ipB,
+	}.Marshal()
+	b = rpcapi.Request{
+		Method: "manager/device/pair/remove",
+		Params: b,
+	}.Marshal()
+	br := bytes.NewBuffer(b)
+
+	resp, err := client.Post(
+		"http://localhost/rpc",
+		"application/json; charset=utf-8",
+		br)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("bad response status code: %d", resp.StatusCode)
+		return err
+	}
+
+	b, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	response := &rpcapi.Response{}
+	err = json.Unmarshal(b, &response)
+	if err != nil {
+		return err
+	}
+
+	os.Stdout.WriteString(string(response.Result) + "\n")
+
+	a.log.Debugf("%s: done", logPrefix)
+
+	return nil
+}
+
+func (a *ActionDevicePairRemove) validate() error {
+	if a.unixSocket == nil || len(*a.unixSocket) == 0 {
+		return errors.New("unix-socket required")
+	}
+
+	if a.ipA == nil || net.ParseIP(*a.ipA).To4() == nil {
+		return errors.New("bad a value")
+	}
+
+	if a.ipB == nil || net.ParseIP(*a.ipB).To4() == nil {
+		return errors.New("bad b value")
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"wgnetwork/api/manager"
+	"wgnetwork/pkg/pretty"
+	"wgnetwork/pkg/rpcapi"
+)
+
+// ActionDevicePairs object.
+type ActionDevicePairs struct {
+	flagset *flag.FlagSet
+	log     logger
+
+	unixSocket *string
+}
+
+// NewActionDevicePairs constructor.
+func NewActionDevicePairs(log logger) *ActionDevicePairs {
+	flagset := flag.NewFlagSet(
+		"device-pairs",
+		flag.ExitOnError)
+
+	unixSocket := flagset.String(
+		"unix-socket",
+		"/tmp/wgmanager.sock",
+		"unix-socket")
+
+	a := &ActionDevicePairs{
+		flagset: flagset,
+		log:     log,
+
+		unixSocket: unixSocket,
+	}
+
+	return a
+}
+
+// Usage prints out flagset usage.
+func (a *ActionDevicePairs) Usage() {
+	a.flagset.Usage()
+}
+
+// Execute action.
+func (a *ActionDevicePairs) Execute(args []string) error {
+	logPrefix := "[device-pairs] Execute"
+
+	a.log.Debugf("%s: trying to parse args: %v…", logPrefix, args)
+	err := a.flagset.Parse(args)
+	if err != nil {
+		return errors.New("can't parse args")
+	}
+
+	// validate arguments
+	err = a.validate()
+	if err != nil {
+		return err
+	}
+
+	client := newHTTPClient(*a.unixSocket)
+
+	b := rpcapi.Request{
+		Method: "manager/device-pairs",
+	}.Marshal()
+	br := bytes.NewBuffer(b)
+
+	resp, err := client.Post(
+		"http://localhost/rpc",
+		"application/json; charset=utf-8",
+		br)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("bad response status code: %d", resp.StatusCode)
+		return err
+	}
+
+	b, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	response := &rpcapi.Response{}
+	err = json.Unmarshal(b, &response)
+	if err != nil {
+		return err
+	}
+
+	result := manager.DevicePairListResponse{}
+	err = json.Unmarshal(response.Result, &result)
+	if err != nil {
+		return err
+	}
+
+	table := pretty.NewTable(5)
+	table.SetHeader([]string{"network", "a", "a label", "b", "b label"})
+
+	for _, p := range result {
+		table.AddRow([]string{
+			p.Network,
+			p.A,
+			p.ALabel,
+			p.B,
+			p.BLabel})
+	}
+
+	os.Stdout.WriteString(table.Render())
+
+	a.log.Debugf("%s: done", logPrefix)
+
+	return nil
+}
+
+func (a *ActionDevicePairs) validate() error {
+	if a.unixSocket == nil || len(*a.unixSocket) == 0 {
+		return errors.New("unix-socket required")
+	}
+
+	return nil
+}
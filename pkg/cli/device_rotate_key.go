@@ -0,0 +1,213 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/zyablitsev/qrencode-go/qrencode"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+
+	"wgnetwork/api/manager"
+	"wgnetwork/pkg/pretty"
+	"wgnetwork/pkg/rpcapi"
+)
+
+// ActionDeviceRotateKey object.
+type ActionDeviceRotateKey struct {
+	flagset *flag.FlagSet
+	log     logger
+
+	unixSocket *string
+	ip         *string
+	wgPubKey   *string
+}
+
+// NewActionDeviceRotateKey constructor.
+func NewActionDeviceRotateKey(log logger) *ActionDeviceRotateKey {
+	flagset := flag.NewFlagSet(
+		"device-rotate-key",
+		flag.ExitOnError)
+
+	unixSocket := flagset.String(
+		"unix-socket",
+		"/tmp/wgmanager.sock",
+		"unix-socket")
+	ip := flagset.String(
+		"ip",
+		"",
+		"device ip")
+	wgPubKey := flagset.String(
+		"wg_pubkey",
+		"",
+		"wireguard public key")
+
+	a := &ActionDeviceRotateKey{
+		flagset: flagset,
+		log:     log,
+
+		unixSocket: unixSocket,
+		ip:         ip,
+		wgPubKey:   wgPubKey,
+	}
+
+	return a
+}
+
+// Usage prints out flagset usage.
+func (a *ActionDeviceRotateKey) Usage() {
+	a.flagset.Usage()
+}
+
+// Execute action.
+func (a *ActionDeviceRotateKey) Execute(args []string) error {
+	logPrefix := "[device-rotate-key] Execute"
+
+	a.log.Debugf("%s: trying to parse args: %v…", logPrefix, args)
+	err := a.flagset.Parse(args)
+	if err != nil {
+		return errors.New("can't parse args")
+	}
+
+	// validate arguments
+	err = a.validate()
+	if err != nil {
+		return err
+	}
+
+	client := newHTTPClient(*a.unixSocket)
+
+	b := manager.DeviceRotateKeyRequest{
+		IP:          *a.ip,
+		WGPublicKey: *a.wgPubKey,
+	}.Marshal()
+	b = rpcapi.Request{
+		Method: "manager/device/rotate-key",
+		Params: b,
+	}.Marshal()
+	br := bytes.NewBuffer(b)
+
+	resp, err := client.Post(
+		"http://localhost/rpc",
+		"application/json; charset=utf-8",
+		br)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("bad response status code: %d", resp.StatusCode)
+		return err
+	}
+
+	b, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	response := &rpcapi.Response{}
+	err = json.Unmarshal(b, &response)
+	if err != nil {
+		return err
+	}
+
+	result := manager.DeviceRotateKeyResponse{}
+	err = json.Unmarshal(response.Result, &result)
+	if err != nil {
+		return err
+	}
+
+	table := pretty.NewTable(7)
+	table.SetHeader([]string{"label", "network", "wan forward", "pubkey", "user name", "user uuid", "expires"})
+
+	table.AddRow([]string{
+		result.Label,
+		result.Network,
+		strconv.FormatBool(result.WANForward),
+		result.WgDevicePubKey,
+		result.UserName,
+		result.UserUUID,
+		formatExpires(result.ExpiresAt, result.Expired)})
+
+	os.Stdout.WriteString(table.Render())
+
+	privKey := "<PLACEHOLDER>"
+	if result.WgDevicePrivKey != "" {
+		privKey = result.WgDevicePrivKey
+	}
+
+	ipnets := make([]*net.IPNet, len(result.WgDeviceAllowedIPs))
+	for i := 0; i < len(result.WgDeviceAllowedIPs); i++ {
+		_, ipnet, err := net.ParseCIDR(result.WgDeviceAllowedIPs[i])
+		if err != nil {
+			return err
+		}
+		ipnets[i] = ipnet
+	}
+	_, wgipnet, err := net.ParseCIDR(result.WgIPNet)
+	if err != nil {
+		return err
+	}
+	ipnets = allowedIPs(wgipnet, ipnets)
+
+	allowedIPs := make([]string, len(ipnets))
+	for i := range ipnets {
+		allowedIPs[i] = ipnets[i].String()
+	}
+
+	cfg := buildWgCfg(
+		privKey,
+		result.WgPubKey,
+		result.WgDevicePresharedKey,
+		joinAddrs(result.WgDeviceInet, result.WgDeviceInet6),
+		result.WgIP,
+		strings.Join(allowedIPs, ", "),
+		result.Endpoints)
+	os.Stdout.WriteString("\ntunnel config:\n")
+	os.Stdout.WriteString(cfg)
+
+	if result.WgDevicePrivKey != "" {
+		grid, err := qrencode.Encode(cfg, qrencode.ECLevelL)
+		if err != nil {
+			return err
+		}
+		os.Stdout.WriteString("\nscan with wireguard app\n")
+		grid.TerminalOutput(os.Stdout)
+	}
+
+	a.log.Debugf("%s: done", logPrefix)
+
+	return nil
+}
+
+func (a *ActionDeviceRotateKey) validate() error {
+	if a.unixSocket == nil || len(*a.unixSocket) == 0 {
+		return errors.New("unix-socket required")
+	}
+
+	if a.ip == nil {
+		return errors.New("ip required")
+	}
+
+	if net.ParseIP(*a.ip).To4() == nil {
+		return errors.New("bad ip value")
+	}
+
+	if a.wgPubKey != nil && len(*a.wgPubKey) > 0 {
+		_, err := wgtypes.ParseKey(*a.wgPubKey)
+		if err != nil {
+			return errors.New("bad wg_pubkey value")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,224 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+
+	"wgnetwork/api/manager"
+	"wgnetwork/pkg/rpcapi"
+)
+
+// ActionDeviceRuleAdd object.
+type ActionDeviceRuleAdd struct {
+	flagset *flag.FlagSet
+	log     logger
+
+	unixSocket *string
+	ip         *string
+	direction  *string
+	ipproto    *string
+	sports     *string
+	dports     *string
+	saddrs     *string
+	daddrs     *string
+}
+
+// NewActionDeviceRuleAdd constructor.
+func NewActionDeviceRuleAdd(log logger) *ActionDeviceRuleAdd {
+	flagset := flag.NewFlagSet(
+		"device-rule-add",
+		flag.ExitOnError)
+
+	unixSocket := flagset.String(
+		"unix-socket",
+		"/tmp/wgmanager.sock",
+		"unix-socket")
+	ip := flagset.String(
+		"ip",
+		"",
+		"device ip")
+	direction := flagset.String(
+		"direction",
+		"",
+		"direction: out or in")
+	ipproto := flagset.String(
+		"ipproto",
+		"",
+		"ipproto: tcp, udp, icmp or empty for any")
+	sports := flagset.String(
+		"sports",
+		"",
+		"comma separated source ports")
+	dports := flagset.String(
+		"dports",
+		"",
+		"comma separated destination ports")
+	saddrs := flagset.String(
+		"saddrs",
+		"",
+		"comma separated source addresses")
+	daddrs := flagset.String(
+		"daddrs",
+		"",
+		"comma separated destination addresses")
+
+	a := &ActionDeviceRuleAdd{
+		flagset: flagset,
+		log:     log,
+
+		unixSocket: unixSocket,
+		ip:         ip,
+		direction:  direction,
+		ipproto:    ipproto,
+		sports:     sports,
+		dports:     dports,
+		saddrs:     saddrs,
+		daddrs:     daddrs,
+	}
+
+	return a
+}
+
+// Usage prints out flagset usage.
+func (a *ActionDeviceRuleAdd) Usage() {
+	a.flagset.Usage()
+}
+
+// Execute action.
+func (a *ActionDeviceRuleAdd) Execute(args []string) error {
+	logPrefix := "[device-rule-add] Execute"
+
+	a.log.Debugf("%s: trying to parse args: %v…", logPrefix, args)
+	err := a.flagset.Parse(args)
+	if err != nil {
+		return errors.New("can't parse args")
+	}
+
+	// validate arguments
+	err = a.validate()
+	if err != nil {
+		return err
+	}
+
+	client := newHTTPClient(*a.unixSocket)
+
+	b := manager.DeviceRuleAddRequest{
+		IP: *a.ip,
+		Rule: ruleRequest(
+			*a.direction, *a.ipproto,
+			*a.sports, *a.dports,
+			*a.saddrs, *a.daddrs),
+	}.Marshal()
+	b = rpcapi.Request{
+		Method: "manager/device/rule/add",
+		Params: b,
+	}.Marshal()
+	br := bytes.NewBuffer(b)
+
+	resp, err := client.Post(
+		"http://localhost/rpc",
+		"application/json; charset=utf-8",
+		br)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("bad response status code: %d", resp.StatusCode)
+		return err
+	}
+
+	b, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	response := &rpcapi.Response{}
+	err = json.Unmarshal(b, &response)
+	if err != nil {
+		return err
+	}
+
+	os.Stdout.WriteString(string(response.Result) + "\n")
+
+	a.log.Debugf("%s: done", logPrefix)
+
+	return nil
+}
+
+func (a *ActionDeviceRuleAdd) validate() error {
+	if a.unixSocket == nil || len(*a.unixSocket) == 0 {
+		return errors.New("unix-socket required")
+	}
+
+	if a.ip == nil {
+		return errors.New("ip required")
+	}
+
+	if net.ParseIP(*a.ip).To4() == nil {
+		return errors.New("bad ip value")
+	}
+
+	if a.direction == nil || len(*a.direction) == 0 {
+		return errors.New("direction required")
+	}
+
+	if _, err := splitPorts(*a.sports); err != nil {
+		return errors.New("bad sports value")
+	}
+
+	if _, err := splitPorts(*a.dports); err != nil {
+		return errors.New("bad dports value")
+	}
+
+	for _, v := range splitRoutes(*a.saddrs) {
+		if net.ParseIP(v) == nil {
+			return errors.New("bad saddrs value")
+		}
+	}
+
+	for _, v := range splitRoutes(*a.daddrs) {
+		if net.ParseIP(v) == nil {
+			return errors.New("bad daddrs value")
+		}
+	}
+
+	return nil
+}
+
+func ruleRequest(
+	direction, ipproto, sports, dports, saddrs, daddrs string,
+) manager.RuleRequest {
+	r := manager.RuleRequest{
+		Direction: direction,
+		IPProto:   ipproto,
+		SAddrs:    splitRoutes(saddrs),
+		DAddrs:    splitRoutes(daddrs),
+	}
+	r.SPorts, _ = splitPorts(sports)
+	r.DPorts, _ = splitPorts(dports)
+
+	return r
+}
+
+func splitPorts(v string) ([]uint16, error) {
+	var ports []uint16
+	for _, item := range splitRoutes(v) {
+		port, err := strconv.ParseUint(item, 10, 16)
+		if err != nil || port == 0 {
+			return nil, fmt.Errorf("bad port %q", item)
+		}
+		ports = append(ports, uint16(port))
+	}
+
+	return ports, nil
+}
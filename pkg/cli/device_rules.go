@@ -0,0 +1,181 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"wgnetwork/api/manager"
+	"wgnetwork/pkg/pretty"
+	"wgnetwork/pkg/rpcapi"
+)
+
+// ActionDeviceRules object.
+type ActionDeviceRules struct {
+	flagset *flag.FlagSet
+	log     logger
+
+	unixSocket *string
+	ip         *string
+}
+
+// NewActionDeviceRules constructor.
+func NewActionDeviceRules(log logger) *ActionDeviceRules {
+	flagset := flag.NewFlagSet(
+		"device-rules",
+		flag.ExitOnError)
+
+	unixSocket := flagset.String(
+		"unix-socket",
+		"/tmp/wgmanager.sock",
+		"unix-socket")
+	ip := flagset.String(
+		"ip",
+		"",
+		"device ip")
+
+	a := &ActionDeviceRules{
+		flagset: flagset,
+		log:     log,
+
+		unixSocket: unixSocket,
+		ip:         ip,
+	}
+
+	return a
+}
+
+// Usage prints out flagset usage.
+func (a *ActionDeviceRules) Usage() {
+	a.flagset.Usage()
+}
+
+// Execute action.
+func (a *ActionDeviceRules) Execute(args []string) error {
+	logPrefix := "[device-rules] Execute"
+
+	a.log.Debugf("%s: trying to parse args: %v…", logPrefix, args)
+	err := a.flagset.Parse(args)
+	if err != nil {
+		return errors.New("can't parse args")
+	}
+
+	// validate arguments
+	err = a.validate()
+	if err != nil {
+		return err
+	}
+
+	client := newHTTPClient(*a.unixSocket)
+
+	b := manager.DeviceRequest{
+		IP: *a.ip,
+	}.Marshal()
+	b = rpcapi.Request{
+		Method: "manager/device/rules",
+		Params: b,
+	}.Marshal()
+	br := bytes.NewBuffer(b)
+
+	resp, err := client.Post(
+		"http://localhost/rpc",
+		"application/json; charset=utf-8",
+		br)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("bad response status code: %d", resp.StatusCode)
+		return err
+	}
+
+	b, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	response := &rpcapi.Response{}
+	err = json.Unmarshal(b, &response)
+	if err != nil {
+		return err
+	}
+
+	result := manager.RulesResponse{}
+	err = json.Unmarshal(response.Result, &result)
+	if err != nil {
+		return err
+	}
+
+	os.Stdout.WriteString(renderRules(result))
+
+	a.log.Debugf("%s: done", logPrefix)
+
+	return nil
+}
+
+func (a *ActionDeviceRules) validate() error {
+	if a.unixSocket == nil || len(*a.unixSocket) == 0 {
+		return errors.New("unix-socket required")
+	}
+
+	if a.ip == nil {
+		return errors.New("ip required")
+	}
+
+	if net.ParseIP(*a.ip).To4() == nil {
+		return errors.New("bad ip value")
+	}
+
+	return nil
+}
+
+func renderRules(rules manager.RulesResponse) string {
+	table := pretty.NewTable(7)
+	table.SetHeader([]string{"index", "direction", "ipproto", "sports", "dports", "saddrs", "daddrs"})
+
+	for _, r := range rules {
+		ipproto := r.IPProto
+		if ipproto == "" {
+			ipproto = "any"
+		}
+		table.AddRow([]string{
+			strconv.Itoa(r.Index),
+			r.Direction,
+			ipproto,
+			joinPorts(r.SPorts),
+			joinPorts(r.DPorts),
+			joinIPs(r.SAddrs),
+			joinIPs(r.DAddrs),
+		})
+	}
+
+	return table.Render()
+}
+
+func joinPorts(ports []uint16) string {
+	items := make([]string, len(ports))
+	for i, port := range ports {
+		items[i] = strconv.Itoa(int(port))
+	}
+
+	return strings.Join(items, ",")
+}
+
+func joinIPs(ips []net.IP) string {
+	items := make([]string, len(ips))
+	for i, ip := range ips {
+		items[i] = ip.String()
+	}
+
+	return strings.Join(items, ",")
+}
@@ -105,18 +105,25 @@ func (a *ActionDevices) Execute(args []string) error {
 		return err
 	}
 
-	table := pretty.NewTable(6)
+	table := pretty.NewTable(13)
 	table.SetHeader(
-		[]string{"ipnetwork", "pubkey", "label", "wan_forward", "allowed ips", "user uuid"})
+		[]string{"ipnetwork", "network", "pubkey", "label", "wan_forward", "disabled", "expires", "allowed ips", "routes", "user uuid", "online", "latest handshake", "received/sent"})
 
 	for _, d := range result {
 		table.AddRow([]string{
 			d.IPNetwork,
+			d.Network,
 			d.PubKey,
 			d.Label,
 			strconv.FormatBool(d.WANForward),
+			strconv.FormatBool(d.Disabled),
+			formatExpires(d.ExpiresAt, d.Expired),
 			strings.Join(d.AllowedIPs, "\n"),
-			d.UserUUID})
+			strings.Join(d.Routes, "\n"),
+			d.UserUUID,
+			strconv.FormatBool(d.WgDeviceOnline),
+			formatHandshake(d.WgDeviceLastHandshake),
+			formatBytes(d.WgDeviceRxBytes) + "/" + formatBytes(d.WgDeviceTxBytes)})
 	}
 
 	os.Stdout.WriteString(table.Render())
@@ -124,6 +124,9 @@ func (a *ActionDomain) Execute(args []string) error {
 	for _, r := range result.A {
 		table.AddRow([]string{"a", r.A.String(), strconv.FormatUint(uint64(r.TTL), 10)})
 	}
+	for _, r := range result.AAAA {
+		table.AddRow([]string{"aaaa", r.AAAA.String(), strconv.FormatUint(uint64(r.TTL), 10)})
+	}
 	os.Stdout.WriteString(table.Render())
 
 	a.log.Debugf("%s: done", logPrefix)
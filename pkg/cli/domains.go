@@ -123,6 +123,9 @@ func (a *ActionDomains) Execute(args []string) error {
 		for _, r := range d.A {
 			table.AddRow([]string{"a", r.A.String(), strconv.FormatUint(uint64(r.TTL), 10)})
 		}
+		for _, r := range d.AAAA {
+			table.AddRow([]string{"aaaa", r.AAAA.String(), strconv.FormatUint(uint64(r.TTL), 10)})
+		}
 		os.Stdout.WriteString(table.Render())
 	}
 
@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"wgnetwork/api/manager"
+	"wgnetwork/pkg/rpcapi"
+)
+
+// ActionEgressRuleAdd object.
+type ActionEgressRuleAdd struct {
+	flagset *flag.FlagSet
+	log     logger
+
+	unixSocket *string
+	action     *string
+	ipproto    *string
+	dports     *string
+	dnets      *string
+}
+
+// NewActionEgressRuleAdd constructor.
+func NewActionEgressRuleAdd(log logger) *ActionEgressRuleAdd {
+	flagset := flag.NewFlagSet(
+		"egress-rule-add",
+		flag.ExitOnError)
+
+	unixSocket := flagset.String(
+		"unix-socket",
+		"/tmp/wgmanager.sock",
+		"unix-socket")
+	action := flagset.String(
+		"action",
+		"",
+		"action: block or allow")
+	ipproto := flagset.String(
+		"ipproto",
+		"",
+		"ipproto: tcp, udp or empty for any")
+	dports := flagset.String(
+		"dports",
+		"",
+		"comma separated destination ports")
+	dnets := flagset.String(
+		"dnets",
+		"",
+		"comma separated destination addresses or cidr ranges")
+
+	a := &ActionEgressRuleAdd{
+		flagset: flagset,
+		log:     log,
+
+		unixSocket: unixSocket,
+		action:     action,
+		ipproto:    ipproto,
+		dports:     dports,
+		dnets:      dnets,
+	}
+
+	return a
+}
+
+// Usage prints out flagset usage.
+func (a *ActionEgressRuleAdd) Usage() {
+	a.flagset.Usage()
+}
+
+// Execute action.
+func (a *ActionEgressRuleAdd) Execute(args []string) error {
+	logPrefix := "[egress-rule-add] Execute"
+
+	a.log.Debugf("%s: trying to parse args: %v…", logPrefix, args)
+	err := a.flagset.Parse(args)
+	if err != nil {
+		return errors.New("can't parse args")
+	}
+
+	// validate arguments
+	err = a.validate()
+	if err != nil {
+		return err
+	}
+
+	client := newHTTPClient(*a.unixSocket)
+
+	b := manager.EgressRuleAddRequest{
+		Rule: egressRuleRequest(
+			*a.action, *a.ipproto, *a.dports, *a.dnets),
+	}.Marshal()
+	b = rpcapi.Request{
+		Method: "manager/egress/rule/add",
+		Params: b,
+	}.Marshal()
+	br := bytes.NewBuffer(b)
+
+	resp, err := client.Post(
+		"http://localhost/rpc",
+		"application/json; charset=utf-8",
+		br)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("bad response status code: %d", resp.StatusCode)
+		return err
+	}
+
+	b, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	response := &rpcapi.Response{}
+	err = json.Unmarshal(b, &response)
+	if err != nil {
+		return err
+	}
+
+	os.Stdout.WriteString(string(response.Result) + "\n")
+
+	a.log.Debugf("%s: done", logPrefix)
+
+	return nil
+}
+
+func (a *ActionEgressRuleAdd) validate() error {
+	if a.unixSocket == nil || len(*a.unixSocket) == 0 {
+		return errors.New("unix-socket required")
+	}
+
+	return validateEgressRule(*a.action, *a.dports, *a.dnets)
+}
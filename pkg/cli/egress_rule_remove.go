@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"wgnetwork/api/manager"
+	"wgnetwork/pkg/rpcapi"
+)
+
+// ActionEgressRuleRemove object.
+type ActionEgressRuleRemove struct {
+	flagset *flag.FlagSet
+	log     logger
+
+	unixSocket *string
+	index      *int
+}
+
+// NewActionEgressRuleRemove constructor.
+func NewActionEgressRuleRemove(log logger) *ActionEgressRuleRemove {
+	flagset := flag.NewFlagSet(
+		"egress-rule-remove",
+		flag.ExitOnError)
+
+	unixSocket := flagset.String(
+		"unix-socket",
+		"/tmp/wgmanager.sock",
+		"unix-socket")
+	index := flagset.Int(
+		"index",
+		-1,
+		"rule index")
+
+	a := &ActionEgressRuleRemove{
+		flagset: flagset,
+		log:     log,
+
+		unixSocket: unixSocket,
+		index:      index,
+	}
+
+	return a
+}
+
+// Usage prints out flagset usage.
+func (a *ActionEgressRuleRemove) Usage() {
+	a.flagset.Usage()
+}
+
+// Execute action.
+func (a *ActionEgressRuleRemove) Execute(args []string) error {
+	logPrefix := "[egress-rule-remove] Execute"
+
+	a.log.Debugf("%s: trying to parse args: %v…", logPrefix, args)
+	err := a.flagset.Parse(args)
+	if err != nil {
+		return errors.New("can't parse args")
+	}
+
+	// validate arguments
+	err = a.validate()
+	if err != nil {
+		return err
+	}
+
+	client := newHTTPClient(*a.unixSocket)
+
+	b := manager.EgressRuleRemoveRequest{
+		Index: *a.index,
+	}.Marshal()
+	b = rpcapi.Request{
+		Method: "manager/egress/rule/remove",
+		Params: b,
+	}.Marshal()
+	br := bytes.NewBuffer(b)
+
+	resp, err := client.Post(
+		"http://localhost/rpc",
+		"application/json; charset=utf-8",
+		br)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("bad response status code: %d", resp.StatusCode)
+		return err
+	}
+
+	b, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	response := &rpcapi.Response{}
+	err = json.Unmarshal(b, &response)
+	if err != nil {
+		return err
+	}
+
+	os.Stdout.WriteString(string(response.Result) + "\n")
+
+	a.log.Debugf("%s: done", logPrefix)
+
+	return nil
+}
+
+func (a *ActionEgressRuleRemove) validate() error {
+	if a.unixSocket == nil || len(*a.unixSocket) == 0 {
+		return errors.New("unix-socket required")
+	}
+
+	if a.index == nil || *a.index < 0 {
+		return errors.New("index required")
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"wgnetwork/api/manager"
+	"wgnetwork/pkg/pretty"
+	"wgnetwork/pkg/rpcapi"
+)
+
+// ActionFirewallBlacklist object.
+type ActionFirewallBlacklist struct {
+	flagset *flag.FlagSet
+	log     logger
+
+	unixSocket *string
+}
+
+// NewActionFirewallBlacklist constructor.
+func NewActionFirewallBlacklist(log logger) *ActionFirewallBlacklist {
+	flagset := flag.NewFlagSet(
+		"firewall-blacklist",
+		flag.ExitOnError)
+
+	unixSocket := flagset.String(
+		"unix-socket",
+		"/tmp/wgmanager.sock",
+		"unix-socket")
+
+	a := &ActionFirewallBlacklist{
+		flagset: flagset,
+		log:     log,
+
+		unixSocket: unixSocket,
+	}
+
+	return a
+}
+
+// Usage prints out flagset usage.
+func (a *ActionFirewallBlacklist) Usage() {
+	a.flagset.Usage()
+}
+
+// Execute action.
+func (a *ActionFirewallBlacklist) Execute(args []string) error {
+	logPrefix := "[firewall-blacklist] Execute"
+
+	a.log.Debugf("%s: trying to parse args: %v…", logPrefix, args)
+	err := a.flagset.Parse(args)
+	if err != nil {
+		return errors.New("can't parse args")
+	}
+
+	// validate arguments
+	err = a.validate()
+	if err != nil {
+		return err
+	}
+
+	client := newHTTPClient(*a.unixSocket)
+
+	b := rpcapi.Request{
+		Method: "manager/firewall/blacklist",
+	}.Marshal()
+	br := bytes.NewBuffer(b)
+
+	resp, err := client.Post(
+		"http://localhost/rpc",
+		"application/json; charset=utf-8",
+		br)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("bad response status code: %d", resp.StatusCode)
+		return err
+	}
+
+	b, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	response := &rpcapi.Response{}
+	err = json.Unmarshal(b, &response)
+	if err != nil {
+		return err
+	}
+
+	result := manager.FirewallBlacklistResponse{}
+	err = json.Unmarshal(response.Result, &result)
+	if err != nil {
+		return err
+	}
+
+	table := pretty.NewTable(1)
+	table.SetHeader([]string{"banned ips"})
+
+	for _, v := range result {
+		table.AddRow([]string{v})
+	}
+
+	os.Stdout.WriteString(table.Render())
+
+	a.log.Debugf("%s: done", logPrefix)
+
+	return nil
+}
+
+func (a *ActionFirewallBlacklist) validate() error {
+	if a.unixSocket == nil || len(*a.unixSocket) == 0 {
+		return errors.New("unix-socket required")
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"wgnetwork/pkg/rpcapi"
+)
+
+// ActionFirewallConfirm object.
+type ActionFirewallConfirm struct {
+	flagset *flag.FlagSet
+	log     logger
+
+	unixSocket *string
+}
+
+// NewActionFirewallConfirm constructor.
+func NewActionFirewallConfirm(log logger) *ActionFirewallConfirm {
+	flagset := flag.NewFlagSet(
+		"firewall-confirm",
+		flag.ExitOnError)
+
+	unixSocket := flagset.String(
+		"unix-socket",
+		"/tmp/wgmanager.sock",
+		"unix-socket")
+
+	a := &ActionFirewallConfirm{
+		flagset: flagset,
+		log:     log,
+
+		unixSocket: unixSocket,
+	}
+
+	return a
+}
+
+// Usage prints out flagset usage.
+func (a *ActionFirewallConfirm) Usage() {
+	a.flagset.Usage()
+}
+
+// Execute action.
+func (a *ActionFirewallConfirm) Execute(args []string) error {
+	logPrefix := "[firewall-confirm] Execute"
+
+	a.log.Debugf("%s: trying to parse args: %v…", logPrefix, args)
+	err := a.flagset.Parse(args)
+	if err != nil {
+		return errors.New("can't parse args")
+	}
+
+	// validate arguments
+	err = a.validate()
+	if err != nil {
+		return err
+	}
+
+	client := newHTTPClient(*a.unixSocket)
+
+	b := rpcapi.Request{
+		Method: "manager/firewall/confirm",
+	}.Marshal()
+	br := bytes.NewBuffer(b)
+
+	resp, err := client.Post(
+		"http://localhost/rpc",
+		"application/json; charset=utf-8",
+		br)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("bad response status code: %d", resp.StatusCode)
+		return err
+	}
+
+	b, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	response := &rpcapi.Response{}
+	err = json.Unmarshal(b, &response)
+	if err != nil {
+		return err
+	}
+
+	os.Stdout.WriteString(string(response.Result) + "\n")
+
+	a.log.Debugf("%s: done", logPrefix)
+
+	return nil
+}
+
+func (a *ActionFirewallConfirm) validate() error {
+	if a.unixSocket == nil || len(*a.unixSocket) == 0 {
+		return errors.New("unix-socket required")
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"wgnetwork/api/manager"
+	"wgnetwork/pkg/rpcapi"
+)
+
+// ActionFirewallPending object.
+type ActionFirewallPending struct {
+	flagset *flag.FlagSet
+	log     logger
+
+	unixSocket *string
+}
+
+// NewActionFirewallPending constructor.
+func NewActionFirewallPending(log logger) *ActionFirewallPending {
+	flagset := flag.NewFlagSet(
+		"firewall-pending",
+		flag.ExitOnError)
+
+	unixSocket := flagset.String(
+		"unix-socket",
+		"/tmp/wgmanager.sock",
+		"unix-socket")
+
+	a := &ActionFirewallPending{
+		flagset: flagset,
+		log:     log,
+
+		unixSocket: unixSocket,
+	}
+
+	return a
+}
+
+// Usage prints out flagset usage.
+func (a *ActionFirewallPending) Usage() {
+	a.flagset.Usage()
+}
+
+// Execute action.
+func (a *ActionFirewallPending) Execute(args []string) error {
+	logPrefix := "[firewall-pending] Execute"
+
+	a.log.Debugf("%s: trying to parse args: %v…", logPrefix, args)
+	err := a.flagset.Parse(args)
+	if err != nil {
+		return errors.New("can't parse args")
+	}
+
+	// validate arguments
+	err = a.validate()
+	if err != nil {
+		return err
+	}
+
+	client := newHTTPClient(*a.unixSocket)
+
+	b := rpcapi.Request{
+		Method: "manager/firewall/pending",
+	}.Marshal()
+	br := bytes.NewBuffer(b)
+
+	resp, err := client.Post(
+		"http://localhost/rpc",
+		"application/json; charset=utf-8",
+		br)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("bad response status code: %d", resp.StatusCode)
+		return err
+	}
+
+	b, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	response := &rpcapi.Response{}
+	err = json.Unmarshal(b, &response)
+	if err != nil {
+		return err
+	}
+
+	result := manager.FirewallPendingResponse{}
+	err = json.Unmarshal(response.Result, &result)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case !result.Enabled:
+		os.Stdout.WriteString("confirmation is turned off\n")
+	case result.Deadline == nil:
+		os.Stdout.WriteString("no changes pending confirmation\n")
+	default:
+		left := time.Until(*result.Deadline).Truncate(time.Second)
+		os.Stdout.WriteString(fmt.Sprintf(
+			"changes pending confirmation until %s (%s left)\n",
+			result.Deadline.Local().Format(time.RFC3339), left))
+	}
+
+	a.log.Debugf("%s: done", logPrefix)
+
+	return nil
+}
+
+func (a *ActionFirewallPending) validate() error {
+	if a.unixSocket == nil || len(*a.unixSocket) == 0 {
+		return errors.New("unix-socket required")
+	}
+
+	return nil
+}
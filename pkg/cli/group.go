@@ -0,0 +1,157 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+
+	"wgnetwork/api/manager"
+	"wgnetwork/pkg/pretty"
+	"wgnetwork/pkg/rpcapi"
+)
+
+// ActionGroup object.
+type ActionGroup struct {
+	flagset *flag.FlagSet
+	log     logger
+
+	unixSocket *string
+	name       *string
+}
+
+// NewActionGroup constructor.
+func NewActionGroup(log logger) *ActionGroup {
+	flagset := flag.NewFlagSet(
+		"group",
+		flag.ExitOnError)
+
+	unixSocket := flagset.String(
+		"unix-socket",
+		"/tmp/wgmanager.sock",
+		"unix-socket")
+	name := flagset.String(
+		"name",
+		"",
+		"group name")
+
+	a := &ActionGroup{
+		flagset: flagset,
+		log:     log,
+
+		unixSocket: unixSocket,
+		name:       name,
+	}
+
+	return a
+}
+
+// Usage prints out flagset usage.
+func (a *ActionGroup) Usage() {
+	a.flagset.Usage()
+}
+
+// Execute action.
+func (a *ActionGroup) Execute(args []string) error {
+	logPrefix := "[group] Execute"
+
+	a.log.Debugf("%s: trying to parse args: %v…", logPrefix, args)
+	err := a.flagset.Parse(args)
+	if err != nil {
+		return errors.New("can't parse args")
+	}
+
+	// validate arguments
+	err = a.validate()
+	if err != nil {
+		return err
+	}
+
+	client := newHTTPClient(*a.unixSocket)
+
+	b := manager.GroupRequest{
+		Name: *a.name,
+	}.Marshal()
+	b = rpcapi.Request{
+		Method: "manager/group",
+		Params: b,
+	}.Marshal()
+	br := bytes.NewBuffer(b)
+
+	resp, err := client.Post(
+		"http://localhost/rpc",
+		"application/json; charset=utf-8",
+		br)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("bad response status code: %d", resp.StatusCode)
+		return err
+	}
+
+	b, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	response := &rpcapi.Response{}
+	err = json.Unmarshal(b, &response)
+	if err != nil {
+		return err
+	}
+
+	result := manager.GroupResponse{}
+	err = json.Unmarshal(response.Result, &result)
+	if err != nil {
+		return err
+	}
+
+	table := pretty.NewTable(4)
+	table.SetHeader([]string{"ip", "label", "network", "user uuid"})
+	for _, d := range result.Devices {
+		table.AddRow([]string{d.IP, d.Label, d.Network, d.UserUUID})
+	}
+
+	os.Stdout.WriteString(table.Render())
+
+	table = pretty.NewTable(4)
+	table.SetHeader([]string{"index", "to", "ipproto", "dports"})
+	for _, p := range result.Policies {
+		ipproto := p.IPProto
+		if ipproto == "" {
+			ipproto = "any"
+		}
+		table.AddRow([]string{
+			strconv.Itoa(p.Index),
+			p.To,
+			ipproto,
+			joinPorts(p.DPorts),
+		})
+	}
+
+	os.Stdout.WriteString(table.Render())
+
+	a.log.Debugf("%s: done", logPrefix)
+
+	return nil
+}
+
+func (a *ActionGroup) validate() error {
+	if a.unixSocket == nil || len(*a.unixSocket) == 0 {
+		return errors.New("unix-socket required")
+	}
+
+	if a.name == nil || len(*a.name) == 0 {
+		return errors.New("name required")
+	}
+
+	return nil
+}
@@ -0,0 +1,157 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"wgnetwork/api/manager"
+	"wgnetwork/pkg/rpcapi"
+)
+
+// ActionGroupPolicyAdd object.
+type ActionGroupPolicyAdd struct {
+	flagset *flag.FlagSet
+	log     logger
+
+	unixSocket *string
+	name       *string
+	to         *string
+	ipproto    *string
+	dports     *string
+}
+
+// NewActionGroupPolicyAdd constructor.
+func NewActionGroupPolicyAdd(log logger) *ActionGroupPolicyAdd {
+	flagset := flag.NewFlagSet(
+		"group-policy-add",
+		flag.ExitOnError)
+
+	unixSocket := flagset.String(
+		"unix-socket",
+		"/tmp/wgmanager.sock",
+		"unix-socket")
+	name := flagset.String(
+		"name",
+		"",
+		"group name")
+	to := flagset.String(
+		"to",
+		"",
+		"target group name")
+	ipproto := flagset.String(
+		"ipproto",
+		"",
+		"ipproto: tcp, udp, icmp or empty for any")
+	dports := flagset.String(
+		"dports",
+		"",
+		"comma separated destination ports")
+
+	a := &ActionGroupPolicyAdd{
+		flagset: flagset,
+		log:     log,
+
+		unixSocket: unixSocket,
+		name:       name,
+		to:         to,
+		ipproto:    ipproto,
+		dports:     dports,
+	}
+
+	return a
+}
+
+// Usage prints out flagset usage.
+func (a *ActionGroupPolicyAdd) Usage() {
+	a.flagset.Usage()
+}
+
+// Execute action.
+func (a *ActionGroupPolicyAdd) Execute(args []string) error {
+	logPrefix := "[group-policy-add] Execute"
+
+	a.log.Debugf("%s: trying to parse args: %v…", logPrefix, args)
+	err := a.flagset.Parse(args)
+	if err != nil {
+		return errors.New("can't parse args")
+	}
+
+	// validate arguments
+	err = a.validate()
+	if err != nil {
+		return err
+	}
+
+	dports, _ := splitPorts(*a.dports)
+
+	client := newHTTPClient(*a.unixSocket)
+
+	b := manager.GroupPolicyAddRequest{
+		Name:    *a.name,
+		To:      *a.to,
+		IPProto: *a.ipproto,
+		DPorts:  dports,
+	}.Marshal()
+	b = rpcapi.Request{
+		Method: "manager/group/policy/add",
+		Params: b,
+	}.Marshal()
+	br := bytes.NewBuffer(b)
+
+	resp, err := client.Post(
+		"http://localhost/rpc",
+		"application/json; charset=utf-8",
+		br)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("bad response status code: %d", resp.StatusCode)
+		return err
+	}
+
+	b, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	response := &rpcapi.Response{}
+	err = json.Unmarshal(b, &response)
+	if err != nil {
+		return err
+	}
+
+	os.Stdout.WriteString(string(response.Result) + "\n")
+
+	a.log.Debugf("%s: done", logPrefix)
+
+	return nil
+}
+
+func (a *ActionGroupPolicyAdd) validate() error {
+	if a.unixSocket == nil || len(*a.unixSocket) == 0 {
+		return errors.New("unix-socket required")
+	}
+
+	if a.name == nil || len(*a.name) == 0 {
+		return errors.New("name required")
+	}
+
+	if a.to == nil || len(*a.to) == 0 {
+		return errors.New("to required")
+	}
+
+	if _, err := splitPorts(*a.dports); err != nil {
+		return errors.New("bad dports value")
+	}
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+
+	"wgnetwork/api/manager"
+	"wgnetwork/pkg/rpcapi"
+)
+
+// ActionPortForwardAdd object.
+type ActionPortForwardAdd struct {
+	flagset *flag.FlagSet
+	log     logger
+
+	unixSocket *string
+	proto      *string
+	port       *int
+	ip         *string
+	devicePort *int
+}
+
+// NewActionPortForwardAdd constructor.
+func NewActionPortForwardAdd(log logger) *ActionPortForwardAdd {
+	flagset := flag.NewFlagSet(
+		"port-forward-add",
+		flag.ExitOnError)
+
+	unixSocket := flagset.String(
+		"unix-socket",
+		"/tmp/wgmanager.sock",
+		"unix-socket")
+	proto := flagset.String(
+		"proto",
+		"tcp",
+		"proto: tcp or udp")
+	port := flagset.Int(
+		"port",
+		0,
+		"wan port")
+	ip := flagset.String(
+		"ip",
+		"",
+		"device ip")
+	devicePort := flagset.Int(
+		"device_port",
+		0,
+		"device port")
+
+	a := &ActionPortForwardAdd{
+		flagset: flagset,
+		log:     log,
+
+		unixSocket: unixSocket,
+		proto:      proto,
+		port:       port,
+		ip:         ip,
+		devicePort: devicePort,
+	}
+
+	return a
+}
+
+// Usage prints out flagset usage.
+func (a *ActionPortForwardAdd) Usage() {
+	a.flagset.Usage()
+}
+
+// Execute action.
+func (a *ActionPortForwardAdd) Execute(args []string) error {
+	logPrefix := "[port-forward-add] Execute"
+
+	a.log.Debugf("%s: trying to parse args: %v…", logPrefix, args)
+	err := a.flagset.Parse(args)
+	if err != nil {
+		return errors.New("can't parse args")
+	}
+
+	// validate arguments
+	err = a.validate()
+	if err != nil {
+		return err
+	}
+
+	client := newHTTPClient(*a.unixSocket)
+
+	b := manager.PortForwardAddRequest{
+		Proto:      *a.proto,
+		Port:       uint16(*a.port),
+		IP:         *a.ip,
+		DevicePort: uint16(*a.devicePort),
+	}.Marshal()
+	b = rpcapi.Request{
+		Method: "manager/port-forward/add",
+		Params: b,
+	}.Marshal()
+	br := bytes.NewBuffer(b)
+
+	resp, err := client.Post(
+		"http://localhost/rpc",
+		"application/json; charset=utf-8",
+		br)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("bad response status code: %d", resp.StatusCode)
+		return err
+	}
+
+	b, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	response := &rpcapi.Response{}
+	err = json.Unmarshal(b, &response)
+	if err != nil {
+		return err
+	}
+
+	os.Stdout.WriteString(string(response.Result) + "\n")
+
+	a.log.Debugf("%s: done", logPrefix)
+
+	return nil
+}
+
+func (a *ActionPortForwardAdd) validate() error {
+	if a.unixSocket == nil || len(*a.unixSocket) == 0 {
+		return errors.New("unix-socket required")
+	}
+
+	if a.proto == nil || (*a.proto != "tcp" && *a.proto != "udp") {
+		return errors.New("bad proto value")
+	}
+
+	if a.port == nil || *a.port <= 0 || *a.port > 65535 {
+		return errors.New("bad port value")
+	}
+
+	if a.ip == nil {
+		return errors.New("ip required")
+	}
+
+	if net.ParseIP(*a.ip).To4() == nil {
+		return errors.New("bad ip value")
+	}
+
+	if a.devicePort == nil || *a.devicePort <= 0 || *a.devicePort > 65535 {
+		return errors.New("bad device_port value")
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"wgnetwork/api/manager"
+	"wgnetwork/pkg/rpcapi"
+)
+
+// ActionPortForwardRemove object.
+type ActionPortForwardRemove struct {
+	flagset *flag.FlagSet
+	log     logger
+
+	unixSocket *string
+	proto      *string
+	port       *int
+}
+
+// NewActionPortForwardRemove constructor.
+func NewActionPortForwardRemove(log logger) *ActionPortForwardRemove {
+	flagset := flag.NewFlagSet(
+		"port-forward-remove",
+		flag.ExitOnError)
+
+	unixSocket := flagset.String(
+		"unix-socket",
+		"/tmp/wgmanager.sock",
+		"unix-socket")
+	proto := flagset.String(
+		"proto",
+		"tcp",
+		"proto: tcp or udp")
+	port := flagset.Int(
+		"port",
+		0,
+		"wan port")
+
+	a := &ActionPortForwardRemove{
+		flagset: flagset,
+		log:     log,
+
+		unixSocket: unixSocket,
+		proto:      proto,
+		port:       port,
+	}
+
+	return a
+}
+
+// Usage prints out flagset usage.
+func (a *ActionPortForwardRemove) Usage() {
+	a.flagset.Usage()
+}
+
+// Execute action.
+func (a *ActionPortForwardRemove) Execute(args []string) error {
+	logPrefix := "[port-forward-remove] Execute"
+
+	a.log.Debugf("%s: trying to parse args: %v…", logPrefix, args)
+	err := a.flagset.Parse(args)
+	if err != nil {
+		return errors.New("can't parse args")
+	}
+
+	// validate arguments
+	err = a.validate()
+	if err != nil {
+		return err
+	}
+
+	client := newHTTPClient(*a.unixSocket)
+
+	b := manager.PortForwardRemoveRequest{
+		Proto: *a.proto,
+		Port:  uint16(*a.port),
+	}.Marshal()
+	b = rpcapi.Request{
+		Method: "manager/port-forward/remove",
+		Params: b,
+	}.Marshal()
+	br := bytes.NewBuffer(b)
+
+	resp, err := client.Post(
+		"http://localhost/rpc",
+		"application/json; charset=utf-8",
+		br)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("bad response status code: %d", resp.StatusCode)
+		return err
+	}
+
+	b, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	response := &rpcapi.Response{}
+	err = json.Unmarshal(b, &response)
+	if err != nil {
+		return err
+	}
+
+	os.Stdout.WriteString(string(response.Result) + "\n")
+
+	a.log.Debugf("%s: done", logPrefix)
+
+	return nil
+}
+
+func (a *ActionPortForwardRemove) validate() error {
+	if a.unixSocket == nil || len(*a.unixSocket) == 0 {
+		return errors.New("unix-socket required")
+	}
+
+	if a.proto == nil || (*a.proto != "tcp" && *a.proto != "udp") {
+		return errors.New("bad proto value")
+	}
+
+	if a.port == nil || *a.port <= 0 || *a.port > 65535 {
+		return errors.New("bad port value")
+	}
+
+	return nil
+}
@@ -102,16 +102,14 @@ func (a *ActionTrustIPSet) Execute(args []string) error {
 		return err
 	}
 
-	ips := make([]string, len(result))
-	for i := 0; i < len(result); i++ {
-		ips[i] = result[i].String()
-	}
-
-	table := pretty.NewTable(1)
-	table.SetHeader([]string{"trusted ips"})
-
-	for _, ip := range ips {
-		table.AddRow([]string{ip})
+	table := pretty.NewTable(3)
+	table.SetHeader([]string{"trusted ips", "comment", "expires"})
+
+	for _, v := range result {
+		table.AddRow([]string{
+			v.IP,
+			v.Comment,
+			formatExpires(v.ExpiresAt, v.Expired)})
 	}
 
 	os.Stdout.WriteString(table.Render())
@@ -7,11 +7,12 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
-	"net"
 	"net/http"
 	"os"
+	"time"
 
 	"wgnetwork/api/manager"
+	"wgnetwork/model"
 	"wgnetwork/pkg/rpcapi"
 )
 
@@ -22,6 +23,8 @@ type ActionTrustIPSetAdd struct {
 
 	unixSocket *string
 	ip         *string
+	comment    *string
+	expiresAt  *string
 }
 
 // NewActionTrustIPSetAdd constructor.
@@ -37,7 +40,15 @@ func NewActionTrustIPSetAdd(log logger) *ActionTrustIPSetAdd {
 	ip := flagset.String(
 		"ip",
 		"",
-		"device ip")
+		"ip address or cidr range")
+	comment := flagset.String(
+		"comment",
+		"",
+		"whom the access is granted to")
+	expiresAt := flagset.String(
+		"expires_at",
+		"",
+		"expiration time in RFC 3339 format, e.g. 2024-12-31T23:59:59Z")
 
 	a := &ActionTrustIPSetAdd{
 		flagset: flagset,
@@ -45,6 +56,8 @@ func NewActionTrustIPSetAdd(log logger) *ActionTrustIPSetAdd {
 
 		unixSocket: unixSocket,
 		ip:         ip,
+		comment:    comment,
+		expiresAt:  expiresAt,
 	}
 
 	return a
@@ -74,7 +87,9 @@ func (a *ActionTrustIPSetAdd) Execute(args []string) error {
 	client := newHTTPClient(*a.unixSocket)
 
 	b := manager.TrustIPSetRequest{
-		IP: *a.ip,
+		IP:        *a.ip,
+		Comment:   *a.comment,
+		ExpiresAt: *a.expiresAt,
 	}.Marshal()
 	b = rpcapi.Request{
 		Method: "manager/trust/ipset/add",
@@ -123,9 +138,17 @@ func (a *ActionTrustIPSetAdd) validate() error {
 		return errors.New("ip required")
 	}
 
-	if net.ParseIP(*a.ip).To4() == nil {
+	_, err := model.ParseTrustIPNet(*a.ip)
+	if err != nil {
 		return errors.New("bad ip value")
 	}
 
+	if a.expiresAt != nil && len(*a.expiresAt) > 0 {
+		_, err = time.Parse(time.RFC3339, *a.expiresAt)
+		if err != nil {
+			return errors.New("bad expires_at value")
+		}
+	}
+
 	return nil
 }
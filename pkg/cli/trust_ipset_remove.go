@@ -7,11 +7,11 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
-	"net"
 	"net/http"
 	"os"
 
 	"wgnetwork/api/manager"
+	"wgnetwork/model"
 	"wgnetwork/pkg/rpcapi"
 )
 
@@ -37,7 +37,7 @@ func NewActionTrustIPSetRemove(log logger) *ActionTrustIPSetRemove {
 	ip := flagset.String(
 		"ip",
 		"",
-		"device ip")
+		"ip address or cidr range")
 
 	a := &ActionTrustIPSetRemove{
 		flagset: flagset,
@@ -123,7 +123,8 @@ func (a *ActionTrustIPSetRemove) validate() error {
 		return errors.New("ip required")
 	}
 
-	if net.ParseIP(*a.ip).To4() == nil {
+	_, err := model.ParseTrustIPNet(*a.ip)
+	if err != nil {
 		return errors.New("bad ip value")
 	}
 
@@ -0,0 +1,197 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/google/uuid"
+
+	"wgnetwork/api/manager"
+	"wgnetwork/pkg/rpcapi"
+)
+
+// ActionUserRuleAdd object.
+type ActionUserRuleAdd struct {
+	flagset *flag.FlagSet
+	log     logger
+
+	unixSocket *string
+	uuid       *string
+	direction  *string
+	ipproto    *string
+	sports     *string
+	dports     *string
+	saddrs     *string
+	daddrs     *string
+}
+
+// NewActionUserRuleAdd constructor.
+func NewActionUserRuleAdd(log logger) *ActionUserRuleAdd {
+	flagset := flag.NewFlagSet(
+		"user-rule-add",
+		flag.ExitOnError)
+
+	unixSocket := flagset.String(
+		"unix-socket",
+		"/tmp/wgmanager.sock",
+		"unix-socket")
+	uuid := flagset.String(
+		"uuid",
+		"",
+		"user uuid")
+	direction := flagset.String(
+		"direction",
+		"",
+		"direction: out or in")
+	ipproto := flagset.String(
+		"ipproto",
+		"",
+		"ipproto: tcp, udp, icmp or empty for any")
+	sports := flagset.String(
+		"sports",
+		"",
+		"comma separated source ports")
+	dports := flagset.String(
+		"dports",
+		"",
+		"comma separated destination ports")
+	saddrs := flagset.String(
+		"saddrs",
+		"",
+		"comma separated source addresses")
+	daddrs := flagset.String(
+		"daddrs",
+		"",
+		"comma separated destination addresses")
+
+	a := &ActionUserRuleAdd{
+		flagset: flagset,
+		log:     log,
+
+		unixSocket: unixSocket,
+		uuid:       uuid,
+		direction:  direction,
+		ipproto:    ipproto,
+		sports:     sports,
+		dports:     dports,
+		saddrs:     saddrs,
+		daddrs:     daddrs,
+	}
+
+	return a
+}
+
+// Usage prints out flagset usage.
+func (a *ActionUserRuleAdd) Usage() {
+	a.flagset.Usage()
+}
+
+// Execute action.
+func (a *ActionUserRuleAdd) Execute(args []string) error {
+	logPrefix := "[user-rule-add] Execute"
+
+	a.log.Debugf("%s: trying to parse args: %v…", logPrefix, args)
+	err := a.flagset.Parse(args)
+	if err != nil {
+		return errors.New("can't parse args")
+	}
+
+	// validate arguments
+	err = a.validate()
+	if err != nil {
+		return err
+	}
+
+	client := newHTTPClient(*a.unixSocket)
+
+	b := manager.UserRuleAddRequest{
+		UUID: *a.uuid,
+		Rule: ruleRequest(
+			*a.direction, *a.ipproto,
+			*a.sports, *a.dports,
+			*a.saddrs, *a.daddrs),
+	}.Marshal()
+	b = rpcapi.Request{
+		Method: "manager/user/rule/add",
+		Params: b,
+	}.Marshal()
+	br := bytes.NewBuffer(b)
+
+	resp, err := client.Post(
+		"http://localhost/rpc",
+		"application/json; charset=utf-8",
+		br)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("bad response status code: %d", resp.StatusCode)
+		return err
+	}
+
+	b, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	response := &rpcapi.Response{}
+	err = json.Unmarshal(b, &response)
+	if err != nil {
+		return err
+	}
+
+	os.Stdout.WriteString(string(response.Result) + "\n")
+
+	a.log.Debugf("%s: done", logPrefix)
+
+	return nil
+}
+
+func (a *ActionUserRuleAdd) validate() error {
+	if a.unixSocket == nil || len(*a.unixSocket) == 0 {
+		return errors.New("unix-socket required")
+	}
+
+	if a.uuid == nil || len(*a.uuid) == 0 {
+		return errors.New("uuid required")
+	}
+
+	if _, err := uuid.Parse(*a.uuid); err != nil {
+		return errors.New("bad uuid value")
+	}
+
+	if a.direction == nil || len(*a.direction) == 0 {
+		return errors.New("direction required")
+	}
+
+	if _, err := splitPorts(*a.sports); err != nil {
+		return errors.New("bad sports value")
+	}
+
+	if _, err := splitPorts(*a.dports); err != nil {
+		return errors.New("bad dports value")
+	}
+
+	for _, v := range splitRoutes(*a.saddrs) {
+		if net.ParseIP(v) == nil {
+			return errors.New("bad saddrs value")
+		}
+	}
+
+	for _, v := range splitRoutes(*a.daddrs) {
+		if net.ParseIP(v) == nil {
+			return errors.New("bad daddrs value")
+		}
+	}
+
+	return nil
+}
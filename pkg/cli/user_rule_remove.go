@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"github.com/google/uuid"
+
+	"wgnetwork/api/manager"
+	"wgnetwork/pkg/rpcapi"
+)
+
+// ActionUserRuleRemove object.
+type ActionUserRuleRemove struct {
+	flagset *flag.FlagSet
+	log     logger
+
+	unixSocket *string
+	uuid       *string
+	index      *int
+}
+
+// NewActionUserRuleRemove constructor.
+func NewActionUserRuleRemove(log logger) *ActionUserRuleRemove {
+	flagset := flag.NewFlagSet(
+		"user-rule-remove",
+		flag.ExitOnError)
+
+	unixSocket := flagset.String(
+		"unix-socket",
+		"/tmp/wgmanager.sock",
+		"unix-socket")
+	uuid := flagset.String(
+		"uuid",
+		"",
+		"user uuid")
+	index := flagset.Int(
+		"index",
+		-1,
+		"rule index")
+
+	a := &ActionUserRuleRemove{
+		flagset: flagset,
+		log:     log,
+
+		unixSocket: unixSocket,
+		uuid:       uuid,
+		index:      index,
+	}
+
+	return a
+}
+
+// Usage prints out flagset usage.
+func (a *ActionUserRuleRemove) Usage() {
+	a.flagset.Usage()
+}
+
+// Execute action.
+func (a *ActionUserRuleRemove) Execute(args []string) error {
+	logPrefix := "[user-rule-remove] Execute"
+
+	a.log.Debugf("%s: trying to parse args: %v…", logPrefix, args)
+	err := a.flagset.Parse(args)
+	if err != nil {
+		return errors.New("can't parse args")
+	}
+
+	// validate arguments
+	err = a.validate()
+	if err != nil {
+		return err
+	}
+
+	client := newHTTPClient(*a.unixSocket)
+
+	b := manager.UserRuleRemoveRequest{
+		UUID:  *a.uuid,
+		Index: *a.index,
+	}.Marshal()
+	b = rpcapi.Request{
+		Method: "manager/user/rule/remove",
+		Params: b,
+	}.Marshal()
+	br := bytes.NewBuffer(b)
+
+	resp, err := client.Post(
+		"http://localhost/rpc",
+		"application/json; charset=utf-8",
+		br)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("bad response status code: %d", resp.StatusCode)
+		return err
+	}
+
+	b, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	response := &rpcapi.Response{}
+	err = json.Unmarshal(b, &response)
+	if err != nil {
+		return err
+	}
+
+	os.Stdout.WriteString(string(response.Result) + "\n")
+
+	a.log.Debugf("%s: done", logPrefix)
+
+	return nil
+}
+
+func (a *ActionUserRuleRemove) validate() error {
+	if a.unixSocket == nil || len(*a.unixSocket) == 0 {
+		return errors.New("unix-socket required")
+	}
+
+	if a.uuid == nil || len(*a.uuid) == 0 {
+		return errors.New("uuid required")
+	}
+
+	if _, err := uuid.Parse(*a.uuid); err != nil {
+		return errors.New("bad uuid value")
+	}
+
+	if a.index == nil || *a.index < 0 {
+		return errors.New("index required")
+	}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"github.com/google/uuid"
+
+	"wgnetwork/api/manager"
+	"wgnetwork/pkg/rpcapi"
+)
+
+// ActionUserRules object.
+type ActionUserRules struct {
+	flagset *flag.FlagSet
+	log     logger
+
+	unixSocket *string
+	uuid       *string
+}
+
+// NewActionUserRules constructor.
+func NewActionUserRules(log logger) *ActionUserRules {
+	flagset := flag.NewFlagSet(
+		"user-rules",
+		flag.ExitOnError)
+
+	unixSocket := flagset.String(
+		"unix-socket",
+		"/tmp/wgmanager.sock",
+		"unix-socket")
+	uuid := flagset.String(
+		"uuid",
+		"",
+		"user uuid")
+
+	a := &ActionUserRules{
+		flagset: flagset,
+		log:     log,
+
+		unixSocket: unixSocket,
+		uuid:       uuid,
+	}
+
+	return a
+}
+
+// Usage prints out flagset usage.
+func (a *ActionUserRules) Usage() {
+	a.flagset.Usage()
+}
+
+// Execute action.
+func (a *ActionUserRules) Execute(args []string) error {
+	logPrefix := "[user-rules] Execute"
+
+	a.log.Debugf("%s: trying to parse args: %v…", logPrefix, args)
+	err := a.flagset.Parse(args)
+	if err != nil {
+		return errors.New("can't parse args")
+	}
+
+	// validate arguments
+	err = a.validate()
+	if err != nil {
+		return err
+	}
+
+	client := newHTTPClient(*a.unixSocket)
+
+	b := manager.UserRequest{
+		UUID: *a.uuid,
+	}.Marshal()
+	b = rpcapi.Request{
+		Method: "manager/user/rules",
+		Params: b,
+	}.Marshal()
+	br := bytes.NewBuffer(b)
+
+	resp, err := client.Post(
+		"http://localhost/rpc",
+		"application/json; charset=utf-8",
+		br)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("bad response status code: %d", resp.StatusCode)
+		return err
+	}
+
+	b, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	response := &rpcapi.Response{}
+	err = json.Unmarshal(b, &response)
+	if err != nil {
+		return err
+	}
+
+	result := manager.RulesResponse{}
+	err = json.Unmarshal(response.Result, &result)
+	if err != nil {
+		return err
+	}
+
+	os.Stdout.WriteString(renderRules(result))
+
+	a.log.Debugf("%s: done", logPrefix)
+
+	return nil
+}
+
+func (a *ActionUserRules) validate() error {
+	if a.unixSocket == nil || len(*a.unixSocket) == 0 {
+		return errors.New("unix-socket required")
+	}
+
+	if a.uuid == nil || len(*a.uuid) == 0 {
+		return errors.New("uuid required")
+	}
+
+	if _, err := uuid.Parse(*a.uuid); err != nil {
+		return errors.New("bad uuid value")
+	}
+
+	return nil
+}
@@ -10,6 +10,8 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"wgnetwork/api/manager"
 	"wgnetwork/pkg/pretty"
@@ -69,7 +71,7 @@ func (a *ActionWgCfg) Execute(args []string) error {
 	client := newHTTPClient(*a.unixSocket)
 
 	b := rpcapi.Request{
-		Method: "manager/wg/cfg",
+		Method: "manager/wg/networks",
 	}.Marshal()
 	br := bytes.NewBuffer(b)
 
@@ -98,20 +100,25 @@ func (a *ActionWgCfg) Execute(args []string) error {
 		return err
 	}
 
-	result := manager.WgCfgResponse{}
+	result := manager.WgNetworksResponse{}
 	err = json.Unmarshal(response.Result, &result)
 	if err != nil {
 		return err
 	}
 
-	table := pretty.NewTable(4)
-	table.SetHeader([]string{"wan ip", "wg inet", "wg port", "wg pubkey"})
-
-	table.AddRow([]string{
-		result.WanIP,
-		result.WgInet,
-		strconv.FormatUint(uint64(result.WgPort), 10),
-		result.WgPubKey})
+	table := pretty.NewTable(7)
+	table.SetHeader([]string{"network", "wan ip", "endpoints", "wg inet", "wg port", "wg pubkey", "rotated at"})
+
+	for _, n := range result {
+		table.AddRow([]string{
+			n.Network,
+			n.WanIP,
+			strings.Join(n.Endpoints, "\n"),
+			strings.TrimSpace(n.WgInet + "\n" + n.WgInet6),
+			strconv.FormatUint(uint64(n.WgPort), 10),
+			n.WgPubKey,
+			formatRotatedAt(n.RotatedAt)})
+	}
 
 	os.Stdout.WriteString(table.Render())
 
@@ -120,6 +127,14 @@ func (a *ActionWgCfg) Execute(args []string) error {
 	return nil
 }
 
+func formatRotatedAt(t *time.Time) string {
+	if t == nil {
+		return "never"
+	}
+
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
 func (a *ActionWgCfg) validate() error {
 	if a.unixSocket == nil || len(*a.unixSocket) == 0 {
 		return errors.New("unix-socket required")
@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"wgnetwork/api/manager"
+	"wgnetwork/model"
+	"wgnetwork/pkg/pretty"
+	"wgnetwork/pkg/rpcapi"
+)
+
+// ActionWgEndpointsSet object.
+type ActionWgEndpointsSet struct {
+	flagset *flag.FlagSet
+	log     logger
+
+	unixSocket *string
+	network    *string
+	endpoints  *string
+}
+
+// NewActionWgEndpointsSet constructor.
+func NewActionWgEndpointsSet(log logger) *ActionWgEndpointsSet {
+	flagset := flag.NewFlagSet(
+		"wg-endpoints-set",
+		flag.ExitOnError)
+
+	unixSocket := flagset.String(
+		"unix-socket",
+		"/tmp/wgmanager.sock",
+		"unix-socket")
+	network := flagset.String(
+		"network",
+		"",
+		"network name (optional, default network if omitted)")
+	endpoints := flagset.String(
+		"endpoints",
+		"",
+		"comma separated host[:port] endpoints, the first one is primary "+
+			"(configured endpoints are used if omitted)")
+
+	a := &ActionWgEndpointsSet{
+		flagset: flagset,
+		log:     log,
+
+		unixSocket: unixSocket,
+		network:    network,
+		endpoints:  endpoints,
+	}
+
+	return a
+}
+
+// Usage prints out flagset usage.
+func (a *ActionWgEndpointsSet) Usage() {
+	a.flagset.Usage()
+}
+
+// Execute action.
+func (a *ActionWgEndpointsSet) Execute(args []string) error {
+	logPrefix := "[wg-endpoints-set] Execute"
+
+	a.log.Debugf("%s: trying to parse args: %v…", logPrefix, args)
+	err := a.flagset.Parse(args)
+	if err != nil {
+		return errors.New("can't parse args")
+	}
+
+	// validate arguments
+	err = a.validate()
+	if err != nil {
+		return err
+	}
+
+	client := newHTTPClient(*a.unixSocket)
+
+	b := manager.WgEndpointsSetRequest{
+		Network:   *a.network,
+		Endpoints: splitEndpoints(*a.endpoints),
+	}.Marshal()
+	b = rpcapi.Request{
+		Method: "manager/wg/endpoints/set",
+		Params: b,
+	}.Marshal()
+	br := bytes.NewBuffer(b)
+
+	resp, err := client.Post(
+		"http://localhost/rpc",
+		"application/json; charset=utf-8",
+		br)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("bad response status code: %d", resp.StatusCode)
+		return err
+	}
+
+	b, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	response := &rpcapi.Response{}
+	err = json.Unmarshal(b, &response)
+	if err != nil {
+		return err
+	}
+
+	result := manager.WgCfgResponse{}
+	err = json.Unmarshal(response.Result, &result)
+	if err != nil {
+		return err
+	}
+
+	table := pretty.NewTable(4)
+	table.SetHeader([]string{"network", "wan ip", "endpoints", "wg port"})
+
+	table.AddRow([]string{
+		result.Network,
+		result.WanIP,
+		strings.Join(result.Endpoints, "\n"),
+		strconv.FormatUint(uint64(result.WgPort), 10)})
+
+	os.Stdout.WriteString(table.Render())
+
+	a.log.Debugf("%s: done", logPrefix)
+
+	return nil
+}
+
+func (a *ActionWgEndpointsSet) validate() error {
+	if a.unixSocket == nil || len(*a.unixSocket) == 0 {
+		return errors.New("unix-socket required")
+	}
+
+	for _, v := range splitEndpoints(*a.endpoints) {
+		_, err := model.ParseEndpoint(v)
+		if err != nil {
+			return errors.New("bad endpoints value")
+		}
+	}
+
+	return nil
+}
+
+func splitEndpoints(v string) []string {
+	endpoints := []string{}
+	for _, item := range strings.Split(v, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		endpoints = append(endpoints, item)
+	}
+
+	return endpoints
+}
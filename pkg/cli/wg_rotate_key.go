@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"wgnetwork/api/manager"
+	"wgnetwork/pkg/pretty"
+	"wgnetwork/pkg/rpcapi"
+)
+
+// ActionWgRotateKey object.
+type ActionWgRotateKey struct {
+	flagset *flag.FlagSet
+	log     logger
+
+	unixSocket *string
+	network    *string
+	configs    *bool
+}
+
+// NewActionWgRotateKey constructor.
+func NewActionWgRotateKey(log logger) *ActionWgRotateKey {
+	flagset := flag.NewFlagSet(
+		"wg-rotate-key",
+		flag.ExitOnError)
+
+	unixSocket := flagset.String(
+		"unix-socket",
+		"/tmp/wgmanager.sock",
+		"unix-socket")
+	network := flagset.String(
+		"network",
+		"",
+		"network name (optional, default network if omitted)")
+	configs := flagset.Bool(
+		"configs",
+		false,
+		"print updated tunnel configs of all devices")
+
+	a := &ActionWgRotateKey{
+		flagset: flagset,
+		log:     log,
+
+		unixSocket: unixSocket,
+		network:    network,
+		configs:    configs,
+	}
+
+	return a
+}
+
+// Usage prints out flagset usage.
+func (a *ActionWgRotateKey) Usage() {
+	a.flagset.Usage()
+}
+
+// Execute action.
+func (a *ActionWgRotateKey) Execute(args []string) error {
+	logPrefix := "[wg-rotate-key] Execute"
+
+	a.log.Debugf("%s: trying to parse args: %v…", logPrefix, args)
+	err := a.flagset.Parse(args)
+	if err != nil {
+		return errors.New("can't parse args")
+	}
+
+	// validate arguments
+	err = a.validate()
+	if err != nil {
+		return err
+	}
+
+	client := newHTTPClient(*a.unixSocket)
+
+	b := manager.WgRotateKeyRequest{
+		Network: *a.network,
+		Configs: *a.configs,
+	}.Marshal()
+	b = rpcapi.Request{
+		Method: "manager/wg/rotate-key",
+		Params: b,
+	}.Marshal()
+	br := bytes.NewBuffer(b)
+
+	resp, err := client.Post(
+		"http://localhost/rpc",
+		"application/json; charset=utf-8",
+		br)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("bad response status code: %d", resp.StatusCode)
+		return err
+	}
+
+	b, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	response := &rpcapi.Response{}
+	err = json.Unmarshal(b, &response)
+	if err != nil {
+		return err
+	}
+
+	result := manager.WgRotateKeyResponse{}
+	err = json.Unmarshal(response.Result, &result)
+	if err != nil {
+		return err
+	}
+
+	table := pretty.NewTable(7)
+	table.SetHeader([]string{"network", "wan ip", "endpoints", "wg inet", "wg port", "wg pubkey", "rotated at"})
+
+	table.AddRow([]string{
+		result.Network,
+		result.WanIP,
+		strings.Join(result.Endpoints, "\n"),
+		strings.TrimSpace(result.WgInet + "\n" + result.WgInet6),
+		strconv.FormatUint(uint64(result.WgPort), 10),
+		result.WgPubKey,
+		formatRotatedAt(result.RotatedAt)})
+
+	os.Stdout.WriteString(table.Render())
+
+	for _, d := range result.Devices {
+		ipnets := make([]*net.IPNet, len(d.WgDeviceAllowedIPs))
+		for i := 0; i < len(d.WgDeviceAllowedIPs); i++ {
+			_, ipnet, err := net.ParseCIDR(d.WgDeviceAllowedIPs[i])
+			if err != nil {
+				return err
+			}
+			ipnets[i] = ipnet
+		}
+		_, wgipnet, err := net.ParseCIDR(d.WgIPNet)
+		if err != nil {
+			return err
+		}
+		ipnets = allowedIPs(wgipnet, ipnets)
+
+		allowedIPs := make([]string, len(ipnets))
+		for i := range ipnets {
+			allowedIPs[i] = ipnets[i].String()
+		}
+
+		cfg := buildWgCfg(
+			"<PLACEHOLDER>",
+			d.WgPubKey,
+			d.WgDevicePresharedKey,
+			joinAddrs(d.WgDeviceInet, d.WgDeviceInet6),
+			d.WgIP,
+			strings.Join(allowedIPs, ", "),
+			d.Endpoints)
+		os.Stdout.WriteString(fmt.Sprintf(
+			"\ntunnel config of %s (%s, %s):\n",
+			d.WgDeviceInet, d.UserName, d.Label))
+		os.Stdout.WriteString(cfg)
+	}
+
+	a.log.Debugf("%s: done", logPrefix)
+
+	return nil
+}
+
+func (a *ActionWgRotateKey) validate() error {
+	if a.unixSocket == nil || len(*a.unixSocket) == 0 {
+		return errors.New("unix-socket required")
+	}
+
+	return nil
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package conntrack
+
+import "net"
+
+// Flush conntrack entries of addresses mock.
+func Flush(log logger, ips []net.IP) error {
+	return nil
+}
+
+// logger desribes interface of log object.
+type logger interface {
+	Debugf(string, ...interface{})
+}
@@ -0,0 +1,52 @@
+//go:build linux
+// +build linux
+
+package conntrack
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// Flush conntrack entries of addresses,
+// an entry matches by the source or destination of the original direction.
+func Flush(log logger, ips []net.IP) error {
+	if len(ips) == 0 {
+		return nil
+	}
+
+	filter := ipFilter(ips)
+	families := []netlink.InetFamily{unix.AF_INET, unix.AF_INET6}
+	for _, family := range families {
+		n, err := netlink.ConntrackDeleteFilter(
+			netlink.ConntrackTable, family, filter)
+		if err != nil {
+			return fmt.Errorf("can't flush conntrack entries: %v", err)
+		}
+		log.Debugf("%d conntrack entries flushed for %v", n, ips)
+	}
+
+	return nil
+}
+
+// ipFilter matches flows by the original direction addresses.
+type ipFilter []net.IP
+
+// MatchConntrackFlow implements netlink.CustomConntrackFilter.
+func (f ipFilter) MatchConntrackFlow(flow *netlink.ConntrackFlow) bool {
+	for _, ip := range f {
+		if ip.Equal(flow.Forward.SrcIP) || ip.Equal(flow.Forward.DstIP) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// logger desribes interface of log object.
+type logger interface {
+	Debugf(string, ...interface{})
+}
@@ -13,11 +13,31 @@ func Create(
 	return nil
 }
 
+// AddrAdd adds address to the network link of interface mock.
+func AddrAdd(log logger, iface string, ip net.IP, ipNet *net.IPNet) error {
+	return nil
+}
+
 // Remove network link for interface mock.
 func Remove(log logger, iface string) error {
 	return nil
 }
 
+// RouteAdd adds routes for networks via interface mock.
+func RouteAdd(log logger, iface string, ipnets []net.IPNet) error {
+	return nil
+}
+
+// HostNets returns the networks of the host mock.
+func HostNets(exclude []string) ([]*net.IPNet, error) {
+	return nil, nil
+}
+
+// RouteDel removes routes for networks via interface mock.
+func RouteDel(log logger, iface string, ipnets []net.IPNet) error {
+	return nil
+}
+
 // logger desribes interface of log object.
 type logger interface {
 	Debugf(string, ...interface{})
@@ -4,10 +4,12 @@
 package iface
 
 import (
+	"errors"
 	"fmt"
 	"net"
 
 	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
 )
 
 // Create network link for interface.
@@ -53,6 +55,23 @@ func Create(
 	return nil
 }
 
+// AddrAdd adds address to the network link of interface.
+func AddrAdd(log logger, iface string, ip net.IP, ipNet *net.IPNet) error {
+	link, err := netlink.LinkByName(iface)
+	if err != nil {
+		return fmt.Errorf("%q can't find: %v", iface, err)
+	}
+
+	addr := &netlink.Addr{IPNet: &net.IPNet{IP: ip, Mask: ipNet.Mask}}
+	err = netlink.AddrAdd(link, addr)
+	if err != nil {
+		return fmt.Errorf("%q can't add addr: %v", iface, err)
+	}
+	log.Debugf("%q ip %q, net %q was set", iface, ip, ipNet)
+
+	return nil
+}
+
 // Remove network link for interface.
 func Remove(log logger, iface string) error {
 	log.Debugf("%q removing…", iface)
@@ -77,6 +96,124 @@ func Remove(log logger, iface string) error {
 	return nil
 }
 
+// RouteAdd adds routes for networks via interface, a route of the network
+// existing via another link is an error.
+func RouteAdd(log logger, iface string, ipnets []net.IPNet) error {
+	if len(ipnets) == 0 {
+		return nil
+	}
+
+	link, err := netlink.LinkByName(iface)
+	if err != nil {
+		return fmt.Errorf("%q can't find: %v", iface, err)
+	}
+
+	for i := range ipnets {
+		route := &netlink.Route{
+			LinkIndex: link.Attrs().Index,
+			Scope:     netlink.SCOPE_LINK,
+			Dst:       &ipnets[i],
+		}
+		// an existing route is never replaced, it may be the route
+		// the host is reachable by
+		err = netlink.RouteAdd(route)
+		if errors.Is(err, unix.EEXIST) {
+			var routes []netlink.Route
+			routes, err = netlink.RouteListFiltered(netlink.FAMILY_V4,
+				route, netlink.RT_FILTER_OIF|netlink.RT_FILTER_DST)
+			if err == nil && len(routes) == 0 {
+				err = errors.New("route exists via another link")
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("%q can't add route %q: %v", iface, &ipnets[i], err)
+		}
+		log.Debugf("%q route %q added", iface, &ipnets[i])
+	}
+
+	return nil
+}
+
+// HostNets returns the ipv4 networks of the interface addresses and
+// the routes of the host, the interfaces excluded and the default routes
+// are skipped.
+func HostNets(exclude []string) ([]*net.IPNet, error) {
+	links, err := netlink.LinkList()
+	if err != nil {
+		return nil, fmt.Errorf("can't list links: %v", err)
+	}
+
+	var ipnets []*net.IPNet
+	for _, link := range links {
+		skip := false
+		for _, iface := range exclude {
+			if link.Attrs().Name == iface {
+				skip = true
+				break
+			}
+		}
+		if skip {
+			continue
+		}
+
+		addrs, err := netlink.AddrList(link, netlink.FAMILY_V4)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"%q can't list addrs: %v", link.Attrs().Name, err)
+		}
+		for _, addr := range addrs {
+			ipnets = append(ipnets, &net.IPNet{
+				IP:   addr.IP.Mask(addr.Mask),
+				Mask: addr.Mask,
+			})
+		}
+
+		routes, err := netlink.RouteList(link, netlink.FAMILY_V4)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"%q can't list routes: %v", link.Attrs().Name, err)
+		}
+		for _, route := range routes {
+			if route.Dst == nil {
+				continue
+			}
+			if ones, _ := route.Dst.Mask.Size(); ones == 0 {
+				continue
+			}
+			ipnets = append(ipnets, route.Dst)
+		}
+	}
+
+	return ipnets, nil
+}
+
+// RouteDel removes routes for networks via interface.
+func RouteDel(log logger, iface string, ipnets []net.IPNet) error {
+	if len(ipnets) == 0 {
+		return nil
+	}
+
+	link, err := netlink.LinkByName(iface)
+	if err != nil {
+		return fmt.Errorf("%q can't find: %v", iface, err)
+	}
+
+	for i := range ipnets {
+		route := &netlink.Route{
+			LinkIndex: link.Attrs().Index,
+			Scope:     netlink.SCOPE_LINK,
+			Dst:       &ipnets[i],
+		}
+		err = netlink.RouteDel(route)
+		if err != nil {
+			return fmt.Errorf("%q can't del route %q: %v", iface, &ipnets[i], err)
+		}
+		log.Debugf("%q route %q removed", iface, &ipnets[i])
+	}
+
+	return nil
+}
+
 // logger desribes interface of log object.
 type logger interface {
 	Debugf(string, ...interface{})
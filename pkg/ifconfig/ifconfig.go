@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package ifconfig
+
+// Watch the address and route changes mock, nothing is notified.
+func Watch(done <-chan struct{}) (<-chan struct{}, error) {
+	ch := make(chan struct{})
+	go func() {
+		<-done
+		close(ch)
+	}()
+
+	return ch, nil
+}
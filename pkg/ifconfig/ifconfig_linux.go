@@ -14,6 +14,8 @@ import (
 	"os"
 	"strconv"
 	"strings"
+
+	"github.com/vishvananda/netlink"
 )
 
 // IPAddr returns default gw iface name, gw ip address
@@ -63,6 +65,65 @@ func IPAddr() (string, net.IP, net.IP, error) {
 	return wanIface, gatewayIP, wanIP, nil
 }
 
+// Watch the address and route changes, the returned channel gets
+// a notification on change without blocking, so a few changes may be
+// notified once. The channel is closed when the subscription fails
+// or done is closed.
+func Watch(done <-chan struct{}) (<-chan struct{}, error) {
+	// stop closes the subscriptions whichever is failed first
+	stop := make(chan struct{})
+
+	addrc := make(chan netlink.AddrUpdate)
+	err := netlink.AddrSubscribe(addrc, stop)
+	if err != nil {
+		close(stop)
+		return nil, fmt.Errorf("can't subscribe to addr updates: %v", err)
+	}
+
+	routec := make(chan netlink.RouteUpdate)
+	err = netlink.RouteSubscribe(routec, stop)
+	if err != nil {
+		close(stop)
+		return nil, fmt.Errorf("can't subscribe to route updates: %v", err)
+	}
+
+	ch := make(chan struct{}, 1)
+	go func() {
+		defer func() {
+			close(stop)
+			close(ch)
+
+			// the updates are drained until the subscriptions are closed
+			for range addrc {
+			}
+			for range routec {
+			}
+		}()
+
+		for {
+			select {
+			case _, ok := <-addrc:
+				if !ok {
+					return
+				}
+			case _, ok := <-routec:
+				if !ok {
+					return
+				}
+			case <-done:
+				return
+			}
+
+			select {
+			case ch <- struct{}{}:
+			default: // notification is pending already
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
 func parseLinuxProcNetRoute(f []byte) (string, net.IP, error) {
 	/* /proc/net/route file:
 	   Iface   Destination Gateway     Flags   RefCnt  Use Metric  Mask
@@ -87,6 +87,36 @@ func NetworkSize(n *net.IPNet) (uint32, uint32) {
 	return total, hosts
 }
 
+// MapIP returns the address of the to network, which host part is equal
+// to the host part of ip within the from network. The to network should
+// have at least as many host bits as the from network.
+func MapIP(ip net.IP, from, to *net.IPNet) net.IP {
+	if len(from.IP) == 4 {
+		ip = ip.To4()
+	} else {
+		ip = ip.To16()
+	}
+	if ip == nil || len(ip) != len(from.Mask) {
+		return nil
+	}
+
+	if len(to.IP) < len(ip) || len(to.IP) != len(to.Mask) {
+		return nil
+	}
+
+	result := make(net.IP, len(to.IP))
+	for i := 0; i < len(to.IP); i++ {
+		result[i] = to.IP[i] & to.Mask[i]
+	}
+
+	offset := len(result) - len(ip)
+	for i := 0; i < len(ip); i++ {
+		result[offset+i] |= ip[i] &^ from.Mask[i]
+	}
+
+	return result
+}
+
 // IP4ToUint32 converts ip bytes to uint32
 func IP4ToUint32(ip net.IP) uint32 {
 	return binary.BigEndian.Uint32([]byte(ip))
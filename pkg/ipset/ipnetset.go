@@ -0,0 +1,116 @@
+package ipset
+
+import (
+	"bytes"
+	"net"
+	"sort"
+)
+
+// IPNetSet object.
+type IPNetSet struct {
+	state map[string]net.IPNet
+
+	removed map[string]net.IPNet
+	added   map[string]net.IPNet
+
+	frozen bool
+}
+
+// Copy of IPNetSet object.
+func (s *IPNetSet) Copy() IPNetSet {
+	cp := IPNetSet{
+		state:  make(map[string]net.IPNet, len(s.state)),
+		frozen: false,
+	}
+
+	for k, v := range s.state {
+		cp.state[k] = copyIPNet(v)
+	}
+
+	return cp
+}
+
+// Replace object state with new set of networks.
+func (s *IPNetSet) Replace(p []net.IPNet) {
+	if s.frozen {
+		return
+	}
+	s.frozen = true
+
+	prevState := make(map[string]net.IPNet, len(s.state))
+	for k, v := range s.state {
+		prevState[k] = copyIPNet(v)
+	}
+
+	s.state = make(map[string]net.IPNet, len(p))
+
+	s.added = make(map[string]net.IPNet)
+	for _, v := range p {
+		// set current state
+		s.state[v.String()] = copyIPNet(v)
+
+		_, ok := prevState[v.String()]
+		if ok {
+			continue
+		}
+
+		s.added[v.String()] = copyIPNet(v)
+	}
+
+	s.removed = make(map[string]net.IPNet)
+	for k, v := range prevState {
+		_, ok := s.state[k]
+		if ok {
+			continue
+		}
+
+		s.removed[k] = copyIPNet(v)
+	}
+}
+
+// Added networks to the state after Replace applied.
+func (s *IPNetSet) Added() []net.IPNet {
+	if !s.frozen {
+		return nil
+	}
+
+	return sortedIPNets(s.added)
+}
+
+// Removed networks from the state after Replace applied.
+func (s *IPNetSet) Removed() []net.IPNet {
+	if !s.frozen {
+		return nil
+	}
+
+	return sortedIPNets(s.removed)
+}
+
+func sortedIPNets(m map[string]net.IPNet) []net.IPNet {
+	ipnets := make([]net.IPNet, len(m))
+	idx := 0
+	for _, v := range m {
+		ipnets[idx] = copyIPNet(v)
+		idx++
+	}
+	sort.Slice(ipnets, func(i, j int) bool {
+		c := bytes.Compare(ipnets[i].IP, ipnets[j].IP)
+		if c == 0 {
+			return bytes.Compare(ipnets[i].Mask, ipnets[j].Mask) < 0
+		}
+		return c < 0
+	})
+
+	return ipnets
+}
+
+func copyIPNet(v net.IPNet) net.IPNet {
+	cp := net.IPNet{
+		IP:   make(net.IP, len(v.IP)),
+		Mask: make(net.IPMask, len(v.Mask)),
+	}
+	copy(cp.IP, v.IP)
+	copy(cp.Mask, v.Mask)
+
+	return cp
+}
@@ -0,0 +1,72 @@
+package ipset
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIPNetSet(t *testing.T) {
+	ipnetset := IPNetSet{}
+
+	ipnets := []net.IPNet{
+		{IP: net.IPv4(10, 1, 0, 0).To4(), Mask: net.IPv4Mask(255, 255, 0, 0)},
+		{IP: net.IPv4(10, 2, 0, 0).To4(), Mask: net.IPv4Mask(255, 255, 0, 0)},
+		{IP: net.IPv4(10, 2, 0, 0).To4(), Mask: net.IPv4Mask(255, 255, 255, 0)},
+	}
+	ipnetset.Replace(ipnets)
+
+	removed := ipnetset.Removed()
+	expectedAmount := 0
+	if len(removed) != expectedAmount {
+		t.Errorf("wrong removed amount %d, expected %d",
+			len(removed), expectedAmount)
+		return
+	}
+
+	added := ipnetset.Added()
+	expectedAmount = 3
+	if len(added) != expectedAmount {
+		t.Errorf("wrong added amount %d, expected %d",
+			len(added), expectedAmount)
+		return
+	}
+
+	ipnetset = ipnetset.Copy()
+
+	ipnets = []net.IPNet{
+		{IP: net.IPv4(10, 1, 0, 0).To4(), Mask: net.IPv4Mask(255, 255, 0, 0)},
+		{IP: net.IPv4(10, 2, 0, 0).To4(), Mask: net.IPv4Mask(255, 255, 0, 0)},
+		{IP: net.IPv4(10, 3, 0, 0).To4(), Mask: net.IPv4Mask(255, 255, 0, 0)},
+	}
+	ipnetset.Replace(ipnets)
+
+	removed = ipnetset.Removed()
+	expectedAmount = 1
+	if len(removed) != expectedAmount {
+		t.Errorf("wrong removed amount %d, expected %d",
+			len(removed), expectedAmount)
+		return
+	}
+
+	expected := "10.2.0.0/24"
+	if removed[0].String() != expected {
+		t.Errorf("wrong removed network %s, expected %s",
+			removed[0].String(), expected)
+		return
+	}
+
+	added = ipnetset.Added()
+	expectedAmount = 1
+	if len(added) != expectedAmount {
+		t.Errorf("wrong added amount %d, expected %d",
+			len(added), expectedAmount)
+		return
+	}
+
+	expected = "10.3.0.0/16"
+	if added[0].String() != expected {
+		t.Errorf("wrong added network %s, expected %s",
+			added[0].String(), expected)
+		return
+	}
+}
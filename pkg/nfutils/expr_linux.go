@@ -4,6 +4,7 @@ package nfutils
 
 import (
 	"net"
+	"time"
 
 	"golang.org/x/sys/unix"
 
@@ -48,6 +49,18 @@ func ifname(n string) []byte {
 	return b
 }
 
+// ExprLoadNFProto wrapper
+func ExprLoadNFProto() *expr.Meta {
+	// [ meta load nfproto => reg 1 ]
+	return &expr.Meta{Key: expr.MetaKeyNFPROTO, Register: 1}
+}
+
+// ExprLoadL4Proto wrapper
+func ExprLoadL4Proto() *expr.Meta {
+	// [ meta load l4proto => reg 1 ]
+	return &expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1}
+}
+
 // ExprLoadNetHeader wrapper
 func ExprLoadNetHeader(reg, offset, l uint32) *expr.Payload {
 	// [ payload load 4b @ network header + 12 => reg 1 ]
@@ -112,6 +125,100 @@ func ExprLookupSet(reg uint32, name string, id uint32) *expr.Lookup {
 	}
 }
 
+// ExprLookupSetInvert wrapper
+func ExprLookupSetInvert(reg uint32, name string, id uint32) *expr.Lookup {
+	// [ lookup reg 1 set adminipset 0x1 ]
+	return &expr.Lookup{
+		SourceRegister: 1,
+		SetName:        name,
+		SetID:          id,
+		Invert:         true,
+	}
+}
+
+// ExprFibDAddrType wrapper
+func ExprFibDAddrType() *expr.Fib {
+	// [ fib daddr type => reg 1 ]
+	return &expr.Fib{
+		Register:       1,
+		ResultADDRTYPE: true,
+		FlagDADDR:      true,
+	}
+}
+
+// ExprDynsetAdd wrapper
+func ExprDynsetAdd(
+	reg uint32,
+	name string,
+	id uint32,
+	timeout time.Duration,
+	exprs ...expr.Any,
+) *expr.Dynset {
+	// [ dynset add reg_key 1 set trust_meter ]
+	return &expr.Dynset{
+		SrcRegKey: reg,
+		SetName:   name,
+		SetID:     id,
+		Operation: unix.NFT_DYNSET_OP_ADD,
+		Timeout:   timeout,
+		Exprs:     exprs,
+	}
+}
+
+// ExprLimitOver wrapper
+func ExprLimitOver(rate uint64, unit expr.LimitTime) *expr.Limit {
+	// [ limit rate 10/minute burst 0 type packets flags 0x1 ]
+	return &expr.Limit{
+		Type: expr.LimitTypePkts,
+		Rate: rate,
+		Over: true,
+		Unit: unit,
+	}
+}
+
+// ExprLimitBytesOver wrapper
+func ExprLimitBytesOver(rate uint64, unit expr.LimitTime) *expr.Limit {
+	// [ limit rate 125000/second burst 0 type bytes flags 0x1 ]
+	return &expr.Limit{
+		Type: expr.LimitTypePktBytes,
+		Rate: rate,
+		Over: true,
+		Unit: unit,
+	}
+}
+
+// ExprLimit wrapper
+func ExprLimit(rate uint64, unit expr.LimitTime) *expr.Limit {
+	// [ limit rate 10/minute burst 0 type packets flags 0x0 ]
+	return &expr.Limit{
+		Type: expr.LimitTypePkts,
+		Rate: rate,
+		Unit: unit,
+	}
+}
+
+// objectCounter is the type of the named counter object,
+// it's not defined by unix package yet.
+const objectCounter = 1
+
+// ExprCounterRef wrapper
+func ExprCounterRef(name string) *expr.Objref {
+	// [ objref type 1 name input_drop ]
+	return &expr.Objref{
+		Type: objectCounter,
+		Name: name,
+	}
+}
+
+// ExprLog wrapper
+func ExprLog(prefix string) *expr.Log {
+	// [ log prefix wgnetwork input drop:  ]
+	return &expr.Log{
+		Key:  1 << unix.NFTA_LOG_PREFIX,
+		Data: []byte(prefix),
+	}
+}
+
 // ExprCtLoadState wrapper
 func ExprCtLoadState(reg uint32) *expr.Ct {
 	// [ ct load state => reg 1 ]
@@ -142,6 +249,23 @@ func ExprSNAT(addrMin, addrMax uint32) *expr.NAT {
 	}
 }
 
+// ExprDNAT wrapper
+func ExprDNAT(addrMin, protoMin uint32) *expr.NAT {
+	// [ nat dnat ip addr_min reg 1 addr_max reg 0 proto_min reg 2 proto_max reg 0 ]
+	return &expr.NAT{
+		Type:        expr.NATTypeDestNAT,
+		Family:      unix.NFPROTO_IPV4,
+		RegAddrMin:  addrMin,
+		RegProtoMin: protoMin,
+	}
+}
+
+// ExprMasquerade wrapper
+func ExprMasquerade() *expr.Masq {
+	// [ masq ]
+	return &expr.Masq{}
+}
+
 // ExprAccept wrapper
 func ExprAccept() *expr.Verdict {
 	// [ immediate reg 0 accept ]
@@ -158,6 +282,23 @@ func ExprDrop() *expr.Verdict {
 	}
 }
 
+// ExprReturn wrapper
+func ExprReturn() *expr.Verdict {
+	// [ immediate reg 0 return ]
+	return &expr.Verdict{
+		Kind: expr.VerdictReturn,
+	}
+}
+
+// ExprJump wrapper
+func ExprJump(chain string) *expr.Verdict {
+	// [ immediate reg 0 jump -> wgacl_out ]
+	return &expr.Verdict{
+		Kind:  expr.VerdictJump,
+		Chain: chain,
+	}
+}
+
 // ExprReject wrapper
 func ExprReject(t uint32, c uint8) *expr.Reject {
 	// [ reject type 0 code 3 ]
@@ -4,9 +4,13 @@
 package nfutils
 
 import (
+	"net"
+	"time"
+
 	"github.com/google/nftables"
 	"github.com/google/nftables/binaryutil"
 	"github.com/google/nftables/expr"
+	"golang.org/x/sys/unix"
 )
 
 // SetIIF helper.
@@ -49,9 +53,31 @@ func SetNOIF(iface string) []expr.Any {
 	return exprs
 }
 
-// SetSourceNet helper.
+// SetNFProtoIPv4 helper.
+func SetNFProtoIPv4() []expr.Any {
+	exprs := []expr.Any{
+		ExprLoadNFProto(),
+		ExprCmpEq(1, NFProtoIPv4()),
+	}
+
+	return exprs
+}
+
+// SetNFProtoIPv6 helper.
+func SetNFProtoIPv6() []expr.Any {
+	exprs := []expr.Any{
+		ExprLoadNFProto(),
+		ExprCmpEq(1, NFProtoIPv6()),
+	}
+
+	return exprs
+}
+
+// SetSourceNet helper, matches ipv4 source network.
 func SetSourceNet(addr []byte, mask []byte) []expr.Any {
 	exprs := []expr.Any{
+		ExprLoadNFProto(),
+		ExprCmpEq(1, NFProtoIPv4()),
 		ExprLoadNetHeader(1, 12, 4),
 		ExprBitwise(1, 1, 4,
 			mask,
@@ -65,13 +91,23 @@ func SetSourceNet(addr []byte, mask []byte) []expr.Any {
 // SetProtoICMP helper.
 func SetProtoICMP() []expr.Any {
 	exprs := []expr.Any{
-		ExprLoadNetHeader(1, 9, 1),
+		ExprLoadL4Proto(),
 		ExprCmpEq(1, ProtoICMP()),
 	}
 
 	return exprs
 }
 
+// SetProtoICMPv6 helper.
+func SetProtoICMPv6() []expr.Any {
+	exprs := []expr.Any{
+		ExprLoadL4Proto(),
+		ExprCmpEq(1, ProtoICMPv6()),
+	}
+
+	return exprs
+}
+
 // SetICMPTypeEchoRequest helper.
 func SetICMPTypeEchoRequest() []expr.Any {
 	exprs := []expr.Any{
@@ -82,10 +118,20 @@ func SetICMPTypeEchoRequest() []expr.Any {
 	return exprs
 }
 
+// SetICMPv6TypeEchoRequest helper.
+func SetICMPv6TypeEchoRequest() []expr.Any {
+	exprs := []expr.Any{
+		ExprLoadTransportHeader(1, 0, 1),
+		ExprCmpEq(1, ICMPv6TypeEchoRequest()),
+	}
+
+	return exprs
+}
+
 // SetProtoUDP helper.
 func SetProtoUDP() []expr.Any {
 	exprs := []expr.Any{
-		ExprLoadNetHeader(1, 9, 1),
+		ExprLoadL4Proto(),
 		ExprCmpEq(1, ProtoUDP()),
 	}
 
@@ -95,16 +141,29 @@ func SetProtoUDP() []expr.Any {
 // SetProtoTCP helper.
 func SetProtoTCP() []expr.Any {
 	exprs := []expr.Any{
-		ExprLoadNetHeader(1, 9, 1),
+		ExprLoadL4Proto(),
 		ExprCmpEq(1, ProtoTCP()),
 	}
 
 	return exprs
 }
 
-// SetSAddrSet helper.
+// SetSAddrSet helper, the address family is taken from the set key type.
 func SetSAddrSet(s *nftables.Set) []expr.Any {
+	if s.KeyType == nftables.TypeIP6Addr {
+		exprs := []expr.Any{
+			ExprLoadNFProto(),
+			ExprCmpEq(1, NFProtoIPv6()),
+			ExprLoadNetHeader(1, 8, 16),
+			ExprLookupSet(1, s.Name, s.ID),
+		}
+
+		return exprs
+	}
+
 	exprs := []expr.Any{
+		ExprLoadNFProto(),
+		ExprCmpEq(1, NFProtoIPv4()),
 		ExprLoadNetHeader(1, 12, 4),
 		ExprLookupSet(1, s.Name, s.ID),
 	}
@@ -112,9 +171,85 @@ func SetSAddrSet(s *nftables.Set) []expr.Any {
 	return exprs
 }
 
-// SetDAddrSet helper.
+// SetSAddrNotSet helper, matches the source out of the set,
+// the address family is taken from the set key type.
+func SetSAddrNotSet(s *nftables.Set) []expr.Any {
+	exprs := SetSAddrSet(s)
+	exprs[len(exprs)-1] = ExprLookupSetInvert(1, s.Name, s.ID)
+
+	return exprs
+}
+
+// SetSAddrLimit helper, the source address is added to the dynamic set
+// and the rule goes on while its rate is over the limit,
+// the address family is taken from the set key type.
+func SetSAddrLimit(
+	s *nftables.Set,
+	rate uint64,
+	unit expr.LimitTime,
+) []expr.Any {
+	limit := ExprLimitOver(rate, unit)
+	if s.KeyType == nftables.TypeIP6Addr {
+		exprs := []expr.Any{
+			ExprLoadNFProto(),
+			ExprCmpEq(1, NFProtoIPv6()),
+			ExprLoadNetHeader(1, 8, 16),
+			ExprDynsetAdd(1, s.Name, s.ID, 0, limit),
+		}
+
+		return exprs
+	}
+
+	exprs := []expr.Any{
+		ExprLoadNFProto(),
+		ExprCmpEq(1, NFProtoIPv4()),
+		ExprLoadNetHeader(1, 12, 4),
+		ExprDynsetAdd(1, s.Name, s.ID, 0, limit),
+	}
+
+	return exprs
+}
+
+// SetSAddrAddSet helper, the source address is added to the dynamic set
+// for the timeout, the address family is taken from the set key type.
+func SetSAddrAddSet(s *nftables.Set, timeout time.Duration) []expr.Any {
+	if s.KeyType == nftables.TypeIP6Addr {
+		exprs := []expr.Any{
+			ExprLoadNFProto(),
+			ExprCmpEq(1, NFProtoIPv6()),
+			ExprLoadNetHeader(1, 8, 16),
+			ExprDynsetAdd(1, s.Name, s.ID, timeout),
+		}
+
+		return exprs
+	}
+
+	exprs := []expr.Any{
+		ExprLoadNFProto(),
+		ExprCmpEq(1, NFProtoIPv4()),
+		ExprLoadNetHeader(1, 12, 4),
+		ExprDynsetAdd(1, s.Name, s.ID, timeout),
+	}
+
+	return exprs
+}
+
+// SetDAddrSet helper, the address family is taken from the set key type.
 func SetDAddrSet(s *nftables.Set) []expr.Any {
+	if s.KeyType == nftables.TypeIP6Addr {
+		exprs := []expr.Any{
+			ExprLoadNFProto(),
+			ExprCmpEq(1, NFProtoIPv6()),
+			ExprLoadNetHeader(1, 24, 16),
+			ExprLookupSet(1, s.Name, s.ID),
+		}
+
+		return exprs
+	}
+
 	exprs := []expr.Any{
+		ExprLoadNFProto(),
+		ExprCmpEq(1, NFProtoIPv4()),
 		ExprLoadNetHeader(1, 16, 4),
 		ExprLookupSet(1, s.Name, s.ID),
 	}
@@ -122,6 +257,142 @@ func SetDAddrSet(s *nftables.Set) []expr.Any {
 	return exprs
 }
 
+// SetSAddr helper, the address family is taken from the address.
+func SetSAddr(ip net.IP) []expr.Any {
+	if ip4 := ip.To4(); ip4 != nil {
+		exprs := []expr.Any{
+			ExprLoadNFProto(),
+			ExprCmpEq(1, NFProtoIPv4()),
+			ExprLoadNetHeader(1, 12, 4),
+			ExprCmpEq(1, ip4),
+		}
+
+		return exprs
+	}
+
+	exprs := []expr.Any{
+		ExprLoadNFProto(),
+		ExprCmpEq(1, NFProtoIPv6()),
+		ExprLoadNetHeader(1, 8, 16),
+		ExprCmpEq(1, ip.To16()),
+	}
+
+	return exprs
+}
+
+// SetDAddr helper, the address family is taken from the address.
+func SetDAddr(ip net.IP) []expr.Any {
+	if ip4 := ip.To4(); ip4 != nil {
+		exprs := []expr.Any{
+			ExprLoadNFProto(),
+			ExprCmpEq(1, NFProtoIPv4()),
+			ExprLoadNetHeader(1, 16, 4),
+			ExprCmpEq(1, ip4),
+		}
+
+		return exprs
+	}
+
+	exprs := []expr.Any{
+		ExprLoadNFProto(),
+		ExprCmpEq(1, NFProtoIPv6()),
+		ExprLoadNetHeader(1, 24, 16),
+		ExprCmpEq(1, ip.To16()),
+	}
+
+	return exprs
+}
+
+// SetSAddrNet helper, matches the source within the range
+// of the address family.
+func SetSAddrNet(ipnet net.IPNet) []expr.Any {
+	if ip4 := ipnet.IP.To4(); ip4 != nil {
+		mask := []byte(ipnet.Mask)
+		if len(mask) == net.IPv6len {
+			mask = mask[12:]
+		}
+		exprs := []expr.Any{
+			ExprLoadNFProto(),
+			ExprCmpEq(1, NFProtoIPv4()),
+			ExprLoadNetHeader(1, 12, 4),
+			ExprBitwise(1, 1, 4,
+				mask,
+				make([]byte, 4)),
+			ExprCmpEq(1, ip4.Mask(mask)),
+		}
+
+		return exprs
+	}
+
+	exprs := []expr.Any{
+		ExprLoadNFProto(),
+		ExprCmpEq(1, NFProtoIPv6()),
+		ExprLoadNetHeader(1, 8, 16),
+		ExprBitwise(1, 1, 16,
+			ipnet.Mask,
+			make([]byte, 16)),
+		ExprCmpEq(1, ipnet.IP.To16().Mask(ipnet.Mask)),
+	}
+
+	return exprs
+}
+
+// SetWGHandshakeInitiation helper, matches the first byte of the udp
+// payload to the handshake initiation message type of wireguard.
+func SetWGHandshakeInitiation() []expr.Any {
+	exprs := []expr.Any{
+		ExprLoadTransportHeader(1, 8, 1),
+		ExprCmpEq(1, []byte{1}),
+	}
+
+	return exprs
+}
+
+// SetDAddrTypeLocal helper, matches the destination address
+// of the host.
+func SetDAddrTypeLocal() []expr.Any {
+	exprs := []expr.Any{
+		ExprFibDAddrType(),
+		ExprCmpEq(1, binaryutil.NativeEndian.PutUint32(unix.RTN_LOCAL)),
+	}
+
+	return exprs
+}
+
+// SetDAddrNet helper, matches the destination within the range
+// of the address family.
+func SetDAddrNet(ipnet net.IPNet) []expr.Any {
+	if ip4 := ipnet.IP.To4(); ip4 != nil {
+		mask := []byte(ipnet.Mask)
+		if len(mask) == net.IPv6len {
+			mask = mask[12:]
+		}
+		exprs := []expr.Any{
+			ExprLoadNFProto(),
+			ExprCmpEq(1, NFProtoIPv4()),
+			ExprLoadNetHeader(1, 16, 4),
+			ExprBitwise(1, 1, 4,
+				mask,
+				make([]byte, 4)),
+			ExprCmpEq(1, ip4.Mask(mask)),
+		}
+
+		return exprs
+	}
+
+	exprs := []expr.Any{
+		ExprLoadNFProto(),
+		ExprCmpEq(1, NFProtoIPv6()),
+		ExprLoadNetHeader(1, 24, 16),
+		ExprBitwise(1, 1, 16,
+			ipnet.Mask,
+			make([]byte, 16)),
+		ExprCmpEq(1, ipnet.IP.To16().Mask(ipnet.Mask)),
+	}
+
+	return exprs
+}
+
 // GetAddrSet helper.
 func GetAddrSet(t *nftables.Table) *nftables.Set {
 	s := &nftables.Set{
@@ -133,6 +404,30 @@ func GetAddrSet(t *nftables.Table) *nftables.Set {
 	return s
 }
 
+// GetAddr6Set helper.
+func GetAddr6Set(t *nftables.Table) *nftables.Set {
+	s := &nftables.Set{
+		Anonymous: true,
+		Constant:  true,
+		Table:     t,
+		KeyType:   nftables.TypeIP6Addr}
+
+	return s
+}
+
+// GetAddrElems helper, addresses should be of the same family.
+func GetAddrElems(ips []net.IP) []nftables.SetElement {
+	elems := make([]nftables.SetElement, 0, len(ips))
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		elems = append(elems, nftables.SetElement{Key: ip})
+	}
+
+	return elems
+}
+
 // SetSPort helper.
 func SetSPort(p uint16) []expr.Any {
 	exprs := []expr.Any{
@@ -153,6 +448,17 @@ func SetDPort(p uint16) []expr.Any {
 	return exprs
 }
 
+// SetDNAT helper, translates destination to the ipv4 address and port.
+func SetDNAT(ip net.IP, p uint16) []expr.Any {
+	exprs := []expr.Any{
+		&expr.Immediate{Register: 1, Data: ip.To4()},
+		&expr.Immediate{Register: 2, Data: binaryutil.BigEndian.PutUint16(p)},
+		ExprDNAT(1, 2),
+	}
+
+	return exprs
+}
+
 // SetSPortSet helper.
 func SetSPortSet(s *nftables.Set) []expr.Any {
 	exprs := []expr.Any{
@@ -3,18 +3,42 @@
 
 package nfutils
 
-import "github.com/google/nftables"
+import (
+	"golang.org/x/sys/unix"
+
+	"github.com/google/nftables"
+)
 
 // ProtoICMP bytes.
 func ProtoICMP() []byte {
 	return []byte{0x01}
 }
 
+// ProtoICMPv6 bytes.
+func ProtoICMPv6() []byte {
+	return []byte{0x3a}
+}
+
+// NFProtoIPv4 bytes.
+func NFProtoIPv4() []byte {
+	return []byte{unix.NFPROTO_IPV4}
+}
+
+// NFProtoIPv6 bytes.
+func NFProtoIPv6() []byte {
+	return []byte{unix.NFPROTO_IPV6}
+}
+
 // ICMPTypeEchoRequest bytes.
 func ICMPTypeEchoRequest() []byte {
 	return []byte{0x08}
 }
 
+// ICMPv6TypeEchoRequest bytes.
+func ICMPv6TypeEchoRequest() []byte {
+	return []byte{0x80}
+}
+
 // ProtoUDP bytes.
 func ProtoUDP() []byte {
 	return []byte{0x11}
@@ -14,6 +14,7 @@ type Peer struct {
 	ipnet net.IPNet
 
 	publicKey    wgtypes.Key
+	presharedKey wgtypes.Key
 	allowedIPs   []net.IPNet
 	endpointIP   net.IP
 	endpointPort uint16
@@ -24,6 +25,7 @@ type Peer struct {
 func NewPeer(
 	ipnet net.IPNet,
 	publicKey wgtypes.Key,
+	presharedKey wgtypes.Key,
 	allowedIPs []net.IPNet,
 	endpointIP net.IP,
 	endpointPort uint16,
@@ -35,6 +37,7 @@ func NewPeer(
 		ipnet: ipnet,
 
 		publicKey:    publicKey,
+		presharedKey: presharedKey,
 		allowedIPs:   allowedIPs,
 		endpointIP:   endpointIP,
 		endpointPort: endpointPort,
@@ -54,6 +57,7 @@ func (p *Peer) Copy() Peer {
 	copy(ipnet.Mask, p.ipnet.Mask)
 
 	publicKey := p.publicKey
+	presharedKey := p.presharedKey
 
 	allowedIPs := make([]net.IPNet, len(p.allowedIPs))
 	for i := 0; i < len(p.allowedIPs); i++ {
@@ -72,6 +76,7 @@ func (p *Peer) Copy() Peer {
 		ipnet: ipnet,
 
 		publicKey:    publicKey,
+		presharedKey: presharedKey,
 		allowedIPs:   allowedIPs,
 		endpointIP:   endpointIP,
 		endpointPort: p.endpointPort,
@@ -110,6 +115,11 @@ func (p *Peer) PublicKey() wgtypes.Key {
 	return p.publicKey
 }
 
+// PresharedKey value, zero key if not set.
+func (p *Peer) PresharedKey() wgtypes.Key {
+	return p.presharedKey
+}
+
 // Endpoint address.
 func (p *Peer) Endpoint() *net.UDPAddr {
 	if p.endpointIP == nil || p.endpointPort == 0 {
@@ -124,6 +134,36 @@ func (p *Peer) KeepAlive() time.Duration {
 	return p.keepAlive
 }
 
+// Equal reports whether peers have the same configuration.
+func (p *Peer) Equal(o Peer) bool {
+	if p.publicKey != o.publicKey || p.presharedKey != o.presharedKey {
+		return false
+	}
+
+	if !p.ipnet.IP.Equal(o.ipnet.IP) ||
+		bytes.Compare(p.ipnet.Mask, o.ipnet.Mask) != 0 {
+		return false
+	}
+
+	if len(p.allowedIPs) != len(o.allowedIPs) {
+		return false
+	}
+	for i := 0; i < len(p.allowedIPs); i++ {
+		if !p.allowedIPs[i].IP.Equal(o.allowedIPs[i].IP) ||
+			bytes.Compare(p.allowedIPs[i].Mask, o.allowedIPs[i].Mask) != 0 {
+			return false
+		}
+	}
+
+	if !p.endpointIP.Equal(o.endpointIP) ||
+		p.endpointPort != o.endpointPort ||
+		p.keepAlive != o.keepAlive {
+		return false
+	}
+
+	return true
+}
+
 // Peers object
 type Peers []Peer
 
@@ -46,8 +46,9 @@ func (s *PeerSet) Replace(p Peers) {
 		publicKey := v.PublicKey().String()
 		s.state[publicKey] = v.Copy()
 
-		_, ok := prevState[publicKey]
-		if ok {
+		// changed peers are added again to apply new configuration
+		prev, ok := prevState[publicKey]
+		if ok && prev.Equal(v) {
 			continue
 		}
 
@@ -307,6 +307,58 @@ func TestPeerSet(t *testing.T) {
 	}
 }
 
+func TestPeerSetChanged(t *testing.T) {
+	peerset := PeerSet{}
+
+	peers := Peers{
+		Peer{
+			ipnet: net.IPNet{
+				IP:   net.ParseIP("10.0.0.1").To4(),
+				Mask: net.IPv4Mask(255, 255, 255, 255),
+			},
+			publicKey: generatePrivateKey(),
+		},
+		Peer{
+			ipnet: net.IPNet{
+				IP:   net.ParseIP("10.0.0.2").To4(),
+				Mask: net.IPv4Mask(255, 255, 255, 255),
+			},
+			publicKey: generatePrivateKey(),
+		},
+	}
+	sort.Sort(peers)
+	peerset.Replace(peers)
+	peerset = peerset.Copy()
+
+	peers = peers.Copy()
+	peers[1].presharedKey = generatePrivateKey()
+	peerset.Replace(peers)
+
+	removed := peerset.Removed()
+	expectedAmount := 0
+	if len(removed) != expectedAmount {
+		t.Errorf("wrong removed amount %d, expected %d",
+			len(removed), expectedAmount)
+		return
+	}
+
+	added := peerset.Added()
+	expectedAmount = 1
+	if len(added) != expectedAmount {
+		t.Errorf("wrong added amount %d, expected %d",
+			len(added), expectedAmount)
+		return
+	}
+
+	expected := peers[1].PresharedKey()
+	psk := added[0].PresharedKey()
+	if bytes.Compare(psk[:], expected[:]) != 0 {
+		t.Errorf("wrong added preshared key %s, expected %s",
+			psk, expected)
+		return
+	}
+}
+
 func generatePrivateKey() wgtypes.Key {
 	k, _ := wgtypes.GeneratePrivateKey()
 	return k
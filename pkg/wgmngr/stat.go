@@ -0,0 +1,27 @@
+package wgmngr
+
+import (
+	"net"
+	"time"
+)
+
+// onlineTimeout is the period after the latest handshake while peer is
+// considered online, wireguard rekeys active sessions every 2 minutes.
+const onlineTimeout = 3 * time.Minute
+
+// PeerStat object, live state of the peer read from the interface.
+type PeerStat struct {
+	Endpoint      *net.UDPAddr
+	LastHandshake time.Time
+	ReceiveBytes  int64
+	TransmitBytes int64
+}
+
+// Online reports whether peer has recent handshake.
+func (s PeerStat) Online() bool {
+	if s.LastHandshake.IsZero() {
+		return false
+	}
+
+	return time.Since(s.LastHandshake) < onlineTimeout
+}
@@ -0,0 +1,25 @@
+package wgmngr
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPeerStatOnline(t *testing.T) {
+	cases := []struct {
+		name     string
+		stat     PeerStat
+		expected bool
+	}{
+		{"never", PeerStat{}, false},
+		{"recent", PeerStat{LastHandshake: time.Now().Add(-time.Minute)}, true},
+		{"stale", PeerStat{LastHandshake: time.Now().Add(-time.Hour)}, false},
+	}
+
+	for _, c := range cases {
+		if c.stat.Online() != c.expected {
+			t.Errorf("%s: wrong online value %t, expected %t",
+				c.name, c.stat.Online(), c.expected)
+		}
+	}
+}
@@ -17,3 +17,13 @@ func configureDevice(iface string, cfg wgtypes.Config) error {
 
 	return ctrl.ConfigureDevice(iface, cfg)
 }
+
+func device(iface string) (*wgtypes.Device, error) {
+	ctrl, err := wgctrl.New()
+	if err != nil {
+		return nil, err
+	}
+	defer ctrl.Close()
+
+	return ctrl.Device(iface)
+}
@@ -1,11 +1,15 @@
 package wgmngr
 
 import (
+	"sync"
+
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 )
 
 // Manager object.
 type Manager struct {
+	mu sync.RWMutex
+
 	privateKey wgtypes.Key
 	publicKey  wgtypes.Key
 	iface      string
@@ -49,6 +53,7 @@ func (wgm *Manager) PeerSet(peers Peers) error {
 	for i := 0; i < len(p); i++ {
 		p[i] = wgtypes.PeerConfig{
 			PublicKey:         peers[i].publicKey,
+			PresharedKey:      &peers[i].presharedKey,
 			AllowedIPs:        peers[i].AllowedIPs(),
 			ReplaceAllowedIPs: true,
 		}
@@ -82,13 +87,51 @@ func (wgm *Manager) PeerRemove(peers Peers) error {
 	return configureDevice(wgm.iface, cfg)
 }
 
+// PeerUpdate removes and sets peers with the single configuration call,
+// so the peer with the replaced public key is swapped atomically.
+func (wgm *Manager) PeerUpdate(removed, added Peers) error {
+	p := make([]wgtypes.PeerConfig, 0, len(removed)+len(added))
+	for i := 0; i < len(removed); i++ {
+		p = append(p, wgtypes.PeerConfig{
+			PublicKey: removed[i].publicKey,
+			Remove:    true,
+		})
+	}
+	for i := 0; i < len(added); i++ {
+		pc := wgtypes.PeerConfig{
+			PublicKey:         added[i].publicKey,
+			PresharedKey:      &added[i].presharedKey,
+			AllowedIPs:        added[i].AllowedIPs(),
+			ReplaceAllowedIPs: true,
+		}
+		endpoint := added[i].Endpoint()
+		if endpoint != nil {
+			pc.Endpoint = endpoint
+		}
+		keepAlive := added[i].KeepAlive()
+		if keepAlive > 0 {
+			pc.PersistentKeepaliveInterval = &keepAlive
+		}
+		p = append(p, pc)
+	}
+
+	if len(p) == 0 {
+		return nil
+	}
+
+	cfg := wgtypes.Config{Peers: p}
+
+	return configureDevice(wgm.iface, cfg)
+}
+
 // PeerReplace configuration.
 func (wgm *Manager) PeerReplace(peers Peers) error {
 	p := make([]wgtypes.PeerConfig, len(peers))
 	for i := 0; i < len(p); i++ {
 		p[i] = wgtypes.PeerConfig{
-			PublicKey:  peers[i].publicKey,
-			AllowedIPs: peers[i].AllowedIPs(),
+			PublicKey:    peers[i].publicKey,
+			PresharedKey: &peers[i].presharedKey,
+			AllowedIPs:   peers[i].AllowedIPs(),
 		}
 		endpoint := peers[i].Endpoint()
 		if endpoint != nil {
@@ -108,8 +151,49 @@ func (wgm *Manager) PeerReplace(peers Peers) error {
 	return configureDevice(wgm.iface, cfg)
 }
 
+// PeerStats reads live state of the peers from the interface,
+// result is keyed by peer public key.
+func (wgm *Manager) PeerStats() (map[wgtypes.Key]PeerStat, error) {
+	d, err := device(wgm.iface)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[wgtypes.Key]PeerStat, len(d.Peers))
+	for _, p := range d.Peers {
+		stats[p.PublicKey] = PeerStat{
+			Endpoint:      p.Endpoint,
+			LastHandshake: p.LastHandshakeTime,
+			ReceiveBytes:  p.ReceiveBytes,
+			TransmitBytes: p.TransmitBytes,
+		}
+	}
+
+	return stats, nil
+}
+
+// SetPrivateKey replaces private key of the interface, peers are kept.
+func (wgm *Manager) SetPrivateKey(privateKey wgtypes.Key) error {
+	wgm.mu.Lock()
+	defer wgm.mu.Unlock()
+
+	cfg := wgtypes.Config{PrivateKey: &privateKey}
+	err := configureDevice(wgm.iface, cfg)
+	if err != nil {
+		return err
+	}
+
+	wgm.privateKey = privateKey
+	wgm.publicKey = privateKey.PublicKey()
+
+	return nil
+}
+
 // PublicKey value.
 func (wgm *Manager) PublicKey() wgtypes.Key {
+	wgm.mu.RLock()
+	defer wgm.mu.RUnlock()
+
 	return wgm.publicKey
 }
 
@@ -22,6 +22,9 @@ type Handler struct {
 	mbox      string
 	wgIfaceIP net.IP
 
+	// optional ipv6 address of the wireguard interface
+	wgIfaceIP6 net.IP
+
 	m map[string]model.Domain
 
 	sync.RWMutex
@@ -35,6 +38,7 @@ func New(
 	ns string,
 	mbox string,
 	wgIfaceIP net.IP,
+	wgIfaceIP6 net.IP,
 ) *Handler {
 	rr := &roundrobin{addrs: servers}
 	c := new(dns.Client)
@@ -50,6 +54,8 @@ func New(
 		mbox:      mbox,
 		wgIfaceIP: wgIfaceIP,
 
+		wgIfaceIP6: wgIfaceIP6,
+
 		m: m}
 
 	return s
@@ -104,20 +110,10 @@ func (s *Handler) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 			resolved = append(resolved, cname)
 			continue
 		}
-		if q.Qtype == dns.TypeA {
-			if len(domain.A) > 0 {
-				for _, v := range domain.A {
-					a := &dns.A{
-						Hdr: dns.RR_Header{
-							Name:   q.Name,
-							Rrtype: dns.TypeA,
-							Class:  dns.ClassINET,
-							Ttl:    v.TTL,
-						},
-						A: v.A,
-					}
-					resolved = append(resolved, a)
-				}
+		if q.Qtype == dns.TypeA || q.Qtype == dns.TypeAAAA {
+			rrs := rrAddrs(q.Name, domain, q.Qtype)
+			if len(rrs) > 0 {
+				resolved = append(resolved, rrs...)
 				continue
 			}
 
@@ -155,19 +151,9 @@ func (s *Handler) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 						name = domain.CNAME.Target
 						continue
 					}
-					for _, v := range domain.A {
-						a := &dns.A{
-							Hdr: dns.RR_Header{
-								Name:   name,
-								Rrtype: dns.TypeA,
-								Class:  dns.ClassINET,
-								Ttl:    v.TTL,
-							},
-							A: v.A,
-						}
-						resolved = append(resolved, a)
-						extra = append(extra, a)
-					}
+					rrs := rrAddrs(name, domain, q.Qtype)
+					resolved = append(resolved, rrs...)
+					extra = append(extra, rrs...)
 					break
 				}
 			}
@@ -183,16 +169,7 @@ func (s *Handler) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 		}
 		result.Answer = resolved
 		result.Ns = []dns.RR{s.rrNs(s.zone)}
-		a := &dns.A{
-			Hdr: dns.RR_Header{
-				Name:   "server.wgn.",
-				Rrtype: dns.TypeA,
-				Class:  dns.ClassINET,
-				Ttl:    60,
-			},
-			A: s.wgIfaceIP,
-		}
-		result.Extra = []dns.RR{a}
+		result.Extra = s.rrNsAddrs()
 		w.WriteMsg(result)
 		return
 	}
@@ -216,19 +193,76 @@ func (s *Handler) getBase(q dns.Question) ([]dns.RR, bool) {
 
 	if q.Qtype == dns.TypeSOA {
 		return []dns.RR{s.rrSoa(q.Name)}, true
-	} else if q.Qtype == dns.TypeA {
-		a := &dns.A{
+	}
+
+	rrs := make([]dns.RR, 0, 1)
+	for _, rr := range s.rrNsAddrs() {
+		if rr.Header().Rrtype == q.Qtype {
+			rrs = append(rrs, rr)
+		}
+	}
+	return rrs, true
+}
+
+// rrNsAddrs returns address records of the name server.
+func (s *Handler) rrNsAddrs() []dns.RR {
+	rrs := []dns.RR{
+		&dns.A{
 			Hdr: dns.RR_Header{
-				Name:   q.Name,
+				Name:   s.ns,
 				Rrtype: dns.TypeA,
 				Class:  dns.ClassINET,
 				Ttl:    60,
 			},
 			A: s.wgIfaceIP,
+		},
+	}
+	if s.wgIfaceIP6 != nil {
+		rrs = append(rrs, &dns.AAAA{
+			Hdr: dns.RR_Header{
+				Name:   s.ns,
+				Rrtype: dns.TypeAAAA,
+				Class:  dns.ClassINET,
+				Ttl:    60,
+			},
+			AAAA: s.wgIfaceIP6,
+		})
+	}
+	return rrs
+}
+
+// rrAddrs returns A or AAAA records of the domain by qtype.
+func rrAddrs(name string, domain model.Domain, qtype uint16) []dns.RR {
+	var rrs []dns.RR
+	if qtype == dns.TypeA {
+		for _, v := range domain.A {
+			a := &dns.A{
+				Hdr: dns.RR_Header{
+					Name:   name,
+					Rrtype: dns.TypeA,
+					Class:  dns.ClassINET,
+					Ttl:    v.TTL,
+				},
+				A: v.A,
+			}
+			rrs = append(rrs, a)
+		}
+		return rrs
+	}
+
+	for _, v := range domain.AAAA {
+		aaaa := &dns.AAAA{
+			Hdr: dns.RR_Header{
+				Name:   name,
+				Rrtype: dns.TypeAAAA,
+				Class:  dns.ClassINET,
+				Ttl:    v.TTL,
+			},
+			AAAA: v.AAAA,
 		}
-		return []dns.RR{a}, true
+		rrs = append(rrs, aaaa)
 	}
-	return []dns.RR{}, true
+	return rrs
 }
 
 func (s *Handler) getDomain(name string) (model.Domain, bool) {
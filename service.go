@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"reflect"
 	"sync"
 	"time"
 
@@ -20,8 +21,10 @@ import (
 	"wgnetwork/femanager"
 	"wgnetwork/firewall"
 	"wgnetwork/model"
+	"wgnetwork/pkg/conntrack"
 	"wgnetwork/pkg/httpapi"
 	"wgnetwork/pkg/iface"
+	"wgnetwork/pkg/ifconfig"
 	"wgnetwork/pkg/ipset"
 	"wgnetwork/pkg/log"
 	"wgnetwork/pkg/rpcapi"
@@ -38,9 +41,39 @@ type Service struct {
 	db  *bolt.DB
 	nft *firewall.NFTables
 
-	trustIPSet        ipset.IPSet
+	trustIPs     []firewall.TrustIP
+	portForwards []firewall.PortForward
+	egress       []firewall.EgressRule
+
+	networks []*network
+
+	// confirm of the firewall changes, nil if it's turned off
+	confirm *confirm
+	// routeRevision is the number of the changes of the device routes,
+	// they are confirmed along with the firewall changes
+	routeRevision uint64
+
+	// refreshc signals the state change, it's buffered to coalesce signals
+	refreshc chan struct{}
+}
+
+// network served by service.
+type network struct {
+	cfg wgNetwork
+	// key of the network in database, empty for the default network
+	key string
+
 	wgManagerIPSet    ipset.IPSet
 	wgForwardWanIPSet ipset.IPSet
+	wgRouteSet        ipset.IPNetSet
+	wgInactiveIPSet   ipset.IPSet
+	wgACLs            []firewall.ACL
+	wgGroups          []firewall.Group
+	wgPolicies        []firewall.GroupPolicy
+	wgDevices         []firewall.Device
+	wgEgress          []firewall.Egress
+	wgIsolation       firewall.Isolation
+	wgBandwidth       []firewall.Bandwidth
 
 	wgm     *wgmngr.Manager
 	wgpeers wgmngr.PeerSet
@@ -75,26 +108,71 @@ func Init(ctx context.Context) (*Service, error) {
 	}()
 	log.Info("opened bolt db")
 
-	// generate wireguard private key for members network
-	var wgsk wgtypes.Key
-	wgsk, err = wgPrivateKey(db)
-	if err != nil {
-		return nil, err
-	}
+	networks := make([]*network, len(cfg.networks))
+	nftNetworks := make([]firewall.WGNetwork, len(cfg.networks))
+	for i, ncfg := range cfg.networks {
+		key := ncfg.name
+		if i == 0 {
+			key = ""
+		}
 
-	const wgLinkType = "wireguard"
-	err = iface.Create(
-		log,
-		cfg.WGIface, wgLinkType, cfg.wgIfaceIP, cfg.wgIfaceIPNet)
-	if err != nil {
-		return nil, err
-	}
+		// generate wireguard private key for members network
+		var wgsk wgtypes.Key
+		wgsk, err = wgPrivateKey(db, key)
+		if err != nil {
+			return nil, err
+		}
 
-	var wgm *wgmngr.Manager
-	wgm, err = wgmngr.NewManager(
-		wgsk, cfg.WGIface, cfg.WGPort)
-	if err != nil {
-		return nil, err
+		const wgLinkType = "wireguard"
+		err = iface.Create(
+			log,
+			ncfg.iface, wgLinkType, ncfg.ifaceIP, ncfg.ifaceIPNet)
+		if err != nil {
+			return nil, err
+		}
+		if ncfg.ifaceIPNet6 != nil {
+			err = iface.AddrAdd(
+				log, ncfg.iface, ncfg.ifaceIP6, ncfg.ifaceIPNet6)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		var wgm *wgmngr.Manager
+		wgm, err = wgmngr.NewManager(
+			wgsk, ncfg.iface, ncfg.port)
+		if err != nil {
+			return nil, err
+		}
+
+		ns := fmt.Sprintf("server.%s", ncfg.dnsZone)
+		mbox := fmt.Sprintf("hostmaster.server.%s", ncfg.dnsZone)
+		resolver := resolver.New(
+			log, db,
+			cfg.DNSResolverAddrs, ncfg.dnsZone,
+			ns, mbox,
+			ncfg.ifaceIP, ncfg.ifaceIP6)
+
+		networks[i] = &network{
+			cfg: ncfg,
+			key: key,
+
+			wgManagerIPSet:    ipset.IPSet{},
+			wgForwardWanIPSet: ipset.IPSet{},
+			wgRouteSet:        ipset.IPNetSet{},
+			wgInactiveIPSet:   ipset.IPSet{},
+
+			wgm:     wgm,
+			wgpeers: wgmngr.PeerSet{},
+
+			resolver: resolver,
+		}
+		nftNetworks[i] = firewall.WGNetwork{
+			Name:     ncfg.name,
+			Iface:    ncfg.iface,
+			Port:     ncfg.port,
+			Isolated: ncfg.isolated,
+		}
 	}
 
 	var (
@@ -107,24 +185,23 @@ func Init(ctx context.Context) (*Service, error) {
 		Enabled:          cfg.NFTEnabled,
 		NetworkNamespace: cfg.NFTNetworkNamespace,
 		DefaultPolicy:    cfg.NFTDefaultPolicy,
-		WGIface:          cfg.WGIface,
-		WGPort:           cfg.WGPort,
+		Mode:             cfg.NFTMode,
+		WGNetworks:       nftNetworks,
 		Ifaces:           cfg.NFTIfaces,
 		TrustPorts:       cfg.NFTTrustPorts,
+		RateLimit: firewall.RateLimit{
+			TrustRate:  cfg.NFTTrustRate,
+			WGRate:     cfg.NFTWGRate,
+			BanTimeout: cfg.NFTBanTimeout,
+		},
+		DropLogRate: cfg.NFTDropLogRate,
+		Masquerade:  cfg.NFTMasquerade,
 	}
 	nft, err = firewall.Init(nftCfg, managerPorts)
 	if err != nil {
 		return nil, err
 	}
 
-	ns := fmt.Sprintf("server.%s", cfg.DNSZone)
-	mbox := fmt.Sprintf("hostmaster.server.%s", cfg.DNSZone)
-	resolver := resolver.New(
-		log, db,
-		cfg.DNSResolverAddrs, cfg.DNSZone,
-		ns, mbox,
-		cfg.wgIfaceIP)
-
 	s := &Service{
 		ctx: ctx,
 		cfg: cfg,
@@ -132,14 +209,12 @@ func Init(ctx context.Context) (*Service, error) {
 		db:  db,
 		nft: nft,
 
-		trustIPSet:        ipset.IPSet{},
-		wgManagerIPSet:    ipset.IPSet{},
-		wgForwardWanIPSet: ipset.IPSet{},
+		networks: networks,
 
-		wgm:     wgm,
-		wgpeers: wgmngr.PeerSet{},
-
-		resolver: resolver,
+		refreshc: make(chan struct{}, 1),
+	}
+	if cfg.NFTConfirmTimeout > 0 {
+		s.confirm = newConfirm(cfg.NFTConfirmTimeout)
 	}
 
 	return s, nil
@@ -168,77 +243,78 @@ func (s *Service) Run() {
 
 	var (
 		lc            *net.ListenConfig
-		listenDnsTcp  net.Listener
-		listenDnsUdp  net.PacketConn
 		listenApiTcp  net.Listener
 		listenApiUnix net.Listener
 		listenFeTcp   net.Listener
 		wg            sync.WaitGroup
 
-		dnsTcp *dns.Server
-		dnsUdp *dns.Server
+		dnsServers []*dns.Server
 
 		apiTcp  *http.Server
 		apiUnix *http.Server
 		feTcp   *http.Server
 	)
 
-	// run dns tcp
-	lc = &net.ListenConfig{}
-	listenDnsTcp, err = lc.Listen(ctx, "tcp", s.cfg.dnsTcpAddr)
-	if err != nil {
-		s.log.Error(err)
-		return
-	}
-	defer listenDnsTcp.Close()
-	dnsTcp = &dns.Server{
-		Listener:     listenDnsTcp,
-		Handler:      s.resolver,
-		ReadTimeout:  2 * time.Second,
-		WriteTimeout: 2 * time.Second,
-	}
-
-	wg.Add(1)
-	go func() {
-		s.log.Info("dns tcp socket serve running…")
-		err = dnsTcp.ActivateAndServe()
+	for _, n := range s.networks {
+		// run dns tcp
+		lc = &net.ListenConfig{}
+		listenDnsTcp, err := lc.Listen(ctx, "tcp", n.cfg.dnsTcpAddr)
 		if err != nil {
-			s.log.Errorf("dns tcp serve has failed %v", err)
-		} else {
-			s.log.Error("dns tcp serve has stopped")
+			s.log.Error(err)
+			return
 		}
-		cancel()
-		wg.Done()
-	}()
-
-	// run dns udp
-	lc = &net.ListenConfig{}
-	listenDnsUdp, err = lc.ListenPacket(ctx, "udp", s.cfg.dnsUdpAddr)
-	if err != nil {
-		s.log.Error(err)
-		return
-	}
-	defer listenDnsUdp.Close()
-	dnsUdp = &dns.Server{
-		PacketConn:   listenDnsUdp,
-		Handler:      s.resolver,
-		UDPSize:      512,
-		ReadTimeout:  2 * time.Second,
-		WriteTimeout: 2 * time.Second,
-	}
+		defer listenDnsTcp.Close()
+		dnsTcp := &dns.Server{
+			Listener:     listenDnsTcp,
+			Handler:      n.resolver,
+			ReadTimeout:  2 * time.Second,
+			WriteTimeout: 2 * time.Second,
+		}
+		dnsServers = append(dnsServers, dnsTcp)
+
+		wg.Add(1)
+		go func(name string) {
+			s.log.Infof("dns tcp socket serve of %q network running…", name)
+			err := dnsTcp.ActivateAndServe()
+			if err != nil {
+				s.log.Errorf("dns tcp serve has failed %v", err)
+			} else {
+				s.log.Error("dns tcp serve has stopped")
+			}
+			cancel()
+			wg.Done()
+		}(n.cfg.name)
 
-	wg.Add(1)
-	go func() {
-		s.log.Info("dns udp socket serve running…")
-		err = dnsUdp.ActivateAndServe()
+		// run dns udp
+		lc = &net.ListenConfig{}
+		listenDnsUdp, err := lc.ListenPacket(ctx, "udp", n.cfg.dnsUdpAddr)
 		if err != nil {
-			s.log.Errorf("dns tcp serve has failed %v", err)
-		} else {
-			s.log.Error("dns tcp serve has stopped")
+			s.log.Error(err)
+			return
 		}
-		cancel()
-		wg.Done()
-	}()
+		defer listenDnsUdp.Close()
+		dnsUdp := &dns.Server{
+			PacketConn:   listenDnsUdp,
+			Handler:      n.resolver,
+			UDPSize:      512,
+			ReadTimeout:  2 * time.Second,
+			WriteTimeout: 2 * time.Second,
+		}
+		dnsServers = append(dnsServers, dnsUdp)
+
+		wg.Add(1)
+		go func(name string) {
+			s.log.Infof("dns udp socket serve of %q network running…", name)
+			err := dnsUdp.ActivateAndServe()
+			if err != nil {
+				s.log.Errorf("dns udp serve has failed %v", err)
+			} else {
+				s.log.Error("dns udp serve has stopped")
+			}
+			cancel()
+			wg.Done()
+		}(n.cfg.name)
+	}
 
 	// run api tcp
 	lc = &net.ListenConfig{}
@@ -338,23 +414,78 @@ func (s *Service) Run() {
 		wg.Done()
 	}()
 
-	// run refresh periodically
+	// run refresh on state change and periodically as a safety net
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		defer cancel()
 
-		ticker := time.NewTicker(1 * time.Second)
+		ticker := time.NewTicker(resyncInterval)
 		defer ticker.Stop()
 
 		tickerChan := ticker.C
+
+		// the deadline of the firewall changes is never passed
+		// while the confirmation is turned off
+		var expiredc chan struct{}
+		if s.confirm != nil {
+			expiredc = s.confirm.expiredc
+		}
+
+		// the wan changes are followed once they settle,
+		// the periodic refresh is the safety net
+		wanc, err := ifconfig.Watch(ctx.Done())
+		if err != nil {
+			s.log.Errorf("wan changes aren't watched: %v", err)
+		}
+		var wanTimerChan <-chan time.Time
 		for {
 			select {
-			case <-tickerChan:
+			case <-s.refreshc:
 				err := s.refresh()
 				if err != nil {
 					s.log.Error(err)
 				}
+			case <-expiredc:
+				err := s.rollback()
+				if err != nil {
+					s.log.Error(err)
+				}
+			case _, ok := <-wanc:
+				if !ok {
+					wanc = nil
+					if ctx.Err() == nil {
+						s.log.Error("wan changes aren't watched anymore")
+					}
+					continue
+				}
+				wanTimerChan = time.After(wanSettleDelay)
+			case <-wanTimerChan:
+				wanTimerChan = nil
+				err := s.updateWan()
+				if err != nil {
+					s.log.Error(err)
+				}
+			case <-tickerChan:
+				err := s.purge()
+				if err != nil {
+					s.log.Error(err)
+				}
+				err = s.updateWan()
+				if err != nil {
+					s.log.Error(err)
+				}
+				err = s.refresh()
+				if err != nil {
+					s.log.Error(err)
+				}
+				if s.confirm != nil {
+					// the failed rollback is tried again
+					err = s.rollback()
+					if err != nil {
+						s.log.Error(err)
+					}
+				}
 			case <-ctx.Done():
 				return
 			}
@@ -370,73 +501,187 @@ func (s *Service) Run() {
 	}
 
 	s.log.Info("cleaning up…")
-	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	err = feTcp.Shutdown(ctx)
-	if err != nil {
-		s.log.Errorf("failed gracefully stop api tcp socket server %v", err)
+	// the workers may still read ctx, the servers are stopped
+	// by the contexts of their own
+	for _, srv := range []*http.Server{feTcp, apiUnix, apiTcp} {
+		shutdownCtx, shutdownCancel := context.WithTimeout(
+			context.Background(), 5*time.Second)
+		err = srv.Shutdown(shutdownCtx)
+		shutdownCancel()
+		if err != nil {
+			s.log.Errorf("failed gracefully stop http server %v", err)
+		}
 	}
 
-	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	err = apiUnix.Shutdown(ctx)
-	if err != nil {
-		s.log.Errorf("failed gracefully stop api tcp socket server %v", err)
+	for _, srv := range dnsServers {
+		err = srv.Shutdown()
+		if err != nil {
+			s.log.Errorf("failed gracefully stop dns socket server %v", err)
+		}
 	}
 
-	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	err = apiTcp.Shutdown(ctx)
+	// the workers are stopped before the state they use is released
+	s.log.Info("waiting workers to stop…")
+	wg.Wait()
+	s.cleanup()
+	s.log.Info("cleanup done, shutdown")
+}
+
+// cleanup the state once the workers are stopped: the ruleset found
+// on start is restored, the interfaces are removed and the db is closed
+// the last.
+func (s *Service) cleanup() {
+	err := s.nft.Cleanup()
 	if err != nil {
-		s.log.Errorf("failed gracefully stop api tcp socket server %v", err)
+		s.log.Error(err)
 	}
 
-	err = dnsUdp.Shutdown()
-	if err != nil {
-		s.log.Errorf("failed gracefully stop dns udp socket server %v", err)
+	for _, n := range s.networks {
+		n.wgm.Cleanup()
+		err = iface.Remove(s.log, n.cfg.iface)
+		if err != nil {
+			s.log.Error(err)
+		}
 	}
 
-	err = dnsTcp.Shutdown()
+	s.db.Close()
+}
+
+// resyncInterval of the full state refresh, the changes made by api are
+// refreshed immediately.
+const resyncInterval = 1 * time.Minute
+
+// wanSettleDelay the wan is detected again after the last address
+// or route change, the change of dhcp lease comes in a few updates.
+const wanSettleDelay = 2 * time.Second
+
+// updateWan follows the changes of the wan interface and ip address.
+func (s *Service) updateWan() error {
+	changed, err := s.nft.UpdateWan()
 	if err != nil {
-		s.log.Errorf("failed gracefully stop dns tcp socket server %v", err)
+		return fmt.Errorf("can't update wan: %v", err)
+	}
+	if changed {
+		s.log.Warningf("wan ip address is changed to %s", s.nft.WanIP())
 	}
 
-	s.cleanup()
-	s.log.Info("waiting workers to stop…")
-	wg.Wait()
-	s.log.Info("cleanup done, shutdown")
+	return nil
 }
 
-func (s *Service) cleanup() {
-	s.db.Close()
-	s.wgm.Cleanup()
-	err := iface.Remove(s.log, s.cfg.WGIface)
+// notify refresh loop about the state change, it never blocks.
+func (s *Service) notify() {
+	if s.confirm != nil {
+		s.confirm.notify()
+	}
+
+	select {
+	case s.refreshc <- struct{}{}:
+	default: // refresh is pending already
+	}
+}
+
+// purge devices expired for more than configured number of days.
+func (s *Service) purge() error {
+	if s.cfg.DevicePurgeDays <= 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin(true) // writeable tx
 	if err != nil {
-		s.log.Error(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	devices, err := model.LoadDevices(tx)
+	if err != nil {
+		return err
+	}
+
+	days := time.Duration(s.cfg.DevicePurgeDays) * 24 * time.Hour
+	devices = devices.ExpiredBefore(time.Now().Add(-days))
+	if len(devices) == 0 {
+		return nil
+	}
+
+	for _, d := range devices {
+		err = model.RemoveDevice(tx, d.IPNetwork.IP)
+		if err != nil {
+			return err
+		}
+
+		u, err := model.LoadUser(tx, d.UserUUID)
+		if err != nil {
+			return err
+		}
+		u.RemoveDevice(d.IPNetwork.IP)
+		err = u.Store(tx)
+		if err != nil {
+			return err
+		}
+
+		s.log.Infof("expired device %q %s purged", d.Label, d.IPNetwork.IP)
 	}
+
+	return tx.Commit()
 }
 
-func (s *Service) refresh() error {
+func (s *Service) refresh() (err error) {
+	var notified bool
+	if s.confirm != nil {
+		notified = s.confirm.begin()
+	}
+
 	tx, err := s.db.Begin(false) // non-writeable tx
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
+	if s.confirm != nil {
+		var snapshot *model.Snapshot
+		snapshot, err = model.TakeSnapshot(tx)
+		if err != nil {
+			return err
+		}
+
+		revision := s.nft.Revision()
+		routeRevision := s.routeRevision
+		defer func() {
+			changed := s.nft.Revision() != revision ||
+				s.routeRevision != routeRevision
+			s.confirm.end(snapshot, notified, changed, err != nil)
+		}()
+	}
+
 	trustIPSet, err := model.LoadManagerSSHTrustIPSet(tx)
 	if err != nil {
 		return err
 	}
-	s.trustIPSet.Replace(trustIPSet)
-	removed := s.trustIPSet.Removed()
-	added := s.trustIPSet.Added()
-	s.trustIPSet = s.trustIPSet.Copy()
-	err = s.nft.UpdateTrustIPs(removed, added)
+	trustIPs := firewallTrustIPs(trustIPSet.Active(time.Now()))
+	if !reflect.DeepEqual(s.trustIPs, trustIPs) {
+		err = s.nft.UpdateTrustIPs(trustIPs)
+		if err != nil {
+			// differs from any result to be reapplied next time
+			s.trustIPs = []firewall.TrustIP{}
+			return err
+		}
+		s.trustIPs = trustIPs
+	}
+
+	egressRules, err := model.LoadEgressRules(tx)
 	if err != nil {
-		s.trustIPSet = ipset.IPSet{}
-		// TODO: flush nft ipset
 		return err
 	}
+	egress := firewallEgressRules(egressRules)
+	if !reflect.DeepEqual(s.egress, egress) {
+		err = s.nft.UpdateEgress(egress)
+		if err != nil {
+			// differs from any result to be reapplied next time
+			s.egress = []firewall.EgressRule{}
+			return err
+		}
+		s.egress = egress
+	}
 
 	users, err := model.LoadUsers(tx)
 	if err != nil {
@@ -448,60 +693,237 @@ func (s *Service) refresh() error {
 		return err
 	}
 
-	wgManagerIPs := wgManagerIPs(users)
-	s.wgManagerIPSet.Replace(wgManagerIPs)
-	removed = s.wgManagerIPSet.Removed()
-	added = s.wgManagerIPSet.Added()
-	s.wgManagerIPSet = s.wgManagerIPSet.Copy()
-	err = s.nft.UpdateWGManagerIPs(removed, added)
+	groups, err := model.LoadGroups(tx)
 	if err != nil {
-		s.wgManagerIPSet = ipset.IPSet{}
-		// TODO: flush nft ipset
 		return err
 	}
 
-	wgForwardWanIPs := wgForwardWanIPs(devices)
-	s.wgForwardWanIPSet.Replace(wgForwardWanIPs)
-	removed = s.wgForwardWanIPSet.Removed()
-	added = s.wgForwardWanIPSet.Added()
-	s.wgForwardWanIPSet = s.wgForwardWanIPSet.Copy()
-	err = s.nft.UpdateWGForwardWanIPs(removed, added)
+	pairs, err := model.LoadDevicePairs(tx)
 	if err != nil {
-		s.wgForwardWanIPSet = ipset.IPSet{}
+		return err
+	}
+
+	for _, n := range s.networks {
+		err = s.refreshNetwork(
+			n, users, groups, devices.Network(n.key), pairs)
+		if err != nil {
+			return err
+		}
+	}
+
+	forwards, err := model.LoadPortForwards(tx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var portForwards []firewall.PortForward
+	for _, n := range s.networks {
+		active := devices.Network(n.key).Active(now)
+		portForwards = append(portForwards,
+			wgPortForwards(forwards, active, n.cfg.name)...)
+	}
+	if !reflect.DeepEqual(s.portForwards, portForwards) {
+		err = s.nft.UpdatePortForwards(portForwards)
+		if err != nil {
+			// differs from any result to be reapplied next time
+			s.portForwards = []firewall.PortForward{}
+			return err
+		}
+		s.portForwards = portForwards
+	}
+
+	domains, err := model.LoadDomains(tx)
+	if err != nil {
+		return err
+	}
+	m := make(map[string]model.Domain, len(domains))
+	for _, d := range domains {
+		m[d.Name] = d
+	}
+	for _, n := range s.networks {
+		n.resolver.Update(m)
+	}
+
+	return nil
+}
+
+// rollback the state to the confirmed one when the deadline
+// of the firewall changes is passed.
+func (s *Service) rollback() error {
+	restored, err := s.confirm.restore(func(snapshot *model.Snapshot) error {
+		tx, err := s.db.Begin(true) // writeable tx
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		err = snapshot.Restore(tx)
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+	if err != nil {
+		return fmt.Errorf("can't rollback unconfirmed changes: %v", err)
+	}
+	if !restored {
+		return nil
+	}
+
+	s.log.Warning("firewall changes aren't confirmed, the settings are rolled back")
+
+	return s.refresh()
+}
+
+func (s *Service) refreshNetwork(
+	n *network,
+	users model.Users,
+	groups model.Groups,
+	devices model.Devices,
+	pairs model.DevicePairs,
+) error {
+	now := time.Now()
+	inactive := devices.Inactive(now)
+	devices = devices.Active(now)
+
+	wgManagerIPs := wgManagerIPs(users, devices, n.cfg.ifaceIPNet6)
+	n.wgManagerIPSet.Replace(wgManagerIPs)
+	removed := n.wgManagerIPSet.Removed()
+	added := n.wgManagerIPSet.Added()
+	n.wgManagerIPSet = n.wgManagerIPSet.Copy()
+	err := s.nft.UpdateWGManagerIPs(n.cfg.name, removed, added)
+	if err != nil {
+		n.wgManagerIPSet = ipset.IPSet{}
 		// TODO: flush nft ipset
 		return err
 	}
 
-	wgpeers, err := wgPeers(devices)
+	wgForwardWanIPs := wgForwardWanIPs(devices, n.cfg.ifaceIPNet6)
+	n.wgForwardWanIPSet.Replace(wgForwardWanIPs)
+	removed = n.wgForwardWanIPSet.Removed()
+	added = n.wgForwardWanIPSet.Added()
+	n.wgForwardWanIPSet = n.wgForwardWanIPSet.Copy()
+	err = s.nft.UpdateWGForwardWanIPs(n.cfg.name, removed, added)
 	if err != nil {
+		n.wgForwardWanIPSet = ipset.IPSet{}
+		// TODO: flush nft ipset
 		return err
 	}
 
-	s.wgpeers.Replace(wgpeers)
-	peersRemoved := s.wgpeers.Removed()
-	peersAdded := s.wgpeers.Added()
-	s.wgpeers = s.wgpeers.Copy()
+	wgACLs := wgACLs(users, devices, n.cfg.ifaceIPNet6)
+	if !reflect.DeepEqual(n.wgACLs, wgACLs) {
+		err = s.nft.UpdateWGACLs(n.cfg.name, wgACLs)
+		if err != nil {
+			// differs from any result to be reapplied next time
+			n.wgACLs = []firewall.ACL{}
+			return err
+		}
+		n.wgACLs = wgACLs
+	}
+
+	wgGroups, wgPolicies := wgGroups(groups, devices, n.cfg.ifaceIPNet6)
+	if !reflect.DeepEqual(n.wgGroups, wgGroups) ||
+		!reflect.DeepEqual(n.wgPolicies, wgPolicies) {
+		err = s.nft.UpdateWGGroups(n.cfg.name, wgGroups, wgPolicies)
+		if err != nil {
+			// differs from any result to be reapplied next time
+			n.wgGroups = []firewall.Group{}
+			return err
+		}
+		n.wgGroups = wgGroups
+		n.wgPolicies = wgPolicies
+	}
+
+	wgDevices := wgFirewallDevices(devices, n.cfg.ifaceIPNet6)
+	if !reflect.DeepEqual(n.wgDevices, wgDevices) {
+		err = s.nft.UpdateWGDevices(n.cfg.name, wgDevices)
+		if err != nil {
+			// differs from any result to be reapplied next time
+			n.wgDevices = []firewall.Device{}
+			return err
+		}
+		n.wgDevices = wgDevices
+	}
+
+	wgEgress := wgEgress(devices, n.cfg.ifaceIPNet6)
+	if !reflect.DeepEqual(n.wgEgress, wgEgress) {
+		err = s.nft.UpdateWGEgress(n.cfg.name, wgEgress)
+		if err != nil {
+			// differs from any result to be reapplied next time
+			n.wgEgress = []firewall.Egress{}
+			return err
+		}
+		n.wgEgress = wgEgress
+	}
 
-	err = s.wgm.PeerRemove(peersRemoved)
+	wgIsolation := wgIsolation(devices, pairs, n.cfg.ifaceIPNet6)
+	if !reflect.DeepEqual(n.wgIsolation, wgIsolation) {
+		err = s.nft.UpdateWGIsolation(n.cfg.name, wgIsolation)
+		if err != nil {
+			// differs from any result to be reapplied next time
+			n.wgIsolation = firewall.Isolation{Servers: []net.IP{}}
+			return err
+		}
+		n.wgIsolation = wgIsolation
+	}
+
+	wgBandwidth := wgBandwidth(devices, n.cfg.ifaceIPNet6)
+	if !reflect.DeepEqual(n.wgBandwidth, wgBandwidth) {
+		err = s.nft.UpdateWGBandwidth(n.cfg.name, wgBandwidth)
+		if err != nil {
+			// differs from any result to be reapplied next time
+			n.wgBandwidth = []firewall.Bandwidth{}
+			return err
+		}
+		n.wgBandwidth = wgBandwidth
+	}
+
+	wgpeers, err := wgPeers(devices, n.cfg.ifaceIPNet6)
 	if err != nil {
-		s.wgpeers = wgmngr.PeerSet{}
 		return err
 	}
-	err = s.wgm.PeerSet(peersAdded)
+
+	n.wgpeers.Replace(wgpeers)
+	peersRemoved := n.wgpeers.Removed()
+	peersAdded := n.wgpeers.Added()
+	n.wgpeers = n.wgpeers.Copy()
+
+	err = n.wgm.PeerUpdate(peersRemoved, peersAdded)
 	if err != nil {
-		s.wgpeers = wgmngr.PeerSet{}
+		n.wgpeers = wgmngr.PeerSet{}
 		return err
 	}
 
-	domains, err := model.LoadDomains(tx)
+	wgRoutes := wgRoutes(devices)
+	n.wgRouteSet.Replace(wgRoutes)
+	routesRemoved := n.wgRouteSet.Removed()
+	routesAdded := n.wgRouteSet.Added()
+	n.wgRouteSet = n.wgRouteSet.Copy()
+	if len(routesRemoved) > 0 || len(routesAdded) > 0 {
+		s.routeRevision++
+	}
+	err = iface.RouteDel(s.log, n.cfg.iface, routesRemoved)
 	if err != nil {
+		n.wgRouteSet = ipset.IPNetSet{}
 		return err
 	}
-	m := make(map[string]model.Domain, len(domains))
-	for _, d := range domains {
-		m[d.Name] = d
+	err = iface.RouteAdd(s.log, n.cfg.iface, routesAdded)
+	if err != nil {
+		n.wgRouteSet = ipset.IPNetSet{}
+		return err
+	}
+
+	// flush connections of the devices being disabled or expired
+	wgInactiveIPs := wgDeviceIPs(inactive, n.cfg.ifaceIPNet6)
+	n.wgInactiveIPSet.Replace(wgInactiveIPs)
+	added = n.wgInactiveIPSet.Added()
+	n.wgInactiveIPSet = n.wgInactiveIPSet.Copy()
+	err = conntrack.Flush(s.log, added)
+	if err != nil {
+		n.wgInactiveIPSet = ipset.IPSet{}
+		return err
 	}
-	s.resolver.Update(m)
 
 	return nil
 }
@@ -522,16 +944,27 @@ func (s *Service) apiTcpMux(ctx context.Context) *http.ServeMux {
 	managerCfg := manager.Config{
 		AuthRequired: true,
 
-		WanIP:    s.nft.WanIP(),
-		WgInet:   s.cfg.wgIfaceInet,
-		WgIPNet:  s.cfg.wgIfaceIPNet,
-		WgPort:   s.cfg.WGPort,
-		WgPubKey: s.wgm.PublicKey(),
+		WanIP:     s.nft.WanIP,
+		HostNets:  s.hostNets,
+		HostPorts: s.hostPorts(),
+		Endpoints: s.cfg.endpoints,
+		Networks:  s.managerNetworks(),
 
 		OTPIssuer: s.cfg.OTPIssuer,
 
 		SessionSecret: s.cfg.SessionSecret,
 		SessionTTL:    s.cfg.SessionTTL,
+
+		Notify: s.notify,
+
+		FirewallPreview:   s.nft.Preview,
+		FirewallBlacklist: s.nft.Blacklist,
+		FirewallUnban:     s.nft.Unban,
+		FirewallStats:     s.firewallStats,
+	}
+	if s.confirm != nil {
+		managerCfg.FirewallConfirm = s.confirm.Confirm
+		managerCfg.FirewallDeadline = s.confirm.Deadline
 	}
 	manager := manager.New(ctx, s.log, managerCfg, s.db)
 	manager.RegisterHandlers(httprpc)
@@ -558,16 +991,27 @@ func (s *Service) apiUnixMux(ctx context.Context) *http.ServeMux {
 	cfg := manager.Config{
 		AuthRequired: false,
 
-		WanIP:    s.nft.WanIP(),
-		WgInet:   s.cfg.wgIfaceInet,
-		WgIPNet:  s.cfg.wgIfaceIPNet,
-		WgPort:   s.cfg.WGPort,
-		WgPubKey: s.wgm.PublicKey(),
+		WanIP:     s.nft.WanIP,
+		HostNets:  s.hostNets,
+		HostPorts: s.hostPorts(),
+		Endpoints: s.cfg.endpoints,
+		Networks:  s.managerNetworks(),
 
 		OTPIssuer: s.cfg.OTPIssuer,
 
 		SessionSecret: s.cfg.SessionSecret,
 		SessionTTL:    s.cfg.SessionTTL,
+
+		Notify: s.notify,
+
+		FirewallPreview:   s.nft.Preview,
+		FirewallBlacklist: s.nft.Blacklist,
+		FirewallUnban:     s.nft.Unban,
+		FirewallStats:     s.firewallStats,
+	}
+	if s.confirm != nil {
+		cfg.FirewallConfirm = s.confirm.Confirm
+		cfg.FirewallDeadline = s.confirm.Deadline
 	}
 	manager := manager.New(ctx, s.log, cfg, s.db)
 	manager.RegisterHandlers(httprpc)
@@ -579,6 +1023,62 @@ func (s *Service) apiUnixMux(ctx context.Context) *http.ServeMux {
 	return mux
 }
 
+// hostPorts returns the tcp ports of the host served on the wan.
+func (s *Service) hostPorts() []uint16 {
+	ports := make([]uint16, 0, len(s.cfg.NFTTrustPorts)+2)
+	ports = append(ports, s.cfg.NFTTrustPorts...)
+	ports = append(ports,
+		uint16(s.cfg.APIHTTPPort), uint16(s.cfg.FEHTTPPort))
+
+	return ports
+}
+
+// hostNets returns the networks of the host but the wireguard ones.
+func (s *Service) hostNets() ([]*net.IPNet, error) {
+	ifaces := make([]string, len(s.networks))
+	for i, n := range s.networks {
+		ifaces[i] = n.cfg.iface
+	}
+
+	return iface.HostNets(ifaces)
+}
+
+func (s *Service) managerNetworks() []manager.Network {
+	networks := make([]manager.Network, len(s.networks))
+	for i, n := range s.networks {
+		networks[i] = manager.Network{
+			Name:      n.cfg.name,
+			WgInet:    n.cfg.ifaceInet,
+			WgIPNet:   n.cfg.ifaceIPNet,
+			WgInet6:   n.cfg.ifaceInet6,
+			WgIPNet6:  n.cfg.ifaceIPNet6,
+			WgPort:    n.cfg.port,
+			WgManager: n.wgm,
+		}
+	}
+
+	return networks
+}
+
+// firewallStats returns the firewall counters for the manager api.
+func (s *Service) firewallStats() ([]manager.FirewallCounter, error) {
+	counters, err := s.nft.Stats()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]manager.FirewallCounter, len(counters))
+	for i, v := range counters {
+		result[i] = manager.FirewallCounter{
+			Name:    v.Name,
+			Packets: v.Packets,
+			Bytes:   v.Bytes,
+		}
+	}
+
+	return result, nil
+}
+
 func (s *Service) feTcpMux(ctx context.Context) (*http.ServeMux, error) {
 	// fe service
 	apiURL := &url.URL{Scheme: "http", Host: s.cfg.apiHTTPAddr, Path: "rpc"}
@@ -6,36 +6,32 @@ import (
 	bolt "go.etcd.io/bbolt"
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 
+	"wgnetwork/firewall"
 	"wgnetwork/model"
 	"wgnetwork/pkg/wgmngr"
 )
 
-func wgPrivateKey(db *bolt.DB) (wgtypes.Key, error) {
+func wgPrivateKey(db *bolt.DB, network string) (wgtypes.Key, error) {
 	tx, err := db.Begin(true) // writeable tx
 	if err != nil {
 		return wgtypes.Key{}, err
 	}
 	defer tx.Rollback()
 
-	bname := []byte("wg")
-	b, err := tx.CreateBucketIfNotExists(bname)
+	s, ok, err := model.LoadWgServer(tx, network)
 	if err != nil {
 		return wgtypes.Key{}, err
 	}
+	if ok {
+		return s.PrivateKey, nil
+	}
 
-	var sk wgtypes.Key
-
-	key := []byte("cfg")
-	v := b.Get(key)
-	if v == nil {
-		sk, err = wgtypes.GeneratePrivateKey()
-		if err == nil {
-			err = b.Put(key, sk[:])
-		}
-	} else {
-		sk, err = wgtypes.NewKey(v)
+	s.PrivateKey, err = wgtypes.GeneratePrivateKey()
+	if err != nil {
+		return wgtypes.Key{}, err
 	}
 
+	err = s.Store(tx)
 	if err != nil {
 		return wgtypes.Key{}, err
 	}
@@ -44,58 +40,354 @@ func wgPrivateKey(db *bolt.DB) (wgtypes.Key, error) {
 		return wgtypes.Key{}, err
 	}
 
-	return sk, nil
+	return s.PrivateKey, nil
 }
 
-func wgManagerIPs(users model.Users) []net.IP {
-	if users == nil {
+func wgManagerIPs(
+	users model.Users,
+	devices model.Devices,
+	ipnet6 *net.IPNet,
+) []net.IP {
+	if users == nil || devices == nil {
 		return nil
 	}
 
-	ips := make([]net.IP, 0, len(users))
+	managers := make(map[string]struct{}, len(users))
 	for i := range users {
-		if !users[i].IsManager {
-			continue
+		if users[i].IsManager {
+			managers[users[i].UUID] = struct{}{}
 		}
+	}
 
-		for j := range users[i].Devices {
-			ips = append(ips, users[i].Devices[j])
+	managerDevices := make(model.Devices, 0, len(devices))
+	for i := range devices {
+		if _, ok := managers[devices[i].UserUUID]; ok {
+			managerDevices = append(managerDevices, devices[i])
 		}
 	}
 
-	return ips
+	return wgDeviceIPs(managerDevices, ipnet6)
 }
 
-func wgForwardWanIPs(devices model.Devices) []net.IP {
+func wgForwardWanIPs(devices model.Devices, ipnet6 *net.IPNet) []net.IP {
 	if devices == nil {
 		return nil
 	}
 
-	ips := make([]net.IP, len(devices))
-	i := 0
+	ips := make([]net.IP, 0, len(devices))
 	for j := range devices {
 		if !devices[j].WANForward {
 			continue
 		}
 
-		ips[i] = devices[j].IPNetwork.IP
-		i++
+		ips = append(ips, devices[j].IPNetwork.IP)
+
+		if ipnet6 != nil {
+			ips = append(ips, devices[j].CIDR6(ipnet6).IP)
+		}
+	}
+
+	return ips
+}
+
+// wgDeviceIPs returns ipv4 and optional ipv6 addresses of the devices.
+func wgDeviceIPs(devices model.Devices, ipnet6 *net.IPNet) []net.IP {
+	ips := make([]net.IP, 0, len(devices))
+	for i := range devices {
+		ips = append(ips, devices[i].IPNetwork.IP)
+
+		if ipnet6 != nil {
+			ips = append(ips, devices[i].CIDR6(ipnet6).IP)
+		}
+	}
+
+	return ips
+}
+
+// wgFirewallDevices returns the devices counted by the firewall.
+func wgFirewallDevices(
+	devices model.Devices,
+	ipnet6 *net.IPNet,
+) []firewall.Device {
+	result := make([]firewall.Device, len(devices))
+	for i := range devices {
+		result[i] = firewall.Device{IP: devices[i].IPNetwork.IP}
+		if ipnet6 != nil {
+			result[i].IP6 = devices[i].CIDR6(ipnet6).IP
+		}
+	}
+
+	return result
+}
+
+// wgACLs returns acl of the devices restricted by the device
+// or its user rules.
+func wgACLs(
+	users model.Users,
+	devices model.Devices,
+	ipnet6 *net.IPNet,
+) []firewall.ACL {
+	userRules := make(map[string]model.NFRules, len(users))
+	for i := range users {
+		if len(users[i].Rules) > 0 {
+			userRules[users[i].UUID] = users[i].Rules
+		}
+	}
+
+	var acls []firewall.ACL
+	for i := range devices {
+		rules := make(model.NFRules, 0,
+			len(devices[i].Rules)+len(userRules[devices[i].UserUUID]))
+		rules = append(rules, devices[i].Rules...)
+		rules = append(rules, userRules[devices[i].UserUUID]...)
+		if len(rules) == 0 {
+			continue
+		}
+
+		acl := firewall.ACL{
+			IP:    devices[i].IPNetwork.IP,
+			Rules: make([]firewall.ACLRule, 0, len(rules)),
+		}
+		if ipnet6 != nil {
+			acl.IP6 = devices[i].CIDR6(ipnet6).IP
+		}
+		for j := range rules {
+			acl.Rules = append(acl.Rules, firewall.ACLRule{
+				Proto:     rules[j].IPProto,
+				Direction: rules[j].Direction,
+				SPorts:    rules[j].SPorts,
+				DPorts:    rules[j].DPorts,
+				Addrs:     rules[j].Addrs(),
+			})
+		}
+		acls = append(acls, acl)
+	}
+
+	return acls
+}
+
+// wgEgress returns egress policies of the devices having egress rules.
+func wgEgress(
+	devices model.Devices,
+	ipnet6 *net.IPNet,
+) []firewall.Egress {
+	var egress []firewall.Egress
+	for i := range devices {
+		if len(devices[i].Egress) == 0 {
+			continue
+		}
+
+		e := firewall.Egress{
+			IP:    devices[i].IPNetwork.IP,
+			Rules: firewallEgressRules(devices[i].Egress),
+		}
+		if ipnet6 != nil {
+			e.IP6 = devices[i].CIDR6(ipnet6).IP
+		}
+		egress = append(egress, e)
+	}
+
+	return egress
+}
+
+// firewallEgressRules returns the rules in firewall representation.
+func firewallEgressRules(rules model.EgressRules) []firewall.EgressRule {
+	result := make([]firewall.EgressRule, len(rules))
+	for i := range rules {
+		result[i] = firewall.EgressRule{
+			Allow:  rules[i].Action == model.EgressActionAllow,
+			Proto:  rules[i].IPProto,
+			DPorts: rules[i].DPorts,
+			DNets:  rules[i].IPNets(),
+		}
 	}
 
-	return ips[:i]
+	return result
 }
 
-func wgPeers(devices model.Devices) (wgmngr.Peers, error) {
+// wgIsolation returns server devices and pairs of the active devices
+// along with their routes, the pairs with a device of another network
+// or inactive are skipped.
+func wgIsolation(
+	devices model.Devices,
+	pairs model.DevicePairs,
+	ipnet6 *net.IPNet,
+) firewall.Isolation {
+	var isolation firewall.Isolation
+	active := make(map[string]model.Device, len(devices))
+	for i := range devices {
+		active[devices[i].IPNetwork.IP.String()] = devices[i]
+
+		if !devices[i].Server {
+			continue
+		}
+		isolation.Servers = append(isolation.Servers,
+			devices[i].IPNetwork.IP)
+		isolation.Routes = append(isolation.Routes,
+			wgRoutes(devices[i:i+1])...)
+		if ipnet6 != nil {
+			isolation.Servers = append(isolation.Servers,
+				devices[i].CIDR6(ipnet6).IP)
+		}
+	}
+
+	for _, p := range pairs {
+		a, ok := active[p.A.String()]
+		if !ok {
+			continue
+		}
+		b, ok := active[p.B.String()]
+		if !ok {
+			continue
+		}
+
+		isolation.Pairs = append(isolation.Pairs, firewall.Pair{
+			A:       a.IPNetwork.IP,
+			B:       b.IPNetwork.IP,
+			ARoutes: wgRoutes(model.Devices{a}),
+			BRoutes: wgRoutes(model.Devices{b}),
+		})
+		if ipnet6 != nil {
+			isolation.Pairs = append(isolation.Pairs, firewall.Pair{
+				A: a.CIDR6(ipnet6).IP,
+				B: b.CIDR6(ipnet6).IP,
+			})
+		}
+	}
+
+	return isolation
+}
+
+// wgBandwidth returns bandwidth limits of the devices having any limit
+// along with their routes.
+func wgBandwidth(
+	devices model.Devices,
+	ipnet6 *net.IPNet,
+) []firewall.Bandwidth {
+	var bandwidth []firewall.Bandwidth
+	for i := range devices {
+		if devices[i].DownloadRate == 0 && devices[i].UploadRate == 0 {
+			continue
+		}
+
+		b := firewall.Bandwidth{
+			IP:       devices[i].IPNetwork.IP,
+			Routes:   wgRoutes(devices[i : i+1]),
+			Download: devices[i].DownloadRate,
+			Upload:   devices[i].UploadRate,
+		}
+		if ipnet6 != nil {
+			b.IP6 = devices[i].CIDR6(ipnet6).IP
+		}
+		bandwidth = append(bandwidth, b)
+	}
+
+	return bandwidth
+}
+
+// wgGroups returns groups with their devices and policies of the groups.
+func wgGroups(
+	groups model.Groups,
+	devices model.Devices,
+	ipnet6 *net.IPNet,
+) ([]firewall.Group, []firewall.GroupPolicy) {
+	var (
+		fwGroups   []firewall.Group
+		fwPolicies []firewall.GroupPolicy
+	)
+	for i := range groups {
+		fwGroups = append(fwGroups, firewall.Group{
+			Name: groups[i].Name,
+			IPs:  wgDeviceIPs(devices.Group(groups[i].Name), ipnet6),
+		})
+
+		for _, p := range groups[i].Policies {
+			fwPolicies = append(fwPolicies, firewall.GroupPolicy{
+				From:   groups[i].Name,
+				To:     p.To,
+				Proto:  p.IPProto,
+				DPorts: p.DPorts,
+			})
+		}
+	}
+
+	return fwGroups, fwPolicies
+}
+
+// wgPortForwards returns port forwards to the devices of the network.
+func wgPortForwards(
+	forwards model.PortForwards,
+	devices model.Devices,
+	network string,
+) []firewall.PortForward {
+	var fwForwards []firewall.PortForward
+	for i := range devices {
+		for _, f := range forwards.Device(devices[i].IPNetwork.IP) {
+			fwForwards = append(fwForwards, firewall.PortForward{
+				Network:    network,
+				Proto:      f.Proto,
+				Port:       f.Port,
+				IP:         f.IP,
+				DevicePort: f.DevicePort,
+			})
+		}
+	}
+
+	return fwForwards
+}
+
+// firewallTrustIPs returns the trusted ranges, the ranges within another
+// range are skipped as the set doesn't accept overlapping intervals.
+func firewallTrustIPs(ipset model.ManagerSSHTrustIPSet) []firewall.TrustIP {
+	var trustIPs []firewall.TrustIP
+	for i := range ipset {
+		n := len(trustIPs)
+		if n > 0 && trustIPs[n-1].IPNet.Contains(ipset[i].IPNet.IP) {
+			continue
+		}
+		trustIPs = append(trustIPs, firewall.TrustIP{
+			IPNet:     ipset[i].IPNet,
+			ExpiresAt: ipset[i].ExpiresAt,
+		})
+	}
+
+	return trustIPs
+}
+
+func wgRoutes(devices model.Devices) []net.IPNet {
+	var routes []net.IPNet
+	for i := range devices {
+		for j := range devices[i].Routes {
+			routes = append(routes, *devices[i].Routes[j])
+		}
+	}
+
+	return routes
+}
+
+func wgPeers(
+	devices model.Devices,
+	ipnet6 *net.IPNet,
+) (wgmngr.Peers, error) {
 	if devices == nil {
 		return nil, nil
 	}
 
 	peers := make(wgmngr.Peers, len(devices))
 	for i := 0; i < len(devices); i++ {
+		routes := make([]net.IPNet, 0, len(devices[i].Routes)+1)
+		if ipnet6 != nil {
+			routes = append(routes, *devices[i].CIDR6(ipnet6))
+		}
+		for j := range devices[i].Routes {
+			routes = append(routes, *devices[i].Routes[j])
+		}
+
 		peer, err := wgmngr.NewPeer(
 			*devices[i].CIDR(),
 			devices[i].PubKey,
-			nil, // allowed ips
+			devices[i].PresharedKey,
+			routes,
 			nil, // endpoint ip
 			0,   // endpoint port
 			0)   // keep alive interval